@@ -1,24 +1,31 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/ed25519"
 	"encoding/base64"
-	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/ton-blockchain/adnl-tunnel/config"
 	"github.com/ton-blockchain/adnl-tunnel/tunnel"
 	"github.com/tonutils/torrent-client/core/api"
+	"github.com/tonutils/torrent-client/core/bagfiles"
 	"github.com/tonutils/torrent-client/core/client"
 	"github.com/tonutils/torrent-client/core/gostorage"
+	"github.com/tonutils/torrent-client/core/mnemonic"
+	"github.com/tonutils/torrent-client/core/qrcode"
+	"github.com/tonutils/torrent-client/core/tonbag"
 	"github.com/tonutils/torrent-client/core/upnp"
 	"github.com/tonutils/torrent-client/oshook"
 	runtime2 "github.com/wailsapp/wails/v2/pkg/runtime"
+	"github.com/xssnick/ton-payment-network/tonpayments"
+	paymentsConfig "github.com/xssnick/ton-payment-network/tonpayments/config"
 	"github.com/xssnick/ton-payment-network/tonpayments/wallet"
+	"github.com/xssnick/tonutils-go/address"
 	"github.com/xssnick/tonutils-go/liteclient"
-	"github.com/xssnick/tonutils-go/tl"
 	"github.com/xssnick/tonutils-go/tlb"
 	"github.com/xssnick/tonutils-go/ton"
 	"github.com/xssnick/tonutils-storage/storage"
@@ -44,8 +51,10 @@ type App struct {
 
 	openFileData []byte
 	openFileHash string
+	openFileURL  string
 
 	lastCreateProgressReport time.Time
+	createProgress           *api.ProgressMeter
 	creationCtx              context.Context
 	cancelCreation           context.CancelFunc
 
@@ -54,13 +63,17 @@ type App struct {
 
 	stoppedCtx context.Context
 
+	spending *spending
+	health   *tunnelHealth
+	pool     *tunnelPool
+
 	mx sync.RWMutex
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
 	a := &App{}
-	oshook.HookStartup(a.openFile, a.openHash)
+	oshook.HookStartup(a.openFile, a.openHash, a.openMetaURL)
 	storage.Logger = log.Println
 
 	tunnel.ChannelCapacityForNumPayments = 40
@@ -78,6 +91,9 @@ func NewApp() *App {
 	}
 
 	a.config = cfg
+	a.spending = loadSpending(a.rootPath + "/tunnel-spending.json")
+	a.health = loadTunnelHealth(a.rootPath + "/tunnel-events.jsonl")
+	a.pool = loadTunnelPool(a.rootPath+"/tunnel-pool-cache.json", a.rootPath+"/tunnel-pool.json")
 
 	return a
 }
@@ -95,6 +111,8 @@ func (a *App) exit(ctx context.Context) {
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 	a.closerCtx, a.closeCtx = context.WithCancel(a.ctx)
+
+	go a.refreshPool()
 }
 
 func (a *App) Throw(err error) {
@@ -131,7 +149,7 @@ func (a *App) ShowWarnMsg(text string) {
 }
 
 func (a *App) prepare() {
-	oshook.HookStartup(a.openFile, a.openHash)
+	oshook.HookStartup(a.openFile, a.openHash, a.openMetaURL)
 
 	if (!a.config.PortsChecked && !a.config.SeedMode) || a.config.FetchIPOnStartup {
 		log.Println("Trying to forward ports using UPnP")
@@ -191,6 +209,10 @@ func (a *App) initializeStorage() {
 		DownloadsPath:     a.config.DownloadsPath,
 		NetworkConfigPath: a.config.NetworkConfigPath,
 	}
+	nodes := a.config.tunnelNodes()
+	cfg.ExcludedTunnelNodes = nodes.excludedKeys()
+	cfg.PinnedTunnelNodes = nodes.pinnedKeys()
+	cfg.TunnelRouting = a.config.tunnelRouting()
 	if !a.config.SeedMode {
 		cfg.ExternalIP = ""
 	}
@@ -205,9 +227,70 @@ func (a *App) initializeStorage() {
 	a.stoppedCtx, stop = context.WithCancel(context.Background())
 
 	tunCfg := a.config.TunnelConfig
+	sub := a.config.poolSubscription()
+
+	var poolErr error
+	if tunCfg != nil && sub.URL != "" {
+		if !a.pool.cached(sub) {
+			// nothing to start with offline yet
+			if err := a.pool.fetch(a.closerCtx, sub); err != nil {
+				log.Println("failed to fetch tunnel nodes pool:", err.Error())
+			}
+		}
+
+		path, err := a.pool.apply(sub)
+		if err != nil {
+			poolErr = err
+			tunCfg = nil
+		} else {
+			// copy to not save pool of subscription as user's file
+			c := *tunCfg
+			c.NodesPoolConfigPath = path
+			tunCfg = &c
+		}
+	} else {
+		a.pool.unused()
+	}
+
+	// kill switch is for configured tunnel only, by local pool or by subscription,
+	// storage is blocked when tunnel is dropped, also when pool of subscription failed
+	configured := a.config.TunnelConfig != nil && (a.config.TunnelConfig.NodesPoolConfigPath != "" || sub.URL != "")
+	cfg.TunnelRequired = a.config.tunnelRequired() && configured
+	withoutTunnel := "will start without it"
+	if cfg.TunnelRequired {
+		withoutTunnel = "storage data is blocked because tunnel is required"
+	}
+	if poolErr != nil {
+		a.ShowWarnMsg("Failed to get tunnel nodes pool by subscription, " + withoutTunnel + "\n\nError: " + poolErr.Error())
+	}
+
+	if p := a.config.tunnelPolicy(); tunCfg != nil && p.Enabled && p.SectionsNum > tunCfg.TunnelSectionsNum {
+		// library builds routes of configured length only, copy to not save it to user's config
+		c := *tunCfg
+		c.TunnelSectionsNum = p.SectionsNum
+		tunCfg = &c
+	}
+
+	if tunCfg != nil && tunCfg.PaymentsEnabled {
+		reason := a.spending.exceeded(a.config.spendingCap())
+		a.spending.setCapHit(reason)
+		if reason != "" {
+			log.Println("paid tunnel is stopped:", reason)
+			c := *tunCfg
+			c.PaymentsEnabled = false
+			tunCfg = &c
+		}
+	}
 
 retry:
-	tunnelAsked := false
+	policyRejects := 0
+	currencyWarned := false
+	var coins paymentsConfig.CoinTypes
+	if tunCfg != nil {
+		// same currencies as payments service of tunnel gets
+		coins = tunCfg.Payments.ChannelsConfig.SupportedCoins
+	}
+	a.spending.restarted()
 	cl, err := gostorage.NewClient(a.closerCtx, a.rootPath+"/tonutils-storage-db", cfg, tunCfg, func(addr string) {
 		go func() {
 			// wait till frontend init, to display event
@@ -217,57 +300,71 @@ retry:
 			runtime2.EventsEmit(a.ctx, "tunnel_assigned", addr)
 			log.Println("TUNNEL ASSIGNED:", addr)
 		}()
+		if len(cfg.PinnedTunnelNodes) > 0 {
+			// pins are for the route which is built now only
+			a.clearPinnedNodes()
+		}
 	}, func() {
 		stop()
 	}, func(to, from []*tunnel.SectionInfo) int {
-		var priceIn, priceOut = big.NewInt(0), big.NewInt(0)
 		var sect []SectionInfo
 		for i, n := range append(to, from...) {
 			sect = append(sect, SectionInfo{
 				Name:  base64.StdEncoding.EncodeToString(n.Keys.ReceiverPubKey)[:8],
 				Outer: i == len(to)-1,
 			})
+		}
 
-			if n.PaymentInfo != nil {
-				if n.PaymentInfo.ExtraCurrencyID != 0 || n.PaymentInfo.JettonMaster != nil {
-					a.ShowWarnMsg("Route has node with payment in currency other than TON, it is not yet supported in Torrent, rerouting")
-					return tunnel.AcceptorDecisionCancel
-				}
-
-				// consider 1 packet = 512 bytes, actually more, but this is avg payload
-				var packetsPerMB int64 = 2048
-
-				amt := new(big.Int).SetUint64(n.PaymentInfo.PricePerPacket)
-				amt.Mul(amt, big.NewInt(packetsPerMB))
-
-				vcFee := big.NewInt(0)
-				for _, section := range n.PaymentInfo.PaymentTunnel {
-					vcFee.Add(vcFee, section.MinFee)
-				}
-
-				packetsPerChannel := tunnel.ChannelCapacityForNumPayments * tunnel.ChannelPacketsToPrepay
-				// channel fee per 1 mb
-				feeDiv := new(big.Float).Quo(new(big.Float).SetInt64(packetsPerMB), new(big.Float).SetInt64(packetsPerChannel))
-
-				feePer1MB, _ := feeDiv.Mul(new(big.Float).SetInt(vcFee), feeDiv).Int(vcFee)
-				amt.Add(amt, feePer1MB)
+		policy := a.config.tunnelPolicy()
+		prices, err := routePrice(to, from, &coins, a.config.tunnelPacketSize())
+		if err != nil {
+			log.Println("tunnel route rejected:", err.Error())
+			if !policy.Enabled && !currencyWarned {
+				// library tries many routes, so user is told once per start
+				currencyWarned = true
+				go a.ShowWarnMsg("Route has node with payment in currency which is not accepted (" + err.Error() + "), searching for another route")
+			}
+			return tunnel.AcceptorDecisionReject
+		}
 
-				if i < len(to)-1 {
-					priceOut.Add(priceOut, amt)
-				} else if i == len(to)-1 {
-					priceOut.Add(priceOut, amt)
-					priceIn.Add(priceOut, amt)
-				} else {
-					priceIn.Add(priceOut, amt)
+		route := newTunnelRoute(to, from, prices)
+		decision, reason := policy.decide(route)
+		nodes := a.config.tunnelNodes()
+		if missing := nodes.missingPinned(route); missing != "" {
+			// pinned nodes are chosen by user, so route is not shown even when policy asks
+			decision, reason = policyReject, missing
+		}
+		switch decision {
+		case policyAccept:
+			log.Println("tunnel route accepted by policy")
+			policyRejects = 0
+			return tunnel.AcceptorDecisionAccept
+		case policyReject:
+			log.Println("tunnel route rejected by policy:", reason)
+			policyRejects++
+			if policyRejects%50 == 0 {
+				// all known routes are likely tried, library starts again without a pause
+				runtime2.EventsEmit(a.ctx, "report_state", "No tunnel route matches policy, still searching...")
+				select {
+				case <-a.closerCtx.Done():
+					return tunnel.AcceptorDecisionCancel
+				case <-time.After(5 * time.Second):
 				}
 			}
+			return tunnel.AcceptorDecisionReject
+		}
+		if reason != "" {
+			log.Println("tunnel route does not match policy, asking:", reason)
 		}
 
 		for !a.frontMounted {
 			time.Sleep(10 * time.Millisecond)
 		}
-		runtime2.EventsEmit(a.ctx, "tunnel_check", sect, tlb.FromNanoTON(priceIn).String(), tlb.FromNanoTON(priceOut).String())
-		tunnelAsked = true
+		var symbols []string
+		for _, p := range prices {
+			symbols = append(symbols, p.symbol)
+		}
+		runtime2.EventsEmit(a.ctx, "tunnel_check", sect, formatPrices(prices, true), formatPrices(prices, false), symbols)
 
 		ch := make(chan int, 1)
 		runtime2.EventsOn(a.ctx, "tunnel_check_result", func(optionalData ...interface{}) {
@@ -297,6 +394,17 @@ retry:
 			return v
 		}
 	}, func() bool {
+		if reason, ok := a.health.requested(); ok {
+			log.Println("rerouting tunnel:", reason)
+			return !a.reinitForPool()
+		}
+
+		const reason = "route does not answer, found by tunnel library"
+		if a.config.tunnelHealth().AutoReroute {
+			a.health.stalled(true, reason)
+			return !a.reinitForPool()
+		}
+
 		for !a.frontMounted {
 			time.Sleep(10 * time.Millisecond)
 		}
@@ -312,7 +420,8 @@ retry:
 		case <-a.closerCtx.Done():
 			return false
 		case v := <-ch:
-			return v
+			a.health.stalled(v, reason)
+			return v && !a.reinitForPool()
 		}
 	}, func(s string) {
 		for !a.frontMounted {
@@ -320,19 +429,80 @@ retry:
 		}
 
 		runtime2.EventsEmit(a.ctx, "report_state", s)
-	}, func(coins tlb.Coins) {
-		runtime2.EventsEmit(a.ctx, "tunnel_paid_updated", coins.String())
+	}, func(paid map[string]tlb.Coins) {
+		runtime2.EventsEmit(a.ctx, "tunnel_paid_updated", formatPaid(a.spending.add(paid)))
+
+		if reason := a.spending.exceeded(a.config.spendingCap()); reason != "" && a.spending.setCapHit(reason) {
+			log.Println("paid tunnel is stopped:", reason)
+			go a.ReinitApp()
+			go a.ShowWarnMsg("Tunnel " + reason + ", paid tunnel is stopped and only free nodes will be used.\n\n" +
+				"Limits can be changed at Settings -> Tunnel payments")
+		}
+	}, func(route client.TunnelRoute) {
+		if !a.loaded {
+			return
+		}
+
+		reason, outage := a.health.observe(route, a.config.tunnelHealth(), a.downloading())
+		if reason == "" {
+			return
+		}
+		if outage {
+			// library asks about route which does not answer, and reroute is agreed as it is requested
+			log.Println("tunnel route does not answer, rerouting when library asks:", reason)
+			return
+		}
+		if a.reinitForPool() {
+			return
+		}
+		// library switches only routes which do not answer, so tunnel is started again for a new route
+		log.Println("tunnel route is unhealthy, starting tunnel again:", reason)
+		go a.ReinitApp()
+	}, func(err error, wasReady bool) {
+		for !a.loaded {
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		if errors.Is(err, gostorage.ErrPinnedRoute) {
+			// library keeps trying the same nodes, restart builds route from the whole pool
+			a.clearPinnedNodes()
+			log.Println("route with pinned nodes failed, restarting storage:", err.Error())
+			go a.ReinitApp()
+			a.ShowWarnMsg("Failed to build route through pinned nodes, pins are cleared and storage is restarted\n\nError: " + err.Error())
+			return
+		}
+
+		if errors.Is(err, tonpayments.ErrNotWhitelisted) {
+			a.ShowWarnMsg("Tunnel nodes are paid in currency which is not enabled, " +
+				"it can be enabled at Settings -> Payment currencies")
+		}
+
+		switch {
+		case wasReady && !cfg.TunnelRequired:
+			// storage is switched to dead tunnel, start again directly and build new one
+			log.Println("tunnel is stopped, restarting storage:", err.Error())
+			go a.ReinitApp()
+			a.ShowWarnMsg("Tunnel is stopped, storage is restarted to build a new one\n\nError: " + err.Error())
+		case wasReady || cfg.TunnelRequired || cfg.TunnelRouting == gostorage.RoutePrivateOnly:
+			runtime2.EventsEmit(a.ctx, "tunnel_state", "Blocked, tunnel failed")
+			a.ShowWarnMsg("Failed to prepare tunnel, data which goes through it is blocked, " +
+				"save settings with other tunnel options to try again\n\nError: " + err.Error())
+		default:
+			runtime2.EventsEmit(a.ctx, "tunnel_state", "Direct, tunnel failed")
+			a.ShowWarnMsg("Failed to prepare tunnel, storage works directly without it\n\nError: " + err.Error())
+		}
 	})
 	if err != nil {
+		if errors.Is(err, gostorage.ErrPinnedRoute) {
+			a.clearPinnedNodes()
+			a.ShowWarnMsg("Pinned nodes can't be used, they are cleared\n\nError: " + err.Error())
+			cfg.PinnedTunnelNodes = nil
+			goto retry
+		}
 		if strings.HasPrefix(err.Error(), "tunnel preparation failed:") {
 			if tunCfg != nil {
-				if !tunnelAsked {
-					// error before tunnel popup, drop tunnel
-					a.ShowWarnMsg("Failed to prepare tunnel, will start without it\n\nError: " + err.Error())
-					tunCfg = nil
-				} else {
-					a.ShowWarnMsg("Failed to prepare tunnel\n\nError: " + err.Error())
-				}
+				a.ShowWarnMsg("Failed to prepare tunnel, " + withoutTunnel + "\n\nError: " + err.Error())
+				tunCfg = nil
 			}
 			goto retry
 		}
@@ -351,6 +521,25 @@ retry:
 	a.api.SetSpeedRefresh(func(speed api.Speed) {
 		runtime2.EventsEmit(a.ctx, "speed", speed)
 	})
+	// storage is started before tunnel is built, its address is shown instead when it is ready
+	state := ""
+	switch {
+	case tunCfg != nil && tunCfg.NodesPoolConfigPath != "":
+		state = "Direct, building tunnel..."
+		if cfg.TunnelRequired {
+			state = "Blocked, building tunnel..."
+		} else if cfg.TunnelRouting == gostorage.RoutePrivateOnly {
+			state = "Private bags wait, building tunnel..."
+		}
+	case cfg.TunnelRequired:
+		state = "Blocked, no tunnel"
+	}
+	if state != "" {
+		for !a.frontMounted {
+			time.Sleep(10 * time.Millisecond)
+		}
+		runtime2.EventsEmit(a.ctx, "tunnel_state", state)
+	}
 	a.loaded = true
 
 	runtime2.EventsOn(a.ctx, "refresh", func(optionalData ...interface{}) {
@@ -404,7 +593,7 @@ func (a *App) ReinitApp() {
 }
 
 func (a *App) GetMaxTunnelNodes() int {
-	res := a.parseTunnelConfig(a.config.TunnelConfig.NodesPoolConfigPath)
+	res := a.parseTunnelConfig(a.nodesPoolPath())
 	if res == nil {
 		return 0
 	}
@@ -440,6 +629,9 @@ func (a *App) WaitReady() {
 			} else if a.openFileHash != "" {
 				a.openHash(a.openFileHash)
 				a.openFileHash = ""
+			} else if a.openFileURL != "" {
+				a.openMetaURL(a.openFileURL)
+				a.openFileURL = ""
 			}
 		}()
 	})
@@ -505,14 +697,289 @@ func (a *App) GetPaymentNetworkWalletAddr() string {
 	return w.WalletAddress().String()
 }
 
-func (a *App) openFile(data []byte) {
+type PaymentWalletResult struct {
+	Address string
+	// Balance is in TON, empty when it was not fetched
+	Balance string
+	Err     string
+}
+
+// GetPaymentWallet returns address of payments wallet with its balance, fetched by liteclient of storage
+func (a *App) GetPaymentWallet() PaymentWalletResult {
+	res := PaymentWalletResult{Address: a.GetPaymentNetworkWalletAddr()}
+	if !a.loaded {
+		res.Err = "Balance will be available when storage is loaded"
+		return res
+	}
+
+	bal, err := a.api.GetWalletBalances(res.Address, nil)
+	if err != nil {
+		log.Println("failed to get payments wallet balance:", err.Error())
+		res.Err = "Failed to get balance: " + err.Error()
+		return res
+	}
+	res.Balance = tlb.FromNanoTON(bal.Ton).String()
+	return res
+}
+
+type TopUpResult struct {
+	Link string
+	// QR is png image of link as data url
+	QR  string
+	Err string
+}
+
+// GetTopUpLink makes ton:// transfer link to payments wallet with qr code of it, amount in TON is optional
+func (a *App) GetTopUpLink(amount string) TopUpResult {
+	link := "ton://transfer/" + a.GetPaymentNetworkWalletAddr()
+	if amount = strings.TrimSpace(amount); amount != "" {
+		amt, err := tlb.FromTON(amount)
+		if err != nil {
+			return TopUpResult{Err: "Invalid amount: " + err.Error()}
+		}
+		link += "?amount=" + amt.Nano().String()
+	}
+
+	code, err := qrcode.Encode([]byte(link))
+	if err != nil {
+		log.Println(err.Error())
+		return TopUpResult{Link: link, Err: err.Error()}
+	}
+
+	data, err := code.PNG(6)
+	if err != nil {
+		log.Println(err.Error())
+		return TopUpResult{Link: link, Err: err.Error()}
+	}
+	return TopUpResult{Link: link, QR: "data:image/png;base64," + base64.StdEncoding.EncodeToString(data)}
+}
+
+type WithdrawResult struct {
+	// Hash of transaction, in hex
+	Hash string
+	Err  string
+}
+
+// WithdrawFromPaymentWallet sends TON from payments wallet, funds deposited to payment channels are not touched
+func (a *App) WithdrawFromPaymentWallet(to, amount, comment string) WithdrawResult {
+	if !a.loaded {
+		return WithdrawResult{Err: "Storage is not loaded yet"}
+	}
+
+	a.config.mx.Lock()
+	key := ed25519.NewKeyFromSeed(a.config.TunnelConfig.Payments.WalletPrivateKey)
+	a.config.mx.Unlock()
+
+	hash, err := a.api.Withdraw(key, to, amount, comment)
+	if err != nil {
+		log.Println("failed to withdraw from payments wallet:", err.Error())
+		return WithdrawResult{Err: err.Error()}
+	}
+	log.Println("withdrawn", amount, "TON from payments wallet to", to, "tx", hash)
+	return WithdrawResult{Hash: hash}
+}
+
+// GetPaymentWalletMnemonic returns seed of payments wallet key as words, to back it up
+func (a *App) GetPaymentWalletMnemonic() string {
+	a.config.mx.Lock()
+	seed := a.config.TunnelConfig.Payments.WalletPrivateKey
+	a.config.mx.Unlock()
+
+	list, err := mnemonic.FromSeed(seed)
+	if err != nil {
+		log.Println("failed to encode payments wallet seed:", err.Error())
+		return ""
+	}
+	return strings.Join(list, " ")
+}
+
+// RestorePaymentWallet replaces key of payments wallet by backed up words, storage is restarted to use it
+func (a *App) RestorePaymentWallet(words string) string {
+	seed, err := mnemonic.ToSeed(words)
+	if err != nil {
+		return "Invalid words: " + err.Error()
+	}
+	if len(seed) != ed25519.SeedSize {
+		return "Invalid words: payments wallet key should be 24 words"
+	}
+
+	a.config.mx.Lock()
+	same := bytes.Equal(a.config.TunnelConfig.Payments.WalletPrivateKey, seed)
+	a.config.mx.Unlock()
+
+	if !same {
+		if n, err := a.openPaymentChannels(); err != nil {
+			log.Println("payment channels of current wallet are not checked before restore:", err.Error())
+		} else if n > 0 {
+			// channels are signed by current key, funds in them would be lost with it
+			return fmt.Sprintf("%d payment channels are still open with current wallet key, close them before restoring another wallet", n)
+		}
+	}
+
+	a.config.mx.Lock()
+	a.config.TunnelConfig.Payments.WalletPrivateKey = seed
+	a.config.mx.Unlock()
+
+	if err = a.config.SaveConfig(a.rootPath); err != nil {
+		log.Println(err.Error())
+		return err.Error()
+	}
+
+	log.Println("payments wallet is restored:", a.GetPaymentNetworkWalletAddr())
 	if a.loaded {
-		res := a.addByMeta(data)
-		if res.Err == "" {
-			runtime2.EventsEmit(a.ctx, "open_torrent", res.Hash)
+		go a.ReinitApp()
+	}
+	return ""
+}
+
+// GetCurrencies lists accepted payment currencies with balances of payments wallet
+func (a *App) GetCurrencies() CurrenciesResult {
+	a.config.mx.Lock()
+	list := currencies(&a.config.TunnelConfig.Payments.ChannelsConfig.SupportedCoins)
+	a.config.mx.Unlock()
+
+	if !a.loaded {
+		return CurrenciesResult{Currencies: list, Err: "Balances will be available when storage is loaded"}
+	}
+
+	var jettons []string
+	for _, c := range list {
+		if c.Jetton != "" {
+			jettons = append(jettons, c.Jetton)
+		}
+	}
+
+	bal, err := a.api.GetWalletBalances(a.GetPaymentNetworkWalletAddr(), jettons)
+	if err != nil {
+		log.Println("failed to get wallet balances:", err.Error())
+		return CurrenciesResult{Currencies: list, Err: "Failed to get balances: " + err.Error()}
+	}
+
+	for i, c := range list {
+		amt := bal.Ton
+		switch {
+		case c.Jetton != "":
+			amt = big.NewInt(0)
+			if addr, err := address.ParseAddr(c.Jetton); err == nil && bal.Jettons[addr.Bounce(true).String()] != nil {
+				amt = bal.Jettons[addr.Bounce(true).String()]
+			}
+		case c.ExtraCurrencyID != 0:
+			amt = big.NewInt(0)
+			if v := bal.ExtraCurrencies[c.ExtraCurrencyID]; v != nil {
+				amt = v
+			}
+		}
+		list[i].Balance = tlb.MustFromNano(amt, int(c.Decimals)).String()
+	}
+	return CurrenciesResult{Currencies: list}
+}
+
+// SaveCurrencies sets accepted payment currencies, they are applied when tunnel is started again
+func (a *App) SaveCurrencies(list []Currency) string {
+	a.config.mx.Lock()
+	coins := a.config.TunnelConfig.Payments.ChannelsConfig.SupportedCoins
+	err := applyCurrencies(&coins, list)
+	if err == nil {
+		a.config.TunnelConfig.Payments.ChannelsConfig.SupportedCoins = coins
+	}
+	a.config.mx.Unlock()
+	if err != nil {
+		return err.Error()
+	}
+
+	if err = a.config.SaveConfig(a.rootPath); err != nil {
+		log.Println(err.Error())
+		return err.Error()
+	}
+	return ""
+}
+
+// GetPaymentsDashboard lists payment channels with their history and amounts paid for tunnels
+func (a *App) GetPaymentsDashboard() PaymentsDashboard {
+	a.config.mx.Lock()
+	coins := a.config.TunnelConfig.Payments.ChannelsConfig.SupportedCoins
+	a.config.mx.Unlock()
+
+	res := PaymentsDashboard{
+		Channels: []PaymentChannel{},
+		History:  []PaymentEvent{},
+		Days:     a.spending.days(),
+		Cap:      a.config.spendingCap(),
+		CapHit:   a.spending.getCapHit(),
+	}
+	for _, c := range currencies(&coins) {
+		res.Symbols = append(res.Symbols, c.Symbol)
+	}
+	session, _ := a.spending.totals()
+	res.Session = formatAmounts(session)
+
+	if !a.loaded {
+		res.ChannelsErr = "Channels will be available when storage is loaded"
+		return res
+	}
+
+	list, err := a.api.GetPaymentChannels()
+	if err != nil {
+		if errors.Is(err, gostorage.ErrNoPayments) {
+			res.ChannelsErr = "Channels are available when tunnel payments were used"
 		} else {
-			a.ShowMsg("Error while parsing meta file: " + res.Err + "")
+			log.Println("failed to get payment channels:", err.Error())
+			res.ChannelsErr = "Failed to get channels: " + err.Error()
+		}
+		return res
+	}
+
+	var events []paymentEvent
+	after := time.Now().Add(-paymentHistoryPeriod)
+	for _, ch := range list {
+		pc := paymentChannel(ch, &coins)
+		res.Channels = append(res.Channels, pc)
+
+		hist, err := a.api.GetPaymentHistory(ch.Address, 0, after)
+		if err != nil {
+			log.Println("failed to get payment channel history:", err.Error())
+			continue
+		}
+		_, decimals := coinOf(&coins, ch.JettonAddress, ch.ExtraCurrencyID)
+		events = append(events, paymentEvents(pc, decimals, hist)...)
+	}
+	res.History = sortPaymentEvents(events)
+	return res
+}
+
+// SaveSpendingCap sets limits of amounts paid for tunnels, paid tunnel stopped by limit is started again when new limits allow
+func (a *App) SaveSpendingCap(c SpendingCap) string {
+	a.config.mx.Lock()
+	err := c.Validate(&a.config.TunnelConfig.Payments.ChannelsConfig.SupportedCoins)
+	if err == nil {
+		a.config.TunnelSpendingCap = c
+	}
+	a.config.mx.Unlock()
+	if err != nil {
+		return err.Error()
+	}
+
+	if err = a.config.SaveConfig(a.rootPath); err != nil {
+		log.Println(err.Error())
+		return err.Error()
+	}
+
+	if a.loaded && a.spending.getCapHit() != "" && a.spending.exceeded(c) == "" {
+		log.Println("spending cap is changed, starting paid tunnel again")
+		go a.ReinitApp()
+	}
+	return ""
+}
+
+func (a *App) openFile(data []byte) {
+	if a.loaded {
+		data, err := tonbag.Decode(data)
+		if err != nil {
+			a.ShowMsg("Error while parsing meta file: " + err.Error())
+			return
 		}
+		// it is added only after user sees preview and confirms
+		runtime2.EventsEmit(a.ctx, "open_torrent_meta", base64.StdEncoding.EncodeToString(data))
 	} else {
 		// wait for loading
 		a.openFileData = data
@@ -542,6 +1009,22 @@ func (a *App) openHash(hash string) {
 	}
 }
 
+// openMetaURL opens meta file published on website, from tonstorage://meta?url= link
+func (a *App) openMetaURL(link string) {
+	if !a.loaded {
+		// wait for loading
+		a.openFileURL = link
+		return
+	}
+
+	data, err := tonbag.Fetch(a.ctx, link)
+	if err != nil {
+		a.ShowMsg("Error while downloading meta file from " + link + ": " + err.Error())
+		return
+	}
+	a.openFile(data)
+}
+
 func (a *App) OpenDir() string {
 	str, err := runtime2.OpenDirectoryDialog(a.ctx, runtime2.OpenDialogOptions{})
 	if err != nil {
@@ -641,6 +1124,7 @@ type TorrentCreateResult struct {
 
 func (a *App) CreateTorrent(dir, description string) TorrentCreateResult {
 	a.creationCtx, a.cancelCreation = context.WithCancel(a.ctx)
+	a.createProgress = &api.ProgressMeter{}
 	hash, err := a.api.CreateTorrent(a.creationCtx, dir, description, a.reportCreationProgress)
 	if err != nil {
 		log.Println(err.Error())
@@ -649,6 +1133,58 @@ func (a *App) CreateTorrent(dir, description string) TorrentCreateResult {
 	return TorrentCreateResult{Hash: hash}
 }
 
+// CreateTorrentWithOptions creates bag from list of files and dirs, with exclusions and ordering,
+// when files are copied inside, they are placed to downloads dir
+func (a *App) CreateTorrentWithOptions(opts bagfiles.Options, create client.CreateOptions, description string) TorrentCreateResult {
+	create.CopyDir = a.config.DownloadsPath
+
+	a.creationCtx, a.cancelCreation = context.WithCancel(a.ctx)
+	a.createProgress = &api.ProgressMeter{}
+	hash, err := a.api.CreateTorrentWithOptions(a.creationCtx, opts, create, description, a.reportCreationProgress)
+	if err != nil {
+		log.Println(err.Error())
+		return TorrentCreateResult{Err: err.Error()}
+	}
+	return TorrentCreateResult{Hash: hash}
+}
+
+// CreateTorrentVersion creates new version of bag from current state of its files,
+// old version is stopped when new one has retireAfterPeers peers, 0 keeps it seeding
+func (a *App) CreateTorrentVersion(hash string, retireAfterPeers int) TorrentCreateResult {
+	a.creationCtx, a.cancelCreation = context.WithCancel(a.ctx)
+	a.createProgress = &api.ProgressMeter{}
+	newHash, err := a.api.CreateTorrentVersion(a.creationCtx, hash, client.CreateOptions{
+		RetireParentAfterPeers: retireAfterPeers,
+	}, a.reportCreationProgress)
+	if err != nil {
+		log.Println(err.Error())
+		return TorrentCreateResult{Err: err.Error()}
+	}
+	return TorrentCreateResult{Hash: newHash}
+}
+
+type TorrentPlanResult struct {
+	FilesCount  int
+	Size        string
+	PieceSize   string
+	PiecesCount uint64
+	Err         string
+}
+
+// PlanTorrent is a dry run of creation, it reports files count, size and pieces without hashing
+func (a *App) PlanTorrent(opts bagfiles.Options, pieceSize uint32) TorrentPlanResult {
+	p, err := a.api.PlanTorrent(opts, pieceSize)
+	if err != nil {
+		return TorrentPlanResult{Err: err.Error()}
+	}
+	return TorrentPlanResult{
+		FilesCount:  p.FilesCount,
+		Size:        p.Size,
+		PieceSize:   p.PieceSize,
+		PiecesCount: p.PiecesCount,
+	}
+}
+
 func (a *App) CancelCreateTorrent() {
 	log.Println("CANCEL CREATION")
 	if a.cancelCreation != nil {
@@ -664,57 +1200,312 @@ func (a *App) reportCreationProgress(done, max uint64) {
 	}
 	a.lastCreateProgressReport = now
 
-	runtime2.EventsEmit(a.ctx, "update-create-progress", fmt.Sprintf("%.2f", (float64(done)/float64(max))*100))
+	speed, eta := a.createProgress.Update(done, max)
+	runtime2.EventsEmit(a.ctx, "update-create-progress", fmt.Sprintf("%.2f", (float64(done)/float64(max))*100), speed, eta)
 }
 
-func (a *App) ExportMeta(hash string) string {
-	m, err := a.api.GetTorrentMeta(hash)
+type TorrentRecheckResult struct {
+	BadPieces uint32
+	Err       string
+}
+
+func (a *App) RecheckTorrent(hash string) TorrentRecheckResult {
+	bad, err := a.api.RecheckTorrent(a.ctx, hash, a.recheckProgress(hash))
 	if err != nil {
 		log.Println(err.Error())
-		return ""
+		return TorrentRecheckResult{Err: err.Error()}
 	}
+	return TorrentRecheckResult{BadPieces: bad}
+}
 
-	info, err := a.api.GetInfo(hash)
+func (a *App) RelocateTorrent(hash, dir string) string {
+	err := a.api.RelocateTorrent(a.ctx, hash, dir, a.recheckProgress(hash))
 	if err != nil {
 		log.Println(err.Error())
-		return ""
-	}
-	name := info.Description
-	if name == "" {
-		name = hash
+		return err.Error()
 	}
+	return ""
+}
 
-	path, err := runtime2.SaveFileDialog(a.ctx, runtime2.SaveDialogOptions{
-		DefaultFilename: name + ".tonbag",
-		Title:           "Save .tonbag",
-		Filters: []runtime2.FileFilter{{
-			DisplayName: "TON Torrent (*.tonbag)",
-			Pattern:     "*.tonbag",
-		}},
-	})
+// VerifyDirMatchesBag compares dir with bag and shows which files are different
+func (a *App) VerifyDirMatchesBag(hash, dir string) string {
+	diff, err := a.api.VerifyDirMatchesBag(a.ctx, hash, dir, a.recheckProgress(hash))
 	if err != nil {
 		log.Println(err.Error())
-		return ""
+		a.ShowWarnMsg("Failed to compare folder with bag\n\nError: " + err.Error())
+		return err.Error()
 	}
 
-	err = os.WriteFile(path, m, 0666)
-	if err != nil {
-		log.Println(err.Error())
+	if diff.Matches {
+		msg := "Folder matches the bag"
+		if len(diff.Files) > 0 {
+			msg += fmt.Sprintf(", %d extra files are not part of it", len(diff.Files))
+		}
+		a.ShowMsg(msg)
 		return ""
 	}
 
-	return path
-}
-
-func (a *App) GetTorrents() []*api.Torrent {
-	list := a.api.GetTorrents()
-	if list == nil {
-		list = []*api.Torrent{}
+	var sb strings.Builder
+	sb.WriteString("Folder does not match the bag")
+	if diff.Details != "" {
+		sb.WriteString(", " + diff.Details)
 	}
-	return list
+	sb.WriteString("\n")
+	for i, f := range diff.Files {
+		if i == 20 {
+			sb.WriteString(fmt.Sprintf("\n...and %d more", len(diff.Files)-i))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("\n%s: %s (%s)", f.Kind, f.Name, f.Details))
+	}
+	a.ShowWarnMsg(sb.String())
+	return ""
 }
 
-func (a *App) GetFiles(hash string) []*api.File {
+func (a *App) recheckProgress(hash string) func(done, max uint64) {
+	hash = strings.ToUpper(hash)
+
+	var mx sync.Mutex
+	var lastReport time.Time
+	return func(done, max uint64) {
+		// hashing can report from multiple goroutines
+		mx.Lock()
+		defer mx.Unlock()
+
+		now := time.Now()
+		if done < max && lastReport.Add(100*time.Millisecond).After(now) {
+			// not refresh too often
+			return
+		}
+		lastReport = now
+
+		runtime2.EventsEmit(a.ctx, "update-recheck-progress", hash, fmt.Sprintf("%.2f", (float64(done)/float64(max))*100))
+	}
+}
+
+func (a *App) ExportMeta(hash string) string {
+	m, err := a.api.GetTorrentMeta(hash)
+	if err != nil {
+		log.Println(err.Error())
+		return ""
+	}
+	return a.saveExport(hash, m, "Save .tonbag", "TON Torrent (*.tonbag)", ".tonbag")
+}
+
+// ExportMetaText returns meta as base64, to be pasted into chats
+func (a *App) ExportMetaText(hash string) string {
+	m, err := a.api.GetTorrentMeta(hash)
+	if err != nil {
+		log.Println(err.Error())
+		return ""
+	}
+	return tonbag.Text(m)
+}
+
+// ExportMetaManifest saves json description of bag, with meta inside, so it can be imported too
+func (a *App) ExportMetaManifest(hash string) string {
+	m, err := a.api.GetTorrentMeta(hash)
+	if err != nil {
+		log.Println(err.Error())
+		return ""
+	}
+
+	manifest, err := tonbag.NewManifest(m)
+	if err != nil {
+		log.Println(err.Error())
+		return ""
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		log.Println(err.Error())
+		return ""
+	}
+	return a.saveExport(hash, data, "Save manifest", "JSON manifest (*.json)", ".json")
+}
+
+// ExportMetaQR saves qr code of bag link
+func (a *App) ExportMetaQR(hash string) string {
+	hashBytes, err := hex.DecodeString(hash)
+	if err != nil {
+		log.Println(err.Error())
+		return ""
+	}
+
+	code, err := qrcode.Encode([]byte(tonbag.Link(hashBytes)))
+	if err != nil {
+		log.Println(err.Error())
+		return ""
+	}
+
+	data, err := code.PNG(8)
+	if err != nil {
+		log.Println(err.Error())
+		return ""
+	}
+	return a.saveExport(hash, data, "Save QR code", "PNG image (*.png)", ".png")
+}
+
+func (a *App) saveExport(hash string, data []byte, title, filter, ext string) string {
+	info, err := a.api.GetInfo(hash)
+	if err != nil {
+		log.Println(err.Error())
+		return ""
+	}
+	name := info.Description
+	if name == "" {
+		name = hash
+	}
+
+	path, err := runtime2.SaveFileDialog(a.ctx, runtime2.SaveDialogOptions{
+		DefaultFilename: name + ext,
+		Title:           title,
+		Filters: []runtime2.FileFilter{{
+			DisplayName: filter,
+			Pattern:     "*" + ext,
+		}},
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return ""
+	}
+	if path == "" {
+		return ""
+	}
+
+	err = os.WriteFile(path, data, 0666)
+	if err != nil {
+		log.Println(err.Error())
+		return ""
+	}
+
+	return path
+}
+
+// ExportAllMeta saves .tonbag files of all bags to one zip archive, bags without info yet are skipped
+func (a *App) ExportAllMeta() string {
+	var metas [][]byte
+	skipped := 0
+	for _, t := range a.api.GetTorrents() {
+		m, err := a.api.GetTorrentMeta(t.ID)
+		if err != nil {
+			log.Println(t.ID, err.Error())
+			skipped++
+			continue
+		}
+		metas = append(metas, m)
+	}
+	if len(metas) == 0 {
+		a.ShowWarnMsg("There are no bags to export")
+		return ""
+	}
+
+	path, err := runtime2.SaveFileDialog(a.ctx, runtime2.SaveDialogOptions{
+		DefaultFilename: "bags-" + time.Now().Format("2006-01-02") + ".zip",
+		Title:           "Export all bags",
+		Filters: []runtime2.FileFilter{{
+			DisplayName: "Zip archive (*.zip)",
+			Pattern:     "*.zip",
+		}},
+	})
+	if err != nil || path == "" {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	if err = tonbag.WriteArchive(&buf, metas); err != nil {
+		a.ShowWarnMsg("Failed to export bags: " + err.Error())
+		return ""
+	}
+	if err = os.WriteFile(path, buf.Bytes(), 0666); err != nil {
+		a.ShowWarnMsg("Failed to save archive: " + err.Error())
+		return ""
+	}
+
+	msg := fmt.Sprintf("%d bags are exported", len(metas))
+	if skipped > 0 {
+		msg += fmt.Sprintf(", %d are skipped because their info is not downloaded yet", skipped)
+	}
+	a.ShowMsg(msg)
+	return path
+}
+
+// ImportMetaArchive adds all bags from archive made by ExportAllMeta, they are downloaded to default dir
+func (a *App) ImportMetaArchive() {
+	path, err := runtime2.OpenFileDialog(a.ctx, runtime2.OpenDialogOptions{
+		Title: "Import bags",
+		Filters: []runtime2.FileFilter{{
+			DisplayName: "Zip archive (*.zip)",
+			Pattern:     "*.zip",
+		}},
+	})
+	if err != nil || path == "" {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		a.ShowWarnMsg("Failed to read archive: " + err.Error())
+		return
+	}
+
+	metas, err := tonbag.ReadArchive(data)
+	if err != nil {
+		a.ShowWarnMsg(err.Error())
+		return
+	}
+
+	have := map[string]bool{}
+	for _, t := range a.api.GetTorrents() {
+		have[strings.ToUpper(t.ID)] = true
+	}
+
+	var failed []string
+	for i, m := range metas {
+		res := a.addByMeta(m, "")
+		if res.Err != "" {
+			failed = append(failed, fmt.Sprintf("bag %d: %s", i+1, res.Err))
+			continue
+		}
+		if have[strings.ToUpper(res.Hash)] {
+			// files of existing bag are already selected by user
+			continue
+		}
+
+		// all files are downloaded, when header is in meta it is known right away
+		files, err := a.api.GetPlainFiles(res.Hash)
+		if err != nil {
+			log.Println(res.Hash, err.Error())
+			continue
+		}
+		names := make([]string, 0, len(files))
+		for _, f := range files {
+			names = append(names, f.Name)
+		}
+		if err = a.api.SetPriorities(res.Hash, names, 1); err != nil {
+			log.Println(res.Hash, err.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		if len(failed) > 20 {
+			failed = append(failed[:20], "...")
+		}
+		a.ShowWarnMsg(fmt.Sprintf("%d of %d bags are added, failed:\n%s", len(metas)-len(failed), len(metas), strings.Join(failed, "\n")))
+		return
+	}
+	a.ShowMsg(fmt.Sprintf("%d bags are added", len(metas)))
+}
+
+func (a *App) GetTorrents() []*api.Torrent {
+	list := a.api.GetTorrents()
+	if list == nil {
+		list = []*api.Torrent{}
+	}
+	return list
+}
+
+func (a *App) GetFiles(hash string) []*api.File {
 	list, err := a.api.GetTorrentFiles(hash)
 	if err != nil {
 		log.Println(err.Error())
@@ -786,31 +1577,83 @@ type TorrentAddResult struct {
 	Err  string
 }
 
-func (a *App) AddTorrentByMeta(meta string) TorrentAddResult {
+// AddTorrentByMeta adds bag from meta, when existingDir is set, data is expected to be already there,
+// it is verified instead of downloaded. Meta is base64 of file or http url to download it.
+func (a *App) AddTorrentByMeta(meta, existingDir string) TorrentAddResult {
+	if tonbag.IsURL(meta) {
+		metaBytes, err := tonbag.Fetch(a.ctx, meta)
+		if err != nil {
+			return TorrentAddResult{Err: err.Error()}
+		}
+		return a.addByMeta(metaBytes, existingDir)
+	}
+
 	metaBytes, err := base64.StdEncoding.DecodeString(meta)
 	if err != nil {
 		return TorrentAddResult{Err: err.Error()}
 	}
-	return a.addByMeta(metaBytes)
+	return a.addByMeta(metaBytes, existingDir)
 }
 
-func (a *App) addByMeta(meta []byte) TorrentAddResult {
-	if len(meta) < 8 {
-		return TorrentAddResult{Err: "too short meta"}
+type MetaFetchResult struct {
+	// Meta is base64 of downloaded file
+	Meta string
+	Err  string
+}
+
+// FetchMeta downloads meta file by url, to show it before adding
+func (a *App) FetchMeta(link string) MetaFetchResult {
+	data, err := tonbag.Fetch(a.ctx, link)
+	if err != nil {
+		return MetaFetchResult{Err: err.Error()}
 	}
-	if binary.LittleEndian.Uint32(meta) == 0x6a7181e0 {
-		// skip id, for compatibility with boxed and not boxed
-		meta = meta[4:]
+	return MetaFetchResult{Meta: base64.StdEncoding.EncodeToString(data)}
+}
+
+type MetaPreviewResult struct {
+	Preview *api.MetaPreview
+	Err     string
+}
+
+// InspectMeta shows content of meta file before it is added, list of files is limited
+func (a *App) InspectMeta(meta string) MetaPreviewResult {
+	metaBytes, err := base64.StdEncoding.DecodeString(meta)
+	if err != nil {
+		return MetaPreviewResult{Err: err.Error()}
+	}
+	// meta can be pasted as text or manifest
+	if metaBytes, err = tonbag.Decode(metaBytes); err != nil {
+		return MetaPreviewResult{Err: err.Error()}
+	}
+
+	p, err := a.api.InspectMeta(metaBytes)
+	if err != nil {
+		return MetaPreviewResult{Err: err.Error()}
 	}
 
-	var ti client.MetaFile
-	_, err := tl.Parse(&ti, meta, false)
+	if len(p.Files) > 1000 {
+		p.Files = p.Files[:1000]
+	}
+	return MetaPreviewResult{Preview: p}
+}
+
+func (a *App) addByMeta(meta []byte, existingDir string) TorrentAddResult {
+	meta, err := tonbag.Decode(meta)
+	if err != nil {
+		return TorrentAddResult{Err: err.Error()}
+	}
+	meta = tonbag.Unbox(meta)
+	ti, err := tonbag.Parse(meta)
 	if err != nil {
 		return TorrentAddResult{Err: err.Error()}
 	}
 	hash := hex.EncodeToString(ti.Hash)
 
-	err = a.api.AddTorrentByMeta(meta, a.config.DownloadsPath+"/"+strings.ToUpper(hash))
+	if existingDir != "" {
+		err = a.api.AddTorrentByMeta(meta, existingDir, true, a.recheckProgress(hash))
+	} else {
+		err = a.api.AddTorrentByMeta(meta, a.config.DownloadsPath+"/"+strings.ToUpper(hash), false, nil)
+	}
 	if err != nil {
 		return TorrentAddResult{Err: err.Error()}
 	}
@@ -864,10 +1707,265 @@ func (a *App) SaveTunnelConfig(num uint, payments bool) string {
 	return ""
 }
 
-func (a *App) SaveConfig(downloads string, seedMode bool, storageExtIP, tunnelConfigPath string, selectedNewTun bool) string {
+func (a *App) GetTunnelPolicy() TunnelPolicy {
+	return a.config.tunnelPolicy()
+}
+
+// SaveTunnelPolicy applies policy to next routes, current tunnel is kept
+func (a *App) SaveTunnelPolicy(policy TunnelPolicy) string {
+	if err := policy.Validate(); err != nil {
+		return err.Error()
+	}
+
+	a.config.mx.Lock()
+	a.config.TunnelPolicy = policy
+	a.config.mx.Unlock()
+
+	if err := a.config.SaveConfig(a.rootPath); err != nil {
+		log.Println(err.Error())
+		return err.Error()
+	}
+	return ""
+}
+
+// GetTunnelInspector returns routes of tunnel since start and nodes of pool, to pin or exclude them
+func (a *App) GetTunnelInspector() TunnelInspector {
+	a.config.mx.Lock()
+	coins := a.config.TunnelConfig.Payments.ChannelsConfig.SupportedCoins
+	a.config.mx.Unlock()
+	poolPath := a.nodesPoolPath()
+
+	model := packetModel(a.config.tunnelPacketSize())
+	nodes := a.config.tunnelNodes()
+	res := TunnelInspector{
+		Routes: []InspectorRoute{},
+		Pool:   []PoolNode{},
+		Nodes:  TunnelNodes{Pinned: []string{}, Excluded: []string{}},
+	}
+	res.Nodes.Pinned = append(res.Nodes.Pinned, nodes.Pinned...)
+	res.Nodes.Excluded = append(res.Nodes.Excluded, nodes.Excluded...)
+
+	if poolPath != "" {
+		var sharedCfg config.SharedConfig
+		data, err := os.ReadFile(poolPath)
+		if err == nil {
+			err = json.Unmarshal(data, &sharedCfg)
+		}
+		if err != nil {
+			res.Err = "Failed to read nodes pool: " + err.Error()
+		}
+		for _, n := range sharedCfg.NodesPool {
+			res.Pool = append(res.Pool, poolNode(n, &coins, model, nodes))
+		}
+	}
+
+	if !a.loaded {
+		return res
+	}
+
+	routes, err := a.api.GetTunnelRoutes()
+	if err != nil {
+		log.Println("failed to get tunnel routes:", err.Error())
+		res.Err = "Failed to get routes: " + err.Error()
+		return res
+	}
+	for i, r := range routes {
+		res.Routes = append(res.Routes, inspectorRoute(r, i == 0 && r.Until.IsZero(), &coins, model, nodes))
+	}
+	return res
+}
+
+// SaveTunnelNodes saves pinned and excluded nodes, they are used for the next route,
+// when reroute is set, storage is restarted to build it now
+func (a *App) SaveTunnelNodes(nodes TunnelNodes, reroute bool) string {
+	a.config.mx.Lock()
+	var sections uint
+	if a.config.TunnelConfig != nil {
+		sections = a.config.TunnelConfig.TunnelSectionsNum
+	}
+	err := nodes.Validate(sections)
+	if err == nil {
+		a.config.TunnelNodes = nodes
+	}
+	a.config.mx.Unlock()
+	if err != nil {
+		return err.Error()
+	}
+
+	if err := a.config.SaveConfig(a.rootPath); err != nil {
+		log.Println(err.Error())
+		return err.Error()
+	}
+
+	if reroute && a.loaded {
+		log.Println("tunnel nodes are changed, building new route")
+		go a.ReinitApp()
+	}
+	return ""
+}
+
+// clearPinnedNodes removes pins when route with them is built or failed, so next routes are chosen freely
+func (a *App) clearPinnedNodes() {
+	a.config.mx.Lock()
+	cleared := len(a.config.TunnelNodes.Pinned) > 0
+	a.config.TunnelNodes.Pinned = nil
+	a.config.mx.Unlock()
+	if !cleared {
+		return
+	}
+
+	if err := a.config.SaveConfig(a.rootPath); err != nil {
+		log.Println("failed to save config after pins are cleared:", err.Error())
+	}
+}
+
+// downloading is whether some bag is downloaded from peers now, tunnel throughput is expected only then
+func (a *App) downloading() bool {
+	for _, t := range a.api.GetTorrents() {
+		if (t.State == "downloading" || t.State == "download-only") && t.PeersNum > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTunnelHealth returns health of current tunnel route, reroute policy and tunnel event log
+func (a *App) GetTunnelHealth() TunnelHealthResult {
+	h, breach := a.health.current()
+	res := TunnelHealthResult{
+		Policy: a.config.tunnelHealth(),
+		Health: healthInfo(h),
+		Breach: breach,
+		Events: []TunnelEventInfo{},
+	}
+	for _, e := range a.health.getEvents() {
+		res.Events = append(res.Events, tunnelEventInfo(e))
+	}
+	return res
+}
+
+// SaveTunnelHealthPolicy applies policy to the next health measurement of route
+func (a *App) SaveTunnelHealthPolicy(policy TunnelHealthPolicy) string {
+	if err := policy.Validate(); err != nil {
+		return err.Error()
+	}
+
+	a.config.mx.Lock()
+	a.config.TunnelHealth = policy
+	a.config.mx.Unlock()
+
+	if err := a.config.SaveConfig(a.rootPath); err != nil {
+		log.Println(err.Error())
+		return err.Error()
+	}
+	return ""
+}
+
+// nodesPoolPath is pool file used by tunnel, subscribed pool is preferred over user's file
+func (a *App) nodesPoolPath() string {
+	if path := a.pool.path(); path != "" {
+		return path
+	}
+	return a.config.TunnelConfig.NodesPoolConfigPath
+}
+
+// refreshPool fetches subscribed nodes pool periodically, changes are applied on the next reroute
+func (a *App) refreshPool() {
+	for {
+		sub := a.config.poolSubscription()
+		if sub.URL != "" {
+			if err := a.pool.fetch(a.ctx, sub); err != nil {
+				log.Println("failed to refresh tunnel nodes pool:", err.Error())
+			} else if diff := a.pool.pending(sub); diff != "" {
+				log.Println("tunnel nodes pool is updated, it will be applied on the next reroute:", diff)
+			}
+		}
+
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-a.pool.refresh:
+		case <-time.After(sub.refreshEvery()):
+		}
+	}
+}
+
+// reinitForPool restarts storage instead of reroute when subscribed pool is changed,
+// tunnel library takes pool only at start
+func (a *App) reinitForPool() bool {
+	diff := a.pool.pending(a.config.poolSubscription())
+	if diff == "" {
+		return false
+	}
+
+	log.Println("applying updated tunnel nodes pool:", diff)
+	go a.ReinitApp()
+	return true
+}
+
+// GetPoolSubscription returns subscription of nodes pool and state of its last fetch
+func (a *App) GetPoolSubscription() PoolSubscriptionResult {
+	return a.pool.status(a.config.poolSubscription())
+}
+
+// SavePoolSubscription fetches pool right away to check subscription,
+// pool is applied on the next reroute, or now when apply is set
+func (a *App) SavePoolSubscription(sub TunnelPoolSubscription, apply bool) string {
+	if err := sub.Validate(); err != nil {
+		return err.Error()
+	}
+
+	if sub.URL != "" {
+		if err := a.pool.fetch(a.ctx, sub); err != nil {
+			return "Failed to fetch pool: " + err.Error()
+		}
+	}
+
+	a.config.mx.Lock()
+	a.config.TunnelPool = sub
+	a.config.mx.Unlock()
+
+	if err := a.config.SaveConfig(a.rootPath); err != nil {
+		log.Println(err.Error())
+		return err.Error()
+	}
+	a.pool.refreshNow()
+
+	if apply && a.loaded && a.pool.pending(sub) != "" {
+		log.Println("tunnel nodes pool subscription is changed, restarting storage")
+		go a.ReinitApp()
+	}
+	return ""
+}
+
+func (a *App) GetTunnelRouting() string {
+	return a.config.tunnelRouting()
+}
+
+func (a *App) SaveConfig(downloads string, seedMode bool, storageExtIP, tunnelConfigPath string, selectedNewTun bool, tunnelRouting string, tunnelRequired bool) string {
+	switch tunnelRouting {
+	case gostorage.RouteAll, gostorage.RouteBagsOnly, gostorage.RoutePrivateOnly:
+	default:
+		return "unknown tunnel routing: " + tunnelRouting
+	}
+
 	reload := false
 	a.config.DownloadsPath = downloads
 
+	if tunnelRouting != a.config.tunnelRouting() {
+		a.config.mx.Lock()
+		a.config.TunnelRouting = tunnelRouting
+		a.config.mx.Unlock()
+		reload = true
+	}
+
+	if tunnelRequired != a.config.tunnelRequired() {
+		a.config.mx.Lock()
+		a.config.TunnelRequired = tunnelRequired
+		a.config.mx.Unlock()
+		reload = true
+	}
+
 	if tunnelConfigPath != a.config.TunnelConfig.NodesPoolConfigPath {
 		a.config.TunnelConfig.NodesPoolConfigPath = tunnelConfigPath
 		reload = true
@@ -932,3 +2030,40 @@ func (a *App) SetActive(hash string, active bool) string {
 	}
 	return ""
 }
+
+func (a *App) SetActiveDownload(hash string, active bool) string {
+	err := a.api.SetActiveDownload(hash, active)
+	if err != nil {
+		log.Println(err.Error())
+		return err.Error()
+	}
+	return ""
+}
+
+func (a *App) SetActiveUpload(hash string, active bool) string {
+	err := a.api.SetActiveUpload(hash, active)
+	if err != nil {
+		log.Println(err.Error())
+		return err.Error()
+	}
+	return ""
+}
+
+// SetPrivate marks bag to go through tunnel when only private bags use it
+func (a *App) SetPrivate(hash string, private bool) string {
+	err := a.api.SetPrivate(hash, private)
+	if err != nil {
+		log.Println(err.Error())
+		return err.Error()
+	}
+	return ""
+}
+
+func (a *App) RetryTorrent(hash string) string {
+	err := a.api.RetryTorrent(hash)
+	if err != nil {
+		log.Println(err.Error())
+		return err.Error()
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+// Command tonbag works with .tonbag meta files without running the app.
+//
+//	tonbag inspect [-json] file.tonbag
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tonutils/torrent-client/core/tonbag"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "inspect":
+		err = inspect(os.Args[2:])
+	default:
+		usage()
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err.Error())
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: tonbag inspect [-json] file.tonbag")
+	os.Exit(2)
+}
+
+func inspect(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print as json")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	// base64 text and manifests are accepted too
+	if data, err = tonbag.Decode(data); err != nil {
+		return fmt.Errorf("invalid meta: %w", err)
+	}
+
+	p, err := tonbag.Inspect(data)
+	if err != nil {
+		return fmt.Errorf("invalid meta: %w", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			BagID string
+			*tonbag.Preview
+		}{strings.ToUpper(hex.EncodeToString(p.BagID)), p})
+	}
+
+	fmt.Printf("Bag ID:      %s\n", strings.ToUpper(hex.EncodeToString(p.BagID)))
+	fmt.Printf("Description: %s\n", p.Description)
+	fmt.Printf("Size:        %d bytes\n", p.Size-p.HeaderSize)
+	fmt.Printf("Piece size:  %d bytes\n", p.PieceSize)
+	fmt.Printf("Pieces:      %d\n", p.PiecesCount)
+	fmt.Printf("Header:      %s\n", yesNo(p.HasHeader))
+	fmt.Printf("Root proof:  %s\n", yesNo(p.HasRootProof))
+	if !p.HasHeader {
+		return nil
+	}
+
+	fmt.Printf("Dir name:    %s\n", strings.TrimSuffix(p.DirName, "/"))
+	fmt.Printf("Files:       %d\n", len(p.Files))
+	for _, f := range p.Files {
+		fmt.Printf("  %14d  %s\n", f.Size, f.Name)
+	}
+	return nil
+}
+
+func yesNo(v bool) string {
+	if v {
+		return "yes"
+	}
+	return "no"
+}
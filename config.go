@@ -4,7 +4,9 @@ import (
 	"context"
 	"crypto/ed25519"
 	"encoding/json"
+	"fmt"
 	tunnelConfig "github.com/ton-blockchain/adnl-tunnel/config"
+	"github.com/tonutils/torrent-client/core/routeprice"
 	"net"
 	"os"
 	"path/filepath"
@@ -13,6 +15,12 @@ import (
 	"time"
 )
 
+// default top up of TON payment channel, can be changed at payment currencies
+const (
+	defaultDepositUpTo         = "3"
+	defaultDepositWhenLessThan = "1"
+)
+
 type Config struct {
 	Version       uint
 	DownloadsPath string
@@ -27,6 +35,19 @@ type Config struct {
 	FetchIPOnStartup  bool
 
 	TunnelConfig *tunnelConfig.ClientConfig
+	TunnelPolicy TunnelPolicy
+	TunnelNodes  TunnelNodes
+	TunnelHealth TunnelHealthPolicy
+	// TunnelPool replaces local nodes pool file when its URL is set
+	TunnelPool TunnelPoolSubscription
+	// TunnelRouting selects traffic which goes through tunnel, all of it when empty
+	TunnelRouting string
+	// TunnelRequired blocks storage data until tunnel is built, otherwise bags go directly meanwhile with one-time key
+	TunnelRequired bool
+
+	TunnelSpendingCap SpendingCap
+	// TunnelPacketSize is average payload of tunnel packet in bytes to estimate route price, default is used when 0
+	TunnelPacketSize uint64
 
 	mx sync.Mutex
 }
@@ -42,10 +63,11 @@ func LoadConfig(dir string) (*Config, error) {
 		}
 
 		cfg = &Config{
-			Version:       1,
+			Version:       3,
 			DownloadsPath: downloadsPath(),
 			ListenAddr:    ":13333",
 			Key:           priv.Seed(),
+			TunnelHealth:  defaultTunnelHealth(),
 		}
 
 		cfg.TunnelConfig, err = tunnelConfig.GenerateClientConfig()
@@ -54,8 +76,8 @@ func LoadConfig(dir string) (*Config, error) {
 		}
 		cfg.TunnelConfig.PaymentsEnabled = true
 		cfg.TunnelConfig.Payments.DBPath = dir + "/payments-db"
-		cfg.TunnelConfig.Payments.ChannelsConfig.SupportedCoins.Ton.BalanceControl.DepositUpToAmount = "3"
-		cfg.TunnelConfig.Payments.ChannelsConfig.SupportedCoins.Ton.BalanceControl.DepositWhenAmountLessThan = "1"
+		cfg.TunnelConfig.Payments.ChannelsConfig.SupportedCoins.Ton.BalanceControl.DepositUpToAmount = defaultDepositUpTo
+		cfg.TunnelConfig.Payments.ChannelsConfig.SupportedCoins.Ton.BalanceControl.DepositWhenAmountLessThan = defaultDepositWhenLessThan
 
 		err = cfg.SaveConfig(dir)
 		if err != nil {
@@ -74,6 +96,10 @@ func LoadConfig(dir string) (*Config, error) {
 			return nil, err
 		}
 
+		if err = cfg.validate(); err != nil {
+			return nil, fmt.Errorf("invalid config %s: %w", path, err)
+		}
+
 		if cfg.Key == nil {
 			_, priv, err := ed25519.GenerateKey(nil)
 			if err != nil {
@@ -92,8 +118,8 @@ func LoadConfig(dir string) (*Config, error) {
 			return nil, err
 		}
 		cfg.TunnelConfig.Payments.DBPath = dir + "/payments-db"
-		cfg.TunnelConfig.Payments.ChannelsConfig.SupportedCoins.Ton.BalanceControl.DepositUpToAmount = "3"
-		cfg.TunnelConfig.Payments.ChannelsConfig.SupportedCoins.Ton.BalanceControl.DepositWhenAmountLessThan = "1"
+		cfg.TunnelConfig.Payments.ChannelsConfig.SupportedCoins.Ton.BalanceControl.DepositUpToAmount = defaultDepositUpTo
+		cfg.TunnelConfig.Payments.ChannelsConfig.SupportedCoins.Ton.BalanceControl.DepositWhenAmountLessThan = defaultDepositWhenLessThan
 		updated = true
 	}
 
@@ -103,6 +129,12 @@ func LoadConfig(dir string) (*Config, error) {
 		updated = true
 	}
 
+	if cfg.Version < 3 {
+		cfg.Version = 3
+		cfg.TunnelHealth = defaultTunnelHealth()
+		updated = true
+	}
+
 	if updated {
 		err = cfg.SaveConfig(dir)
 		if err != nil {
@@ -117,6 +149,10 @@ func (cfg *Config) SaveConfig(dir string) error {
 	cfg.mx.Lock()
 	defer cfg.mx.Unlock()
 
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
 	path := dir + "/config.json"
 
 	data, err := json.MarshalIndent(cfg, "", "\t")
@@ -131,6 +167,66 @@ func (cfg *Config) SaveConfig(dir string) error {
 	return nil
 }
 
+// validate rejects values which are set only by editing config file and can not be used
+func (cfg *Config) validate() error {
+	if cfg.TunnelPacketSize > routeprice.MaxPacketSize {
+		return fmt.Errorf("tunnel packet size %d is bigger than %d bytes", cfg.TunnelPacketSize, routeprice.MaxPacketSize)
+	}
+	return nil
+}
+
+func (cfg *Config) tunnelPolicy() TunnelPolicy {
+	cfg.mx.Lock()
+	defer cfg.mx.Unlock()
+	return cfg.TunnelPolicy
+}
+
+func (cfg *Config) tunnelHealth() TunnelHealthPolicy {
+	cfg.mx.Lock()
+	defer cfg.mx.Unlock()
+	return cfg.TunnelHealth
+}
+
+func (cfg *Config) poolSubscription() TunnelPoolSubscription {
+	cfg.mx.Lock()
+	defer cfg.mx.Unlock()
+	return cfg.TunnelPool
+}
+
+func (cfg *Config) tunnelRouting() string {
+	cfg.mx.Lock()
+	defer cfg.mx.Unlock()
+	return cfg.TunnelRouting
+}
+
+func (cfg *Config) tunnelRequired() bool {
+	cfg.mx.Lock()
+	defer cfg.mx.Unlock()
+	return cfg.TunnelRequired
+}
+
+func (cfg *Config) tunnelNodes() TunnelNodes {
+	cfg.mx.Lock()
+	defer cfg.mx.Unlock()
+	return cfg.TunnelNodes
+}
+
+func (cfg *Config) spendingCap() SpendingCap {
+	cfg.mx.Lock()
+	defer cfg.mx.Unlock()
+	return cfg.TunnelSpendingCap
+}
+
+func (cfg *Config) tunnelPacketSize() uint64 {
+	cfg.mx.Lock()
+	defer cfg.mx.Unlock()
+
+	if cfg.TunnelPacketSize == 0 {
+		return routeprice.DefaultPacketSize
+	}
+	return cfg.TunnelPacketSize
+}
+
 func downloadsPath() string {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
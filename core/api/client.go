@@ -2,11 +2,15 @@ package api
 
 import (
 	"context"
+	"crypto/ed25519"
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"github.com/tonutils/torrent-client/core/bagfiles"
 	"github.com/tonutils/torrent-client/core/client"
+	"github.com/tonutils/torrent-client/core/tonbag"
+	"github.com/xssnick/ton-payment-network/tonpayments/db"
 	"github.com/xssnick/tonutils-go/address"
 	"github.com/xssnick/tonutils-go/tlb"
 	"github.com/xssnick/tonutils-storage-provider/pkg/contract"
@@ -56,8 +60,14 @@ type Torrent struct {
 	PeersNum       int
 	Uploaded       string
 	Ratio          string
+	ActiveDownload bool
+	ActiveUpload   bool
+	Error          string
+	// Private bags go through tunnel when only private bags use it
+	Private bool
 
 	rawDowSpeed    int64
+	rawErrorAt     uint32
 	rawDownloaded  int64
 	rawSize        int64
 	rawDescription string
@@ -77,6 +87,35 @@ type TorrentInfo struct {
 	AddedAt     string
 	Uploaded    string
 	Ratio       string
+
+	ActiveDownload bool
+	ActiveUpload   bool
+
+	Error   string
+	ErrorAt string
+
+	// PrevVersion and NextVersion are bag ids of linked versions, next is the latest one
+	PrevVersion string
+	NextVersion string
+}
+
+// MetaPreview is content of meta file shown before adding bag
+type MetaPreview struct {
+	BagID        string
+	Description  string
+	Size         string
+	PieceSize    string
+	PiecesCount  uint32
+	DirName      string
+	HasHeader    bool
+	HasRootProof bool
+	FilesCount   int
+	Files        []MetaPreviewFile
+}
+
+type MetaPreviewFile struct {
+	Name string
+	Size string
 }
 
 type SpeedLimits struct {
@@ -140,12 +179,23 @@ type StorageClient interface {
 	GetTorrents(ctx context.Context) (*client.TorrentsList, error)
 	AddByHash(ctx context.Context, hash []byte, dir string) (*client.TorrentFull, error)
 	AddByMeta(ctx context.Context, meta []byte, dir string) (*client.TorrentFull, error)
-	CreateTorrent(ctx context.Context, dir, description string, progressCallback func(done uint64, max uint64)) (*client.TorrentFull, error)
+	AddByMetaStopped(ctx context.Context, meta []byte, dir string) (*client.TorrentFull, error)
+	CreateTorrent(ctx context.Context, dir, description string, opts client.CreateOptions, progressCallback func(done uint64, max uint64)) (*client.TorrentFull, error)
+	CreateTorrentFromFiles(ctx context.Context, set *bagfiles.Set, description string, opts client.CreateOptions, progressCallback func(done uint64, max uint64)) (*client.TorrentFull, error)
+	CreateTorrentVersion(ctx context.Context, hash []byte, opts client.CreateOptions, progressCallback func(done uint64, max uint64)) (*client.TorrentFull, error)
+	GetVersions(ctx context.Context, hash []byte) (*client.BagVersions, error)
 	GetTorrentFull(ctx context.Context, hash []byte) (*client.TorrentFull, error)
 	GetTorrentMeta(ctx context.Context, hash []byte) ([]byte, error)
 	GetPeers(ctx context.Context, hash []byte) (*client.PeersList, error)
 	RemoveTorrent(ctx context.Context, hash []byte, withFiles bool) error
 	SetActive(ctx context.Context, hash []byte, active bool) error
+	SetActiveDownload(ctx context.Context, hash []byte, active bool) error
+	SetActiveUpload(ctx context.Context, hash []byte, active bool) error
+	RetryTorrent(ctx context.Context, hash []byte) error
+	SetPrivate(ctx context.Context, hash []byte, private bool) error
+	RecheckTorrent(ctx context.Context, hash []byte, progressCallback func(done uint64, max uint64)) (uint32, error)
+	RelocateTorrent(ctx context.Context, hash []byte, dir string, progressCallback func(done uint64, max uint64)) error
+	VerifyDirMatchesBag(ctx context.Context, meta []byte, dir string, progressCallback func(done uint64, max uint64)) (*bagfiles.Diff, error)
 	SetFilesPriority(ctx context.Context, hash []byte, names []string, priority int32) error
 	GetSpeedLimits(ctx context.Context) (*client.SpeedLimits, error)
 	SetSpeedLimits(ctx context.Context, download, upload int64) error
@@ -155,6 +205,11 @@ type StorageClient interface {
 	RequestProviderStorageInfo(ctx context.Context, torrentHash, providerKey []byte, owner *address.Address) (*provider.ProviderStorageInfo, error)
 	BuildAddProviderTransaction(ctx context.Context, torrentHash []byte, owner *address.Address, providers []provider.NewProviderData) (addr *address.Address, bodyData, stateInit []byte, err error)
 	BuildWithdrawalTransaction(torrentHash []byte, owner *address.Address) (addr *address.Address, bodyData []byte, err error)
+	GetWalletBalances(ctx context.Context, owner *address.Address, jettons []*address.Address) (*client.WalletBalances, error)
+	GetPaymentChannels(ctx context.Context) ([]*db.Channel, error)
+	Withdraw(ctx context.Context, key ed25519.PrivateKey, to *address.Address, amount tlb.Coins, comment string) ([]byte, error)
+	GetPaymentHistory(ctx context.Context, channel string, limit int, after time.Time) ([]db.ChannelHistoryItem, error)
+	GetTunnelRoutes(ctx context.Context) ([]client.TunnelRoute, error)
 	GetNotifier() <-chan bool
 }
 
@@ -253,6 +308,11 @@ iter:
 	return nil
 }
 
+// FormatSize formats size in bytes as text for user, like "1.50 MB"
+func FormatSize(sz int64) string {
+	return toSz(sz)
+}
+
 func toSz(sz int64) string {
 	switch {
 	case sz < 1024:
@@ -266,6 +326,29 @@ func toSz(sz int64) string {
 	}
 }
 
+// ProgressMeter calculates speed and time left of long operations with progress in bytes,
+// speed is counted from the first update, so resumed work is not included
+type ProgressMeter struct {
+	startedAt time.Time
+	startDone uint64
+}
+
+func (m *ProgressMeter) Update(done, max uint64) (speed, eta string) {
+	now := time.Now()
+	if m.startedAt.IsZero() || done < m.startDone {
+		m.startedAt, m.startDone = now, done
+	}
+
+	elapsed := now.Sub(m.startedAt)
+	if elapsed < time.Second || done == m.startDone {
+		return "", ""
+	}
+
+	rate := float64(done-m.startDone) / elapsed.Seconds()
+	left := time.Duration(float64(max-done)/rate) * time.Second
+	return toSpeed(int64(rate), false), left.Round(time.Second).String()
+}
+
 func toRatio(uploaded, size uint64) string {
 	if size == 0 || uploaded == 0 {
 		return "0"
@@ -334,12 +417,22 @@ func formatTorrent(full *client.TorrentFull, peersNum int, hide0Speed bool, uplo
 		uplSpeed = toSpeed(int64(torrent.UploadSpeed), hide0Speed)
 	}
 
-	state := "fail"
-	if torrent.ActiveDownload && !torrent.Completed {
+	var state string
+	switch {
+	case torrent.ActiveDownload && !torrent.Completed && torrent.ActiveUpload:
 		state = "downloading"
-	} else if torrent.ActiveUpload && torrent.Completed {
+	case torrent.ActiveDownload && !torrent.Completed:
+		// leech without seeding
+		state = "download-only"
+	case torrent.ActiveUpload && torrent.Completed:
 		state = "seeding"
-	} else {
+	case torrent.ActiveUpload:
+		// seeds what is already downloaded, not fetching more
+		state = "seed-only"
+	case torrent.ActiveDownload:
+		// download is done, but seeding is disabled
+		state = "completed"
+	default:
 		state = "inactive"
 	}
 
@@ -347,6 +440,12 @@ func formatTorrent(full *client.TorrentFull, peersNum int, hide0Speed bool, uplo
 		state = "verifying"
 	}
 
+	var fatalErr string
+	if torrent.FatalError != nil {
+		fatalErr = *torrent.FatalError
+		state = "fail"
+	}
+
 	path := torrent.RootDir
 	if torrent.DirName != nil {
 		path += "/" + *torrent.DirName
@@ -365,7 +464,12 @@ func formatTorrent(full *client.TorrentFull, peersNum int, hide0Speed bool, uplo
 		PeersNum:       peersNum,
 		Uploaded:       toSz(int64(uploaded)),
 		Ratio:          toRatio(uploaded, uint64(dataSz)),
+		ActiveDownload: torrent.ActiveDownload,
+		ActiveUpload:   torrent.ActiveUpload,
+		Error:          fatalErr,
+		Private:        torrent.Private,
 		rawDowSpeed:    int64(torrent.DownloadSpeed),
+		rawErrorAt:     torrent.FatalErrorAt,
 		rawDownloaded:  downloadedSz,
 		rawSize:        dataSz,
 		rawDescription: rawDesc,
@@ -413,10 +517,16 @@ func (a *API) AddTorrentByHash(hash, rootDir string) error {
 	return nil
 }
 
-func (a *API) AddTorrentByMeta(meta []byte, rootDir string) error {
+// AddTorrentByMeta adds bag, when verifyExisting is set, data is expected to be already in rootDir,
+// so it is verified instead of downloaded
+func (a *API) AddTorrentByMeta(meta []byte, rootDir string, verifyExisting bool, progressCallback func(done uint64, max uint64)) error {
+	if verifyExisting {
+		return a.addExistingByMeta(meta, rootDir, progressCallback)
+	}
+
 	_, err := a.client.AddByMeta(a.globalCtx, meta, rootDir)
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate hash") {
+		if strings.Contains(err.Error(), "duplicate hash") && !verifyExisting {
 			// if we already have it - still ok, maybe use wants to load more files,
 			// or something went wrong on kill switch
 			return nil
@@ -426,14 +536,126 @@ func (a *API) AddTorrentByMeta(meta []byte, rootDir string) error {
 	return nil
 }
 
+// addExistingByMeta adds bag stopped and verifies data in rootDir first, so nothing is downloaded
+// into it before, bag is started only when data is matching and removed otherwise
+func (a *API) addExistingByMeta(meta []byte, rootDir string, progressCallback func(done uint64, max uint64)) error {
+	ti, err := tonbag.Parse(meta)
+	if err != nil {
+		return err
+	}
+	if ti.Header == nil {
+		return fmt.Errorf("meta has no bag header, existing data cannot be verified")
+	}
+
+	if _, err = a.client.GetTorrentFull(a.globalCtx, ti.Hash); err == nil {
+		// already added bag is only pointed to data, it is not removed when data is different
+		return a.client.RelocateTorrent(a.globalCtx, ti.Hash, rootDir, progressCallback)
+	}
+
+	if _, err = a.client.AddByMetaStopped(a.globalCtx, meta, rootDir); err != nil {
+		return err
+	}
+
+	if err = a.client.RelocateTorrent(a.globalCtx, ti.Hash, rootDir, progressCallback); err != nil {
+		if rmErr := a.client.RemoveTorrent(a.globalCtx, ti.Hash, false); rmErr != nil {
+			log.Println("failed to remove not verified bag:", rmErr.Error())
+		}
+		return err
+	}
+
+	if err = a.client.SetActive(a.globalCtx, ti.Hash, true); err != nil {
+		return fmt.Errorf("failed to start verified bag: %w", err)
+	}
+	return nil
+}
+
 func (a *API) CreateTorrent(ctx context.Context, dir, description string, progressCallback func(done uint64, max uint64)) (string, error) {
-	t, err := a.client.CreateTorrent(ctx, dir, description, progressCallback)
+	t, err := a.client.CreateTorrent(ctx, dir, description, client.CreateOptions{}, progressCallback)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hex.EncodeToString(t.Torrent.Hash)), nil
+}
+
+type TorrentPlan struct {
+	FilesCount  int
+	Size        string
+	PieceSize   string
+	PiecesCount uint64
+}
+
+// PlanTorrent scans files like CreateTorrentWithOptions does, but only reports what bag will be created
+func (a *API) PlanTorrent(opts bagfiles.Options, pieceSize uint32) (*TorrentPlan, error) {
+	set, err := bagfiles.Scan(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := set.Plan(pieceSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TorrentPlan{
+		FilesCount:  p.FilesCount,
+		Size:        toSz(int64(p.DataSize)),
+		PieceSize:   toSz(int64(p.PieceSize)),
+		PiecesCount: p.PiecesCount,
+	}, nil
+}
+
+func (a *API) CreateTorrentWithOptions(ctx context.Context, opts bagfiles.Options, create client.CreateOptions, description string, progressCallback func(done uint64, max uint64)) (string, error) {
+	set, err := bagfiles.Scan(opts)
+	if err != nil {
+		return "", err
+	}
+
+	t, err := a.client.CreateTorrentFromFiles(ctx, set, description, create, progressCallback)
 	if err != nil {
 		return "", err
 	}
 	return strings.ToUpper(hex.EncodeToString(t.Torrent.Hash)), nil
 }
 
+// CreateTorrentVersion creates new bag from current data of bag and links them as versions
+func (a *API) CreateTorrentVersion(ctx context.Context, hash string, create client.CreateOptions, progressCallback func(done uint64, max uint64)) (string, error) {
+	hashBytes, err := toHashBytes(hash)
+	if err != nil {
+		return "", err
+	}
+
+	t, err := a.client.CreateTorrentVersion(ctx, hashBytes, create, progressCallback)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hex.EncodeToString(t.Torrent.Hash)), nil
+}
+
+// InspectMeta shows what is inside of meta file, without adding it
+func (a *API) InspectMeta(meta []byte) (*MetaPreview, error) {
+	p, err := tonbag.Inspect(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &MetaPreview{
+		BagID:        strings.ToUpper(hex.EncodeToString(p.BagID)),
+		Description:  p.Description,
+		Size:         toSz(int64(p.Size - p.HeaderSize)),
+		PieceSize:    toSz(int64(p.PieceSize)),
+		PiecesCount:  p.PiecesCount,
+		DirName:      strings.TrimSuffix(p.DirName, "/"),
+		HasHeader:    p.HasHeader,
+		HasRootProof: p.HasRootProof,
+		FilesCount:   len(p.Files),
+		Files:        make([]MetaPreviewFile, 0, len(p.Files)),
+	}
+	for _, f := range p.Files {
+		res.Files = append(res.Files, MetaPreviewFile{Name: f.Name, Size: toSz(int64(f.Size))})
+	}
+	return res, nil
+}
+
 func (a *API) GetTorrentMeta(hash string) ([]byte, error) {
 	hashBytes, err := toHashBytes(hash)
 	if err != nil {
@@ -633,6 +855,21 @@ func (a *API) GetInfo(hash string) (*TorrentInfo, error) {
 		left = "∞"
 	}
 
+	var errorAt string
+	if tr.rawErrorAt > 0 {
+		errorAt = time.Unix(int64(tr.rawErrorAt), 0).Format("02 Jan 2006 15:04:05")
+	}
+
+	var prev, next string
+	if v, err := a.client.GetVersions(a.globalCtx, hashBytes); err == nil {
+		if v.Parent != nil {
+			prev = strings.ToUpper(hex.EncodeToString(v.Parent))
+		}
+		if len(v.Children) > 0 {
+			next = strings.ToUpper(hex.EncodeToString(v.Children[len(v.Children)-1]))
+		}
+	}
+
 	return &TorrentInfo{
 		Description: tr.Name,
 		Size:        tr.Size,
@@ -647,6 +884,15 @@ func (a *API) GetInfo(hash string) (*TorrentInfo, error) {
 		AddedAt:     time.Unix(int64(t.Torrent.AddedAt), 0).Format("02 Jan 2006 15:04:05"),
 		Uploaded:    tr.Uploaded,
 		Ratio:       tr.Ratio,
+
+		ActiveDownload: tr.ActiveDownload,
+		ActiveUpload:   tr.ActiveUpload,
+
+		Error:   tr.Error,
+		ErrorAt: errorAt,
+
+		PrevVersion: prev,
+		NextVersion: next,
 	}, nil
 }
 
@@ -694,6 +940,67 @@ func (a *API) SetActive(hash string, active bool) error {
 	return nil
 }
 
+func (a *API) SetActiveDownload(hash string, active bool) error {
+	hashBytes, err := toHashBytes(hash)
+	if err != nil {
+		return err
+	}
+	return a.client.SetActiveDownload(a.globalCtx, hashBytes, active)
+}
+
+func (a *API) SetActiveUpload(hash string, active bool) error {
+	hashBytes, err := toHashBytes(hash)
+	if err != nil {
+		return err
+	}
+	return a.client.SetActiveUpload(a.globalCtx, hashBytes, active)
+}
+
+// SetPrivate marks bag to go through tunnel when only private bags use it
+func (a *API) SetPrivate(hash string, private bool) error {
+	hashBytes, err := toHashBytes(hash)
+	if err != nil {
+		return err
+	}
+	return a.client.SetPrivate(a.globalCtx, hashBytes, private)
+}
+
+// RetryTorrent clears bag error and restarts it, stored data is verified again on start
+func (a *API) RetryTorrent(hash string) error {
+	hashBytes, err := toHashBytes(hash)
+	if err != nil {
+		return err
+	}
+	return a.client.RetryTorrent(a.globalCtx, hashBytes)
+}
+
+// RecheckTorrent verifies all stored pieces and returns number of corrupted ones, they will be downloaded again
+func (a *API) RecheckTorrent(ctx context.Context, hash string, progressCallback func(done uint64, max uint64)) (uint32, error) {
+	hashBytes, err := toHashBytes(hash)
+	if err != nil {
+		return 0, err
+	}
+	return a.client.RecheckTorrent(ctx, hashBytes, progressCallback)
+}
+
+// RelocateTorrent changes root dir of bag to one with already existing data and verifies it
+func (a *API) RelocateTorrent(ctx context.Context, hash, dir string, progressCallback func(done uint64, max uint64)) error {
+	hashBytes, err := toHashBytes(hash)
+	if err != nil {
+		return err
+	}
+	return a.client.RelocateTorrent(ctx, hashBytes, dir, progressCallback)
+}
+
+// VerifyDirMatchesBag hashes dir and reports which files are different from bag
+func (a *API) VerifyDirMatchesBag(ctx context.Context, hash, dir string, progressCallback func(done uint64, max uint64)) (*bagfiles.Diff, error) {
+	meta, err := a.GetTorrentMeta(hash)
+	if err != nil {
+		return nil, err
+	}
+	return a.client.VerifyDirMatchesBag(ctx, meta, dir, progressCallback)
+}
+
 func (a *API) SetPriorities(hash string, list []string, priority int) error {
 	hashBytes, err := toHashBytes(hash)
 	if err != nil {
@@ -973,6 +1280,78 @@ func (a *API) BuildWithdrawalContractData(hash, ownerAddr string) (*Transaction,
 	}, nil
 }
 
+// GetWalletBalances returns balances of wallet, jettons are master addresses
+func (a *API) GetWalletBalances(ownerAddr string, jettons []string) (*client.WalletBalances, error) {
+	owner, err := address.ParseAddr(ownerAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wallet address: %w", err)
+	}
+
+	var masters []*address.Address
+	for _, j := range jettons {
+		m, err := address.ParseAddr(j)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jetton master address %s: %w", j, err)
+		}
+		masters = append(masters, m)
+	}
+
+	ctx, cancel := context.WithTimeout(a.globalCtx, 15*time.Second)
+	defer cancel()
+
+	return a.client.GetWalletBalances(ctx, owner, masters)
+}
+
+// Withdraw sends TON from payments wallet to address, amount is in TON, hash of transaction is returned
+func (a *API) Withdraw(key ed25519.PrivateKey, toAddr, amount, comment string) (string, error) {
+	to, err := address.ParseAddr(strings.TrimSpace(toAddr))
+	if err != nil {
+		return "", fmt.Errorf("invalid address: %w", err)
+	}
+
+	amt, err := tlb.FromTON(strings.TrimSpace(amount))
+	if err != nil {
+		return "", fmt.Errorf("invalid amount: %w", err)
+	}
+	if amt.Nano().Sign() <= 0 {
+		return "", fmt.Errorf("amount should be more than zero")
+	}
+
+	// transaction is waited, it takes few blocks
+	ctx, cancel := context.WithTimeout(a.globalCtx, 90*time.Second)
+	defer cancel()
+
+	hash, err := a.client.Withdraw(ctx, key, to, amt, comment)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash), nil
+}
+
+// GetPaymentChannels lists channels of tunnel payments node, they are available when tunnel payments are enabled
+func (a *API) GetPaymentChannels() ([]*db.Channel, error) {
+	ctx, cancel := context.WithTimeout(a.globalCtx, 10*time.Second)
+	defer cancel()
+
+	return a.client.GetPaymentChannels(ctx)
+}
+
+// GetPaymentHistory returns events of payment channel after given time, up to limit
+func (a *API) GetPaymentHistory(channel string, limit int, after time.Time) ([]db.ChannelHistoryItem, error) {
+	ctx, cancel := context.WithTimeout(a.globalCtx, 10*time.Second)
+	defer cancel()
+
+	return a.client.GetPaymentHistory(ctx, channel, limit, after)
+}
+
+// GetTunnelRoutes returns routes of tunnel since start, newest first
+func (a *API) GetTunnelRoutes() ([]client.TunnelRoute, error) {
+	ctx, cancel := context.WithTimeout(a.globalCtx, 10*time.Second)
+	defer cancel()
+
+	return a.client.GetTunnelRoutes(ctx)
+}
+
 func toHashBytes(hash string) ([]byte, error) {
 	hashBytes, err := hex.DecodeString(hash)
 	if err != nil {
@@ -0,0 +1,473 @@
+// Package bagfiles selects files for a new bag.
+//
+// With Options.Deterministic the same content gives the same bag id on any machine:
+// names are NFC normalized and use / separators, files are ordered by bytes of name,
+// names which can't be represented the same on every OS are rejected.
+// Bag info has no timestamps, and default piece size depends only on data size,
+// so description, piece size when it is chosen manually and dir name are the only other inputs of bag id.
+package bagfiles
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xssnick/tonutils-go/tl"
+	"github.com/xssnick/tonutils-storage/storage"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	OrderName  = "name"
+	OrderSize  = "size"
+	OrderInput = "input"
+)
+
+// NoDirName as Options.DirName puts files to the root of bag
+const NoDirName = "/"
+
+const (
+	MinPieceSize = 16 << 10
+	// MaxPieceSize is limited by max size of TL bytes, which is 16MB - 1
+	MaxPieceSize = 8 << 20
+)
+
+type Options struct {
+	// Paths of files and dirs to include, names in bag are relative to their common parent dir
+	Paths []string
+	// Exclude has glob patterns, matched against each path element and whole name, like .git or *.tmp
+	Exclude []string
+
+	IncludeHidden bool
+	// FollowSymlinks includes symlinks targets, otherwise symlinks are skipped
+	FollowSymlinks bool
+
+	// Order of files in bag, OrderName by default
+	Order string
+	// DirName of bag, by default it is a name of common parent dir. Files are not copied,
+	// so it can only be NoDirName or a name of that dir
+	DirName string
+
+	// Deterministic makes names portable, so bag id depends only on content, order can only be by name
+	Deterministic bool
+}
+
+type File struct {
+	Path string
+	Name string
+	Size uint64
+}
+
+func (f *File) GetName() string {
+	return f.Name
+}
+
+func (f *File) GetSize() uint64 {
+	return f.Size
+}
+
+func (f *File) CreateReader() (io.ReaderAt, func() error, error) {
+	fl, err := os.Open(f.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fl, fl.Close, nil
+}
+
+// Set is a scanned list of files ready to be hashed, files are expected to be in Root/DirName
+type Set struct {
+	Root    string
+	DirName string
+	Files   []*File
+	Size    uint64
+	// Options set was scanned with, to scan it again for a new version of bag
+	Options Options
+}
+
+type Plan struct {
+	FilesCount  int
+	DataSize    uint64
+	HeaderSize  uint64
+	PieceSize   uint32
+	PiecesCount uint64
+}
+
+func Scan(opts Options) (*Set, error) {
+	if len(opts.Paths) == 0 {
+		return nil, fmt.Errorf("no paths to add")
+	}
+
+	for _, p := range opts.Exclude {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", p, err)
+		}
+	}
+
+	paths := make([]string, 0, len(opts.Paths))
+	for _, p := range opts.Paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, abs)
+	}
+
+	common, err := commonDir(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Deterministic && opts.Order != "" && opts.Order != OrderName {
+		return nil, fmt.Errorf("deterministic bag can only be ordered by name")
+	}
+
+	set := &Set{Options: opts}
+	switch {
+	case opts.DirName == NoDirName, opts.DirName == "" && len(paths) == 1 && !isDir(paths[0]):
+		// single file bag has no dir, same as library does
+		set.Root = common
+	case opts.DirName != "" && strings.TrimSuffix(opts.DirName, "/") != filepath.Base(common):
+		return nil, fmt.Errorf("dir name must be %q or empty, files are read from their place on disk", filepath.Base(common))
+	case strings.HasPrefix(filepath.Base(common), ".") || filepath.Dir(common) == common:
+		// fs root or hidden dir, fallback to empty name
+		set.Root = common
+	default:
+		set.Root = filepath.Dir(common)
+		// with trailing slash, like library detects it, so bag id is the same as for single dir
+		set.DirName = filepath.Base(common) + "/"
+	}
+
+	s := &scanner{opts: opts, base: common, seen: map[string]bool{}, visited: map[string]bool{}}
+	for _, p := range paths {
+		if err = s.add(p, p); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(s.files) == 0 {
+		return nil, fmt.Errorf("no files to add, everything is excluded")
+	}
+
+	switch opts.Order {
+	case OrderSize:
+		sort.SliceStable(s.files, func(i, j int) bool { return s.files[i].Size > s.files[j].Size })
+	case OrderInput:
+	case OrderName, "":
+		sort.SliceStable(s.files, func(i, j int) bool { return s.files[i].Name < s.files[j].Name })
+	default:
+		return nil, fmt.Errorf("unknown order %q", opts.Order)
+	}
+
+	if opts.Deterministic {
+		if err = normalize(set, s.files); err != nil {
+			return nil, err
+		}
+		sort.SliceStable(s.files, func(i, j int) bool { return s.files[i].Name < s.files[j].Name })
+	}
+
+	set.Files = s.files
+	for _, f := range set.Files {
+		set.Size += f.Size
+	}
+	return set, nil
+}
+
+// normalize converts names to NFC, which is used by most systems except macOS,
+// and rejects names which would be different files or invalid on other OS
+func normalize(set *Set, files []*File) error {
+	set.DirName = norm.NFC.String(set.DirName)
+
+	names := map[string]string{}
+	for _, f := range files {
+		f.Name = norm.NFC.String(f.Name)
+		if strings.Contains(f.Name, "\\") {
+			return fmt.Errorf("file name %q has backslash, it is a separator on windows", f.Name)
+		}
+
+		lower := strings.ToLower(f.Name)
+		if prev, ok := names[lower]; ok {
+			return fmt.Errorf("file names %q and %q are the same on case insensitive systems", prev, f.Name)
+		}
+		names[lower] = f.Name
+	}
+	return nil
+}
+
+// commonDir returns dir which includes all paths, for single dir it is that dir
+func commonDir(paths []string) (string, error) {
+	var common []string
+	for i, p := range paths {
+		st, err := os.Stat(p)
+		if err != nil {
+			return "", fmt.Errorf("failed to stat %s: %w", p, err)
+		}
+
+		dir := p
+		if !st.IsDir() {
+			dir = filepath.Dir(p)
+		}
+
+		parts := strings.Split(filepath.ToSlash(dir), "/")
+		if i == 0 {
+			common = parts
+			continue
+		}
+
+		n := 0
+		for n < len(common) && n < len(parts) && common[n] == parts[n] {
+			n++
+		}
+		common = common[:n]
+	}
+
+	if len(common) == 0 || (len(common) == 1 && common[0] == "") {
+		return "", fmt.Errorf("paths have no common parent dir")
+	}
+
+	dir := strings.Join(common, "/")
+	if common[len(common)-1] == "" || len(common) == 1 {
+		// unix root or windows volume
+		dir += "/"
+	}
+	return filepath.FromSlash(dir), nil
+}
+
+type scanner struct {
+	opts    Options
+	base    string
+	files   []*File
+	seen    map[string]bool
+	visited map[string]bool
+}
+
+// add walks path, name is a path of element inside bag, it differs from real path for symlinked dirs
+func (s *scanner) add(path, name string) error {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	if s.visited[real] {
+		// symlink loop or path added twice
+		return nil
+	}
+	s.visited[real] = true
+	defer delete(s.visited, real)
+
+	// walk is not following symlinks, even for root, so resolved path is walked
+	return filepath.WalkDir(real, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(s.base, filepath.Join(name, strings.TrimPrefix(p, real)))
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rel != "." && s.skip(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 {
+			if !s.opts.FollowSymlinks {
+				return nil
+			}
+
+			st, err := os.Stat(p)
+			if err != nil {
+				return fmt.Errorf("failed to follow symlink %s: %w", p, err)
+			}
+			if st.IsDir() {
+				return s.add(p, filepath.Join(s.base, rel))
+			}
+		} else if d.IsDir() {
+			return nil
+		}
+
+		if rel == "." {
+			// single file
+			rel = filepath.Base(p)
+		}
+
+		if s.seen[rel] {
+			return nil
+		}
+		s.seen[rel] = true
+
+		size, err := fileSize(p)
+		if err != nil {
+			return err
+		}
+
+		s.files = append(s.files, &File{Path: p, Name: rel, Size: size})
+		return nil
+	})
+}
+
+func (s *scanner) skip(rel string) bool {
+	parts := strings.Split(rel, "/")
+	last := parts[len(parts)-1]
+	lower := strings.ToLower(last)
+	if last == ".DS_Store" || lower == "desktop.ini" || lower == "thumbs.db" {
+		// OS-created files that can be modified automatically and thus break some pieces
+		return true
+	}
+
+	if !s.opts.IncludeHidden && strings.HasPrefix(last, ".") {
+		return true
+	}
+
+	for _, pattern := range s.opts.Exclude {
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, last); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isDir(path string) bool {
+	st, err := os.Stat(path)
+	return err == nil && st.IsDir()
+}
+
+func fileSize(path string) (uint64, error) {
+	// stat is not always gives the right file size, so we open file and find the end
+	fl, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer fl.Close()
+
+	sz, err := fl.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, fmt.Errorf("failed to seek file end %s: %w", path, err)
+	}
+	return uint64(sz), nil
+}
+
+func (s *Set) Refs() []storage.FileRef {
+	refs := make([]storage.FileRef, 0, len(s.Files))
+	for _, f := range s.Files {
+		refs = append(refs, f)
+	}
+	return refs
+}
+
+// BuildHeader makes bag header same as library does on creation
+func BuildHeader(dirName string, files []storage.FileRef) *storage.TorrentHeader {
+	header := &storage.TorrentHeader{
+		DirNameSize: uint32(len(dirName)),
+		DirName:     []byte(dirName),
+	}
+
+	// indexes are cumulative
+	var off uint64
+	for _, f := range files {
+		off += f.GetSize()
+		header.FilesCount++
+		header.TotalNameSize += uint64(len(f.GetName()))
+		header.Names = append(header.Names, f.GetName()...)
+		header.NameIndex = append(header.NameIndex, header.TotalNameSize)
+		header.DataIndex = append(header.DataIndex, off)
+	}
+	return header
+}
+
+// HeaderFile returns name and size of file by its index in header, header should be checked with CheckHeader
+func HeaderFile(h *storage.TorrentHeader, i uint32) (string, uint64) {
+	var nameFrom, dataFrom uint64
+	if i > 0 {
+		nameFrom, dataFrom = h.NameIndex[i-1], h.DataIndex[i-1]
+	}
+	return string(h.Names[nameFrom:h.NameIndex[i]]), h.DataIndex[i] - dataFrom
+}
+
+// CheckHeader verifies that indexes of header are consistent, header from meta file or peer can be broken
+func CheckHeader(h *storage.TorrentHeader) error {
+	if uint64(len(h.NameIndex)) != uint64(h.FilesCount) || uint64(len(h.DataIndex)) != uint64(h.FilesCount) {
+		return fmt.Errorf("header has %d files, but %d names and %d data indexes", h.FilesCount, len(h.NameIndex), len(h.DataIndex))
+	}
+	if uint64(len(h.Names)) != h.TotalNameSize || uint64(len(h.DirName)) != uint64(h.DirNameSize) {
+		return fmt.Errorf("header names size is incorrect")
+	}
+
+	var name, data uint64
+	for i := range h.NameIndex {
+		if h.NameIndex[i] < name || h.DataIndex[i] < data {
+			return fmt.Errorf("header index of file %d is less than previous", i)
+		}
+		name, data = h.NameIndex[i], h.DataIndex[i]
+	}
+	if name != h.TotalNameSize {
+		return fmt.Errorf("header names are not fully indexed")
+	}
+	return nil
+}
+
+// Plan calculates bag layout without hashing, pieceSize 0 means the one library will choose
+func (s *Set) Plan(pieceSize uint32) (*Plan, error) {
+	headerData, err := tl.Serialize(BuildHeader(s.DirName, s.Refs()), true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize header: %w", err)
+	}
+
+	if pieceSize == 0 {
+		pieceSize = DefaultPieceSize(s.Size)
+	} else if err = ValidatePieceSize(pieceSize); err != nil {
+		return nil, err
+	}
+
+	full := uint64(len(headerData)) + s.Size
+	pieces := full / uint64(pieceSize)
+	if full%uint64(pieceSize) != 0 {
+		pieces++
+	}
+
+	return &Plan{
+		FilesCount:  len(s.Files),
+		DataSize:    s.Size,
+		HeaderSize:  uint64(len(headerData)),
+		PieceSize:   pieceSize,
+		PiecesCount: pieces,
+	}, nil
+}
+
+// DefaultPieceSize is the same as library chooses for bag of this size
+func DefaultPieceSize(dataSize uint64) uint32 {
+	switch {
+	case dataSize > 100<<30: // > 100 GB
+		return 8 << 20
+	case dataSize > 20<<30: // > 20 GB
+		return 4 << 20
+	case dataSize > 10<<30: // > 10 GB
+		return 2 << 20
+	case dataSize > 1<<30: // > 1 GB
+		return 1 << 20
+	case dataSize > 512<<20: // > 512 MB
+		return 256 << 10
+	default:
+		return 128 << 10
+	}
+}
+
+// ValidatePieceSize checks custom piece size, it should be a power of 2 for proofs tree to be balanced
+func ValidatePieceSize(sz uint32) error {
+	if sz < MinPieceSize || sz > MaxPieceSize {
+		return fmt.Errorf("piece size should be between %d KB and %d MB", MinPieceSize>>10, MaxPieceSize>>20)
+	}
+	if sz&(sz-1) != 0 {
+		return fmt.Errorf("piece size should be a power of 2")
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package bagfiles
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/xssnick/tonutils-go/tl"
+	"github.com/xssnick/tonutils-storage/storage"
+)
+
+// memStorage drops pieces, only bag id is needed
+type memStorage struct {
+	storage.Storage
+}
+
+func (s *memStorage) GetForcedPieceSize() uint32 {
+	return 0
+}
+
+func (s *memStorage) SetPiece(bagId []byte, id uint32, p *storage.PieceInfo) error {
+	return nil
+}
+
+func (s *memStorage) SetActiveFiles(bagId []byte, ids []uint32) error {
+	return nil
+}
+
+// bagID creates bag of set the same way as on bag creation
+func bagID(t *testing.T, set *Set) []byte {
+	header := &storage.TorrentHeader{DirNameSize: uint32(len(set.DirName)), DirName: []byte(set.DirName)}
+	tor, err := storage.CreateTorrentWithInitialHeader(context.Background(), set.Root, "same description", header, &memStorage{}, nil, set.Refs(), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tor.BagID
+}
+
+// writeTree creates files in given order, names use / separators
+func writeTree(t *testing.T, root string, names []string, content map[string]string) {
+	for _, name := range names {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content[name]), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDeterministicBagID(t *testing.T) {
+	content := map[string]string{
+		"b.txt":           "second",
+		"a.txt":           "first",
+		"sub/z.bin":       strings.Repeat("z", 200000),
+		"sub/deep/c.txt":  "third",
+		"Upper.txt":       "upper",
+		"sub/.hidden":     "hidden",
+		"sub/.DS_Store":   "os file",
+		"sub/deep/d.tmp":  "excluded",
+		"sub/Thumbs.db":   "os file",
+		"name with space": "space",
+	}
+	var names []string
+	for name := range content {
+		names = append(names, name)
+	}
+	reversed := make([]string, len(names))
+	for i, name := range names {
+		reversed[len(names)-1-i] = name
+	}
+
+	// same dir name in different places, files are written in different order
+	first := filepath.Join(t.TempDir(), "bag")
+	second := filepath.Join(t.TempDir(), "other", "bag")
+	writeTree(t, first, names, content)
+	writeTree(t, second, reversed, content)
+
+	scan := func(paths ...string) *Set {
+		set, err := Scan(Options{Paths: paths, Exclude: []string{"*.tmp"}, Deterministic: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return set
+	}
+
+	want := scan(first)
+	var got []string
+	for _, f := range want.Files {
+		got = append(got, f.Name)
+	}
+	if strings.Join(got, ",") != "Upper.txt,a.txt,b.txt,name with space,sub/deep/c.txt,sub/z.bin" {
+		t.Fatalf("files are %v", got)
+	}
+	if want.DirName != "bag/" {
+		t.Fatalf("dir name is %q", want.DirName)
+	}
+	id := bagID(t, want)
+
+	sets := map[string]*Set{
+		"other place":        scan(second),
+		"paths inside":       scan(filepath.Join(second, "sub"), filepath.Join(second, "a.txt"), filepath.Join(second, "b.txt"), filepath.Join(second, "Upper.txt"), filepath.Join(second, "name with space")),
+		"paths inside order": scan(filepath.Join(first, "name with space"), filepath.Join(first, "b.txt"), filepath.Join(first, "Upper.txt"), filepath.Join(first, "a.txt"), filepath.Join(first, "sub")),
+	}
+	for name, set := range sets {
+		if !bytes.Equal(bagID(t, set), id) {
+			t.Errorf("%s: bag id is different", name)
+		}
+	}
+
+	if _, err := Scan(Options{Paths: []string{first}, Order: OrderSize, Deterministic: true}); err == nil {
+		t.Fatal("deterministic bag is ordered by size")
+	}
+}
+
+func TestDeterministicNames(t *testing.T) {
+	// é as e with combining accent, like macOS stores it, and as single rune
+	const nfd, nfc = "cafe\u0301.txt", "caf\u00e9.txt"
+
+	first := filepath.Join(t.TempDir(), "bag")
+	second := filepath.Join(t.TempDir(), "bag")
+	writeTree(t, first, []string{nfd}, map[string]string{nfd: "coffee"})
+	writeTree(t, second, []string{nfc}, map[string]string{nfc: "coffee"})
+
+	var ids [][]byte
+	for _, root := range []string{first, second} {
+		set, err := Scan(Options{Paths: []string{root}, Deterministic: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if set.Files[0].Name != nfc {
+			t.Fatalf("name %q is not normalized", set.Files[0].Name)
+		}
+		if _, err = os.Stat(set.Files[0].Path); err != nil {
+			t.Fatal("file path is changed with name:", err)
+		}
+		ids = append(ids, bagID(t, set))
+	}
+	if !bytes.Equal(ids[0], ids[1]) {
+		t.Fatal("bag id depends on unicode normalization of name")
+	}
+
+	tests := []struct {
+		name   string
+		files  []string
+		errHas string
+	}{
+		{"case collision", []string{"Readme.md", "README.md"}, "case insensitive"},
+		{"case collision in dirs", []string{"Docs/a.txt", "docs/A.txt"}, "case insensitive"},
+		{"normalization collision", []string{nfd, strings.ToUpper(nfc)}, "case insensitive"},
+		{"backslash", []string{"a\\b.txt"}, "backslash"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+				t.Skip("such names can not be created on this system")
+			}
+
+			root := filepath.Join(t.TempDir(), "bag")
+			writeTree(t, root, tt.files, map[string]string{})
+
+			if _, err := Scan(Options{Paths: []string{root}}); err != nil {
+				t.Fatal("not deterministic bag is rejected:", err)
+			}
+			_, err := Scan(Options{Paths: []string{root}, Deterministic: true})
+			if err == nil || !strings.Contains(err.Error(), tt.errHas) {
+				t.Fatalf("error %v, expected %q", err, tt.errHas)
+			}
+		})
+	}
+}
+
+func TestValidatePieceSize(t *testing.T) {
+	tests := []struct {
+		size uint32
+		ok   bool
+	}{
+		{MinPieceSize, true},
+		{MaxPieceSize, true},
+		{128 << 10, true},
+		{MinPieceSize / 2, false},
+		{MaxPieceSize * 2, false},
+		{0, false},
+		{MinPieceSize + 1, false},
+		{3 << 20, false},
+	}
+
+	for _, tt := range tests {
+		if err := ValidatePieceSize(tt.size); (err == nil) != tt.ok {
+			t.Errorf("piece size %d: error %v", tt.size, err)
+		}
+	}
+}
+
+func TestDefaultPieceSize(t *testing.T) {
+	tests := []struct {
+		size uint64
+		want uint32
+	}{
+		{0, 128 << 10},
+		{512 << 20, 128 << 10},
+		{512<<20 + 1, 256 << 10},
+		{1 << 30, 256 << 10},
+		{1<<30 + 1, 1 << 20},
+		{10<<30 + 1, 2 << 20},
+		{20<<30 + 1, 4 << 20},
+		{100 << 30, 4 << 20},
+		{100<<30 + 1, 8 << 20},
+	}
+
+	for _, tt := range tests {
+		if got := DefaultPieceSize(tt.size); got != tt.want {
+			t.Errorf("piece size %d for %d bytes, expected %d", got, tt.size, tt.want)
+		}
+		if err := ValidatePieceSize(DefaultPieceSize(tt.size)); err != nil {
+			t.Errorf("default piece size of %d bytes is invalid: %v", tt.size, err)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	set := &Set{DirName: "bag/", Files: []*File{{Name: "a.txt"}, {Name: "b.txt"}}}
+	headerData, err := tl.Serialize(BuildHeader(set.DirName, set.Refs()), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := uint64(len(headerData))
+
+	tests := []struct {
+		name      string
+		data      uint64
+		pieceSize uint32
+		pieces    uint64
+	}{
+		{"empty files", 0, 0, 1},
+		{"exactly one piece", MinPieceSize - header, MinPieceSize, 1},
+		{"one byte more", MinPieceSize - header + 1, MinPieceSize, 2},
+		{"exactly two pieces", 2*MinPieceSize - header, MinPieceSize, 2},
+		{"default piece size", 1 << 20, 0, (1<<20 + header + 128<<10 - 1) / (128 << 10)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// sizes are fixed length in header, so header size is the same
+			set.Files[0].Size, set.Files[1].Size = tt.data/2, tt.data-tt.data/2
+			set.Size = tt.data
+
+			plan, err := set.Plan(tt.pieceSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plan.HeaderSize != header || plan.DataSize != tt.data || plan.FilesCount != 2 {
+				t.Fatalf("plan %+v", plan)
+			}
+			if plan.PiecesCount != tt.pieces {
+				t.Fatalf("%d pieces, expected %d", plan.PiecesCount, tt.pieces)
+			}
+		})
+	}
+
+	if _, err = set.Plan(MinPieceSize + 1); err == nil {
+		t.Fatal("plan is made with invalid piece size")
+	}
+}
@@ -0,0 +1,23 @@
+package bagfiles
+
+const (
+	DiffMissing = "missing"
+	DiffSize    = "size"
+	DiffContent = "content"
+	// DiffExtra is a file in bag dir which is not in bag, it is not affecting bag id
+	DiffExtra = "extra"
+)
+
+type FileDiff struct {
+	Name    string
+	Kind    string
+	Details string
+}
+
+// Diff is a result of comparing dir with bag
+type Diff struct {
+	Matches bool
+	Files   []FileDiff
+	// Details explains mismatch which can't be bound to files
+	Details string
+}
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/xssnick/tonutils-go/liteclient"
+	"github.com/xssnick/tonutils-go/tl"
+)
+
+// reconnectPool is ADNL connection to storage daemon which is re-established
+// when it is broken, for example after daemon restart
+type reconnectPool struct {
+	addr      string
+	serverKey string
+	authKey   ed25519.PrivateKey
+
+	pool   *liteclient.ConnectionPool
+	broken bool
+	closed bool
+
+	mx sync.Mutex
+}
+
+func newReconnectPool(ctx context.Context, addr, serverKey string, authKey ed25519.PrivateKey) (*reconnectPool, error) {
+	p := &reconnectPool{
+		addr:      addr,
+		serverKey: serverKey,
+		authKey:   authKey,
+	}
+
+	if _, err := p.getPool(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *reconnectPool) getPool(ctx context.Context) (*liteclient.ConnectionPool, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if p.closed {
+		return nil, liteclient.ErrStopped
+	}
+
+	if p.pool != nil && !p.broken {
+		return p.pool, nil
+	}
+
+	if p.pool != nil {
+		p.pool.Stop()
+		p.pool = nil
+	}
+
+	pool := liteclient.NewConnectionPoolWithAuth(p.authKey)
+	if err := pool.AddConnection(ctx, p.addr, p.serverKey); err != nil {
+		pool.Stop()
+		return nil, fmt.Errorf("connect to daemon err: %w", err)
+	}
+
+	pool.SetOnDisconnect(func(addr, key string) {
+		p.mx.Lock()
+		defer p.mx.Unlock()
+
+		if p.pool == pool {
+			log.Println("connection to daemon lost, will reconnect on next query")
+			p.broken = true
+		}
+	})
+
+	p.pool = pool
+	p.broken = false
+	return pool, nil
+}
+
+func (p *reconnectPool) markBroken(pool *liteclient.ConnectionPool) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if p.pool == pool {
+		p.broken = true
+	}
+}
+
+func (p *reconnectPool) QueryADNL(ctx context.Context, payload, response tl.Serializable) error {
+	var err error
+	// one retry with a fresh connection if current is broken
+	for i := 0; i < 2; i++ {
+		var pool *liteclient.ConnectionPool
+		pool, err = p.getPool(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = pool.QueryADNL(ctx, payload, response)
+		if err == nil {
+			return nil
+		}
+
+		if !isConnectionErr(err) || ctx.Err() != nil {
+			return err
+		}
+		p.markBroken(pool)
+	}
+	return err
+}
+
+func (p *reconnectPool) Close() {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.closed = true
+	if p.pool != nil {
+		p.pool.Stop()
+		p.pool = nil
+	}
+}
+
+func isConnectionErr(err error) bool {
+	return errors.Is(err, liteclient.ErrNoActiveConnections) ||
+		errors.Is(err, liteclient.ErrADNLReqTimeout) ||
+		errors.Is(err, liteclient.NetworkErr{})
+}
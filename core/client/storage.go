@@ -5,12 +5,16 @@ import (
 	"crypto/ed25519"
 	"encoding/base64"
 	"fmt"
+	"github.com/tonutils/torrent-client/core/bagfiles"
+	"github.com/xssnick/ton-payment-network/tonpayments/db"
 	"github.com/xssnick/tonutils-go/address"
-	"github.com/xssnick/tonutils-go/liteclient"
 	"github.com/xssnick/tonutils-go/tl"
+	"github.com/xssnick/tonutils-go/tlb"
 	"github.com/xssnick/tonutils-storage/provider"
 	"log"
+	"math/big"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -21,9 +25,22 @@ type ADNL interface {
 type StorageClient struct {
 	client   ADNL
 	notifier chan bool
+
+	// daemon reports only speed, so uploaded amount is accumulated from it
+	uploaded     map[string]float64
+	lastSampleAt time.Time
+
+	// when fatal error of bag was noticed first, daemon does not report it
+	errorsSeen map[string]uint32
+
+	closeCtx context.Context
+	close    context.CancelFunc
+	mx       sync.Mutex
 }
 
-func ConnectToStorageDaemon(addr string, dbPath string) (*StorageClient, error) {
+// ConnectToStorageDaemon connects to daemon control port, ctx limits only connecting,
+// client lives until Close
+func ConnectToStorageDaemon(ctx context.Context, addr string, dbPath string) (*StorageClient, error) {
 	clientKey, err := os.ReadFile(dbPath + "/cli-keys/client")
 	if err != nil {
 		log.Println(dbPath+"/client read err:", err.Error())
@@ -39,25 +56,46 @@ func ConnectToStorageDaemon(addr string, dbPath string) (*StorageClient, error)
 	}
 	serverKey := base64.StdEncoding.EncodeToString(key[4:])
 
-	pool := liteclient.NewConnectionPoolWithAuth(authKey)
-	err = pool.AddConnection(context.Background(), addr, serverKey)
+	pool, err := newReconnectPool(ctx, addr, serverKey, authKey)
 	if err != nil {
 		log.Println("connect to daemon err:", err.Error())
-		return nil, fmt.Errorf("connect to daemon err: %w", err)
+		return nil, err
+	}
+
+	s := &StorageClient{
+		client:     pool,
+		notifier:   make(chan bool, 1),
+		uploaded:   map[string]float64{},
+		errorsSeen: map[string]uint32{},
 	}
+	s.closeCtx, s.close = context.WithCancel(context.Background())
 
-	notifier := make(chan bool, 1)
 	go func() {
+		defer pool.Close()
+
+		ticker := time.NewTicker(1 * time.Second)
+		defer ticker.Stop()
+
 		for {
-			time.Sleep(1 * time.Second)
-			notifier <- true
+			select {
+			case <-s.closeCtx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			select {
+			case s.notifier <- true:
+			default:
+			}
 		}
 	}()
 
-	return &StorageClient{
-		client:   pool,
-		notifier: notifier,
-	}, nil
+	return s, nil
+}
+
+// Close stops notifier and closes connection to daemon
+func (s *StorageClient) Close() {
+	s.close()
 }
 
 func (s *StorageClient) GetTorrents(ctx context.Context) (*TorrentsList, error) {
@@ -69,6 +107,10 @@ func (s *StorageClient) GetTorrents(ctx context.Context) (*TorrentsList, error)
 
 	switch t := res.(type) {
 	case TorrentsList:
+		s.sampleUploaded(t.Torrents)
+		for i := range t.Torrents {
+			s.stampError(&t.Torrents[i])
+		}
 		return &t, nil
 	case DaemonError:
 		return nil, fmt.Errorf("%s", t.Message)
@@ -77,13 +119,18 @@ func (s *StorageClient) GetTorrents(ctx context.Context) (*TorrentsList, error)
 }
 
 func (s *StorageClient) AddByHash(ctx context.Context, hash []byte, dir string) (*TorrentFull, error) {
+	return s.AddByHashWithPriorities(ctx, hash, dir, true, true, []any{PriorityActionAll{0}}) // download only header
+}
+
+// AddByHashWithPriorities adds bag, priorities can contain PriorityActionAll, PriorityActionIndex and PriorityActionName
+func (s *StorageClient) AddByHashWithPriorities(ctx context.Context, hash []byte, dir string, startDownload, allowUpload bool, priorities []any) (*TorrentFull, error) {
 	var res tl.Serializable
 	err := s.client.QueryADNL(ctx, AddByHash{
 		Hash:          hash,
 		RootDir:       dir,
-		StartDownload: true,
-		AllowUpload:   true,
-		Priorities:    []any{PriorityActionAll{0}}, // download only header
+		StartDownload: startDownload,
+		AllowUpload:   allowUpload,
+		Priorities:    priorities,
 	}, &res)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query add by hash: %w", err)
@@ -99,13 +146,23 @@ func (s *StorageClient) AddByHash(ctx context.Context, hash []byte, dir string)
 }
 
 func (s *StorageClient) AddByMeta(ctx context.Context, meta []byte, dir string) (*TorrentFull, error) {
+	return s.AddByMetaWithPriorities(ctx, meta, dir, true, true, []any{PriorityActionAll{0}}) // download only header
+}
+
+// AddByMetaStopped imports meta file without starting download and upload
+func (s *StorageClient) AddByMetaStopped(ctx context.Context, meta []byte, dir string) (*TorrentFull, error) {
+	return s.AddByMetaWithPriorities(ctx, meta, dir, false, false, []any{PriorityActionAll{0}})
+}
+
+// AddByMetaWithPriorities imports meta file, priorities can contain PriorityActionAll, PriorityActionIndex and PriorityActionName
+func (s *StorageClient) AddByMetaWithPriorities(ctx context.Context, meta []byte, dir string, startDownload, allowUpload bool, priorities []any) (*TorrentFull, error) {
 	var res tl.Serializable
 	err := s.client.QueryADNL(ctx, AddByMeta{
 		Meta:          meta,
 		RootDir:       dir,
-		StartDownload: true,
-		AllowUpload:   true,
-		Priorities:    []any{PriorityActionAll{0}}, // download only header
+		StartDownload: startDownload,
+		AllowUpload:   allowUpload,
+		Priorities:    priorities,
 	}, &res)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query add by meta: %w", err)
@@ -120,7 +177,94 @@ func (s *StorageClient) AddByMeta(ctx context.Context, meta []byte, dir string)
 	return nil, fmt.Errorf("unexpected response")
 }
 
-func (s *StorageClient) CreateTorrent(ctx context.Context, dir, description string, progressCallback func(done uint64, max uint64)) (*TorrentFull, error) {
+type CreateOptions struct {
+	// PieceSize of bag, 0 means it is chosen by data size
+	PieceSize uint32
+	// CopyInside copies files to storage, so bag is not broken when original files are changed
+	CopyInside bool
+	// CopyDir is where embedded storage puts copied files, daemon uses its own db dir
+	CopyDir string
+	// StartPaused creates bag without seeding it
+	StartPaused bool
+	// Parent is a bag which new one is the next version of, hashes of its unchanged data are reused
+	Parent []byte
+	// RetireParentAfterPeers stops parent when new version has this number of peers, 0 keeps it active
+	RetireParentAfterPeers int
+}
+
+// BagVersions are links between versions of bag, they are known only locally
+type BagVersions struct {
+	Parent   []byte
+	Children [][]byte
+}
+
+// WalletBalances are balances of wallet in every currency, jettons are by master address
+type WalletBalances struct {
+	Ton             *big.Int
+	Jettons         map[string]*big.Int
+	ExtraCurrencies map[uint32]*big.Int
+}
+
+// TunnelRoute is a route of tunnel, it is current when Until is zero
+type TunnelRoute struct {
+	Sections []TunnelSection
+	ExtAddr  string
+
+	Since time.Time
+	Until time.Time
+
+	// BytesIn and BytesOut are traffic through route
+	BytesIn  uint64
+	BytesOut uint64
+
+	// LastCheckedAt is when probe through the whole route was answered last time, zero when none was
+	LastCheckedAt time.Time
+	// Health is measured last time, zero before the first measurement
+	Health TunnelHealth
+}
+
+// TunnelHealth is measured for route every few seconds
+type TunnelHealth struct {
+	// Loss is part of probes through route without answer, it is measured when Packets (finished probes) are enough
+	Loss    float64
+	Packets uint64
+	// RTT is average round trip of probes through the whole route, zero when none returned
+	RTT time.Duration
+	// In and Out are bytes per second
+	In  uint64
+	Out uint64
+	// NoAnswerFor is time since probe through the whole route was answered last time
+	NoAnswerFor time.Duration
+	MeasuredAt  time.Time
+}
+
+// TunnelSection is a node of route, Outer sends and receives packets, Inbound ones are on the way back from it
+type TunnelSection struct {
+	Key     []byte
+	Outer   bool
+	Inbound bool
+
+	// Payment is nil for free node
+	Payment *TunnelSectionPayment
+}
+
+type TunnelSectionPayment struct {
+	PricePerPacket uint64
+	// JettonMaster is bounceable address of jetton, ExtraCurrencyID is set for extra currency, TON otherwise
+	JettonMaster    string
+	ExtraCurrencyID uint32
+	// ProxyFees are min fees of payment network proxies for virtual channel to node
+	ProxyFees []*big.Int
+}
+
+func (s *StorageClient) CreateTorrent(ctx context.Context, dir, description string, opts CreateOptions, progressCallback func(done uint64, max uint64)) (*TorrentFull, error) {
+	if opts.PieceSize != 0 {
+		return nil, fmt.Errorf("custom piece size is not supported by storage daemon")
+	}
+	if opts.Parent != nil {
+		return nil, fmt.Errorf("bag versions are not supported by storage daemon")
+	}
+
 	if progressCallback != nil {
 		progressCallback(50, 100)
 	}
@@ -129,8 +273,8 @@ func (s *StorageClient) CreateTorrent(ctx context.Context, dir, description stri
 	err := s.client.QueryADNL(ctx, CreateTorrent{
 		Path:        dir,
 		Description: description,
-		AllowUpload: true,
-		CopyInside:  false,
+		AllowUpload: !opts.StartPaused,
+		CopyInside:  opts.CopyInside,
 	}, &res)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query create torrent: %w", err)
@@ -156,6 +300,7 @@ func (s *StorageClient) GetTorrentFull(ctx context.Context, hash []byte) (*Torre
 
 	switch t := res.(type) {
 	case TorrentFull:
+		s.stampError(&t.Torrent)
 		return &t, nil
 	case DaemonError:
 		return nil, fmt.Errorf("%s", t.Message)
@@ -182,8 +327,50 @@ func (s *StorageClient) GetTorrentMeta(ctx context.Context, hash []byte) ([]byte
 }
 
 func (s *StorageClient) GetUploadStats(ctx context.Context, hash []byte) (uint64, error) {
-	// not supported by daemon
-	return 0, nil
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	// daemon has no upload counter, so it is estimated from speed samples
+	return uint64(s.uploaded[string(hash)]), nil
+}
+
+func (s *StorageClient) sampleUploaded(list []Torrent) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	now := time.Now()
+	if !s.lastSampleAt.IsZero() {
+		since := now.Sub(s.lastSampleAt).Seconds()
+		if since > 10 {
+			// too long gap, speed could be different
+			since = 10
+		}
+
+		for _, t := range list {
+			if t.ActiveUpload {
+				s.uploaded[string(t.Hash)] += t.UploadSpeed * since
+			}
+		}
+	}
+	s.lastSampleAt = now
+}
+
+func (s *StorageClient) stampError(t *Torrent) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	key := string(t.Hash)
+	if t.FatalError == nil {
+		delete(s.errorsSeen, key)
+		return
+	}
+
+	at, ok := s.errorsSeen[key]
+	if !ok {
+		at = uint32(time.Now().Unix())
+		s.errorsSeen[key] = at
+	}
+	t.FatalErrorAt = at
 }
 
 func (s *StorageClient) GetPeers(ctx context.Context, hash []byte) (*PeersList, error) {
@@ -224,6 +411,13 @@ func (s *StorageClient) RemoveTorrent(ctx context.Context, hash []byte, withFile
 }
 
 func (s *StorageClient) SetActive(ctx context.Context, hash []byte, active bool) error {
+	if err := s.SetActiveDownload(ctx, hash, active); err != nil {
+		return err
+	}
+	return s.SetActiveUpload(ctx, hash, active)
+}
+
+func (s *StorageClient) SetActiveDownload(ctx context.Context, hash []byte, active bool) error {
 	var res tl.Serializable
 	err := s.client.QueryADNL(ctx, SetActiveDownload{
 		Hash:   hash,
@@ -235,28 +429,75 @@ func (s *StorageClient) SetActive(ctx context.Context, hash []byte, active bool)
 
 	switch t := res.(type) {
 	case Success:
-		var res tl.Serializable
-		err = s.client.QueryADNL(ctx, SetActiveUpload{
-			Hash:   hash,
-			Active: active,
-		}, &res)
-		if err != nil {
-			return fmt.Errorf("failed to query set active upload torrent: %w", err)
-		}
+		return nil
+	case DaemonError:
+		return fmt.Errorf("%s", t.Message)
+	}
+	return fmt.Errorf("unexpected response")
+}
 
-		switch t := res.(type) {
-		case Success:
-			return nil
-		case DaemonError:
-			return fmt.Errorf("%s", t.Message)
-		}
-		return fmt.Errorf("unexpected response")
+func (s *StorageClient) SetActiveUpload(ctx context.Context, hash []byte, active bool) error {
+	var res tl.Serializable
+	err := s.client.QueryADNL(ctx, SetActiveUpload{
+		Hash:   hash,
+		Active: active,
+	}, &res)
+	if err != nil {
+		return fmt.Errorf("failed to query set active upload torrent: %w", err)
+	}
+
+	switch t := res.(type) {
+	case Success:
+		return nil
 	case DaemonError:
 		return fmt.Errorf("%s", t.Message)
 	}
 	return fmt.Errorf("unexpected response")
 }
 
+// RetryTorrent restarts download of bag, daemon resets fatal error and verifies files on start
+func (s *StorageClient) RetryTorrent(ctx context.Context, hash []byte) error {
+	if err := s.SetActiveDownload(ctx, hash, false); err != nil {
+		return err
+	}
+
+	s.mx.Lock()
+	delete(s.errorsSeen, string(hash))
+	s.mx.Unlock()
+
+	return s.SetActiveDownload(ctx, hash, true)
+}
+
+// RecheckTorrent is not available, daemon protocol has no command to verify stored data
+func (s *StorageClient) RecheckTorrent(ctx context.Context, hash []byte, progressCallback func(done uint64, max uint64)) (uint32, error) {
+	return 0, fmt.Errorf("recheck is not supported by storage daemon, restart bag to verify it")
+}
+
+// RelocateTorrent is not available, daemon keeps root dir of bag fixed
+func (s *StorageClient) RelocateTorrent(ctx context.Context, hash []byte, dir string, progressCallback func(done uint64, max uint64)) error {
+	return fmt.Errorf("changing data location is not supported by storage daemon")
+}
+
+// VerifyDirMatchesBag is not available, daemon can hash only bags it stores
+func (s *StorageClient) VerifyDirMatchesBag(ctx context.Context, meta []byte, dir string, progressCallback func(done uint64, max uint64)) (*bagfiles.Diff, error) {
+	return nil, fmt.Errorf("comparing folder with bag is not supported by storage daemon")
+}
+
+// CreateTorrentVersion is not available, daemon has no local index of versions
+func (s *StorageClient) CreateTorrentVersion(ctx context.Context, hash []byte, opts CreateOptions, progressCallback func(done uint64, max uint64)) (*TorrentFull, error) {
+	return nil, fmt.Errorf("bag versions are not supported by storage daemon")
+}
+
+// GetVersions returns no links, daemon doesn't know about versions
+func (s *StorageClient) GetVersions(ctx context.Context, hash []byte) (*BagVersions, error) {
+	return &BagVersions{}, nil
+}
+
+// CreateTorrentFromFiles is not available, daemon detects files of bag by itself from single path
+func (s *StorageClient) CreateTorrentFromFiles(ctx context.Context, set *bagfiles.Set, description string, opts CreateOptions, progressCallback func(done uint64, max uint64)) (*TorrentFull, error) {
+	return nil, fmt.Errorf("creating bag from list of files is not supported by storage daemon")
+}
+
 func (s *StorageClient) SetFilesPriority(ctx context.Context, hash []byte, names []string, priority int32) error {
 	for _, name := range names {
 		err := s.SetFilePriority(ctx, hash, name, priority)
@@ -288,6 +529,45 @@ func (s *StorageClient) SetFilePriority(ctx context.Context, hash []byte, name s
 	return fmt.Errorf("unexpected response")
 }
 
+func (s *StorageClient) SetFilePriorityByIndex(ctx context.Context, hash []byte, idx int64, priority int32) error {
+	var res tl.Serializable
+	err := s.client.QueryADNL(ctx, SetFilePriorityByIdx{
+		Hash:     hash,
+		Index:    idx,
+		Priority: priority,
+	}, &res)
+	if err != nil {
+		return fmt.Errorf("failed to query set file priority by idx: %w", err)
+	}
+
+	switch t := res.(type) {
+	case PriorityStatusPending, PriorityStatusSet:
+		return nil
+	case DaemonError:
+		return fmt.Errorf("%s", t.Message)
+	}
+	return fmt.Errorf("unexpected response")
+}
+
+func (s *StorageClient) SetAllFilesPriority(ctx context.Context, hash []byte, priority int32) error {
+	var res tl.Serializable
+	err := s.client.QueryADNL(ctx, SetFilePriorityAll{
+		Hash:     hash,
+		Priority: priority,
+	}, &res)
+	if err != nil {
+		return fmt.Errorf("failed to query set all files priority: %w", err)
+	}
+
+	switch t := res.(type) {
+	case PriorityStatusPending, PriorityStatusSet:
+		return nil
+	case DaemonError:
+		return fmt.Errorf("%s", t.Message)
+	}
+	return fmt.Errorf("unexpected response")
+}
+
 func (s *StorageClient) GetSpeedLimits(ctx context.Context) (*SpeedLimits, error) {
 	var res tl.Serializable
 	err := s.client.QueryADNL(ctx, GetSpeedLimits{
@@ -350,6 +630,30 @@ func (s *StorageClient) BuildWithdrawalTransaction(torrentHash []byte, owner *ad
 	return nil, nil, fmt.Errorf("not supported with storage daemon")
 }
 
+func (s *StorageClient) GetWalletBalances(ctx context.Context, owner *address.Address, jettons []*address.Address) (*WalletBalances, error) {
+	return nil, fmt.Errorf("not supported with storage daemon")
+}
+
+func (s *StorageClient) Withdraw(ctx context.Context, key ed25519.PrivateKey, to *address.Address, amount tlb.Coins, comment string) ([]byte, error) {
+	return nil, fmt.Errorf("not supported with storage daemon")
+}
+
+func (s *StorageClient) GetTunnelRoutes(ctx context.Context) ([]TunnelRoute, error) {
+	return nil, fmt.Errorf("not supported with storage daemon")
+}
+
+func (s *StorageClient) SetPrivate(ctx context.Context, hash []byte, private bool) error {
+	return fmt.Errorf("not supported with storage daemon")
+}
+
+func (s *StorageClient) GetPaymentChannels(ctx context.Context) ([]*db.Channel, error) {
+	return nil, fmt.Errorf("not supported with storage daemon")
+}
+
+func (s *StorageClient) GetPaymentHistory(ctx context.Context, channel string, limit int, after time.Time) ([]db.ChannelHistoryItem, error) {
+	return nil, fmt.Errorf("not supported with storage daemon")
+}
+
 func (s *StorageClient) GetNotifier() <-chan bool {
 	return s.notifier
 }
@@ -20,6 +20,8 @@ func init() {
 	tl.Register(SetActiveDownload{}, "storage.daemon.setActiveDownload hash:int256 active:Bool = storage.daemon.Success")
 	tl.Register(GetTorrentFull{}, "storage.daemon.getTorrentFull hash:int256 flags:# = storage.daemon.TorrentFull")
 	tl.Register(SetFilePriorityByName{}, "storage.daemon.setFilePriorityByName hash:int256 name:string priority:int = storage.daemon.SetPriorityStatus")
+	tl.Register(SetFilePriorityByIdx{}, "storage.daemon.setFilePriorityByIdx hash:int256 idx:long priority:int = storage.daemon.SetPriorityStatus")
+	tl.Register(SetFilePriorityAll{}, "storage.daemon.setFilePriorityAll hash:int256 priority:int = storage.daemon.SetPriorityStatus")
 	tl.Register(CreateTorrent{}, "storage.daemon.createTorrent path:string description:string allow_upload:Bool copy_inside:Bool flags:# = storage.daemon.TorrentFull")
 	tl.Register(GetTorrentMeta{}, "storage.daemon.getTorrentMeta hash:int256 flags:# = storage.daemon.TorrentMeta")
 	tl.Register(GetPeers{}, "storage.daemon.getTorrentPeers hash:int256 flags:# = storage.daemon.PeerList")
@@ -75,6 +77,17 @@ type SetFilePriorityByName struct {
 	Priority int32  `tl:"int"`
 }
 
+type SetFilePriorityByIdx struct {
+	Hash     []byte `tl:"int256"`
+	Index    int64  `tl:"long"`
+	Priority int32  `tl:"int"`
+}
+
+type SetFilePriorityAll struct {
+	Hash     []byte `tl:"int256"`
+	Priority int32  `tl:"int"`
+}
+
 type GetTorrentFull struct {
 	Hash  []byte `tl:"int256"`
 	Flags uint32 `tl:"int"`
@@ -205,6 +218,10 @@ type Torrent struct {
 	FatalError     *string // 2
 
 	Verified bool
+	// FatalErrorAt is unix time when error happened, daemon doesn't report it, so it is when we noticed it
+	FatalErrorAt uint32
+	// Private is set for bags which go through tunnel when only private bags use it, daemon doesn't have it
+	Private bool
 }
 
 type TorrentsList struct {
@@ -222,6 +239,13 @@ var BoolTrue = tl.CRC("boolTrue = Bool")
 
 func (t *Torrent) Parse(data []byte) (_ []byte, err error) {
 	// Manual parse because of not standard array definition
+	defer func() {
+		// tl.FromBytes can panic on broken data
+		if r := recover(); r != nil {
+			err = fmt.Errorf("broken torrent data: %v", r)
+		}
+	}()
+
 	if len(data) < 36 {
 		return nil, fmt.Errorf("too short sizes data to parse")
 	}
@@ -230,6 +254,9 @@ func (t *Torrent) Parse(data []byte) (_ []byte, err error) {
 	t.Flags = binary.LittleEndian.Uint32(data)
 	data = data[4:]
 	if t.Flags&1 != 0 {
+		if err = need(data, 8, "total size"); err != nil {
+			return nil, err
+		}
 		sz := binary.LittleEndian.Uint64(data)
 		data = data[8:]
 
@@ -244,6 +271,9 @@ func (t *Torrent) Parse(data []byte) (_ []byte, err error) {
 		t.Description = &descStr
 	}
 	if t.Flags&2 != 0 {
+		if err = need(data, 16, "files count"); err != nil {
+			return nil, err
+		}
 		filesCnt := binary.LittleEndian.Uint64(data)
 		data = data[8:]
 		incSz := binary.LittleEndian.Uint64(data)
@@ -261,6 +291,9 @@ func (t *Torrent) Parse(data []byte) (_ []byte, err error) {
 		t.DirName = &dirStr
 	}
 
+	if err = need(data, 12, "downloaded size"); err != nil {
+		return nil, err
+	}
 	t.DownloadedSize = binary.LittleEndian.Uint64(data)
 	data = data[8:]
 	t.AddedAt = binary.LittleEndian.Uint32(data)
@@ -269,10 +302,14 @@ func (t *Torrent) Parse(data []byte) (_ []byte, err error) {
 	var rootDir []byte
 	rootDir, data, err = tl.FromBytes(data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse description: %w", err)
+		return nil, fmt.Errorf("failed to parse root dir: %w", err)
 	}
 	t.RootDir = string(rootDir)
 
+	if err = need(data, 28, "state"); err != nil {
+		return nil, err
+	}
+
 	t.ActiveDownload = binary.LittleEndian.Uint32(data) == BoolTrue
 	data = data[4:]
 	t.ActiveUpload = binary.LittleEndian.Uint32(data) == BoolTrue
@@ -289,7 +326,7 @@ func (t *Torrent) Parse(data []byte) (_ []byte, err error) {
 		var fatalErr []byte
 		fatalErr, data, err = tl.FromBytes(data)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse dir name: %w", err)
+			return nil, fmt.Errorf("failed to parse fatal error: %w", err)
 		}
 		fatalErrStr := string(fatalErr)
 
@@ -299,6 +336,14 @@ func (t *Torrent) Parse(data []byte) (_ []byte, err error) {
 	return data, nil
 }
 
+// need checks that data has n more bytes, daemon responses and meta files are not trusted
+func need(data []byte, n int, what string) error {
+	if len(data) < n {
+		return fmt.Errorf("too short data to parse %s", what)
+	}
+	return nil
+}
+
 func (t *Torrent) Serialize(buf *bytes.Buffer) error {
 	return fmt.Errorf("not implemented")
 }
@@ -318,6 +363,27 @@ func (d *Double) Serialize(buf *bytes.Buffer) error {
 	return nil
 }
 
+// parseBOC checks counters of boc header before loading it, library allocates memory by them before reading data
+func parseBOC(data []byte) (*cell.Cell, error) {
+	if len(data) < 6 {
+		return nil, fmt.Errorf("too short boc")
+	}
+	sz := int(data[4] & 7)
+	if sz == 0 || sz > 4 || data[5] > 8 || len(data) < 6+2*sz {
+		return nil, fmt.Errorf("invalid boc header")
+	}
+
+	var cells, roots int
+	for i := 0; i < sz; i++ {
+		cells = cells<<8 | int(data[6+i])
+		roots = roots<<8 | int(data[6+sz+i])
+	}
+	if roots == 0 || roots > cells || cells > len(data) {
+		return nil, fmt.Errorf("invalid boc cells count")
+	}
+	return cell.FromBOC(data)
+}
+
 func (d *MetaFile) Parse(data []byte) (_ []byte, err error) {
 	// torrent_file#6a7181e0 flags:(## 32) info_boc_size:uint32
 	//   root_proof_boc_size:flags.0?uint32
@@ -325,18 +391,30 @@ func (d *MetaFile) Parse(data []byte) (_ []byte, err error) {
 	//   root_proof_boc:flags.0?(root_proof_boc_size * [uint8])
 	//   header:flags.1?TorrentHeader = TorrentMeta;
 
+	defer func() {
+		// boc parser of library can panic on broken data, meta files come from anywhere
+		if r := recover(); r != nil {
+			err = fmt.Errorf("broken meta file: %v", r)
+		}
+	}()
+
 	if len(data) < 8 {
 		return nil, fmt.Errorf("too short data")
 	}
 
 	flags := binary.LittleEndian.Uint32(data)
 	data = data[4:]
+	if flags&^3 != 0 {
+		return nil, fmt.Errorf("unknown flags %d", flags)
+	}
 	infoSz := binary.LittleEndian.Uint32(data)
 	data = data[4:]
 
 	var rootProofSz uint32
 	if flags&1 > 0 {
-		// skip
+		if err = need(data, 4, "root proof size"); err != nil {
+			return nil, err
+		}
 		rootProofSz = binary.LittleEndian.Uint32(data)
 		data = data[4:]
 	}
@@ -344,7 +422,7 @@ func (d *MetaFile) Parse(data []byte) (_ []byte, err error) {
 		return nil, fmt.Errorf("too short info")
 	}
 
-	info, err := cell.FromBOC(data[:infoSz])
+	info, err := parseBOC(data[:infoSz])
 	if err != nil {
 		return nil, fmt.Errorf("failed to load info cell: %w", err)
 	}
@@ -361,9 +439,9 @@ func (d *MetaFile) Parse(data []byte) (_ []byte, err error) {
 			return nil, fmt.Errorf("invalid root proof")
 		}
 
-		proof, err := cell.FromBOC(data[:rootProofSz])
+		proof, err := parseBOC(data[:rootProofSz])
 		if err != nil {
-			return nil, fmt.Errorf("failed to load info cell: %w", err)
+			return nil, fmt.Errorf("failed to load root proof cell: %w", err)
 		}
 		data = data[rootProofSz:]
 		d.RootProof = proof
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/xssnick/tonutils-go/tl"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"github.com/xssnick/tonutils-storage/storage"
+)
+
+func FuzzMetaFileParse(f *testing.F) {
+	root := cell.BeginCell().MustStoreUInt(1, 8).EndCell()
+	proof, err := root.CreateProof(cell.CreateProofSkeleton())
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	tor := storage.NewTorrent("", nil, nil)
+	tor.SetInfoStats(1024, nil, root.Hash(), 3000, 100, "fuzz")
+	header := &storage.TorrentHeader{
+		FilesCount:    1,
+		TotalNameSize: 5,
+		Names:         []byte("a.txt"),
+		NameIndex:     []uint64{5},
+		DataIndex:     []uint64{2900},
+	}
+
+	for _, mf := range []MetaFile{
+		{Info: *tor.Info},
+		{Info: *tor.Info, RootProof: proof},
+		{Info: *tor.Info, RootProof: proof, Header: header},
+	} {
+		data, err := tl.Serialize(mf, false)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var mf MetaFile
+		if _, err := mf.Parse(data); err != nil {
+			return
+		}
+		if len(mf.Hash) != 32 {
+			t.Fatalf("parsed meta has bag id of %d bytes", len(mf.Hash))
+		}
+
+		var buf bytes.Buffer
+		if err := mf.Serialize(&buf); err != nil {
+			t.Fatal("failed to serialize parsed meta:", err)
+		}
+
+		var again MetaFile
+		if _, err := again.Parse(buf.Bytes()); err != nil {
+			t.Fatal("serialized meta is not parsed:", err)
+		}
+		if !bytes.Equal(again.Hash, mf.Hash) {
+			t.Fatal("bag id is changed after serialization")
+		}
+	})
+}
+
+// torrentData serializes torrent the way daemon does
+func torrentData(flags uint32, fatal string) []byte {
+	u32 := func(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+	u64 := func(v uint64) []byte { return binary.LittleEndian.AppendUint64(nil, v) }
+
+	data := append(make([]byte, 32), u32(flags)...)
+	if flags&1 != 0 {
+		data = append(data, u64(3000)...)
+		data = append(data, tl.ToBytes([]byte("description"))...)
+	}
+	if flags&2 != 0 {
+		data = append(data, u64(2)...)
+		data = append(data, u64(2900)...)
+		data = append(data, tl.ToBytes([]byte("dir"))...)
+	}
+	data = append(data, u64(1500)...)
+	data = append(data, u32(1700000000)...)
+	data = append(data, tl.ToBytes([]byte("/root/dir"))...)
+	data = append(data, u32(BoolTrue)...)
+	data = append(data, u32(BoolTrue)...)
+	data = append(data, u32(0)...)
+	data = append(data, u64(math.Float64bits(1.5))...)
+	data = append(data, u64(math.Float64bits(0))...)
+	if flags&4 != 0 {
+		data = append(data, tl.ToBytes([]byte(fatal))...)
+	}
+	return data
+}
+
+func TestTorrentParse(t *testing.T) {
+	var tr Torrent
+	rest, err := tr.Parse(torrentData(7, "disk is full"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 0 {
+		t.Fatalf("%d bytes left after parse", len(rest))
+	}
+	if tr.Description == nil || *tr.Description != "description" || tr.DirName == nil || *tr.DirName != "dir" {
+		t.Fatal("optional fields are not parsed")
+	}
+	if tr.RootDir != "/root/dir" || !tr.ActiveDownload || tr.Completed || tr.DownloadSpeed != 1.5 {
+		t.Fatal("state is not parsed")
+	}
+	if tr.FatalError == nil || *tr.FatalError != "disk is full" {
+		t.Fatal("fatal error is not parsed")
+	}
+
+	full := torrentData(3, "")
+	for i := 0; i < len(full); i++ {
+		if _, err = new(Torrent).Parse(full[:i]); err == nil {
+			t.Fatalf("truncated data of %d bytes is parsed", i)
+		}
+	}
+}
+
+func FuzzTorrentParse(f *testing.F) {
+	for flags := uint32(0); flags < 8; flags++ {
+		f.Add(torrentData(flags, "error"))
+	}
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var tr Torrent
+		rest, err := tr.Parse(data)
+		if err != nil {
+			return
+		}
+		if len(rest) > len(data) {
+			t.Fatal("parse returned more data than it got")
+		}
+	})
+}
@@ -2,8 +2,18 @@
 
 package daemon
 
-import "syscall"
+import (
+	"os"
+	"syscall"
+)
 
 func daemonAttr() *syscall.SysProcAttr {
 	return &syscall.SysProcAttr{}
 }
+
+func terminate(p *os.Process) error {
+	if p == nil {
+		return nil
+	}
+	return p.Signal(syscall.SIGTERM)
+}
@@ -2,8 +2,19 @@
 
 package daemon
 
-import "syscall"
+import (
+	"os"
+	"syscall"
+)
 
 func daemonAttr() *syscall.SysProcAttr {
 	return &syscall.SysProcAttr{HideWindow: true}
 }
+
+func terminate(p *os.Process) error {
+	if p == nil {
+		return nil
+	}
+	// no SIGTERM on windows
+	return p.Kill()
+}
@@ -1,7 +1,6 @@
 package daemon
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -12,40 +11,119 @@ import (
 	"os/exec"
 	"runtime"
 	"strings"
+	"time"
 )
 
+const NetworkConfigURL = "https://ton-blockchain.github.io/global.config.json"
+
+// NetworkConfigMaxAge is how long downloaded network config is considered fresh
+var NetworkConfigMaxAge = 24 * time.Hour
+
+const DefaultStopTimeout = 10 * time.Second
+
 func Run(ctx context.Context, root, path string, listen, controlPort string, onFinish func(error)) (*os.Process, error) {
+	if err := prepareRoot(ctx, root); err != nil {
+		return nil, err
+	}
+
+	errLogs := NewLogBuffer(500)
+	cmd := newCommand(ctx, root, path, listen, controlPort, errLogs, DefaultStopTimeout)
+
+	if err := cmd.Start(); err != nil {
+		switch e := err.(type) {
+		case *exec.Error:
+			fmt.Println("failed executing:", err)
+		case *exec.ExitError:
+			fmt.Println("command exit rc =", e.ExitCode())
+		default:
+			return nil, err
+		}
+	}
+
+	go func() {
+		err := cmd.Wait()
+		if err != nil {
+			reason := errLogs.String()
+			reason += " | Exit code: " + err.Error()
+			err = errors.New(reason)
+		}
+		onFinish(err)
+	}()
+
+	return cmd.Process, nil
+}
+
+func prepareRoot(ctx context.Context, root string) error {
 	dbPath := root + "/storage-db"
 	_, err := os.Stat(dbPath)
 	if os.IsNotExist(err) {
 		err = os.MkdirAll(dbPath, os.ModePerm)
 	}
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	netConfigPath := root + "/global.config.json"
-	_, err = os.Stat(netConfigPath)
-	if os.IsNotExist(err) { // download network config if not exists
-		resp, err := http.Get("https://ton-blockchain.github.io/global.config.json")
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
+	return refreshNetworkConfig(ctx, root+"/global.config.json", NetworkConfigMaxAge)
+}
 
-		cfgData, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
+// refreshNetworkConfig downloads network config when it is missing or older than maxAge,
+// stale config is kept when download fails.
+func refreshNetworkConfig(ctx context.Context, netConfigPath string, maxAge time.Duration) error {
+	fi, err := os.Stat(netConfigPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
 
-		err = os.WriteFile(netConfigPath, cfgData, 0644)
-		if err != nil {
-			return nil, err
+	exists := err == nil
+	if exists && time.Since(fi.ModTime()) < maxAge {
+		return nil
+	}
+
+	if err = downloadNetworkConfig(ctx, netConfigPath); err != nil {
+		if exists {
+			log.Println("failed to refresh network config, using stale one:", err.Error())
+			return nil
 		}
-	} else if err != nil {
-		return nil, err
+		return err
+	}
+	return nil
+}
+
+func downloadNetworkConfig(ctx context.Context, netConfigPath string) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, NetworkConfigURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	cfgData, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return err
+	}
+
+	// write to temp file first to not leave broken config on failure
+	tmp := netConfigPath + ".tmp"
+	if err = os.WriteFile(tmp, cfgData, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, netConfigPath)
+}
+
+// newCommand prepares daemon process, when ctx is canceled it is asked to terminate,
+// and killed if it is still alive after stopTimeout
+func newCommand(ctx context.Context, root, path, listen, controlPort string, logs io.Writer, stopTimeout time.Duration) *exec.Cmd {
 	args := []string{"-v", "1", "-C", "global.config.json", "-I", listen, "-p", controlPort, "-D", "storage-db"}
 
 	var cmd *exec.Cmd
@@ -57,35 +135,15 @@ func Run(ctx context.Context, root, path string, listen, controlPort string, onF
 		cmd = exec.CommandContext(ctx, path+"/storage-daemon", args...)
 	}
 
-	errLogs := &bytes.Buffer{}
-
 	log.Println("command: ", cmd.String())
 
 	cmd.Dir = root
 	cmd.SysProcAttr = daemonAttr()
-	cmd.Stdout = io.MultiWriter(os.Stdout, errLogs)
-	cmd.Stderr = io.MultiWriter(os.Stderr, errLogs)
-
-	if err := cmd.Start(); err != nil {
-		switch e := err.(type) {
-		case *exec.Error:
-			fmt.Println("failed executing:", err)
-		case *exec.ExitError:
-			fmt.Println("command exit rc =", e.ExitCode())
-		default:
-			return nil, err
-		}
+	cmd.Stdout = io.MultiWriter(os.Stdout, logs)
+	cmd.Stderr = io.MultiWriter(os.Stderr, logs)
+	cmd.Cancel = func() error {
+		return terminate(cmd.Process)
 	}
-
-	go func() {
-		err := cmd.Wait()
-		if err != nil {
-			reason := errLogs.String()
-			reason += " | Exit code: " + err.Error()
-			err = errors.New(reason)
-		}
-		onFinish(err)
-	}()
-
-	return cmd.Process, nil
+	cmd.WaitDelay = stopTimeout
+	return cmd
 }
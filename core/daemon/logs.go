@@ -0,0 +1,83 @@
+package daemon
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// LogBuffer is a bounded io.Writer which keeps only the last lines written to it
+type LogBuffer struct {
+	lines   []string
+	start   int
+	partial []byte
+	max     int
+
+	mx sync.Mutex
+}
+
+func NewLogBuffer(maxLines int) *LogBuffer {
+	if maxLines <= 0 {
+		maxLines = 1
+	}
+	return &LogBuffer{
+		lines: make([]string, 0, maxLines),
+		max:   maxLines,
+	}
+}
+
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	data := p
+	for {
+		idx := bytes.IndexByte(data, '\n')
+		if idx == -1 {
+			b.partial = append(b.partial, data...)
+			if len(b.partial) > 64<<10 {
+				// too long line without break, flush it to not grow forever
+				b.push(string(b.partial))
+				b.partial = b.partial[:0]
+			}
+			break
+		}
+
+		line := string(append(b.partial, data[:idx]...))
+		b.partial = b.partial[:0]
+		b.push(strings.TrimRight(line, "\r"))
+		data = data[idx+1:]
+	}
+	return len(p), nil
+}
+
+func (b *LogBuffer) push(line string) {
+	if len(b.lines) < b.max {
+		b.lines = append(b.lines, line)
+		return
+	}
+	b.lines[b.start] = line
+	b.start = (b.start + 1) % b.max
+}
+
+// Lines returns up to n last lines in the order they were written, n <= 0 means all
+func (b *LogBuffer) Lines(n int) []string {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	res := make([]string, 0, len(b.lines)+1)
+	res = append(res, b.lines[b.start:]...)
+	res = append(res, b.lines[:b.start]...)
+	if len(b.partial) > 0 {
+		res = append(res, string(b.partial))
+	}
+
+	if n > 0 && len(res) > n {
+		res = res[len(res)-n:]
+	}
+	return res
+}
+
+func (b *LogBuffer) String() string {
+	return strings.Join(b.Lines(0), "\n")
+}
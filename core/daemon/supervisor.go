@@ -0,0 +1,307 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/tonutils/torrent-client/core/client"
+)
+
+type SupervisorConfig struct {
+	Root        string
+	Path        string
+	Listen      string
+	ControlPort string
+
+	// MinBackoff and MaxBackoff limit delay between restarts, delay is doubled after each crash
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// StableAfter is how long process should live to reset backoff
+	StableAfter time.Duration
+
+	HealthCheckInterval time.Duration
+	// HealthCheckFailures in a row after which process is considered hung and restarted
+	HealthCheckFailures int
+
+	StopTimeout time.Duration
+	LogLines    int
+}
+
+type Status struct {
+	Running   bool
+	PID       int
+	Restarts  int
+	StartedAt time.Time
+	LastError string
+}
+
+// Supervisor keeps storage daemon running, restarts it on crash or when it stops responding
+type Supervisor struct {
+	cfg  SupervisorConfig
+	logs *LogBuffer
+
+	onExit  func(error)
+	onStart func()
+
+	client *client.StorageClient
+	status Status
+
+	stop context.CancelFunc
+	done chan struct{}
+
+	mx sync.RWMutex
+}
+
+func NewSupervisor(cfg SupervisorConfig) *Supervisor {
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = 1 * time.Second
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = 1 * time.Minute
+	}
+	if cfg.StableAfter <= 0 {
+		cfg.StableAfter = 1 * time.Minute
+	}
+	if cfg.HealthCheckInterval <= 0 {
+		cfg.HealthCheckInterval = 10 * time.Second
+	}
+	if cfg.HealthCheckFailures <= 0 {
+		cfg.HealthCheckFailures = 3
+	}
+	if cfg.StopTimeout <= 0 {
+		cfg.StopTimeout = DefaultStopTimeout
+	}
+	if cfg.LogLines <= 0 {
+		cfg.LogLines = 2000
+	}
+
+	return &Supervisor{
+		cfg:  cfg,
+		logs: NewLogBuffer(cfg.LogLines),
+	}
+}
+
+// SetOnExit sets handler called every time process exits, err is nil only on requested stop
+func (s *Supervisor) SetOnExit(handler func(err error)) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.onExit = handler
+}
+
+// SetOnStart sets handler called every time process is (re)started
+func (s *Supervisor) SetOnStart(handler func()) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.onStart = handler
+}
+
+func (s *Supervisor) Start(ctx context.Context) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.done != nil {
+		return fmt.Errorf("already started")
+	}
+
+	if err := prepareRoot(ctx, s.cfg.Root); err != nil {
+		return fmt.Errorf("failed to prepare daemon root: %w", err)
+	}
+
+	ctx, s.stop = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	go s.loop(ctx)
+
+	return nil
+}
+
+// Stop asks process to terminate, kills it after timeout and waits for exit
+func (s *Supervisor) Stop() {
+	s.mx.RLock()
+	stop, done := s.stop, s.done
+	s.mx.RUnlock()
+
+	if stop == nil {
+		return
+	}
+	stop()
+	<-done
+}
+
+// Logs returns up to n last lines of daemon stdout and stderr, n <= 0 means all kept lines
+func (s *Supervisor) Logs(n int) []string {
+	return s.logs.Lines(n)
+}
+
+func (s *Supervisor) Status() Status {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	return s.status
+}
+
+// Client returns connection to running daemon, or nil when it is not connected yet
+func (s *Supervisor) Client() *client.StorageClient {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	return s.client
+}
+
+func (s *Supervisor) loop(ctx context.Context) {
+	defer close(s.done)
+
+	backoff := s.cfg.MinBackoff
+	for {
+		startedAt := time.Now()
+		err := s.runOnce(ctx)
+
+		s.mx.Lock()
+		s.status.Running = false
+		s.status.PID = 0
+		if s.client != nil {
+			s.client.Close()
+			s.client = nil
+		}
+		onExit := s.onExit
+		s.mx.Unlock()
+
+		if ctx.Err() != nil {
+			if onExit != nil {
+				onExit(nil)
+			}
+			return
+		}
+
+		if err == nil {
+			err = errors.New("exited unexpectedly")
+		}
+		err = fmt.Errorf("storage daemon: %w | Last logs: %s", err, s.logs.String())
+
+		s.mx.Lock()
+		s.status.LastError = err.Error()
+		s.status.Restarts++
+		s.mx.Unlock()
+
+		if onExit != nil {
+			onExit(err)
+		}
+
+		if time.Since(startedAt) > s.cfg.StableAfter {
+			backoff = s.cfg.MinBackoff
+		}
+
+		log.Println("storage daemon stopped, restarting in", backoff.String())
+		select {
+		case <-ctx.Done():
+			if onExit != nil {
+				onExit(nil)
+			}
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > s.cfg.MaxBackoff {
+			backoff = s.cfg.MaxBackoff
+		}
+	}
+}
+
+func (s *Supervisor) runOnce(ctx context.Context) error {
+	// config can become stale while we are running for a long time
+	if err := refreshNetworkConfig(ctx, s.cfg.Root+"/global.config.json", NetworkConfigMaxAge); err != nil {
+		log.Println("failed to refresh network config:", err.Error())
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := newCommand(runCtx, s.cfg.Root, s.cfg.Path, s.cfg.Listen, s.cfg.ControlPort, s.logs, s.cfg.StopTimeout)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start: %w", err)
+	}
+
+	s.mx.Lock()
+	s.status.Running = true
+	s.status.PID = cmd.Process.Pid
+	s.status.StartedAt = time.Now()
+	onStart := s.onStart
+	s.mx.Unlock()
+
+	if onStart != nil {
+		onStart()
+	}
+
+	go s.healthCheck(runCtx, cancel)
+
+	return cmd.Wait()
+}
+
+// healthCheck pings daemon and terminates it when it stops responding, so loop will restart it
+func (s *Supervisor) healthCheck(ctx context.Context, restart context.CancelFunc) {
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.cfg.HealthCheckInterval):
+		}
+
+		if err := s.ping(ctx); err != nil {
+			failures++
+			log.Println("storage daemon health check failed:", err.Error(), "attempt", failures)
+
+			if failures >= s.cfg.HealthCheckFailures {
+				log.Println("storage daemon is not responding, restarting")
+				restart()
+				return
+			}
+			continue
+		}
+		failures = 0
+	}
+}
+
+func (s *Supervisor) ping(ctx context.Context) error {
+	cl := s.Client()
+	if cl == nil {
+		var err error
+		if cl, err = s.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := cl.GetSpeedLimits(ctx)
+	return err
+}
+
+// connect opens connection to running daemon, previous one is closed
+func (s *Supervisor) connect(ctx context.Context) (*client.StorageClient, error) {
+	cl, err := client.ConnectToStorageDaemon(ctx, "127.0.0.1:"+s.cfg.ControlPort, s.cfg.Root+"/storage-db")
+	if err != nil {
+		return nil, err
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if ctx.Err() != nil {
+		// process exited while we were connecting, loop already dropped its connection
+		cl.Close()
+		return nil, ctx.Err()
+	}
+	if s.client != nil {
+		s.client.Close()
+	}
+	s.client = cl
+	return cl, nil
+}
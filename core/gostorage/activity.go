@@ -0,0 +1,182 @@
+package gostorage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/xssnick/tonutils-storage/storage"
+)
+
+// activity is stored only when download and upload of bag are controlled separately,
+// library can't pause download of started bag, so for seed only mode we restrict
+// active files to already completed ones and keep the real selection here.
+type activity struct {
+	Download bool
+	Upload   bool
+
+	// Files selected by user, set when download is paused but bag is seeding
+	Files []uint32
+}
+
+func activityKey(bagId []byte) []byte {
+	return append([]byte("tt_activity:"), bagId...)
+}
+
+func (c *Client) getActivity(bagId []byte) *activity {
+	c.activityMx.Lock()
+	defer c.activityMx.Unlock()
+
+	if a, ok := c.activity[string(bagId)]; ok {
+		return a
+	}
+
+	var a *activity
+	data, err := c.db.Get(activityKey(bagId), nil)
+	if err == nil {
+		var stored activity
+		if err = json.Unmarshal(data, &stored); err == nil {
+			a = &stored
+		}
+	}
+	if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+		log.Error().Err(err).Msg("failed to load bag activity")
+	}
+
+	c.activity[string(bagId)] = a
+	return a
+}
+
+func (c *Client) setActivity(bagId []byte, a *activity) error {
+	c.activityMx.Lock()
+	defer c.activityMx.Unlock()
+
+	if a == nil || (a.Download && a.Upload && a.Files == nil) {
+		// regular mode, nothing to remember
+		c.activity[string(bagId)] = nil
+		return c.db.Delete(activityKey(bagId), nil)
+	}
+
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	c.activity[string(bagId)] = a
+	return c.db.Put(activityKey(bagId), data, nil)
+}
+
+// selectedFiles returns files chosen by user, which can differ from active in seed only mode
+func (c *Client) selectedFiles(t *storage.Torrent) []uint32 {
+	if a := c.getActivity(t.BagID); a != nil && a.Files != nil {
+		return a.Files
+	}
+	return t.GetActiveFilesIDs()
+}
+
+func (c *Client) applyActivity(t *storage.Torrent, download, upload bool) error {
+	a := c.getActivity(t.BagID)
+	if a == nil {
+		a = &activity{}
+	} else {
+		cp := *a
+		a = &cp
+	}
+
+	switch {
+	case !download && !upload:
+		t.Stop()
+	case download:
+		if err := t.Start(upload, false, false); err != nil {
+			return err
+		}
+
+		if a.Files != nil {
+			if err := t.SetActiveFilesIDs(a.Files); err != nil {
+				return fmt.Errorf("failed to restore selected files: %w", err)
+			}
+			a.Files = nil
+		}
+	default:
+		if t.Header == nil {
+			return fmt.Errorf("bag header is not downloaded yet, nothing to seed")
+		}
+
+		if a.Files == nil {
+			a.Files = append([]uint32{}, t.GetActiveFilesIDs()...)
+		}
+
+		if err := t.Start(true, false, false); err != nil {
+			return err
+		}
+
+		// keep only completed files active, to not fetch anything new
+		if err := t.SetActiveFilesIDs(completedFiles(t, a.Files)); err != nil {
+			return fmt.Errorf("failed to freeze files: %w", err)
+		}
+	}
+
+	c.setExpectedActive(t.BagID, download || upload)
+
+	a.Download, a.Upload = download, upload
+	if err := c.setActivity(t.BagID, a); err != nil {
+		return fmt.Errorf("failed to save activity: %w", err)
+	}
+	return c.storage.SetTorrent(t)
+}
+
+func completedFiles(t *storage.Torrent, ids []uint32) []uint32 {
+	mask := t.PiecesMask()
+
+	res := make([]uint32, 0, len(ids))
+next:
+	for _, id := range ids {
+		fi, err := t.GetFileOffsetsByID(id)
+		if err != nil {
+			continue
+		}
+
+		for y := fi.FromPiece; y <= fi.ToPiece; y++ {
+			if int(y/8) >= len(mask) || mask[y/8]&(1<<(y%8)) == 0 {
+				continue next
+			}
+		}
+		res = append(res, id)
+	}
+	return res
+}
+
+func (c *Client) SetActiveDownload(ctx context.Context, hash []byte, active bool) error {
+	t := c.storage.GetTorrent(hash)
+	if t == nil {
+		return fmt.Errorf("torrent is not found")
+	}
+
+	_, upload := c.isActive(t)
+	return c.applyActivity(t, active, upload)
+}
+
+func (c *Client) SetActiveUpload(ctx context.Context, hash []byte, active bool) error {
+	t := c.storage.GetTorrent(hash)
+	if t == nil {
+		return fmt.Errorf("torrent is not found")
+	}
+
+	download, _ := c.isActive(t)
+	return c.applyActivity(t, download, active)
+}
+
+// isActive reports activity as user sees it, not as library does
+func (c *Client) isActive(t *storage.Torrent) (download, upload bool) {
+	download, upload = t.IsActiveRaw()
+	if !download {
+		return false, false
+	}
+
+	if a := c.getActivity(t.BagID); a != nil {
+		download = a.Download
+	}
+	return download, upload
+}
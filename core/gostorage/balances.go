@@ -0,0 +1,68 @@
+package gostorage
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/tonutils/torrent-client/core/client"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/ton/jetton"
+)
+
+// GetWalletBalances fetches balances of wallet which pays for tunnel, jettons are keyed by bounceable master address
+func (c *Client) GetWalletBalances(ctx context.Context, owner *address.Address, jettons []*address.Address) (*client.WalletBalances, error) {
+	blk, err := c.api.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get masterchain info: %w", err)
+	}
+
+	acc, err := c.api.WaitForBlock(blk.SeqNo).GetAccount(ctx, blk, owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet account: %w", err)
+	}
+
+	res := &client.WalletBalances{
+		Ton:             big.NewInt(0),
+		Jettons:         map[string]*big.Int{},
+		ExtraCurrencies: map[uint32]*big.Int{},
+	}
+
+	if acc.IsActive && acc.State != nil {
+		res.Ton = acc.State.Balance.Nano()
+
+		if !acc.State.ExtraCurrencies.IsEmpty() {
+			kvs, err := acc.State.ExtraCurrencies.LoadAll()
+			if err != nil {
+				return nil, fmt.Errorf("failed to load extra currencies: %w", err)
+			}
+
+			for _, kv := range kvs {
+				id, err := kv.Key.LoadUInt(32)
+				if err != nil {
+					return nil, fmt.Errorf("failed to load extra currency id: %w", err)
+				}
+				amt, err := kv.Value.LoadVarUInt(32)
+				if err != nil {
+					return nil, fmt.Errorf("failed to load extra currency %d amount: %w", id, err)
+				}
+				res.ExtraCurrencies[uint32(id)] = amt
+			}
+		}
+	}
+
+	for _, master := range jettons {
+		w, err := jetton.NewJettonMasterClient(c.api, master).GetJettonWallet(ctx, owner)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get jetton wallet of %s: %w", master.String(), err)
+		}
+
+		amt, err := w.GetBalance(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get jetton balance of %s: %w", master.String(), err)
+		}
+		res.Jettons[master.Bounce(true).String()] = amt
+	}
+
+	return res, nil
+}
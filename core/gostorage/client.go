@@ -5,7 +5,6 @@ import (
 	"context"
 	"crypto/ed25519"
 	"crypto/sha256"
-	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -15,6 +14,7 @@ import (
 	tunnelConfig "github.com/ton-blockchain/adnl-tunnel/config"
 	"github.com/ton-blockchain/adnl-tunnel/tunnel"
 	"github.com/tonutils/torrent-client/core/client"
+	"github.com/tonutils/torrent-client/core/tonbag"
 	"github.com/xssnick/tonutils-go/address"
 	"github.com/xssnick/tonutils-go/adnl"
 	adnlAddress "github.com/xssnick/tonutils-go/adnl/address"
@@ -31,8 +31,10 @@ import (
 	"net"
 	"net/netip"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
+	"sync"
 	"time"
 )
 
@@ -43,6 +45,15 @@ type Config struct {
 	DownloadsPath string
 
 	NetworkConfigPath string
+
+	// ExcludedTunnelNodes are keys of nodes which are removed from pool, to not be used in route
+	ExcludedTunnelNodes [][]byte
+	// PinnedTunnelNodes are keys of nodes which must be in route, pool is reduced to make it so
+	PinnedTunnelNodes [][]byte
+	// TunnelRouting selects traffic which goes through tunnel, one of Route constants
+	TunnelRouting string
+	// TunnelRequired blocks storage data until tunnel is built, instead of serving bags directly with one-time key meanwhile
+	TunnelRequired bool
 }
 
 type Client struct {
@@ -50,13 +61,57 @@ type Client struct {
 	srv       *storage.Server
 	connector storage.NetConnector
 	provider  *provider.Client
+	api       ton.APIClientWrapped
+	db        *leveldb.DB
+
+	activity   map[string]*activity
+	activityMx sync.Mutex
+
+	errors     map[string]*bagError
+	health     map[string]*bagHealth
+	rechecking map[string]bool
+	healthMx   sync.Mutex
+
+	// hashingPath is where checkpoints of bags creation are stored
+	hashingPath string
+
+	// tunnels are alive tunnels, paidBase is what was paid for closed ones
+	tunnels  []*tunnel.RegularOutTunnel
+	paidBase map[string]tlb.Coins
+	// routes are routes of tunnels, the last one is current, traffic is counted for all of them
+	routes    []*tunnelRoute
+	accepted  []client.TunnelSection
+	traffic   *countingConn
+	prober    *prober
+	tunnelsMx sync.Mutex
+
+	// payments is nil when tunnel payments are not enabled
+	payments *paymentsSnapshot
+
+	// onRouteHealth is called with current route every time its health is measured
+	onRouteHealth func(route client.TunnelRoute)
+
+	// routing is TunnelRouting used by running tunnel, private is route of bags for RoutePrivateOnly
+	routing   string
+	private   map[string]bool
+	privateMx sync.Mutex
 
 	notify chan bool
 }
 
-func NewClient(globalCtx context.Context, dbPath string, cfg Config, tunCfg *tunnelConfig.ClientConfig, onTunnel func(addr string), onStopped func(), tunAcceptor func(to, from []*tunnel.SectionInfo) int, reRouter func() bool, reportLoadingState func(string), onPaidUpdate func(coins tlb.Coins)) (*Client, error) {
+func NewClient(globalCtx context.Context, dbPath string, cfg Config, tunCfg *tunnelConfig.ClientConfig, onTunnel func(addr string), onStopped func(), tunAcceptor func(to, from []*tunnel.SectionInfo) int, reRouter func() bool, reportLoadingState func(string), onPaidUpdate func(paid map[string]tlb.Coins), onRouteHealth func(route client.TunnelRoute), onTunnelFailed func(err error, wasReady bool)) (*Client, error) {
 	c := &Client{
-		notify: make(chan bool, 1), // to refresh fast a bit after
+		onRouteHealth: onRouteHealth,
+
+		notify:   make(chan bool, 1), // to refresh fast a bit after
+		activity: map[string]*activity{},
+		errors:   map[string]*bagError{},
+		health:   map[string]*bagHealth{},
+		private:  map[string]bool{},
+
+		rechecking: map[string]bool{},
+
+		hashingPath: filepath.Join(filepath.Dir(dbPath), "tonutils-storage-hashing"),
 	}
 
 	closerCtx, closerCancel := context.WithCancel(globalCtx)
@@ -149,8 +204,16 @@ func NewClient(globalCtx context.Context, dbPath string, cfg Config, tunCfg *tun
 		}
 	}()
 
+	listenThreads := runtime.NumCPU()
+	if listenThreads > 32 {
+		listenThreads = 32
+	}
+
 	var gate *adnl.Gateway
 	var netMgr adnl.NetManager
+	// sw is switched once to tunnel, bags are served by pre until then
+	var sw *switchableConn
+	var pre *preTunnel
 	if tunCfg != nil && tunCfg.NodesPoolConfigPath != "" {
 		reportLoadingState("Preparing ADNL tunnel...")
 
@@ -164,16 +227,68 @@ func NewClient(globalCtx context.Context, dbPath string, cfg Config, tunCfg *tun
 			return nil, fmt.Errorf("failed to parse tunnel nodes pool config: %w", err)
 		}
 
+		if len(cfg.ExcludedTunnelNodes) > 0 {
+			tunNodesCfg.NodesPool = excludeNodes(tunNodesCfg.NodesPool, cfg.ExcludedTunnelNodes)
+			if len(tunNodesCfg.NodesPool) == 0 {
+				return nil, fmt.Errorf("tunnel preparation failed: all nodes of pool are excluded")
+			}
+		}
+
+		if tunCfg.PaymentsEnabled {
+			c.payments = &paymentsSnapshot{
+				path: tunCfg.Payments.DBPath,
+				key:  ed25519.NewKeyFromSeed(tunCfg.Payments.PaymentsNodeKey).Public().(ed25519.PublicKey),
+			}
+			toClose = append(toClose, c.payments.Close)
+		}
+
+		pinned := len(cfg.PinnedTunnelNodes) > 0
+		if pinned {
+			tunNodesCfg.NodesPool, err = pinNodes(tunNodesCfg.NodesPool, cfg.PinnedTunnelNodes, tunCfg.TunnelSectionsNum, tunCfg.PaymentsEnabled)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// storage starts before tunnel is built and is switched to it when it is ready,
+		// bags go directly meanwhile with one-time key, or wait for tunnel when it is required
+		if !cfg.TunnelRequired && cfg.TunnelRouting != RoutePrivateOnly {
+			pre, err = startPreTunnel(lsCfg, listenThreads)
+			if err != nil {
+				return nil, err
+			}
+			toClose = append(toClose, pre.close)
+		}
+
+		atm := &tunnel.AtomicSwitchableRegularTunnel{}
+		traffic := &countingConn{PacketConn: atm}
+		c.tunnelsMx.Lock()
+		c.traffic = traffic
+		c.tunnelsMx.Unlock()
+
+		sw = newSwitchableConn(traffic)
+		netMgr = adnl.NewMultiNetReader(sw)
+		gate = adnl.NewGatewayWithNetManager(cfg.Key, netMgr)
+		// no address until tunnel gives it
+		gate.SetAddressList([]*adnlAddress.UDP{})
+
 		tunnel.AskReroute = reRouter
-		tunnel.Acceptor = tunAcceptor
+		tunnel.Acceptor = func(to, from []*tunnel.SectionInfo) int {
+			decision := tunAcceptor(to, from)
+			if decision == tunnel.AcceptorDecisionAccept {
+				c.tunnelsMx.Lock()
+				c.accepted = routeSections(to, from)
+				c.tunnelsMx.Unlock()
+			}
+			return decision
+		}
 		events := make(chan any, 1)
 		go tunnel.RunTunnel(closerCtx, tunCfg, &tunNodesCfg, lsCfg, log.Logger, events)
 		tunnelInitialized = true
 
-		initUpd := make(chan any, 1)
-		inited := false
 		go func() {
-			atm := &tunnel.AtomicSwitchableRegularTunnel{}
+			// library tries routes endlessly, with pinned nodes it can't find other ones
+			pinnedFails := 0
 			for event := range events {
 				switch e := event.(type) {
 				case tunnel.StoppedEvent:
@@ -183,8 +298,11 @@ func NewClient(globalCtx context.Context, dbPath string, cfg Config, tunCfg *tun
 					reportLoadingState(e.Msg)
 				case tunnel.UpdatedEvent:
 					log.Info().Msg("tunnel updated")
+					pinnedFails = 0
 
+					tun := e.Tunnel
 					e.Tunnel.SetOutAddressChangedHandler(func(addr *net.UDPAddr) {
+						c.setRouteAddr(tun, addr.String())
 						gate.SetAddressList([]*adnlAddress.UDP{
 							{
 								IP:   addr.IP,
@@ -195,57 +313,57 @@ func NewClient(globalCtx context.Context, dbPath string, cfg Config, tunCfg *tun
 					})
 					onTunnel(fmt.Sprintf("%s:%d", e.ExtIP.String(), e.ExtPort))
 
+					c.addTunnel(e.Tunnel)
+					c.addRoute(e.Tunnel, fmt.Sprintf("%s:%d", e.ExtIP.String(), e.ExtPort))
 					go func() {
 						for {
 							select {
 							case <-e.Tunnel.AliveCtx().Done():
 								return
 							case <-time.After(5 * time.Second):
-								onPaidUpdate(e.Tunnel.CalcPaidAmount()["TON"])
+								onPaidUpdate(c.SessionPaid())
 							}
 						}
 					}()
 
 					atm.SwitchTo(e.Tunnel)
-					if !inited {
-						inited = true
-						netMgr = adnl.NewMultiNetReader(atm)
-						gate = adnl.NewGatewayWithNetManager(cfg.Key, netMgr)
-
-						select {
-						case initUpd <- e:
-						default:
-						}
-					} else {
-						gate.SetAddressList([]*adnlAddress.UDP{
-							{
-								IP:   e.ExtIP,
-								Port: int32(e.ExtPort),
-							},
-						})
+					gate.SetAddressList([]*adnlAddress.UDP{
+						{
+							IP:   e.ExtIP,
+							Port: int32(e.ExtPort),
+						},
+					})
+					if !sw.switched.Load() {
+						sw.switchToTunnel()
 
+						pterm.Info.Println("Using tunnel - IP:", e.ExtIP.String(), " Port:", e.ExtPort)
+						log.Info().Msg("storage switched to tunnel")
+					} else {
 						log.Info().Msg("connection switched to new tunnel")
 					}
 				case tunnel.ConfigurationErrorEvent:
 					log.Err(e.Err).Msg("tunnel configuration error, will retry...")
+					if pinned {
+						if pinnedFails++; pinnedFails == maxPinnedRouteAttempts {
+							go onTunnelFailed(fmt.Errorf("%w: %d routes failed, last error: %v", ErrPinnedRoute, pinnedFails, e.Err), sw.switched.Load())
+						}
+					}
 				case error:
-					select {
-					case initUpd <- e:
-					default:
+					if closerCtx.Err() != nil {
+						// stopped with storage
+						continue
 					}
+					log.Err(e).Msg("tunnel failed")
+					// tunnel is not retried anymore, caller decides what to do with storage
+					go onTunnelFailed(e, sw.switched.Load())
 				}
 			}
 		}()
-
-		switch x := (<-initUpd).(type) {
-		case tunnel.UpdatedEvent:
-			ip = x.ExtIP
-			port = x.ExtPort
-
-			pterm.Info.Println("Using tunnel - IP:", x.ExtIP.String(), " Port:", x.ExtPort)
-		case error:
-			return nil, fmt.Errorf("tunnel preparation failed: %w", x)
-		}
+	} else if cfg.TunnelRequired {
+		// tunnel is required but cannot be built, nothing goes out except what routing sends directly
+		netMgr = adnl.NewMultiNetReader(newSwitchableConn(nil))
+		gate = adnl.NewGatewayWithNetManager(cfg.Key, netMgr)
+		gate.SetAddressList([]*adnlAddress.UDP{})
 	} else {
 		reportLoadingState("Binding UDP port...")
 
@@ -263,13 +381,14 @@ func NewClient(globalCtx context.Context, dbPath string, cfg Config, tunCfg *tun
 
 	reportLoadingState("Starting ADNL server...")
 
-	listenThreads := runtime.NumCPU()
-	if listenThreads > 32 {
-		listenThreads = 32
-	}
-
-	serverMode := ip != nil
-	if serverMode {
+	// with tunnel we are reachable by its address, which is set when tunnel is ready
+	viaTunnel := tunnelInitialized || cfg.TunnelRequired
+	serverMode := tunnelInitialized || (ip != nil && !viaTunnel)
+	if viaTunnel {
+		if err = gate.StartClient(listenThreads); err != nil {
+			return nil, fmt.Errorf("failed to start adnl gateway: %w", err)
+		}
+	} else if serverMode {
 		gate.SetAddressList([]*adnlAddress.UDP{
 			{
 				IP:   ip.To4(),
@@ -285,12 +404,40 @@ func NewClient(globalCtx context.Context, dbPath string, cfg Config, tunCfg *tun
 		}
 	}
 
+	if tunnelInitialized {
+		p, closeProber, err := startProber(netMgr, lsCfg)
+		if err != nil {
+			// route works without it, only round trip, loss and outage are not measured
+			log.Warn().Err(err).Msg("failed to start tunnel route prober")
+		} else {
+			toClose = append(toClose, closeProber)
+			c.tunnelsMx.Lock()
+			c.prober = p
+			c.tunnelsMx.Unlock()
+		}
+	}
+
+	// traffic which routing keeps out of tunnel goes through direct listener
+	directMgr := netMgr
+	if viaTunnel && cfg.TunnelRouting != RouteAll {
+		c.routing = cfg.TunnelRouting
+
+		dl, err := adnl.DefaultListener(cfg.ListenAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create direct listener: %w", err)
+		}
+		directMgr = adnl.NewMultiNetReader(dl)
+		toClose = append(toClose, func() {
+			directMgr.Close()
+		})
+	}
+
 	_, dhtAdnlKey, err := ed25519.GenerateKey(nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate ed25519 dht adnl key: %w", err)
 	}
 
-	dhtGate := adnl.NewGatewayWithNetManager(dhtAdnlKey, netMgr)
+	dhtGate := adnl.NewGatewayWithNetManager(dhtAdnlKey, directMgr)
 	if err = dhtGate.StartClient(); err != nil {
 		return nil, fmt.Errorf("failed to init dht adnl gateway: %w", err)
 	}
@@ -308,7 +455,7 @@ func NewClient(globalCtx context.Context, dbPath string, cfg Config, tunCfg *tun
 
 	providerGateSeed := sha256.Sum256(cfg.Key.Seed())
 	gateKey := ed25519.NewKeyFromSeed(providerGateSeed[:])
-	gateProvider := adnl.NewGatewayWithNetManager(gateKey, netMgr)
+	gateProvider := adnl.NewGatewayWithNetManager(gateKey, directMgr)
 	if err = gateProvider.StartClient(); err != nil {
 		return nil, fmt.Errorf("failed to start adnl gateway for provider: %w", err)
 	}
@@ -325,14 +472,41 @@ func NewClient(globalCtx context.Context, dbPath string, cfg Config, tunCfg *tun
 	toClose = append(toClose, func() {
 		ldb.Close()
 	})
+	c.db = ldb
 
-	c.srv = storage.NewServer(dhtClient, gate, cfg.Key, serverMode, 8)
+	srvDHT := dhtClient
+	if c.routing == RoutePrivateOnly {
+		// private bags are searched through tunnel, to not reveal them by direct lookups
+		tunDHT, closeDHT, err := startDHT(netMgr, lsCfg)
+		if err != nil {
+			return nil, err
+		}
+		toClose = append(toClose, closeDHT)
+		srvDHT = tunDHT
+	}
+
+	c.srv = storage.NewServer(srvDHT, gate, cfg.Key, serverMode, 8)
 	toClose = append(toClose, func() {
 		c.srv.Stop()
 	})
 
-	ch := make(chan db.Event, 1)
+	var directSrv *storage.Server
+	// before tunnel is ready all bags go through pre tunnel server
+	switched := func([]byte) bool { return sw.switched.Load() }
 	c.connector = storage.NewConnector(c.srv)
+	if c.routing == RoutePrivateOnly {
+		var closeDirect func()
+		directSrv, closeDirect, err = startDirectServer(cfg, directMgr, dhtClient, listenThreads)
+		if err != nil {
+			return nil, err
+		}
+		toClose = append(toClose, closeDirect)
+		c.connector = &routedConnector{Connector: storage.NewConnector(c.srv), direct: directSrv, private: c.isPrivate}
+	} else if pre != nil {
+		c.connector = &routedConnector{Connector: storage.NewConnector(c.srv), direct: pre.srv, private: switched}
+	}
+
+	ch := make(chan db.Event, 1)
 	c.storage, err = db.NewStorage(ldb, c.connector, 0, false, false, false, ch)
 	if err != nil {
 		return nil, fmt.Errorf("failed to init storage: %w", err)
@@ -340,10 +514,29 @@ func NewClient(globalCtx context.Context, dbPath string, cfg Config, tunCfg *tun
 	toClose = append(toClose, func() {
 		c.storage.Close()
 	})
-	c.srv.SetStorage(c.storage)
+	if directSrv != nil {
+		c.srv.SetStorage(&routedStorage{Storage: c.storage, private: true, isPrivate: c.isPrivate})
+		directSrv.SetStorage(&routedStorage{Storage: c.storage, private: false, isPrivate: c.isPrivate})
+	} else if pre != nil {
+		c.srv.SetStorage(&routedStorage{Storage: c.storage, private: true, isPrivate: switched})
+		pre.srv.SetStorage(&routedStorage{Storage: c.storage, private: false, isPrivate: switched})
+
+		go func() {
+			select {
+			case <-sw.ready:
+				// bags find their peers again through tunnel
+				pre.close()
+				log.Info().Msg("pre tunnel storage server stopped")
+			case <-closerCtx.Done():
+			}
+		}()
+	} else {
+		c.srv.SetStorage(c.storage)
+	}
 
 	prvClient := transport.NewClient(gateProvider, dhtClient)
 	c.provider = provider.NewClient(c.storage, apiClient, prvClient)
+	c.api = apiClient
 
 	d, u, err := c.storage.GetSpeedLimits()
 	if err != nil {
@@ -371,6 +564,7 @@ func NewClient(globalCtx context.Context, dbPath string, cfg Config, tunCfg *tun
 			}
 		}
 	}()
+	go c.monitor(closerCtx)
 	success = true
 
 	reportLoadingState("Initialized")
@@ -414,16 +608,16 @@ func (c *Client) AddByHash(ctx context.Context, hash []byte, dir string) (*clien
 }
 
 func (c *Client) AddByMeta(ctx context.Context, meta []byte, dir string) (*client.TorrentFull, error) {
-	if len(meta) < 8 {
-		return nil, fmt.Errorf("too short meta")
-	}
-	if binary.LittleEndian.Uint32(meta) == 0x6a7181e0 {
-		// skip id, for compatibility with boxed and not boxed
-		meta = meta[4:]
-	}
+	return c.addByMeta(ctx, meta, dir, true)
+}
+
+// AddByMetaStopped adds bag without starting it, for example to verify existing data first
+func (c *Client) AddByMetaStopped(ctx context.Context, meta []byte, dir string) (*client.TorrentFull, error) {
+	return c.addByMeta(ctx, meta, dir, false)
+}
 
-	var ti client.MetaFile
-	_, err := tl.Parse(&ti, meta, false)
+func (c *Client) addByMeta(ctx context.Context, meta []byte, dir string, start bool) (*client.TorrentFull, error) {
+	ti, err := tonbag.Parse(meta)
 	if err != nil {
 		return nil, err
 	}
@@ -442,8 +636,10 @@ func (c *Client) AddByMeta(ctx context.Context, meta []byte, dir string) (*clien
 		tor.InitMask()
 	}
 
-	if err = tor.Start(true, false, false); err != nil {
-		return nil, fmt.Errorf("download error: %w", err)
+	if start {
+		if err = tor.Start(true, false, false); err != nil {
+			return nil, fmt.Errorf("download error: %w", err)
+		}
 	}
 
 	if err = c.storage.SetTorrent(tor); err != nil {
@@ -452,25 +648,6 @@ func (c *Client) AddByMeta(ctx context.Context, meta []byte, dir string) (*clien
 	return c.GetTorrentFull(ctx, tor.BagID)
 }
 
-func (c *Client) CreateTorrent(ctx context.Context, path, description string, progressCallback func(done uint64, max uint64)) (*client.TorrentFull, error) {
-	rootPath, dir, files, err := c.storage.DetectFileRefs(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read files: %w", err)
-	}
-
-	it, err := storage.CreateTorrent(ctx, rootPath, dir, description, c.storage, c.connector, files, progressCallback)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create bag: %w", err)
-	}
-	it.Start(true, false, false)
-
-	err = c.storage.SetTorrent(it)
-	if err != nil {
-		return nil, fmt.Errorf("failed to save bag: %w", err)
-	}
-	return c.GetTorrentFull(ctx, it.BagID)
-}
-
 func (c *Client) GetTorrentFull(ctx context.Context, hash []byte) (*client.TorrentFull, error) {
 	return c.getTorrent(hash, true)
 }
@@ -490,7 +667,11 @@ func (c *Client) getTorrent(hash []byte, withFiles bool) (*client.TorrentFull, e
 	}
 
 	var files []client.FileInfo
-	activeDownload, activeUpload := t.IsActive()
+	activeDownload, activeUpload := c.isActive(t)
+	if t.Header == nil {
+		// cannot upload without header
+		activeUpload = false
+	}
 	verificationInProgress, _ := t.GetLastVerifiedAt()
 	torrent := client.Torrent{
 		Hash:           t.BagID,
@@ -499,9 +680,15 @@ func (c *Client) getTorrent(hash []byte, withFiles bool) (*client.TorrentFull, e
 		ActiveDownload: activeDownload,
 		ActiveUpload:   activeUpload,
 		Completed:      false,
-		Verified:       !verificationInProgress,
+		Verified:       !verificationInProgress && !c.isRechecking(t.BagID),
+		Private:        c.isPrivate(t.BagID),
 		FatalError:     nil,
 	}
+	if e := c.getError(t.BagID); e != nil {
+		torrent.Flags |= 4
+		torrent.FatalError = &e.Message
+		torrent.FatalErrorAt = uint32(e.At.Unix())
+	}
 	if t.Info != nil {
 		torrent.Flags |= 1
 		incSize := t.Info.FileSize
@@ -534,7 +721,7 @@ func (c *Client) getTorrent(hash []byte, withFiles bool) (*client.TorrentFull, e
 
 		completed := true
 		mask := t.PiecesMask()
-		for _, u := range t.GetActiveFilesIDs() {
+		for _, u := range c.selectedFiles(t) {
 			fi, err := t.GetFileOffsetsByID(u)
 			if err != nil {
 				return nil, fmt.Errorf("failed to get offset for file %d: %w", u, err)
@@ -633,6 +820,15 @@ func (c *Client) RemoveTorrent(ctx context.Context, hash []byte, withFiles bool)
 	if t == nil {
 		return fmt.Errorf("torrent is not found")
 	}
+	if err := c.setActivity(hash, nil); err != nil {
+		log.Error().Err(err).Msg("failed to remove bag activity")
+	}
+	if err := c.setPrivate(hash, false); err != nil {
+		log.Error().Err(err).Msg("failed to remove bag route")
+	}
+	c.resolveError(hash, "")
+	c.forgetHealth(hash)
+	c.forgetVersions(hash)
 	return c.storage.RemoveTorrent(t, withFiles)
 }
 
@@ -642,15 +838,7 @@ func (c *Client) SetActive(ctx context.Context, hash []byte, active bool) error
 		return fmt.Errorf("torrent is not found")
 	}
 
-	if !active {
-		t.Stop()
-	} else {
-		err := t.Start(true, false, false)
-		if err != nil {
-			return err
-		}
-	}
-	return c.storage.SetTorrent(t)
+	return c.applyActivity(t, active, active)
 }
 
 func (c *Client) SetFilesPriority(ctx context.Context, hash []byte, names []string, priority int32) error {
@@ -659,7 +847,8 @@ func (c *Client) SetFilesPriority(ctx context.Context, hash []byte, names []stri
 		return fmt.Errorf("torrent is not found")
 	}
 
-	list := t.GetActiveFilesIDs()
+	a := c.getActivity(hash)
+	list := append([]uint32{}, c.selectedFiles(t)...)
 
 	for _, name := range names {
 		fileInfo, err := t.GetFileOffsets(name)
@@ -672,6 +861,7 @@ func (c *Client) SetFilesPriority(ctx context.Context, hash []byte, names []stri
 			for y, u := range list {
 				if u == fileInfo.Index {
 					list[y] = list[len(list)-1]
+					list = list[:len(list)-1]
 					break
 				}
 			}
@@ -689,6 +879,16 @@ func (c *Client) SetFilesPriority(ctx context.Context, hash []byte, names []stri
 			list = append(list, fileInfo.Index)
 		}
 	}
+
+	if a != nil && a.Files != nil {
+		// download is paused, remember selection to apply it on resume
+		upd := *a
+		upd.Files = list
+		if err := c.setActivity(hash, &upd); err != nil {
+			return fmt.Errorf("failed to save selected files: %w", err)
+		}
+		return t.SetActiveFilesIDs(completedFiles(t, list))
+	}
 	return t.SetActiveFilesIDs(list)
 }
 
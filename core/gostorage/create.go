@@ -0,0 +1,229 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/tonutils/torrent-client/core/bagfiles"
+	"github.com/tonutils/torrent-client/core/client"
+	"github.com/xssnick/tonutils-storage/storage"
+)
+
+func (c *Client) CreateTorrent(ctx context.Context, path, description string, opts client.CreateOptions, progressCallback func(done uint64, max uint64)) (*client.TorrentFull, error) {
+	rootPath, dir, files, err := c.storage.DetectFileRefs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read files: %w", err)
+	}
+
+	return c.createTorrent(ctx, rootPath, dir, description, files, opts, nil, progressCallback)
+}
+
+// CreateTorrentFromFiles creates bag from already scanned list of files
+func (c *Client) CreateTorrentFromFiles(ctx context.Context, set *bagfiles.Set, description string, opts client.CreateOptions, progressCallback func(done uint64, max uint64)) (*client.TorrentFull, error) {
+	return c.createTorrent(ctx, set.Root, set.DirName, description, set.Refs(), opts, &set.Options, progressCallback)
+}
+
+// createTorrent hashes files and adds bag, scan options are remembered to create next versions of it
+func (c *Client) createTorrent(ctx context.Context, rootPath, dir, description string, files []storage.FileRef, opts client.CreateOptions, scan *bagfiles.Options, progressCallback func(done uint64, max uint64)) (*client.TorrentFull, error) {
+	if opts.PieceSize != 0 {
+		if err := bagfiles.ValidatePieceSize(opts.PieceSize); err != nil {
+			return nil, err
+		}
+	}
+
+	hashProgress := progressCallback
+	if opts.CopyInside {
+		tmp, refs, err := copyFiles(ctx, files, opts.CopyDir, dir, progressCallback)
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tmp) // it is renamed on success, so only garbage is removed
+
+		rootPath, files = tmp, refs
+		if progressCallback != nil {
+			// copy was the first half
+			hashProgress = func(done uint64, max uint64) {
+				progressCallback(max+done, max*2)
+			}
+		}
+	}
+
+	j, err := newHashJob(rootPath, bagfiles.BuildHeader(dir, files), files, opts.PieceSize, c.hashingPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare bag: %w", err)
+	}
+
+	var keys [][32]byte
+	if !opts.CopyInside {
+		// copied files get new paths, so their hashes can't be found later
+		keys = j.pieceKeys()
+		if opts.Parent != nil {
+			if src := c.getSource(opts.Parent); src != nil {
+				n := j.reuse(keys, src.Pieces)
+				log.Info().Uint32("pieces", n).Uint32("of", j.pieces).Msg("reusing hashes of previous bag version")
+			}
+		}
+	}
+
+	if err = j.hash(ctx, hashProgress); err != nil {
+		j.close(false)
+		return nil, fmt.Errorf("failed to hash files: %w", err)
+	}
+	j.close(true)
+
+	t, tree, err := j.bag(description, c.storage, c.connector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bag: %w", err)
+	}
+
+	// checks go before pieces are stored, so nothing is left in db when they fail
+	if opts.Parent != nil && bytes.Equal(t.BagID, opts.Parent) {
+		return nil, fmt.Errorf("data was not changed since previous version")
+	}
+
+	var path string
+	if opts.CopyInside {
+		path = filepath.Join(opts.CopyDir, strings.ToUpper(hex.EncodeToString(t.BagID)))
+		if _, err = os.Stat(path); err == nil {
+			return nil, fmt.Errorf("bag data already exists in %s", path)
+		}
+	}
+
+	// pieces of the same bag added before are shared, they must stay on failure
+	existed := c.storage.GetTorrent(t.BagID) != nil
+	fail := func(err error) (*client.TorrentFull, error) {
+		if !existed {
+			j.unstore(t, c.storage)
+		}
+		return nil, err
+	}
+
+	if err = j.store(ctx, t, tree, c.storage, hashProgress); err != nil {
+		return fail(fmt.Errorf("failed to create bag: %w", err))
+	}
+
+	if opts.CopyInside {
+		if err = os.Rename(rootPath, path); err != nil {
+			return fail(fmt.Errorf("failed to move copied files: %w", err))
+		}
+		t.Path = path
+	}
+
+	if !opts.StartPaused {
+		if err = t.Start(true, false, false); err != nil {
+			return fail(fmt.Errorf("failed to start bag: %w", err))
+		}
+	}
+
+	err = c.storage.SetTorrent(t)
+	if err != nil {
+		t.Stop()
+		return fail(fmt.Errorf("failed to save bag: %w", err))
+	}
+
+	if keys != nil {
+		if err = c.setSource(t.BagID, &bagSource{Options: scan, Pieces: j.sourcePieces(keys)}); err != nil {
+			log.Warn().Err(err).Msg("failed to save bag source, next version will be hashed fully")
+		}
+	}
+
+	if opts.Parent != nil {
+		err = c.setVersion(t.BagID, &bagVersion{
+			Parent:                 opts.Parent,
+			RetireParentAfterPeers: opts.RetireParentAfterPeers,
+			CreatedAt:              time.Now(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to save bag version: %w", err)
+		}
+	}
+	return c.GetTorrentFull(ctx, t.BagID)
+}
+
+// copyFiles copies files to temporary dir inside dst, to be renamed when bag id is known.
+// Progress is reported as a first half of creation.
+func copyFiles(ctx context.Context, files []storage.FileRef, dst, dirName string, progressCallback func(done uint64, max uint64)) (string, []storage.FileRef, error) {
+	if dst == "" {
+		return "", nil, fmt.Errorf("dir to copy files is not set")
+	}
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return "", nil, fmt.Errorf("failed to create dir to copy files: %w", err)
+	}
+
+	tmp, err := os.MkdirTemp(dst, ".tt-create-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+
+	var total, done uint64
+	for _, f := range files {
+		total += f.GetSize()
+	}
+
+	refs := make([]storage.FileRef, 0, len(files))
+	for _, f := range files {
+		path := filepath.Join(tmp, dirName, f.GetName())
+		if err = copyFile(ctx, f, path, func(n uint64) {
+			done += n
+			if progressCallback != nil {
+				progressCallback(done, total*2)
+			}
+		}); err != nil {
+			_ = os.RemoveAll(tmp)
+			return "", nil, fmt.Errorf("failed to copy %s: %w", f.GetName(), err)
+		}
+		refs = append(refs, &diskFileRef{path: path, name: f.GetName(), size: f.GetSize()})
+	}
+	return tmp, refs, nil
+}
+
+func copyFile(ctx context.Context, f storage.FileRef, path string, written func(n uint64)) error {
+	r, closer, err := f.CreateReader()
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	src := io.NewSectionReader(r, 0, int64(f.GetSize()))
+	buf := make([]byte, 1<<20)
+	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, werr := out.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			written(uint64(n))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return out.Close()
+}
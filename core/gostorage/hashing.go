@@ -0,0 +1,601 @@
+package gostorage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tonutils/torrent-client/core/bagfiles"
+	"github.com/xssnick/tonutils-go/tl"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"github.com/xssnick/tonutils-storage/storage"
+)
+
+var (
+	// HashingQueueSize is how many pieces can be read ahead of hashing, it bounds memory and IO
+	HashingQueueSize = 64
+	// HashingMemory is how much memory buffers of read ahead pieces can take, at least one piece is read anyway
+	HashingMemory uint64 = 64 << 20
+	// CheckpointInterval is how often hashed pieces are flushed to disk to resume after cancel or crash
+	CheckpointInterval = 5 * time.Second
+	// CheckpointTTL is how long unfinished hashing is kept
+	CheckpointTTL = 7 * 24 * time.Hour
+)
+
+// hashJob hashes bag data in parallel, and keeps progress in checkpoint files,
+// hashes are written as they are calculated, and bitmap of done pieces only after sync,
+// so after crash only pieces confirmed by bitmap are trusted
+type hashJob struct {
+	root       string
+	files      []storage.FileRef
+	header     *storage.TorrentHeader
+	headerData []byte
+	pieceSize  uint32
+	fullSize   uint64
+	pieces     uint32
+
+	hashes []byte
+	done   []byte
+
+	checkpoint string
+	hashFile   *os.File
+}
+
+// newHashJob prepares hashing of files with given header, checkpoints are not used when dir is empty
+func newHashJob(root string, header *storage.TorrentHeader, files []storage.FileRef, pieceSize uint32, checkpointDir string) (*hashJob, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("0 files in bag")
+	}
+
+	j := &hashJob{
+		root:   root,
+		files:  files,
+		header: header,
+	}
+
+	var err error
+	if j.headerData, err = tl.Serialize(j.header, true); err != nil {
+		return nil, fmt.Errorf("failed to serialize header: %w", err)
+	}
+
+	dataSize := j.header.DataIndex[len(j.header.DataIndex)-1]
+	if pieceSize == 0 {
+		pieceSize = bagfiles.DefaultPieceSize(dataSize)
+	}
+	j.pieceSize = pieceSize
+	j.fullSize = uint64(len(j.headerData)) + dataSize
+
+	pieces := j.fullSize / uint64(pieceSize)
+	if j.fullSize%uint64(pieceSize) != 0 {
+		pieces++
+	}
+	if pieces > 1<<32-1 {
+		return nil, fmt.Errorf("too many pieces, use bigger piece size")
+	}
+	j.pieces = uint32(pieces)
+
+	j.hashes = make([]byte, int(j.pieces)*32)
+	j.done = make([]byte, (j.pieces+7)/8)
+
+	if checkpointDir != "" {
+		if err = j.openCheckpoint(checkpointDir); err != nil {
+			// hashing can still be done, just without resume
+			log.Warn().Err(err).Msg("failed to open hashing checkpoint")
+		}
+	}
+	return j, nil
+}
+
+// id of job depends on content layout and files modification time, so changed files are hashed again
+func (j *hashJob) id() string {
+	h := sha256.New()
+	h.Write([]byte(j.root))
+	h.Write(j.headerData)
+	_ = binary.Write(h, binary.BigEndian, j.pieceSize)
+
+	dir := string(j.header.DirName)
+	for _, f := range j.files {
+		if st, err := os.Stat(filepath.Join(j.root, dir, f.GetName())); err == nil {
+			_ = binary.Write(h, binary.BigEndian, st.ModTime().UnixNano())
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (j *hashJob) openCheckpoint(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	cleanCheckpoints(dir)
+
+	j.checkpoint = filepath.Join(dir, j.id())
+
+	f, err := os.OpenFile(j.checkpoint+".hashes", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	j.hashFile = f
+
+	done, err := os.ReadFile(j.checkpoint + ".done")
+	if err != nil || len(done) != len(j.done) {
+		return nil
+	}
+
+	// file is shorter when last pieces are not hashed yet
+	if _, err = f.ReadAt(j.hashes, 0); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	copy(j.done, done)
+	log.Info().Uint32("pieces", j.donePieces()).Msg("resuming bag hashing from checkpoint")
+	return nil
+}
+
+// flush syncs calculated hashes, and only then marks them as done
+func (j *hashJob) flush(done []byte) {
+	if j.hashFile == nil {
+		return
+	}
+
+	if err := j.hashFile.Sync(); err != nil {
+		log.Warn().Err(err).Msg("failed to sync hashing checkpoint")
+		return
+	}
+
+	tmp := j.checkpoint + ".done.tmp"
+	if err := os.WriteFile(tmp, done, 0644); err != nil {
+		log.Warn().Err(err).Msg("failed to write hashing checkpoint")
+		return
+	}
+	if err := os.Rename(tmp, j.checkpoint+".done"); err != nil {
+		log.Warn().Err(err).Msg("failed to save hashing checkpoint")
+	}
+}
+
+func (j *hashJob) close(finished bool) {
+	if j.hashFile == nil {
+		return
+	}
+	_ = j.hashFile.Close()
+
+	if finished {
+		_ = os.Remove(j.checkpoint + ".hashes")
+		_ = os.Remove(j.checkpoint + ".done")
+	}
+}
+
+func cleanCheckpoints(dir string) {
+	list, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, e := range list {
+		info, err := e.Info()
+		if err == nil && time.Since(info.ModTime()) > CheckpointTTL {
+			_ = os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+}
+
+func (j *hashJob) isDone(id uint32) bool {
+	return j.done[id/8]&(1<<(id%8)) != 0
+}
+
+func (j *hashJob) donePieces() (n uint32) {
+	for i := uint32(0); i < j.pieces; i++ {
+		if j.isDone(i) {
+			n++
+		}
+	}
+	return n
+}
+
+func (j *hashJob) pieceLen(id uint32) uint64 {
+	if id == j.pieces-1 && j.fullSize%uint64(j.pieceSize) != 0 {
+		return j.fullSize % uint64(j.pieceSize)
+	}
+	return uint64(j.pieceSize)
+}
+
+// proofUnits is a progress weight of proof calculation for piece,
+// it is taken from library, where hashing is 3 times longer than proof and store
+func (j *hashJob) proofUnits() uint64 {
+	return uint64(j.pieceSize) / 3
+}
+
+// hash reads pieces sequentially in one goroutine, which is the best for disks,
+// and hashes them on all cores. Progress is reported in bytes.
+func (j *hashJob) hash(ctx context.Context, progressCallback func(done uint64, max uint64)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		id   uint32
+		hash [32]byte
+	}
+
+	type piece struct {
+		id  uint32
+		buf []byte
+	}
+
+	// buffers are allocated when reader gets ahead of hashing, so small bags take little memory
+	bufs := min(uint64(HashingQueueSize), uint64(j.pieces-j.donePieces()), max(HashingMemory/uint64(j.pieceSize), 1))
+	pool := make(chan []byte, bufs)
+	var allocated uint64
+
+	toHash := make(chan piece, HashingQueueSize)
+	results := make(chan result, HashingQueueSize)
+	readErr := make(chan error, 1)
+
+	go func() {
+		defer close(toHash)
+
+		rd := &bagReader{header: j.headerData, files: j.files, ends: j.header.DataIndex}
+		defer rd.close()
+
+		for id := uint32(0); id < j.pieces; id++ {
+			if j.isDone(id) {
+				continue
+			}
+
+			var buf []byte
+			select {
+			case buf = <-pool:
+			default:
+				if allocated < bufs {
+					buf = make([]byte, j.pieceSize)
+					allocated++
+					break
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case buf = <-pool:
+				}
+			}
+
+			buf = buf[:j.pieceLen(id)]
+			if err := rd.read(buf, uint64(id)*uint64(j.pieceSize)); err != nil {
+				readErr <- err
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case toHash <- piece{id: id, buf: buf}:
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range toHash {
+				r := result{id: p.id, hash: sha256.Sum256(p.buf)}
+				pool <- p.buf[:cap(p.buf)]
+
+				select {
+				case <-ctx.Done():
+					return
+				case results <- r:
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	max := j.fullSize + uint64(j.pieces)*j.proofUnits()
+	var done uint64
+	for id := uint32(0); id < j.pieces; id++ {
+		if j.isDone(id) {
+			done += j.pieceLen(id)
+		}
+	}
+	if progressCallback != nil {
+		progressCallback(done, max)
+	}
+
+	// bitmap of pieces which hashes are written, but not yet synced
+	pending := append([]byte{}, j.done...)
+	lastFlush := time.Now()
+	for r := range results {
+		copy(j.hashes[r.id*32:], r.hash[:])
+		if j.hashFile != nil {
+			if _, err := j.hashFile.WriteAt(r.hash[:], int64(r.id)*32); err != nil {
+				log.Warn().Err(err).Msg("failed to write hashing checkpoint, continue without it")
+				_ = j.hashFile.Close()
+				j.hashFile = nil
+			}
+		}
+		pending[r.id/8] |= 1 << (r.id % 8)
+
+		done += j.pieceLen(r.id)
+		if progressCallback != nil {
+			progressCallback(done, max)
+		}
+
+		if time.Since(lastFlush) > CheckpointInterval {
+			j.flush(pending)
+			lastFlush = time.Now()
+		}
+	}
+
+	select {
+	case err := <-readErr:
+		j.flush(pending)
+		return err
+	default:
+	}
+
+	if err := ctx.Err(); err != nil {
+		j.flush(pending)
+		return err
+	}
+	j.done = pending
+	return nil
+}
+
+// build creates bag from calculated hashes and stores proofs of all pieces
+func (j *hashJob) build(ctx context.Context, description string, db storage.Storage, connector storage.NetConnector, progressCallback func(done uint64, max uint64)) (*storage.Torrent, error) {
+	t, tree, err := j.bag(description, db, connector)
+	if err != nil {
+		return nil, err
+	}
+	if err = j.store(ctx, t, tree, db, progressCallback); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// bag creates bag from calculated hashes, nothing is stored to db yet
+func (j *hashJob) bag(description string, db storage.Storage, connector storage.NetConnector) (*storage.Torrent, *cell.Cell, error) {
+	if j.donePieces() != j.pieces {
+		return nil, nil, fmt.Errorf("not all pieces are hashed")
+	}
+
+	tree := buildMerkleTree(j.hashes, int(j.pieces))
+
+	t := storage.NewTorrent(j.root, db, connector)
+	t.Header = j.header
+	t.CreatedLocally = true
+	t.SetInfoStats(j.pieceSize, j.headerData, tree.Hash(), j.fullSize, uint64(len(j.headerData)), description)
+
+	infoCell, err := tlb.ToCell(t.Info)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to serialize bag info: %w", err)
+	}
+	t.BagID = infoCell.Hash()
+	return t, tree, nil
+}
+
+// store stores proofs of all pieces of bag and selects all its files
+func (j *hashJob) store(ctx context.Context, t *storage.Torrent, tree *cell.Cell, db storage.Storage, progressCallback func(done uint64, max uint64)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ids := make(chan uint32, HashingQueueSize)
+	go func() {
+		defer close(ids)
+		for id := uint32(0); id < j.pieces; id++ {
+			select {
+			case <-ctx.Done():
+				return
+			case ids <- id:
+			}
+		}
+	}()
+
+	var firstErr error
+	var mx sync.Mutex
+	var wg sync.WaitGroup
+
+	max := j.fullSize + uint64(j.pieces)*j.proofUnits()
+	done := j.fullSize
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				err := j.storeProof(t, tree, id, db)
+
+				mx.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				done += j.proofUnits()
+				if progressCallback != nil {
+					progressCallback(done, max)
+				}
+				mx.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	all := make([]uint32, len(j.files))
+	for i := range all {
+		all[i] = uint32(i)
+	}
+	if err := db.SetActiveFiles(t.BagID, all); err != nil {
+		return fmt.Errorf("failed to store active files: %w", err)
+	}
+
+	t.InitMask()
+	if err := t.LoadActiveFilesIDs(); err != nil {
+		return fmt.Errorf("failed to load active files: %w", err)
+	}
+	return nil
+}
+
+// unstore removes proofs stored for bag which was not saved
+func (j *hashJob) unstore(t *storage.Torrent, db storage.Storage) {
+	for id := uint32(0); id < j.pieces; id++ {
+		if err := db.RemovePiece(t.BagID, id); err != nil {
+			log.Warn().Err(err).Uint32("piece", id).Msg("failed to remove piece of not created bag")
+			return
+		}
+	}
+}
+
+func (j *hashJob) storeProof(t *storage.Torrent, tree *cell.Cell, id uint32, db storage.Storage) error {
+	sk := cell.CreateProofSkeleton()
+	cur := sk
+	for bit := treeDepth(j.pieces) - 1; bit >= 0; bit-- {
+		cur = cur.ProofRef(int(id>>bit) & 1)
+	}
+
+	proof, err := tree.CreateProof(sk)
+	if err != nil {
+		return fmt.Errorf("failed to create proof for piece %d: %w", id, err)
+	}
+
+	return db.SetPiece(t.BagID, id, &storage.PieceInfo{
+		StartFileIndex: j.startFileIndex(id),
+		Proof:          proof.ToBOCWithFlags(false),
+	})
+}
+
+// startFileIndex is an index of file where piece begins, empty files are skipped,
+// piece which starts in header has index 0
+func (j *hashJob) startFileIndex(id uint32) uint32 {
+	off := uint64(id) * uint64(j.pieceSize)
+	if off < uint64(len(j.headerData)) {
+		return 0
+	}
+	off -= uint64(len(j.headerData))
+
+	return uint32(sort.Search(len(j.header.DataIndex), func(i int) bool {
+		return j.header.DataIndex[i] > off
+	}))
+}
+
+func treeDepth(pieces uint32) int {
+	depth := 0
+	for (uint64(1) << depth) < uint64(pieces) {
+		depth++
+	}
+	return depth
+}
+
+var emptyHashCell = cell.FromRawUnsafe(cell.RawUnsafeCell{
+	BitsSz: 256,
+	Data:   make([]byte, 32),
+})
+
+// buildMerkleTree is the same tree as library builds, leaves are padded to power of 2 with zero hashes
+func buildMerkleTree(hashes []byte, num int) *cell.Cell {
+	cells := make([]*cell.Cell, 1<<treeDepth(uint32(num)))
+	for i := range cells {
+		if i < num {
+			cells[i] = cell.FromRawUnsafe(cell.RawUnsafeCell{
+				BitsSz: 256,
+				Data:   hashes[i*32 : i*32+32],
+			})
+		} else {
+			cells[i] = emptyHashCell
+		}
+	}
+
+	for len(cells) > 1 {
+		next := make([]*cell.Cell, len(cells)/2)
+		for i := range next {
+			next[i] = cell.FromRawUnsafe(cell.RawUnsafeCell{
+				Refs: []*cell.Cell{cells[i*2], cells[i*2+1]},
+			})
+		}
+		cells = next
+	}
+	return cells[0]
+}
+
+// bagReader reads bag data as one stream of header and files
+type bagReader struct {
+	header []byte
+	files  []storage.FileRef
+	ends   []uint64
+
+	cur    int
+	rd     io.ReaderAt
+	closer func() error
+}
+
+func (r *bagReader) read(buf []byte, off uint64) error {
+	if off < uint64(len(r.header)) {
+		n := copy(buf, r.header[off:])
+		buf, off = buf[n:], off+uint64(n)
+	}
+	off -= uint64(len(r.header))
+
+	for len(buf) > 0 {
+		i := sort.Search(len(r.ends), func(i int) bool {
+			return r.ends[i] > off
+		})
+		if i == len(r.ends) {
+			return fmt.Errorf("read out of bag data")
+		}
+
+		if r.rd == nil || r.cur != i {
+			r.close()
+
+			rd, closer, err := r.files[i].CreateReader()
+			if err != nil {
+				return fmt.Errorf("failed to open file %s: %w", r.files[i].GetName(), err)
+			}
+			r.rd, r.closer, r.cur = rd, closer, i
+		}
+
+		start := r.ends[i] - r.files[i].GetSize()
+		chunk := r.ends[i] - off
+		if chunk > uint64(len(buf)) {
+			chunk = uint64(len(buf))
+		}
+
+		n, err := r.rd.ReadAt(buf[:chunk], int64(off-start))
+		if uint64(n) < chunk {
+			if err == nil || errors.Is(err, io.EOF) {
+				err = fmt.Errorf("file is smaller than expected, it was changed")
+			}
+			return fmt.Errorf("failed to read file %s: %w", r.files[i].GetName(), err)
+		}
+
+		buf, off = buf[chunk:], off+chunk
+	}
+	return nil
+}
+
+func (r *bagReader) close() {
+	if r.closer != nil {
+		_ = r.closer()
+		r.closer, r.rd = nil, nil
+	}
+}
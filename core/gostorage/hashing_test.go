@@ -0,0 +1,227 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/tonutils/torrent-client/core/bagfiles"
+	"github.com/xssnick/tonutils-go/tl"
+	"github.com/xssnick/tonutils-storage/storage"
+)
+
+// memStorage keeps pieces of created bags in memory
+type memStorage struct {
+	storage.Storage
+	pieceSize uint32
+
+	pieces map[uint32]*storage.PieceInfo
+	active []uint32
+	mx     sync.Mutex
+}
+
+func newMemStorage(pieceSize uint32) *memStorage {
+	return &memStorage{pieceSize: pieceSize, pieces: map[uint32]*storage.PieceInfo{}}
+}
+
+func (s *memStorage) GetForcedPieceSize() uint32 {
+	return s.pieceSize
+}
+
+func (s *memStorage) SetPiece(bagId []byte, id uint32, p *storage.PieceInfo) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.pieces[id] = p
+	return nil
+}
+
+func (s *memStorage) GetPiece(bagId []byte, id uint32) (*storage.PieceInfo, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	p := s.pieces[id]
+	if p == nil {
+		return nil, fmt.Errorf("piece %d is not stored", id)
+	}
+	return p, nil
+}
+
+func (s *memStorage) PiecesMask(bagId []byte, num uint32) []byte {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	mask := make([]byte, (num+7)/8)
+	for id := range s.pieces {
+		mask[id/8] |= 1 << (id % 8)
+	}
+	return mask
+}
+
+func (s *memStorage) SetActiveFiles(bagId []byte, ids []uint32) error {
+	s.active = ids
+	return nil
+}
+
+func (s *memStorage) GetActiveFiles(bagId []byte) ([]uint32, error) {
+	return s.active, nil
+}
+
+type memFile struct {
+	name string
+	data []byte
+}
+
+func (f *memFile) GetName() string {
+	return f.name
+}
+
+func (f *memFile) GetSize() uint64 {
+	return uint64(len(f.data))
+}
+
+func (f *memFile) CreateReader() (io.ReaderAt, func() error, error) {
+	return bytes.NewReader(f.data), func() error { return nil }, nil
+}
+
+func fileData(n, seed int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i*7 + seed)
+	}
+	return data
+}
+
+func refsOf(files []*memFile) []storage.FileRef {
+	refs := make([]storage.FileRef, 0, len(files))
+	for _, f := range files {
+		refs = append(refs, f)
+	}
+	return refs
+}
+
+// headerSize is serialized size of header, to place files at piece boundaries
+func headerSize(t *testing.T, dir string, files []*memFile) int {
+	data, err := tl.Serialize(bagfiles.BuildHeader(dir, refsOf(files)), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(data)
+}
+
+func TestHashJobMatchesLibrary(t *testing.T) {
+	const dir = "bag"
+
+	boundary := func(pieceSize int) []*memFile {
+		files := []*memFile{{name: "first.bin"}, {name: "second.bin", data: fileData(700, 2)}}
+		// first file ends exactly where the second piece ends
+		files[0].data = fileData(2*pieceSize-headerSize(t, dir, files), 1)
+		return files
+	}
+
+	evenTotal := func(pieceSize int) []*memFile {
+		files := []*memFile{{name: "a.bin", data: fileData(1000, 3)}, {name: "b.bin"}}
+		// whole bag is exactly 4 pieces
+		files[1].data = fileData(4*pieceSize-headerSize(t, dir, files)-1000, 4)
+		return files
+	}
+
+	var many []*memFile
+	for i := 0; i < 300; i++ {
+		many = append(many, &memFile{name: fmt.Sprintf("dir%d/f%03d.txt", i%5, i), data: fileData(i%50+1, i)})
+	}
+
+	tests := []struct {
+		name      string
+		pieceSize uint32
+		files     []*memFile
+	}{
+		{"single file", 1024, []*memFile{{name: "file.bin", data: fileData(5000, 0)}}},
+		{"single piece", 1 << 16, []*memFile{{name: "small.txt", data: fileData(10, 0)}}},
+		{"empty files", 1024, []*memFile{
+			{name: "empty-first"},
+			{name: "a.bin", data: fileData(100, 1)},
+			{name: "empty-middle"},
+			{name: "b.bin", data: fileData(3000, 2)},
+			{name: "empty-last"},
+		}},
+		{"file at piece boundary", 1024, boundary(1024)},
+		{"total at piece boundary", 512, evenTotal(512)},
+		{"many small files", 256, many},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			refs := refsOf(tt.files)
+
+			libDB := newMemStorage(tt.pieceSize)
+			lib, err := storage.CreateTorrent(ctx, "", dir, "test", libDB, nil, refs, nil)
+			if err != nil {
+				t.Fatal("library failed to create bag:", err)
+			}
+
+			j, err := newHashJob("", bagfiles.BuildHeader(dir, refs), refs, tt.pieceSize, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = j.hash(ctx, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			ourDB := newMemStorage(tt.pieceSize)
+			our, err := j.build(ctx, "test", ourDB, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(lib.BagID, our.BagID) {
+				t.Fatalf("bag id %x, library has %x", our.BagID, lib.BagID)
+			}
+
+			num := lib.Info.PiecesNum()
+			if len(libDB.pieces) != int(num) || len(ourDB.pieces) != int(num) {
+				t.Fatalf("%d pieces stored, library stored %d of %d", len(ourDB.pieces), len(libDB.pieces), num)
+			}
+			for id := uint32(0); id < num; id++ {
+				want, got := libDB.pieces[id], ourDB.pieces[id]
+				if got.StartFileIndex != want.StartFileIndex {
+					t.Errorf("piece %d starts in file %d, library has %d", id, got.StartFileIndex, want.StartFileIndex)
+				}
+				if !bytes.Equal(got.Proof, want.Proof) {
+					t.Errorf("proof of piece %d is different from library", id)
+				}
+			}
+		})
+	}
+}
+
+func TestHashJobMemoryLimit(t *testing.T) {
+	files := []*memFile{{name: "a.bin", data: fileData(20000, 1)}, {name: "b.bin", data: fileData(7000, 2)}}
+	refs := refsOf(files)
+
+	hashes := func(memory uint64) []byte {
+		defer func(v uint64) { HashingMemory = v }(HashingMemory)
+		HashingMemory = memory
+
+		j, err := newHashJob("", bagfiles.BuildHeader("bag", refs), refs, 1024, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = j.hash(context.Background(), nil); err != nil {
+			t.Fatal(err)
+		}
+		return j.hashes
+	}
+
+	want := hashes(64 << 20)
+	// less than a piece still reads with one buffer
+	for _, memory := range []uint64{1, 1024, 3 * 1024} {
+		if !bytes.Equal(hashes(memory), want) {
+			t.Fatalf("hashes with %d bytes of memory are different", memory)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/xssnick/tonutils-go/tl"
+	"github.com/xssnick/tonutils-storage/storage"
+)
+
+const (
+	ErrKindDiskWrite    = "disk-write"
+	ErrKindDownload     = "download"
+	ErrKindMissingFiles = "missing-files"
+	ErrKindHeader       = "header-verification"
+	ErrKindNoPeers      = "no-peers"
+)
+
+var (
+	HealthCheckInterval = 30 * time.Second
+	// FilesCheckInterval is how often seeded files are checked for existence, it is slower on big bags
+	FilesCheckInterval = 5 * time.Minute
+	// NoPeersTimeout is how long downloading bag can stay without peers before it is reported
+	NoPeersTimeout = 10 * time.Minute
+)
+
+// bagError is persisted, so problem stays visible after restart until retry or recovery
+type bagError struct {
+	Kind    string
+	Message string
+	At      time.Time
+}
+
+// bagHealth is runtime only observation state of bag
+type bagHealth struct {
+	active bool
+	// pausedSince is when library was first seen paused while bag is active,
+	// pause is reported only when it lasts for the whole check interval
+	pausedSince    time.Time
+	noPeersSince   time.Time
+	filesCheckedAt time.Time
+	headerChecked  bool
+}
+
+func errorKey(bagId []byte) []byte {
+	return append([]byte("tt_error:"), bagId...)
+}
+
+func (c *Client) getError(bagId []byte) *bagError {
+	c.healthMx.Lock()
+	defer c.healthMx.Unlock()
+
+	return c.loadError(bagId)
+}
+
+func (c *Client) loadError(bagId []byte) *bagError {
+	if e, ok := c.errors[string(bagId)]; ok {
+		return e
+	}
+
+	var e *bagError
+	data, err := c.db.Get(errorKey(bagId), nil)
+	if err == nil {
+		var stored bagError
+		if err = json.Unmarshal(data, &stored); err == nil {
+			e = &stored
+		}
+	}
+	if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+		log.Error().Err(err).Msg("failed to load bag error")
+	}
+
+	c.errors[string(bagId)] = e
+	return e
+}
+
+// reportError records error if bag has no other error yet, time of the first occurrence is kept
+func (c *Client) reportError(bagId []byte, kind, msg string) {
+	c.healthMx.Lock()
+	defer c.healthMx.Unlock()
+
+	cur := c.loadError(bagId)
+	if cur != nil && (cur.Kind != kind || cur.Message == msg) {
+		return
+	}
+
+	e := &bagError{Kind: kind, Message: msg, At: time.Now()}
+	if cur != nil {
+		e.At = cur.At
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to serialize bag error")
+		return
+	}
+
+	c.errors[string(bagId)] = e
+	if err = c.db.Put(errorKey(bagId), data, nil); err != nil {
+		log.Error().Err(err).Msg("failed to save bag error")
+	}
+	log.Warn().Str("bag", fmt.Sprintf("%x", bagId)).Str("kind", kind).Msg(msg)
+}
+
+// resolveError removes error of specified kind, empty kind removes any
+func (c *Client) resolveError(bagId []byte, kind string) {
+	c.healthMx.Lock()
+	defer c.healthMx.Unlock()
+
+	cur := c.loadError(bagId)
+	if cur == nil || (kind != "" && cur.Kind != kind) {
+		return
+	}
+
+	c.errors[string(bagId)] = nil
+	if err := c.db.Delete(errorKey(bagId), nil); err != nil {
+		log.Error().Err(err).Msg("failed to remove bag error")
+	}
+}
+
+func (c *Client) getHealth(bagId []byte) *bagHealth {
+	h := c.health[string(bagId)]
+	if h == nil {
+		h = &bagHealth{}
+		c.health[string(bagId)] = h
+	}
+	return h
+}
+
+// setExpectedActive should be called on every requested start or stop,
+// so monitor can distinguish it from a pause caused by storage error
+func (c *Client) setExpectedActive(bagId []byte, active bool) {
+	c.healthMx.Lock()
+	defer c.healthMx.Unlock()
+
+	h := c.getHealth(bagId)
+	h.active = active
+	h.pausedSince = time.Time{}
+}
+
+func (c *Client) forgetHealth(bagId []byte) {
+	c.healthMx.Lock()
+	defer c.healthMx.Unlock()
+
+	delete(c.health, string(bagId))
+}
+
+func (c *Client) monitor(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(HealthCheckInterval):
+		}
+
+		for _, t := range c.storage.GetAll() {
+			c.checkTorrent(t)
+		}
+	}
+}
+
+func (c *Client) checkTorrent(t *storage.Torrent) {
+	download, upload := c.isActive(t)
+	rawDownload, _ := t.IsActiveRaw()
+
+	c.healthMx.Lock()
+	h := c.getHealth(t.BagID)
+	paused := false
+	switch {
+	case rawDownload:
+		// started by library itself, when bag was loaded active
+		h.active = true
+		h.pausedSince = time.Time{}
+	case h.active && h.pausedSince.IsZero():
+		h.pausedSince = time.Now()
+	case h.active && time.Since(h.pausedSince) >= HealthCheckInterval:
+		paused = true
+		h.active = false
+		h.pausedSince = time.Time{}
+	}
+
+	checkHeader := !h.headerChecked && t.Header != nil && t.Info != nil
+	h.headerChecked = h.headerChecked || checkHeader
+
+	checkFiles := upload && t.Header != nil && time.Since(h.filesCheckedAt) > FilesCheckInterval
+	if checkFiles {
+		h.filesCheckedAt = time.Now()
+	}
+
+	// short pause of library does not reset stall, until it is seen for the whole interval
+	downloading := download || h.active
+	peers := len(t.GetPeers())
+	noPeersFor := time.Duration(0)
+	if downloading && !t.IsCompleted() && peers == 0 {
+		if h.noPeersSince.IsZero() {
+			h.noPeersSince = time.Now()
+		}
+		noPeersFor = time.Since(h.noPeersSince)
+	} else {
+		h.noPeersSince = time.Time{}
+	}
+	c.healthMx.Unlock()
+
+	if paused {
+		// library pauses bag by itself when download fails, mostly because of disk errors
+		if err := probeWrite(bagDir(t)); err != nil {
+			c.reportError(t.BagID, ErrKindDiskWrite, "failed to write to disk: "+err.Error())
+		} else {
+			c.reportError(t.BagID, ErrKindDownload, "download was stopped because of storage error")
+		}
+	}
+
+	if checkHeader {
+		if err := verifyHeader(t); err != nil {
+			c.reportError(t.BagID, ErrKindHeader, err.Error())
+		}
+	}
+
+	if checkFiles {
+		if missing := missingFiles(t, completedFiles(t, c.selectedFiles(t))); len(missing) > 0 {
+			msg := fmt.Sprintf("%d seeded files are missing on disk, first: %s", len(missing), missing[0])
+			c.reportError(t.BagID, ErrKindMissingFiles, msg)
+		} else {
+			c.resolveError(t.BagID, ErrKindMissingFiles)
+		}
+	}
+
+	if noPeersFor > NoPeersTimeout {
+		c.reportError(t.BagID, ErrKindNoPeers, fmt.Sprintf("no peers found for %s", noPeersFor.Truncate(time.Minute)))
+	} else if peers > 0 || !downloading {
+		c.resolveError(t.BagID, ErrKindNoPeers)
+	}
+
+	if upload {
+		c.retireParent(t, peers)
+	}
+}
+
+func bagDir(t *storage.Torrent) string {
+	if t.Header == nil {
+		return t.Path
+	}
+	return t.Path + "/" + string(t.Header.DirName)
+}
+
+func probeWrite(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, ".tt-write-check-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	_ = f.Close()
+	return os.Remove(name)
+}
+
+func verifyHeader(t *storage.Torrent) error {
+	data, err := tl.Serialize(t.Header, true)
+	if err != nil {
+		return fmt.Errorf("failed to serialize header: %w", err)
+	}
+
+	hash := sha256.Sum256(data)
+	if !bytes.Equal(hash[:], t.Info.HeaderHash) {
+		return fmt.Errorf("header hash does not match bag info, header is corrupted")
+	}
+	return nil
+}
+
+func missingFiles(t *storage.Torrent, ids []uint32) []string {
+	root := bagDir(t)
+
+	var missing []string
+	for _, id := range ids {
+		fi, err := t.GetFileOffsetsByID(id)
+		if err != nil {
+			continue
+		}
+
+		if _, err = os.Stat(root + "/" + fi.Name); errors.Is(err, os.ErrNotExist) {
+			missing = append(missing, fi.Name)
+		}
+	}
+	return missing
+}
+
+// RetryTorrent clears recorded error and restarts bag, restart also verifies stored pieces
+func (c *Client) RetryTorrent(ctx context.Context, hash []byte) error {
+	t := c.storage.GetTorrent(hash)
+	if t == nil {
+		return fmt.Errorf("torrent is not found")
+	}
+
+	download, upload := true, true
+	if a := c.getActivity(t.BagID); a != nil && (a.Download || a.Upload) {
+		download, upload = a.Download, a.Upload
+	}
+
+	c.resolveError(t.BagID, "")
+	c.forgetHealth(t.BagID)
+
+	c.setExpectedActive(t.BagID, false)
+	t.Stop()
+	return c.applyActivity(t, download, upload)
+}
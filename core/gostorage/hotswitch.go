@@ -0,0 +1,161 @@
+package gostorage
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xssnick/tonutils-go/adnl"
+	"github.com/xssnick/tonutils-go/liteclient"
+	"github.com/xssnick/tonutils-storage/storage"
+)
+
+// ErrTunnelNotReady is returned for data sent before tunnel is built
+var ErrTunnelNotReady = errors.New("tunnel is not ready")
+
+// switchableConn lets storage start before tunnel is built, packets are blocked
+// until tunnel is ready, then it is switched to tunnel once and for all.
+// Nothing of storage key goes directly, so real address is not linked with tunnel one,
+// bags are served directly meanwhile by preTunnel with its own key.
+type switchableConn struct {
+	tun net.PacketConn
+
+	switched atomic.Bool
+	ready    chan struct{}
+	closed   chan struct{}
+
+	switchOnce sync.Once
+	closeOnce  sync.Once
+}
+
+func newSwitchableConn(tun net.PacketConn) *switchableConn {
+	return &switchableConn{
+		tun:    tun,
+		ready:  make(chan struct{}),
+		closed: make(chan struct{}),
+	}
+}
+
+// switchToTunnel sends all next packets through tunnel
+func (s *switchableConn) switchToTunnel() {
+	s.switchOnce.Do(func() {
+		s.switched.Store(true)
+		close(s.ready)
+	})
+}
+
+func (s *switchableConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	if !s.switched.Load() {
+		select {
+		case <-s.ready:
+		case <-s.closed:
+			return 0, nil, net.ErrClosed
+		}
+	}
+	return s.tun.ReadFrom(p)
+}
+
+func (s *switchableConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if !s.switched.Load() {
+		return 0, ErrTunnelNotReady
+	}
+	return s.tun.WriteTo(p, addr)
+}
+
+func (s *switchableConn) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		if s.switched.Load() {
+			err = s.tun.Close()
+		}
+	})
+	return err
+}
+
+// conn is connection packets go through now, nil while they are blocked until tunnel
+func (s *switchableConn) conn() net.PacketConn {
+	if s.switched.Load() {
+		return s.tun
+	}
+	return nil
+}
+
+func (s *switchableConn) LocalAddr() net.Addr {
+	if c := s.conn(); c != nil {
+		return c.LocalAddr()
+	}
+	return &net.UDPAddr{}
+}
+
+func (s *switchableConn) SetDeadline(t time.Time) error {
+	if c := s.conn(); c != nil {
+		return c.SetDeadline(t)
+	}
+	return nil
+}
+
+func (s *switchableConn) SetReadDeadline(t time.Time) error {
+	if c := s.conn(); c != nil {
+		return c.SetReadDeadline(t)
+	}
+	return nil
+}
+
+func (s *switchableConn) SetWriteDeadline(t time.Time) error {
+	if c := s.conn(); c != nil {
+		return c.SetWriteDeadline(t)
+	}
+	return nil
+}
+
+// preTunnel serves bags directly until tunnel is ready, from random local port. Its server and DHT
+// have one-time keys, so peers which see it directly can't link it with storage key used through tunnel.
+type preTunnel struct {
+	srv   *storage.Server
+	close func()
+}
+
+func startPreTunnel(lsCfg *liteclient.GlobalConfig, listenThreads int) (*preTunnel, error) {
+	dl, err := adnl.DefaultListener("0.0.0.0:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pre tunnel listener: %w", err)
+	}
+	mgr := adnl.NewMultiNetReader(dl)
+
+	dhtClient, closeDHT, err := startDHT(mgr, lsCfg)
+	if err != nil {
+		mgr.Close()
+		return nil, err
+	}
+
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		closeDHT()
+		mgr.Close()
+		return nil, fmt.Errorf("failed to generate ed25519 pre tunnel adnl key: %w", err)
+	}
+
+	gate := adnl.NewGatewayWithNetManager(key, mgr)
+	if err = gate.StartClient(listenThreads); err != nil {
+		closeDHT()
+		mgr.Close()
+		return nil, fmt.Errorf("failed to start pre tunnel adnl gateway: %w", err)
+	}
+
+	p := &preTunnel{srv: storage.NewServer(dhtClient, gate, key, false, 8)}
+	var once sync.Once
+	p.close = func() {
+		once.Do(func() {
+			p.srv.Stop()
+			gate.Close()
+			closeDHT()
+			mgr.Close()
+		})
+	}
+	return p, nil
+}
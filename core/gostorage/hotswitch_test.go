@@ -0,0 +1,84 @@
+package gostorage
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSwitchableConn(t *testing.T) {
+	tun, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	sw := newSwitchableConn(tun)
+	defer sw.Close()
+
+	// nothing goes out before tunnel, so real address is not seen with storage key
+	if _, err = sw.WriteTo([]byte("x"), peer.LocalAddr()); !errors.Is(err, ErrTunnelNotReady) {
+		t.Fatal("written before switch:", err)
+	}
+
+	read := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 16)
+		n, _, err := sw.ReadFrom(buf)
+		if err != nil {
+			read <- err.Error()
+			return
+		}
+		read <- string(buf[:n])
+	}()
+
+	if _, err = peer.WriteTo([]byte("early"), tun.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-read:
+		t.Fatal("read before switch:", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	sw.switchToTunnel()
+	select {
+	case got := <-read:
+		if got != "early" {
+			t.Fatalf("read %q after switch", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("read is not unblocked by switch")
+	}
+
+	if _, err = sw.WriteTo([]byte("x"), peer.LocalAddr()); err != nil {
+		t.Fatal("write after switch:", err)
+	}
+}
+
+func TestSwitchableConnClose(t *testing.T) {
+	sw := newSwitchableConn(nil)
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := sw.ReadFrom(make([]byte, 16))
+		done <- err
+	}()
+
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Fatal("unexpected read error:", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("read is not unblocked by close")
+	}
+}
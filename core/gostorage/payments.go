@@ -0,0 +1,202 @@
+package gostorage
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ton-blockchain/adnl-tunnel/tunnel"
+	"github.com/xssnick/ton-payment-network/tonpayments/db"
+	"github.com/xssnick/ton-payment-network/tonpayments/db/leveldb"
+	"github.com/xssnick/tonutils-go/tlb"
+)
+
+// ErrNoPayments is returned when tunnel payments are not enabled or were never used
+var ErrNoPayments = fmt.Errorf("tunnel payments are not used")
+
+// paymentsSnapshotTTL is how long copy of payments db is reused, so channels and their history are read from one copy
+const paymentsSnapshotTTL = 5 * time.Second
+
+// paymentsSnapshot is a copy of payments db. Db is locked by payments service of running tunnel,
+// and library does not export the service, so db is copied to read it.
+type paymentsSnapshot struct {
+	path string
+	key  ed25519.PublicKey
+
+	dir string
+	db  *db.DB
+	at  time.Time
+	mx  sync.Mutex
+}
+
+// with runs f on fresh enough copy of db
+func (s *paymentsSnapshot) with(f func(d *db.DB) error) error {
+	if s == nil {
+		return ErrNoPayments
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.db == nil || time.Since(s.at) > paymentsSnapshotTTL {
+		s.close()
+
+		var err error
+		// file can be removed by compaction while it is copied, next try takes new files
+		for i := 0; i < 3; i++ {
+			if err = s.open(); err == nil || errors.Is(err, ErrNoPayments) {
+				break
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return f(s.db)
+}
+
+func (s *paymentsSnapshot) open() error {
+	files, err := os.ReadDir(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNoPayments
+		}
+		return fmt.Errorf("failed to read payments db: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "tonutils-payments-snapshot")
+	if err != nil {
+		return fmt.Errorf("failed to create payments db copy: %w", err)
+	}
+
+	for _, f := range files {
+		if f.IsDir() || f.Name() == "LOCK" {
+			continue
+		}
+		if err = copyDBFile(filepath.Join(s.path, f.Name()), filepath.Join(dir, f.Name())); err != nil && !os.IsNotExist(err) {
+			_ = os.RemoveAll(dir)
+			return fmt.Errorf("failed to copy payments db: %w", err)
+		}
+	}
+
+	ldb, _, err := leveldb.NewLevelDB(dir)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return fmt.Errorf("failed to open payments db copy: %w", err)
+	}
+
+	s.dir, s.db, s.at = dir, db.NewDB(ldb, s.key), time.Now()
+	return nil
+}
+
+// close must be called under lock
+func (s *paymentsSnapshot) close() {
+	if s.db == nil {
+		return
+	}
+	s.db.Close()
+	_ = os.RemoveAll(s.dir)
+	s.db, s.dir = nil, ""
+}
+
+func (s *paymentsSnapshot) Close() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.close()
+}
+
+func copyDBFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// addTunnel keeps tunnel till it is closed, to count its payments in session
+func (c *Client) addTunnel(t *tunnel.RegularOutTunnel) {
+	c.tunnelsMx.Lock()
+	c.tunnels = append(c.tunnels, t)
+	c.tunnelsMx.Unlock()
+
+	go func() {
+		<-t.AliveCtx().Done()
+
+		c.tunnelsMx.Lock()
+		defer c.tunnelsMx.Unlock()
+
+		c.paidBase = addPaid(c.paidBase, t.CalcPaidAmount())
+		for i, x := range c.tunnels {
+			if x == t {
+				c.tunnels = append(c.tunnels[:i], c.tunnels[i+1:]...)
+				break
+			}
+		}
+	}()
+}
+
+// SessionPaid is amount paid for all tunnels since client start, by currency symbol
+func (c *Client) SessionPaid() map[string]tlb.Coins {
+	c.tunnelsMx.Lock()
+	defer c.tunnelsMx.Unlock()
+
+	res := addPaid(nil, c.paidBase)
+	for _, t := range c.tunnels {
+		res = addPaid(res, t.CalcPaidAmount())
+	}
+	return res
+}
+
+func addPaid(to, paid map[string]tlb.Coins) map[string]tlb.Coins {
+	res := map[string]tlb.Coins{}
+	for s, v := range to {
+		res[s] = v
+	}
+	for s, v := range paid {
+		if cur, ok := res[s]; ok {
+			v = tlb.MustFromNano(new(big.Int).Add(cur.Nano(), v.Nano()), v.Decimals())
+		}
+		res[s] = v
+	}
+	return res
+}
+
+// GetPaymentChannels lists onchain channels of payments node, they are in payments db
+func (c *Client) GetPaymentChannels(ctx context.Context) (list []*db.Channel, err error) {
+	err = c.payments.with(func(d *db.DB) error {
+		if list, err = d.GetChannels(ctx, nil, db.ChannelStateAny); err != nil {
+			return fmt.Errorf("failed to list channels: %w", err)
+		}
+		return nil
+	})
+	return list, err
+}
+
+// GetPaymentHistory returns events of channel after given time, up to limit
+func (c *Client) GetPaymentHistory(ctx context.Context, channel string, limit int, after time.Time) (list []db.ChannelHistoryItem, err error) {
+	err = c.payments.with(func(d *db.DB) error {
+		if list, err = d.GetChannelsHistoryByPeriod(ctx, channel, limit, nil, &after); err != nil {
+			return fmt.Errorf("failed to get history of channel %s: %w", channel, err)
+		}
+		return nil
+	})
+	return list, err
+}
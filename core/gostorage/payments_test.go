@@ -0,0 +1,90 @@
+package gostorage
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xssnick/ton-payment-network/tonpayments/db"
+	"github.com/xssnick/ton-payment-network/tonpayments/db/leveldb"
+)
+
+func testChannel(addr string) *db.Channel {
+	id := make([]byte, 16)
+	copy(id, addr)
+	return &db.Channel{
+		ID:      id,
+		Address: addr,
+		Status:  db.ChannelStateActive,
+		Our:     db.NewSide(id, 0, 0),
+		Their:   db.NewSide(id, 0, 0),
+	}
+}
+
+func TestPaymentsSnapshot(t *testing.T) {
+	ctx := context.Background()
+	pub, _, _ := ed25519.GenerateKey(nil)
+	path := filepath.Join(t.TempDir(), "payments-db")
+
+	// db stays open and locked, like payments service of running tunnel keeps it
+	ldb, _, err := leveldb.NewLevelDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := db.NewDB(ldb, pub)
+	defer live.Close()
+
+	if err = live.CreateChannel(ctx, testChannel("ch1")); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &paymentsSnapshot{path: path, key: pub}
+	defer s.Close()
+
+	count := func() int {
+		var list []*db.Channel
+		if err := s.with(func(d *db.DB) (err error) {
+			list, err = d.GetChannels(ctx, nil, db.ChannelStateAny)
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return len(list)
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("%d channels are read, expected 1", n)
+	}
+
+	if err = live.CreateChannel(ctx, testChannel("ch2")); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("%d channels are read from the same copy, expected 1", n)
+	}
+
+	dir := s.dir
+	s.at = time.Now().Add(-paymentsSnapshotTTL)
+	if n := count(); n != 2 {
+		t.Fatalf("%d channels are read from new copy, expected 2", n)
+	}
+	if s.dir == dir {
+		t.Fatal("copy is not renewed")
+	}
+}
+
+func TestPaymentsSnapshotNotUsed(t *testing.T) {
+	f := func(d *db.DB) error { return nil }
+
+	var none *paymentsSnapshot
+	if err := none.with(f); !errors.Is(err, ErrNoPayments) {
+		t.Fatal("payments are used when they are not enabled:", err)
+	}
+
+	s := &paymentsSnapshot{path: filepath.Join(t.TempDir(), "payments-db")}
+	if err := s.with(f); !errors.Is(err, ErrNoPayments) {
+		t.Fatal("payments are used when db is not created:", err)
+	}
+}
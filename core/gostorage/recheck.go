@@ -0,0 +1,157 @@
+package gostorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/xssnick/tonutils-storage/storage"
+)
+
+// RecheckTorrent rehashes every stored piece against bag root hash,
+// corrupted pieces are removed, so bag becomes incomplete and they can be downloaded again
+func (c *Client) RecheckTorrent(ctx context.Context, hash []byte, progressCallback func(done uint64, max uint64)) (uint32, error) {
+	t := c.storage.GetTorrent(hash)
+	if t == nil {
+		return 0, fmt.Errorf("torrent is not found")
+	}
+
+	if t.Info == nil || t.Header == nil {
+		return 0, fmt.Errorf("bag header is not downloaded yet, nothing to check")
+	}
+
+	if !c.setRechecking(t.BagID, true) {
+		return 0, fmt.Errorf("bag is already being checked")
+	}
+	defer c.setRechecking(t.BagID, false)
+
+	bad, err := verifyPieces(ctx, t, progressCallback)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(bad) > 0 {
+		if _, err = c.reloadTorrent(t, t.Path, bad); err != nil {
+			return 0, fmt.Errorf("failed to remove corrupted pieces: %w", err)
+		}
+	}
+	return uint32(len(bad)), nil
+}
+
+func (c *Client) setRechecking(bagId []byte, val bool) bool {
+	c.healthMx.Lock()
+	defer c.healthMx.Unlock()
+
+	if val && c.rechecking[string(bagId)] {
+		return false
+	}
+
+	if val {
+		c.rechecking[string(bagId)] = true
+	} else {
+		delete(c.rechecking, string(bagId))
+	}
+	return true
+}
+
+func (c *Client) isRechecking(bagId []byte) bool {
+	c.healthMx.Lock()
+	defer c.healthMx.Unlock()
+
+	return c.rechecking[string(bagId)]
+}
+
+func verifyPieces(ctx context.Context, t *storage.Torrent, progressCallback func(done uint64, max uint64)) ([]uint32, error) {
+	num := t.Info.PiecesNum()
+	mask := t.PiecesMask()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan uint32, 64)
+	go func() {
+		defer close(jobs)
+		for i := uint32(0); i < num; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- i:
+			}
+		}
+	}()
+
+	var done uint64
+	var bad []uint32
+	var mx sync.Mutex
+	var wg sync.WaitGroup
+
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				if mask[id/8]&(1<<(id%8)) != 0 {
+					// proof is returned only when piece data is matching root hash
+					// piece removed from db meanwhile is not bad, library wraps not found error of db
+					if _, err := t.GetPieceProof(id); err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+						mx.Lock()
+						bad = append(bad, id)
+						mx.Unlock()
+					}
+				}
+
+				if progressCallback != nil {
+					mx.Lock()
+					done++
+					progressCallback(done, uint64(num))
+					mx.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(bad, func(i, j int) bool { return bad[i] < bad[j] })
+	return bad, nil
+}
+
+// reloadTorrent removes pieces from db and replaces bag object, optionally with new root path,
+// library keeps pieces mask in memory and has no way to drop single piece from outside
+func (c *Client) reloadTorrent(t *storage.Torrent, path string, pieces []uint32) (*storage.Torrent, error) {
+	download, upload := c.isActive(t)
+
+	c.setExpectedActive(t.BagID, false)
+	t.Stop()
+	t.Wait()
+
+	for _, id := range pieces {
+		if err := c.storage.RemovePiece(t.BagID, id); err != nil {
+			return nil, fmt.Errorf("failed to remove piece %d: %w", id, err)
+		}
+	}
+
+	n := cloneTorrent(t, path, c.storage, c.connector)
+	if err := n.LoadActiveFilesIDs(); err != nil {
+		return nil, err
+	}
+	n.SetUploadStats(t.GetUploadStats())
+
+	if err := c.storage.SetTorrent(n); err != nil {
+		return nil, fmt.Errorf("failed to save bag: %w", err)
+	}
+
+	if download || upload {
+		if err := c.applyActivity(n, download, upload); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,194 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/xssnick/tonutils-storage/storage"
+)
+
+var errBagMismatch = errors.New("data does not match bag")
+
+// bagStorage overrides piece size of created bags, and when bag id is known
+// it refuses to write anything for other bags, so failed rehash leaves no garbage in db
+type bagStorage struct {
+	storage.Storage
+	pieceSize uint32
+	bagId     []byte
+}
+
+func (s *bagStorage) GetForcedPieceSize() uint32 {
+	return s.pieceSize
+}
+
+func (s *bagStorage) SetPiece(bagId []byte, id uint32, p *storage.PieceInfo) error {
+	if s.bagId != nil && !bytes.Equal(bagId, s.bagId) {
+		return errBagMismatch
+	}
+	return s.Storage.SetPiece(bagId, id, p)
+}
+
+func (s *bagStorage) SetActiveFiles(bagId []byte, ids []uint32) error {
+	if s.bagId != nil && !bytes.Equal(bagId, s.bagId) {
+		return errBagMismatch
+	}
+	return s.Storage.SetActiveFiles(bagId, ids)
+}
+
+type diskFileRef struct {
+	path string
+	name string
+	size uint64
+}
+
+func (f *diskFileRef) GetName() string {
+	return f.name
+}
+
+func (f *diskFileRef) GetSize() uint64 {
+	return f.size
+}
+
+func (f *diskFileRef) CreateReader() (io.ReaderAt, func() error, error) {
+	fl, err := os.Open(f.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fl, fl.Close, nil
+}
+
+// RelocateTorrent points bag to directory which already has its data and verifies it.
+// When all files are in place, proofs are rebuilt from data, so bag can be seeded without download,
+// otherwise only already known pieces are checked and the rest will be downloaded.
+func (c *Client) RelocateTorrent(ctx context.Context, hash []byte, dir string, progressCallback func(done uint64, max uint64)) error {
+	t := c.storage.GetTorrent(hash)
+	if t == nil {
+		return fmt.Errorf("torrent is not found")
+	}
+
+	if t.Info == nil || t.Header == nil {
+		return fmt.Errorf("bag header is not downloaded yet, cannot check files")
+	}
+
+	if !c.setRechecking(t.BagID, true) {
+		return fmt.Errorf("bag is already being checked")
+	}
+	defer c.setRechecking(t.BagID, false)
+
+	root := resolveRoot(string(t.Header.DirName), dir)
+
+	var bad []uint32
+	refs, err := existingFiles(t, root)
+	if err == nil {
+		if err = rehash(ctx, t, root, refs, c.storage, c.connector, progressCallback); err != nil {
+			return err
+		}
+	} else {
+		log.Info().Err(err).Str("path", root).Msg("not all files of bag are in place, checking only stored pieces")
+
+		probe := cloneTorrent(t, root, c.storage, c.connector)
+		if bad, err = verifyPieces(ctx, probe, progressCallback); err != nil {
+			return err
+		}
+	}
+
+	n, err := c.reloadTorrent(t, root, bad)
+	if err != nil {
+		return err
+	}
+
+	if len(n.GetActiveFilesIDs()) == 0 {
+		// nothing was selected yet, bag pointed to data is expected to have all of it
+		all := make([]uint32, n.Header.FilesCount)
+		for i := range all {
+			all[i] = uint32(i)
+		}
+		if err = n.SetActiveFilesIDs(all); err != nil {
+			return fmt.Errorf("failed to select files: %w", err)
+		}
+	}
+
+	c.resolveError(n.BagID, ErrKindMissingFiles)
+	return nil
+}
+
+// resolveRoot accepts both parent directory and bag directory itself
+func resolveRoot(dirName, dir string) string {
+	dir = filepath.Clean(dir)
+
+	name := strings.TrimSuffix(dirName, "/")
+	if name == "" || filepath.Base(dir) != name {
+		return dir
+	}
+
+	if st, err := os.Stat(filepath.Join(dir, name)); err == nil && st.IsDir() {
+		// bag dir has nested dir with same name
+		return dir
+	}
+	return filepath.Dir(dir)
+}
+
+func existingFiles(t *storage.Torrent, root string) ([]storage.FileRef, error) {
+	refs := make([]storage.FileRef, 0, t.Header.FilesCount)
+	for i := uint32(0); i < t.Header.FilesCount; i++ {
+		fi, err := t.GetFileOffsetsByID(i)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get offset for file %d: %w", i, err)
+		}
+
+		path := root + "/" + string(t.Header.DirName) + "/" + fi.Name
+		st, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("file %s is not accessible: %w", fi.Name, err)
+		}
+
+		if uint64(st.Size()) != fi.Size {
+			return nil, fmt.Errorf("file %s has size %d, but %d is expected", fi.Name, st.Size(), fi.Size)
+		}
+
+		refs = append(refs, &diskFileRef{path: path, name: fi.Name, size: fi.Size})
+	}
+	return refs, nil
+}
+
+// rehash builds bag from files with same header and piece size, when result has same id,
+// all pieces with proofs are stored for it
+func rehash(ctx context.Context, t *storage.Torrent, root string, refs []storage.FileRef, db storage.Storage, connector storage.NetConnector, progressCallback func(done uint64, max uint64)) error {
+	bs := &bagStorage{
+		Storage:   db,
+		pieceSize: t.Info.PieceSize,
+		bagId:     t.BagID,
+	}
+
+	header := &storage.TorrentHeader{
+		DirNameSize: t.Header.DirNameSize,
+		DirName:     t.Header.DirName,
+	}
+
+	_, err := storage.CreateTorrentWithInitialHeader(ctx, root, t.Info.Description.Value, header, bs, connector, refs, progressCallback, false)
+	if err != nil {
+		if errors.Is(err, errBagMismatch) {
+			return fmt.Errorf("files in %s are different from bag content", root)
+		}
+		return fmt.Errorf("failed to hash files: %w", err)
+	}
+	return nil
+}
+
+func cloneTorrent(t *storage.Torrent, path string, db storage.Storage, connector storage.NetConnector) *storage.Torrent {
+	n := storage.NewTorrent(path, db, connector)
+	n.Info = t.Info
+	n.Header = t.Header
+	n.BagID = t.BagID
+	n.CreatedAt = t.CreatedAt
+	n.CreatedLocally = t.CreatedLocally
+	n.InitMask()
+	return n
+}
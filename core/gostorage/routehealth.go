@@ -0,0 +1,218 @@
+package gostorage
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/tonutils/torrent-client/core/client"
+	"github.com/xssnick/tonutils-go/adnl"
+	"github.com/xssnick/tonutils-go/adnl/dht"
+	"github.com/xssnick/tonutils-go/liteclient"
+	"github.com/xssnick/tonutils-go/tl"
+)
+
+// healthWindow is period of route health measurement
+const healthWindow = 5 * time.Second
+
+// probeInterval is how often probe is sent through route, probe without answer in probeTimeout is lost
+const (
+	probeInterval = time.Second
+	probeTimeout  = 5 * time.Second
+)
+
+// minLossProbes is minimal number of finished probes in window to measure loss
+const minLossProbes = 3
+
+type probeTarget struct {
+	addr string
+	key  ed25519.PublicKey
+}
+
+// prober pings static dht nodes of network config through tunnel, they are well known and always online.
+// It has own adnl key, so probes are not linked to storage identity.
+type prober struct {
+	gate    *adnl.Gateway
+	targets []probeTarget
+	next    atomic.Uint32
+}
+
+type probeResult struct {
+	rtt time.Duration
+	err error
+	at  time.Time
+}
+
+func startProber(mgr adnl.NetManager, lsCfg *liteclient.GlobalConfig) (*prober, func(), error) {
+	var targets []probeTarget
+	for _, n := range lsCfg.DHT.StaticNodes.Nodes {
+		key, err := base64.StdEncoding.DecodeString(n.ID.Key)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			continue
+		}
+		for _, a := range n.AddrList.Addrs {
+			ip := make(net.IP, 4)
+			binary.BigEndian.PutUint32(ip, uint32(int32(a.IP)))
+			targets = append(targets, probeTarget{
+				addr: fmt.Sprintf("%s:%d", ip.String(), a.Port),
+				key:  key,
+			})
+		}
+	}
+	if len(targets) == 0 {
+		return nil, nil, fmt.Errorf("no dht nodes in network config to probe route")
+	}
+
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate ed25519 probe adnl key: %w", err)
+	}
+
+	gate := adnl.NewGatewayWithNetManager(key, mgr)
+	if err = gate.StartClient(); err != nil {
+		return nil, nil, fmt.Errorf("failed to init probe adnl gateway: %w", err)
+	}
+
+	p := &prober{gate: gate, targets: targets}
+	p.next.Store(uint32(rand.Intn(len(targets))))
+	return p, func() {
+		gate.Close()
+	}, nil
+}
+
+// probe sends dht ping to next target, adnl resends it till answer, so rtt is time till the first answer
+func (p *prober) probe(ctx context.Context) (time.Duration, error) {
+	t := p.targets[int(p.next.Add(1))%len(p.targets)]
+	peer, err := p.gate.RegisterClient(t.addr, t.key)
+	if err != nil {
+		return 0, err
+	}
+
+	val, err := tl.Serialize(dht.Ping{ID: rand.Int63()}, true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to serialize dht ping: %w", err)
+	}
+
+	start := time.Now()
+	var res any
+	if err = peer.Query(ctx, tl.Raw(val), &res); err != nil {
+		return 0, err
+	}
+	if _, ok := res.(dht.Pong); !ok {
+		return 0, fmt.Errorf("unexpected answer to dht ping: %T", res)
+	}
+	return time.Since(start), nil
+}
+
+// monitorHealth measures route health till tunnel is alive
+func (c *Client) monitorHealth(r *tunnelRoute) {
+	ctx := r.tun.AliveCtx()
+	c.tunnelsMx.Lock()
+	since := r.info.Since
+	c.tunnelsMx.Unlock()
+
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+
+	results := make(chan probeResult, 2*probeTimeout/probeInterval)
+	var rtts []time.Duration
+	var probes, lost uint64
+	var lastAnswer time.Time
+
+	in, out := c.trafficCounters()
+	start := time.Now()
+
+	for {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case res := <-results:
+			probes++
+			if res.err != nil {
+				lost++
+				continue
+			}
+			rtts = append(rtts, res.rtt)
+			lastAnswer = res.at
+			continue
+		case now = <-ticker.C:
+		}
+
+		c.tunnelsMx.Lock()
+		p := c.prober
+		c.tunnelsMx.Unlock()
+
+		if p != nil {
+			go func() {
+				probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+				rtt, err := p.probe(probeCtx)
+				cancel()
+				if ctx.Err() != nil {
+					return
+				}
+				results <- probeResult{rtt: rtt, err: err, at: time.Now()}
+			}()
+		}
+
+		if now.Sub(start) < healthWindow {
+			continue
+		}
+
+		h := client.TunnelHealth{
+			MeasuredAt: now,
+		}
+		if p != nil {
+			answered := lastAnswer
+			if answered.IsZero() {
+				answered = since
+			}
+			h.NoAnswerFor = now.Sub(answered).Round(time.Second)
+		}
+		if len(rtts) > 0 {
+			var sum time.Duration
+			for _, rtt := range rtts {
+				sum += rtt
+			}
+			h.RTT = sum / time.Duration(len(rtts))
+		}
+		if probes >= minLossProbes {
+			h.Packets = probes
+			h.Loss = float64(lost) / float64(probes)
+		}
+
+		newIn, newOut := c.trafficCounters()
+		secs := now.Sub(start).Seconds()
+		h.In, h.Out = uint64(float64(newIn-in)/secs), uint64(float64(newOut-out)/secs)
+
+		in, out = newIn, newOut
+		rtts, probes, lost = rtts[:0], 0, 0
+		start = now
+
+		c.tunnelsMx.Lock()
+		r.info.Health = h
+		r.info.LastCheckedAt = lastAnswer
+		info := c.routeInfo(r)
+		c.tunnelsMx.Unlock()
+
+		if c.onRouteHealth != nil {
+			c.onRouteHealth(info)
+		}
+	}
+}
+
+func (c *Client) trafficCounters() (in, out uint64) {
+	c.tunnelsMx.Lock()
+	defer c.tunnelsMx.Unlock()
+
+	if c.traffic == nil {
+		return 0, 0
+	}
+	return c.traffic.in.Load(), c.traffic.out.Load()
+}
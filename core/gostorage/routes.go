@@ -0,0 +1,217 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync/atomic"
+	"time"
+
+	tunnelConfig "github.com/ton-blockchain/adnl-tunnel/config"
+	"github.com/ton-blockchain/adnl-tunnel/tunnel"
+	"github.com/tonutils/torrent-client/core/client"
+)
+
+// routes to keep in history, including current
+const routesHistoryMax = 20
+
+// countingConn counts traffic through tunnels
+type countingConn struct {
+	net.PacketConn
+	in, out atomic.Uint64
+}
+
+func (c *countingConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(p)
+	c.in.Add(uint64(n))
+	return n, addr, err
+}
+
+func (c *countingConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	n, err := c.PacketConn.WriteTo(p, addr)
+	c.out.Add(uint64(n))
+	return n, err
+}
+
+type tunnelRoute struct {
+	tun  *tunnel.RegularOutTunnel
+	info client.TunnelRoute
+
+	// traffic counters when route was started
+	in, out uint64
+}
+
+// ErrPinnedRoute is returned when route through all pinned nodes can not be built
+var ErrPinnedRoute = errors.New("route with pinned nodes can not be built")
+
+// maxPinnedRouteAttempts is how many routes in a row can fail while nodes are pinned, before giving up
+const maxPinnedRouteAttempts = 20
+
+// pinNodes leaves in pool pinned nodes and random others up to sections number.
+// Library builds way to the outer node through that many different nodes of pool, so every pinned node is in route.
+func pinNodes(pool []tunnelConfig.TunnelRouteSection, keys [][]byte, sections uint, paymentsEnabled bool) ([]tunnelConfig.TunnelRouteSection, error) {
+	if sections == 0 {
+		sections = 1
+	}
+	if uint(len(keys)) > sections {
+		return nil, fmt.Errorf("%w: %d nodes are pinned, but route has only %d sections", ErrPinnedRoute, len(keys), sections)
+	}
+
+	var res, rest []tunnelConfig.TunnelRouteSection
+next:
+	for _, n := range pool {
+		if !paymentsEnabled && n.Payment != nil {
+			// library skips paid nodes too
+			continue
+		}
+		for _, k := range keys {
+			if bytes.Equal(n.Key, k) {
+				res = append(res, n)
+				continue next
+			}
+		}
+		rest = append(rest, n)
+	}
+	if len(res) != len(keys) {
+		return nil, fmt.Errorf("%w: some pinned nodes are not in pool or require disabled payments", ErrPinnedRoute)
+	}
+
+	rand.Shuffle(len(rest), func(i, j int) {
+		rest[i], rest[j] = rest[j], rest[i]
+	})
+	for _, n := range rest {
+		if uint(len(res)) >= sections {
+			break
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+// excludeNodes removes nodes with given keys from pool
+func excludeNodes(pool []tunnelConfig.TunnelRouteSection, keys [][]byte) []tunnelConfig.TunnelRouteSection {
+	var res []tunnelConfig.TunnelRouteSection
+next:
+	for _, n := range pool {
+		for _, k := range keys {
+			if bytes.Equal(n.Key, k) {
+				continue next
+			}
+		}
+		res = append(res, n)
+	}
+	return res
+}
+
+// routeSections converts route given to acceptor, library does not export route of built tunnel
+func routeSections(to, from []*tunnel.SectionInfo) []client.TunnelSection {
+	var res []client.TunnelSection
+	for i, n := range append(append([]*tunnel.SectionInfo{}, to...), from...) {
+		if n == nil || n.Keys == nil {
+			continue
+		}
+
+		s := client.TunnelSection{
+			Key:     n.Keys.ReceiverPubKey,
+			Outer:   i == len(to)-1,
+			Inbound: i >= len(to),
+		}
+		if p := n.PaymentInfo; p != nil {
+			s.Payment = &client.TunnelSectionPayment{
+				PricePerPacket:  p.PricePerPacket,
+				ExtraCurrencyID: p.ExtraCurrencyID,
+			}
+			if p.JettonMaster != nil {
+				s.Payment.JettonMaster = p.JettonMaster.Bounce(true).String()
+			}
+			for _, ps := range p.PaymentTunnel {
+				s.Payment.ProxyFees = append(s.Payment.ProxyFees, ps.MinFee)
+			}
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+// addRoute starts route of new tunnel, current one is finished, because connection is switched to new tunnel
+func (c *Client) addRoute(t *tunnel.RegularOutTunnel, extAddr string) {
+	r := &tunnelRoute{
+		tun: t,
+		info: client.TunnelRoute{
+			ExtAddr: extAddr,
+			Since:   time.Now(),
+		},
+	}
+
+	c.tunnelsMx.Lock()
+	// tunnel is built for the last accepted route
+	r.info.Sections, c.accepted = c.accepted, nil
+	if c.traffic != nil {
+		r.in, r.out = c.traffic.in.Load(), c.traffic.out.Load()
+	}
+	for _, x := range c.routes {
+		c.finishRoute(x)
+	}
+	c.routes = append(c.routes, r)
+	if len(c.routes) > routesHistoryMax {
+		c.routes = c.routes[len(c.routes)-routesHistoryMax:]
+	}
+	c.tunnelsMx.Unlock()
+
+	go func() {
+		<-t.AliveCtx().Done()
+
+		c.tunnelsMx.Lock()
+		c.finishRoute(r)
+		c.tunnelsMx.Unlock()
+	}()
+	go c.monitorHealth(r)
+}
+
+// setRouteAddr updates external address of route, it can be changed by outer node
+func (c *Client) setRouteAddr(t *tunnel.RegularOutTunnel, extAddr string) {
+	c.tunnelsMx.Lock()
+	defer c.tunnelsMx.Unlock()
+
+	for _, r := range c.routes {
+		if r.tun == t {
+			r.info.ExtAddr = extAddr
+		}
+	}
+}
+
+// finishRoute must be called under lock
+func (c *Client) finishRoute(r *tunnelRoute) {
+	if !r.info.Until.IsZero() {
+		return
+	}
+	r.info = c.routeInfo(r)
+	r.info.Until = time.Now()
+}
+
+// routeInfo must be called under lock
+func (c *Client) routeInfo(r *tunnelRoute) client.TunnelRoute {
+	info := r.info
+	if info.Until.IsZero() {
+		if c.traffic != nil {
+			info.BytesIn = c.traffic.in.Load() - r.in
+			info.BytesOut = c.traffic.out.Load() - r.out
+		}
+	}
+	return info
+}
+
+// GetTunnelRoutes returns routes of tunnel since client start, newest first, empty when tunnel is not used
+func (c *Client) GetTunnelRoutes(ctx context.Context) ([]client.TunnelRoute, error) {
+	c.tunnelsMx.Lock()
+	defer c.tunnelsMx.Unlock()
+
+	res := make([]client.TunnelRoute, 0, len(c.routes))
+	for i := len(c.routes) - 1; i >= 0; i-- {
+		res = append(res, c.routeInfo(c.routes[i]))
+	}
+	return res, nil
+}
@@ -0,0 +1,110 @@
+package gostorage
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	tunnelConfig "github.com/ton-blockchain/adnl-tunnel/config"
+	"github.com/ton-blockchain/adnl-tunnel/tunnel"
+	"github.com/xssnick/tonutils-go/address"
+)
+
+func testPool(n int, paid ...int) []tunnelConfig.TunnelRouteSection {
+	pool := make([]tunnelConfig.TunnelRouteSection, n)
+	for i := range pool {
+		pool[i].Key = bytes.Repeat([]byte{byte(i + 1)}, 32)
+	}
+	for _, i := range paid {
+		pool[i].Payment = &tunnelConfig.TunnelSectionPayment{}
+	}
+	return pool
+}
+
+func TestPinNodes(t *testing.T) {
+	pool := testPool(10, 3)
+	key := func(i int) []byte { return pool[i].Key }
+
+	tests := []struct {
+		name     string
+		keys     [][]byte
+		sections uint
+		payments bool
+		err      bool
+	}{
+		{"one pinned", [][]byte{key(5)}, 3, true, false},
+		{"all sections pinned", [][]byte{key(0), key(5), key(9)}, 3, true, false},
+		{"zero sections means one", [][]byte{key(2)}, 0, true, false},
+		{"paid node pinned", [][]byte{key(3)}, 2, true, false},
+		{"more pinned than sections", [][]byte{key(0), key(1), key(2)}, 2, true, true},
+		{"pinned node is not in pool", [][]byte{bytes.Repeat([]byte{0xFF}, 32)}, 3, true, true},
+		{"paid node pinned without payments", [][]byte{key(3)}, 3, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := pinNodes(pool, tt.keys, tt.sections, tt.payments)
+			if tt.err {
+				if !errors.Is(err, ErrPinnedRoute) {
+					t.Fatalf("error %v, expected pinned route error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sections := int(max(tt.sections, 1))
+			if len(res) != sections {
+				t.Fatalf("pool of %d nodes, route has %d sections", len(res), sections)
+			}
+			for _, k := range tt.keys {
+				found := false
+				for _, n := range res {
+					found = found || bytes.Equal(n.Key, k)
+				}
+				if !found {
+					t.Fatalf("pinned node %x is not in pool", k[:4])
+				}
+			}
+			for _, n := range res {
+				if !tt.payments && n.Payment != nil {
+					t.Fatal("paid node is in pool while payments are disabled")
+				}
+			}
+		})
+	}
+}
+
+func TestRouteSections(t *testing.T) {
+	node := func(b byte, p *tunnel.Payer) *tunnel.SectionInfo {
+		return &tunnel.SectionInfo{Keys: &tunnel.EncryptionKeys{ReceiverPubKey: bytes.Repeat([]byte{b}, 32)}, PaymentInfo: p}
+	}
+	jetton := address.MustParseAddr("EQCxE6mUtQJKFnGfaROTKOt1lZbDiiX1kCixRv7Nw2Id_sDs")
+
+	to := []*tunnel.SectionInfo{
+		node(1, nil),
+		node(2, &tunnel.Payer{PricePerPacket: 5, JettonMaster: jetton.Bounce(false), PaymentTunnel: []tunnel.PaymentTunnelSection{{MinFee: big.NewInt(7)}}}),
+	}
+	from := []*tunnel.SectionInfo{node(3, &tunnel.Payer{PricePerPacket: 1, ExtraCurrencyID: 9}), {}}
+
+	res := routeSections(to, from)
+	if len(res) != 3 {
+		t.Fatalf("%d sections, expected 3, section without keys is skipped", len(res))
+	}
+	for i, s := range res {
+		if s.Key[0] != byte(i+1) || s.Outer != (i == 1) || s.Inbound != (i == 2) {
+			t.Fatalf("section %d: key %x outer %v inbound %v", i, s.Key[:1], s.Outer, s.Inbound)
+		}
+	}
+	if res[0].Payment != nil {
+		t.Fatal("free node has payment")
+	}
+	if p := res[1].Payment; p.PricePerPacket != 5 || p.JettonMaster != jetton.Bounce(true).String() || len(p.ProxyFees) != 1 || p.ProxyFees[0].Int64() != 7 {
+		t.Fatalf("outer node payment %+v", *p)
+	}
+	if p := res[2].Payment; p.ExtraCurrencyID != 9 || p.JettonMaster != "" {
+		t.Fatalf("inbound node payment %+v", *p)
+	}
+}
@@ -0,0 +1,195 @@
+package gostorage
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/rs/zerolog/log"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/xssnick/tonutils-go/adnl"
+	"github.com/xssnick/tonutils-go/adnl/address"
+	"github.com/xssnick/tonutils-go/adnl/dht"
+	"github.com/xssnick/tonutils-go/adnl/overlay"
+	"github.com/xssnick/tonutils-go/liteclient"
+	"github.com/xssnick/tonutils-storage/db"
+	"github.com/xssnick/tonutils-storage/storage"
+)
+
+const (
+	// RouteAll sends all ADNL traffic through tunnel
+	RouteAll = ""
+	// RouteBagsOnly sends DHT and provider traffic directly, only traffic of bag peers goes through tunnel
+	RouteBagsOnly = "bags"
+	// RoutePrivateOnly sends through tunnel only bags marked private with their DHT lookups, others go directly
+	RoutePrivateOnly = "private"
+)
+
+func privateKey(bagId []byte) []byte {
+	return append([]byte("tt_private:"), bagId...)
+}
+
+// routedConnector connects to peers of bag by its route, private bags use tunnel server
+type routedConnector struct {
+	*storage.Connector
+	direct  storage.TorrentServer
+	private func(bagId []byte) bool
+}
+
+func (r *routedConnector) ConnectToNode(ctx context.Context, t *storage.Torrent, node *overlay.Node, addrs *address.List) error {
+	if !r.private(t.BagID) {
+		return r.direct.ConnectToNode(ctx, t, node, addrs)
+	}
+	return r.Connector.ConnectToNode(ctx, t, node, addrs)
+}
+
+// routedStorage is storage as seen by server of one route, bags of other route
+// are not searched in DHT and not served to peers by it
+type routedStorage struct {
+	*db.Storage
+	private   bool
+	isPrivate func(bagId []byte) bool
+}
+
+func (s *routedStorage) GetAll() []*storage.Torrent {
+	var res []*storage.Torrent
+	for _, t := range s.Storage.GetAll() {
+		if s.isPrivate(t.BagID) == s.private {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+func (s *routedStorage) GetTorrentByOverlay(over []byte) *storage.Torrent {
+	t := s.Storage.GetTorrentByOverlay(over)
+	if t == nil || s.isPrivate(t.BagID) != s.private {
+		return nil
+	}
+	return t
+}
+
+// startDHT starts DHT client with its own gateway on network manager
+func startDHT(mgr adnl.NetManager, lsCfg *liteclient.GlobalConfig) (*dht.Client, func(), error) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate ed25519 dht adnl key: %w", err)
+	}
+
+	gate := adnl.NewGatewayWithNetManager(key, mgr)
+	if err = gate.StartClient(); err != nil {
+		return nil, nil, fmt.Errorf("failed to init dht adnl gateway: %w", err)
+	}
+
+	dhtClient, err := dht.NewClientFromConfig(gate, lsCfg)
+	if err != nil {
+		gate.Close()
+		return nil, nil, fmt.Errorf("failed to init dht client: %w", err)
+	}
+	return dhtClient, func() {
+		dhtClient.Close()
+		gate.Close()
+	}, nil
+}
+
+// startDirectServer starts storage server for bags which are not private, it has own key,
+// so peers don't mix it with tunnel one, and accepts connections only when external ip is set
+func startDirectServer(cfg Config, mgr adnl.NetManager, dhtClient *dht.Client, listenThreads int) (*storage.Server, func(), error) {
+	seed := sha256.Sum256(append([]byte("direct"), cfg.Key.Seed()...))
+	key := ed25519.NewKeyFromSeed(seed[:])
+	gate := adnl.NewGatewayWithNetManager(key, mgr)
+
+	serverMode := false
+	if ip := net.ParseIP(cfg.ExternalIP); ip != nil {
+		addr, err := netip.ParseAddrPort(cfg.ListenAddr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid listen addr")
+		}
+
+		serverMode = true
+		gate.SetAddressList([]*address.UDP{
+			{
+				IP:   ip.To4(),
+				Port: int32(addr.Port()),
+			},
+		})
+		if err = gate.StartServer(cfg.ListenAddr, listenThreads); err != nil {
+			return nil, nil, fmt.Errorf("failed to start direct adnl gateway in server mode: %w", err)
+		}
+	} else if err := gate.StartClient(listenThreads); err != nil {
+		return nil, nil, fmt.Errorf("failed to start direct adnl gateway: %w", err)
+	}
+
+	srv := storage.NewServer(dhtClient, gate, key, serverMode, 8)
+	return srv, func() {
+		srv.Stop()
+		gate.Close()
+	}, nil
+}
+
+func (c *Client) isPrivate(bagId []byte) bool {
+	c.privateMx.Lock()
+	defer c.privateMx.Unlock()
+
+	if p, ok := c.private[string(bagId)]; ok {
+		return p
+	}
+
+	var p bool
+	data, err := c.db.Get(privateKey(bagId), nil)
+	if err == nil {
+		err = json.Unmarshal(data, &p)
+	}
+	if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+		log.Error().Err(err).Msg("failed to load bag route")
+	}
+
+	c.private[string(bagId)] = p
+	return p
+}
+
+func (c *Client) setPrivate(bagId []byte, private bool) error {
+	c.privateMx.Lock()
+	defer c.privateMx.Unlock()
+
+	c.private[string(bagId)] = private
+	if !private {
+		return c.db.Delete(privateKey(bagId), nil)
+	}
+
+	data, err := json.Marshal(private)
+	if err != nil {
+		return err
+	}
+	return c.db.Put(privateKey(bagId), data, nil)
+}
+
+// SetPrivate marks bag to go through tunnel when only private bags use it,
+// active bag is restarted to find its peers by the new route
+func (c *Client) SetPrivate(ctx context.Context, hash []byte, private bool) error {
+	t := c.storage.GetTorrent(hash)
+	if t == nil {
+		return fmt.Errorf("torrent is not found")
+	}
+
+	if c.isPrivate(t.BagID) == private {
+		return nil
+	}
+	if err := c.setPrivate(t.BagID, private); err != nil {
+		return fmt.Errorf("failed to save bag route: %w", err)
+	}
+
+	download, upload := c.isActive(t)
+	if c.routing != RoutePrivateOnly || (!download && !upload) {
+		return nil
+	}
+
+	c.setExpectedActive(t.BagID, false)
+	t.Stop()
+	return c.applyActivity(t, download, upload)
+}
@@ -0,0 +1,192 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tonutils/torrent-client/core/bagfiles"
+	"github.com/tonutils/torrent-client/core/tonbag"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"github.com/xssnick/tonutils-storage/storage"
+)
+
+// VerifyDirMatchesBag hashes dir as it would be a bag from meta and reports files which are different.
+// Which files have different content can be found only when bag pieces are stored locally,
+// otherwise only root hash is compared.
+func (c *Client) VerifyDirMatchesBag(ctx context.Context, meta []byte, dir string, progressCallback func(done uint64, max uint64)) (*bagfiles.Diff, error) {
+	mf, err := tonbag.Parse(meta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse meta: %w", err)
+	}
+	if mf.Header == nil {
+		return nil, fmt.Errorf("meta has no header, files are unknown")
+	}
+	if err = bagfiles.CheckHeader(mf.Header); err != nil {
+		return nil, err
+	}
+
+	root := resolveRoot(string(mf.Header.DirName), dir)
+	bagDir := filepath.Join(root, string(mf.Header.DirName))
+
+	diff := &bagfiles.Diff{}
+	names := map[string]bool{}
+	refs := make([]storage.FileRef, 0, mf.Header.FilesCount)
+	for i := uint32(0); i < mf.Header.FilesCount; i++ {
+		name, size := bagfiles.HeaderFile(mf.Header, i)
+		names[name] = true
+
+		path := filepath.Join(bagDir, name)
+		st, err := os.Stat(path)
+		if err != nil {
+			diff.Files = append(diff.Files, bagfiles.FileDiff{Name: name, Kind: bagfiles.DiffMissing, Details: err.Error()})
+			continue
+		}
+		if uint64(st.Size()) != size {
+			diff.Files = append(diff.Files, bagfiles.FileDiff{Name: name, Kind: bagfiles.DiffSize,
+				Details: fmt.Sprintf("size is %d, but %d is expected", st.Size(), size)})
+			continue
+		}
+		refs = append(refs, &diskFileRef{path: path, name: name, size: size})
+	}
+
+	if len(mf.Header.DirName) > 0 {
+		// extra files are only meaningful when bag has own dir
+		diff.Files = append(diff.Files, extraFiles(bagDir, names)...)
+	}
+
+	if len(refs) != int(mf.Header.FilesCount) {
+		return diff, nil
+	}
+
+	j, err := newHashJob(root, mf.Header, refs, mf.Info.PieceSize, "")
+	if err != nil {
+		return nil, err
+	}
+	headerHash := sha256.Sum256(j.headerData)
+	if j.pieces != mf.Info.PiecesNum() || !bytes.Equal(headerHash[:], mf.Info.HeaderHash) {
+		return nil, fmt.Errorf("meta header does not match bag info")
+	}
+
+	if err = j.hash(ctx, progressCallback); err != nil {
+		return nil, fmt.Errorf("failed to hash files: %w", err)
+	}
+
+	if bytes.Equal(buildMerkleTree(j.hashes, int(j.pieces)).Hash(), mf.Info.RootHash) {
+		diff.Matches = !hasContentDiff(diff.Files)
+		return diff, nil
+	}
+
+	bad, unknown := c.findBadPieces(j, mf.Hash, mf.Info.RootHash)
+	files := map[uint32]bool{}
+	for _, id := range bad {
+		for _, f := range j.pieceFiles(id) {
+			files[f] = true
+		}
+	}
+
+	for i := uint32(0); i < mf.Header.FilesCount; i++ {
+		if files[i] {
+			name, _ := bagfiles.HeaderFile(mf.Header, i)
+			diff.Files = append(diff.Files, bagfiles.FileDiff{Name: name, Kind: bagfiles.DiffContent, Details: "data is different"})
+		}
+	}
+
+	if unknown > 0 {
+		diff.Details = fmt.Sprintf("root hash is different, %d pieces are not stored locally to compare", unknown)
+	} else if len(bad) == 0 {
+		diff.Details = "root hash is different"
+	}
+	return diff, nil
+}
+
+func hasContentDiff(files []bagfiles.FileDiff) bool {
+	for _, f := range files {
+		if f.Kind != bagfiles.DiffExtra {
+			return true
+		}
+	}
+	return false
+}
+
+func extraFiles(dir string, names map[string]bool) []bagfiles.FileDiff {
+	var extra []bagfiles.FileDiff
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		lower := strings.ToLower(d.Name())
+		if d.Name() == ".DS_Store" || lower == "desktop.ini" || lower == "thumbs.db" {
+			return nil
+		}
+
+		if !names[rel] {
+			extra = append(extra, bagfiles.FileDiff{Name: rel, Kind: bagfiles.DiffExtra, Details: "not in bag"})
+		}
+		return nil
+	})
+	return extra
+}
+
+// findBadPieces compares hashed pieces with leaves from locally stored proofs
+func (c *Client) findBadPieces(j *hashJob, bagId, rootHash []byte) (bad []uint32, unknown uint32) {
+	depth := treeDepth(j.pieces)
+	for id := uint32(0); id < j.pieces; id++ {
+		p, err := c.storage.GetPiece(bagId, id)
+		if err != nil {
+			unknown++
+			continue
+		}
+
+		leaf, err := proofLeaf(p.Proof, rootHash, id, depth)
+		if err != nil {
+			unknown++
+			continue
+		}
+
+		if !bytes.Equal(leaf, j.hashes[id*32:id*32+32]) {
+			bad = append(bad, id)
+		}
+	}
+	return bad, unknown
+}
+
+func proofLeaf(proof, rootHash []byte, id uint32, depth int) ([]byte, error) {
+	pc, err := cell.FromBOC(proof)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := cell.UnwrapProof(pc, rootHash)
+	if err != nil {
+		return nil, err
+	}
+
+	for bit := depth - 1; bit >= 0; bit-- {
+		if node, err = node.PeekRef(int(id>>bit) & 1); err != nil {
+			return nil, err
+		}
+	}
+	return node.BeginParse().LoadSlice(256)
+}
+
+// pieceFiles returns ids of not empty files which data is in piece
+func (j *hashJob) pieceFiles(id uint32) []uint32 {
+	var res []uint32
+	j.pieceSegments(id, func(file int, _, _ uint64) {
+		res = append(res, uint32(file))
+	})
+	return res
+}
@@ -0,0 +1,330 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/tonutils/torrent-client/core/bagfiles"
+	"github.com/tonutils/torrent-client/core/client"
+	"github.com/xssnick/tonutils-storage/storage"
+)
+
+// bagSource is kept for locally created bags, to create next versions of them faster
+type bagSource struct {
+	// Options are set when bag was created from scanned list of files, otherwise its dir is detected again
+	Options *bagfiles.Options
+	// Pieces are pairs of piece key and hash, see hashJob.pieceKeys
+	Pieces []byte
+}
+
+// bagVersion links bag to its previous version
+type bagVersion struct {
+	Parent                 []byte
+	RetireParentAfterPeers int
+	// Retired is set when parent was stopped, so it is not stopped again when user starts it
+	Retired   bool
+	CreatedAt time.Time
+}
+
+var versionPrefix = []byte("tt_version:")
+
+func sourceKey(bagId []byte) []byte {
+	return append([]byte("tt_source:"), bagId...)
+}
+
+func versionKey(bagId []byte) []byte {
+	return append(append([]byte{}, versionPrefix...), bagId...)
+}
+
+// CreateTorrentVersion creates new bag from current data of bag, hashes of unchanged pieces are reused
+func (c *Client) CreateTorrentVersion(ctx context.Context, hash []byte, opts client.CreateOptions, progressCallback func(done uint64, max uint64)) (*client.TorrentFull, error) {
+	t := c.storage.GetTorrent(hash)
+	if t == nil {
+		return nil, fmt.Errorf("torrent is not found")
+	}
+	if t.Header == nil || t.Info == nil {
+		return nil, fmt.Errorf("bag header is not downloaded yet")
+	}
+
+	opts.Parent = hash
+	if opts.PieceSize == 0 && bagfiles.ValidatePieceSize(t.Info.PieceSize) == nil {
+		// same piece size keeps pieces of unchanged data the same
+		opts.PieceSize = t.Info.PieceSize
+	}
+	description := t.Info.Description.Value
+
+	if src := c.getSource(hash); src != nil && src.Options != nil {
+		set, err := bagfiles.Scan(*src.Options)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read files: %w", err)
+		}
+		return c.createTorrent(ctx, set.Root, set.DirName, description, set.Refs(), opts, &set.Options, progressCallback)
+	}
+
+	path := bagDir(t)
+	if len(t.Header.DirName) == 0 {
+		if t.Header.FilesCount != 1 {
+			return nil, fmt.Errorf("bag has no dir, its files can't be detected again, create new bag from them")
+		}
+		name, _ := bagfiles.HeaderFile(t.Header, 0)
+		path = filepath.Join(t.Path, name)
+	}
+	return c.CreateTorrent(ctx, path, description, opts, progressCallback)
+}
+
+// GetVersions returns previous and next versions of bag
+func (c *Client) GetVersions(ctx context.Context, hash []byte) (*client.BagVersions, error) {
+	res := &client.BagVersions{}
+	if v := c.getVersion(hash); v != nil {
+		res.Parent = v.Parent
+	}
+
+	it := c.db.NewIterator(util.BytesPrefix(versionPrefix), nil)
+	defer it.Release()
+
+	type child struct {
+		id []byte
+		at time.Time
+	}
+
+	var children []child
+	for it.Next() {
+		var v bagVersion
+		if err := json.Unmarshal(it.Value(), &v); err != nil {
+			continue
+		}
+		if bytes.Equal(v.Parent, hash) {
+			children = append(children, child{id: append([]byte{}, it.Key()[len(versionPrefix):]...), at: v.CreatedAt})
+		}
+	}
+
+	// oldest first, so the last one is the latest version
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].at.Before(children[j].at)
+	})
+	for _, ch := range children {
+		res.Children = append(res.Children, ch.id)
+	}
+	return res, it.Error()
+}
+
+func (c *Client) getSource(bagId []byte) *bagSource {
+	data, err := c.db.Get(sourceKey(bagId), nil)
+	if err != nil {
+		if !errors.Is(err, leveldb.ErrNotFound) {
+			log.Error().Err(err).Msg("failed to load bag source")
+		}
+		return nil
+	}
+
+	var src bagSource
+	if err = json.Unmarshal(data, &src); err != nil {
+		log.Error().Err(err).Msg("failed to parse bag source")
+		return nil
+	}
+	return &src
+}
+
+func (c *Client) setSource(bagId []byte, src *bagSource) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return c.db.Put(sourceKey(bagId), data, nil)
+}
+
+func (c *Client) getVersion(bagId []byte) *bagVersion {
+	data, err := c.db.Get(versionKey(bagId), nil)
+	if err != nil {
+		if !errors.Is(err, leveldb.ErrNotFound) {
+			log.Error().Err(err).Msg("failed to load bag version")
+		}
+		return nil
+	}
+
+	var v bagVersion
+	if err = json.Unmarshal(data, &v); err != nil {
+		log.Error().Err(err).Msg("failed to parse bag version")
+		return nil
+	}
+	return &v
+}
+
+func (c *Client) setVersion(bagId []byte, v *bagVersion) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.db.Put(versionKey(bagId), data, nil)
+}
+
+func (c *Client) forgetVersions(bagId []byte) {
+	if err := c.db.Delete(sourceKey(bagId), nil); err != nil {
+		log.Error().Err(err).Msg("failed to remove bag source")
+	}
+	if err := c.db.Delete(versionKey(bagId), nil); err != nil {
+		log.Error().Err(err).Msg("failed to remove bag version")
+	}
+}
+
+// retireParent stops previous version of bag, when this one has enough peers to replace it
+func (c *Client) retireParent(t *storage.Torrent, peers int) {
+	v := c.getVersion(t.BagID)
+	if v == nil || v.Retired || v.RetireParentAfterPeers <= 0 || peers < v.RetireParentAfterPeers {
+		return
+	}
+
+	if p := c.storage.GetTorrent(v.Parent); p != nil {
+		if err := c.applyActivity(p, false, false); err != nil {
+			log.Error().Err(err).Msg("failed to stop previous version of bag")
+			return
+		}
+		log.Info().Str("bag", fmt.Sprintf("%x", p.BagID)).Int("peers", peers).Msg("previous version of bag is stopped")
+	}
+
+	v.Retired = true
+	if err := c.setVersion(t.BagID, v); err != nil {
+		log.Error().Err(err).Msg("failed to save bag version")
+	}
+}
+
+// pieceKeys identifies data of every piece without reading it, by header bytes and by paths,
+// sizes and modification times of files in it. Piece with file which can't be checked has zero key.
+func (j *hashJob) pieceKeys() [][32]byte {
+	type fileStat struct {
+		path  string
+		size  int64
+		mtime int64
+		ok    bool
+	}
+
+	dir := string(j.header.DirName)
+	stats := make([]fileStat, len(j.files))
+	for i, f := range j.files {
+		path := refPath(j.root, dir, f)
+		if st, err := os.Stat(path); err == nil && uint64(st.Size()) == f.GetSize() {
+			stats[i] = fileStat{path: path, size: st.Size(), mtime: st.ModTime().UnixNano(), ok: true}
+		}
+	}
+
+	keys := make([][32]byte, j.pieces)
+	for id := uint32(0); id < j.pieces; id++ {
+		from, to := j.pieceRange(id)
+
+		h := sha256.New()
+		if hdr := uint64(len(j.headerData)); from < hdr {
+			h.Write(j.headerData[from:min(to, hdr)])
+		}
+
+		ok := true
+		j.pieceSegments(id, func(file int, off, ln uint64) {
+			s := stats[file]
+			ok = ok && s.ok
+			_ = binary.Write(h, binary.BigEndian, uint32(len(s.path)))
+			h.Write([]byte(s.path))
+			_ = binary.Write(h, binary.BigEndian, []int64{s.size, s.mtime})
+			_ = binary.Write(h, binary.BigEndian, []uint64{off, ln})
+		})
+
+		if ok {
+			copy(keys[id][:], h.Sum(nil))
+		}
+	}
+	return keys
+}
+
+// reuse takes hashes of pieces with known keys, they are marked done and not read again
+func (j *hashJob) reuse(keys [][32]byte, pieces []byte) (n uint32) {
+	known := map[[32]byte][]byte{}
+	for i := 0; i+64 <= len(pieces); i += 64 {
+		known[[32]byte(pieces[i:i+32])] = pieces[i+32 : i+64]
+	}
+
+	for id, k := range keys {
+		h, ok := known[k]
+		if !ok || k == ([32]byte{}) || j.isDone(uint32(id)) {
+			continue
+		}
+
+		copy(j.hashes[id*32:], h)
+		if j.hashFile != nil {
+			// checkpoint should have hashes of all done pieces
+			if _, err := j.hashFile.WriteAt(h, int64(id)*32); err != nil {
+				log.Warn().Err(err).Msg("failed to write hashing checkpoint, continue without it")
+				_ = j.hashFile.Close()
+				j.hashFile = nil
+			}
+		}
+		j.done[id/8] |= 1 << (id % 8)
+		n++
+	}
+	return n
+}
+
+func (j *hashJob) sourcePieces(keys [][32]byte) []byte {
+	res := make([]byte, 0, len(keys)*64)
+	for id, k := range keys {
+		if k == ([32]byte{}) {
+			continue
+		}
+		res = append(res, k[:]...)
+		res = append(res, j.hashes[id*32:id*32+32]...)
+	}
+	return res
+}
+
+// pieceRange is an offset of piece in bag data, including header
+func (j *hashJob) pieceRange(id uint32) (from, to uint64) {
+	from = uint64(id) * uint64(j.pieceSize)
+	return from, from + j.pieceLen(id)
+}
+
+// pieceSegments calls fn for every not empty file which data is in piece,
+// with offset and length of this data inside the file
+func (j *hashJob) pieceSegments(id uint32, fn func(file int, off, ln uint64)) {
+	hdr := uint64(len(j.headerData))
+	from, to := j.pieceRange(id)
+
+	idx := j.header.DataIndex
+	i := sort.Search(len(idx), func(i int) bool {
+		return hdr+idx[i] > from
+	})
+	for ; i < len(idx); i++ {
+		var start uint64
+		if i > 0 {
+			start = idx[i-1]
+		}
+		if hdr+start >= to {
+			break
+		}
+		if idx[i] == start {
+			continue
+		}
+
+		segFrom := max(from, hdr+start)
+		fn(i, segFrom-(hdr+start), min(to, hdr+idx[i])-segFrom)
+	}
+}
+
+// refPath is where file is on disk, names of scanned files can be normalized and differ from it
+func refPath(root, dir string, f storage.FileRef) string {
+	switch r := f.(type) {
+	case *bagfiles.File:
+		return r.Path
+	case *diskFileRef:
+		return r.path
+	}
+	return filepath.Join(root, dir, f.GetName())
+}
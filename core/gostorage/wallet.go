@@ -0,0 +1,81 @@
+package gostorage
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	pWallet "github.com/xssnick/ton-payment-network/tonpayments/wallet"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton/wallet"
+)
+
+// paymentsWalletTTL is message ttl of payments wallet, it is a part of wallet address
+const paymentsWalletTTL = 3*60 + 30
+
+// queryBitNumbers is how many bit numbers of highload wallet can be used, last one is reserved by contract
+const queryBitNumbers = 1023
+
+// paymentsWallet makes the same highload wallet as payments service uses. Service counts query ids from 1
+// in its own process, so ids here are random to not collide with it, and wallet address is checked against
+// the one of service to be sure config is the same.
+func paymentsWallet(api wallet.TonAPI, key ed25519.PrivateKey) (*wallet.Wallet, error) {
+	svc, err := pWallet.InitWallet(api, key)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := wallet.FromPrivateKey(api, key, wallet.ConfigHighloadV3{
+		MessageTTL: paymentsWalletTTL,
+		MessageBuilder: func(ctx context.Context, subWalletId uint32) (uint32, int64, error) {
+			// something older than last master block, to pass through time validation of liteserver
+			createdAt := time.Now().UTC().Unix() - 30
+			id, err := randomQueryID(createdAt)
+			return id, createdAt, err
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !w.WalletAddress().Equals(svc.Wallet().WalletAddress()) {
+		return nil, fmt.Errorf("wallet config is different from payments service")
+	}
+	return w, nil
+}
+
+// randomQueryID makes id in the same shift window as payments service, bit number is random except reserved one
+func randomQueryID(createdAt int64) (uint32, error) {
+	var rnd [2]byte
+	if _, err := rand.Read(rnd[:]); err != nil {
+		return 0, err
+	}
+
+	// 5 bits of shift are left for random, 10 bits are bit number
+	n := uint32(binary.BigEndian.Uint16(rnd[:])) % (32 * queryBitNumbers)
+	low := (n/queryBitNumbers)<<10 | n%queryBitNumbers
+	return uint32(createdAt%paymentsWalletTTL)<<15 | low, nil
+}
+
+// Withdraw sends TON from payments wallet and waits for transaction, its hash is returned
+func (c *Client) Withdraw(ctx context.Context, key ed25519.PrivateKey, to *address.Address, amount tlb.Coins, comment string) ([]byte, error) {
+	w, err := paymentsWallet(c.api, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init wallet: %w", err)
+	}
+
+	msg, err := w.BuildTransfer(to, amount, to.IsBounceable(), comment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build transfer: %w", err)
+	}
+
+	tx, _, err := w.SendWaitTransaction(ctx, msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send transfer: %w", err)
+	}
+	return tx.Hash, nil
+}
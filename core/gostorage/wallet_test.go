@@ -0,0 +1,163 @@
+package gostorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"testing"
+
+	pWallet "github.com/xssnick/ton-payment-network/tonpayments/wallet"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+)
+
+// mockAPI answers wallet requests without network, sent messages are recorded
+type mockAPI struct {
+	ton.APIClientWrapped
+
+	active  bool
+	sendErr error
+	sent    []*tlb.ExternalMessage
+}
+
+func (m *mockAPI) CurrentMasterchainInfo(ctx context.Context) (*ton.BlockIDExt, error) {
+	return &ton.BlockIDExt{SeqNo: 100}, nil
+}
+
+func (m *mockAPI) WaitForBlock(seqno uint32) ton.APIClientWrapped {
+	return m
+}
+
+func (m *mockAPI) GetAccount(ctx context.Context, block *ton.BlockIDExt, addr *address.Address) (*tlb.Account, error) {
+	if !m.active {
+		return &tlb.Account{}, nil
+	}
+	return &tlb.Account{IsActive: true, State: &tlb.AccountState{AccountStorage: tlb.AccountStorage{Status: tlb.AccountStatusActive}}}, nil
+}
+
+func (m *mockAPI) SendExternalMessageWaitTransaction(ctx context.Context, ext *tlb.ExternalMessage) (*tlb.Transaction, *ton.BlockIDExt, []byte, error) {
+	if m.sendErr != nil {
+		return nil, nil, nil, m.sendErr
+	}
+	m.sent = append(m.sent, ext)
+	return &tlb.Transaction{Hash: ext.Body.Hash()}, &ton.BlockIDExt{SeqNo: 101}, ext.Body.Hash(), nil
+}
+
+func testKey(seed byte) ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(bytes.Repeat([]byte{seed}, ed25519.SeedSize))
+}
+
+// sentQueryID reads query id from signed external message of highload wallet
+func sentQueryID(t *testing.T, ext *tlb.ExternalMessage) uint32 {
+	s := ext.Body.BeginParse()
+	if _, err := s.LoadSlice(512); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := s.LoadRef()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// subwallet id and mode go before query id
+	if _, err = payload.LoadUInt(32); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = payload.LoadUInt(8); err != nil {
+		t.Fatal(err)
+	}
+	id, err := payload.LoadUInt(23)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uint32(id)
+}
+
+func TestPaymentsWalletAddress(t *testing.T) {
+	api := &mockAPI{}
+	for seed := byte(1); seed < 5; seed++ {
+		w, err := paymentsWallet(api, testKey(seed))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		svc, err := pWallet.InitWallet(api, testKey(seed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !w.WalletAddress().Equals(svc.WalletAddress()) {
+			t.Fatalf("wallet %s is not the one of payments service %s", w.WalletAddress(), svc.WalletAddress())
+		}
+	}
+}
+
+func TestRandomQueryID(t *testing.T) {
+	const createdAt = 1700000123
+	shifts := map[uint32]bool{}
+	for i := 0; i < 100000; i++ {
+		id, err := randomQueryID(createdAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id >= 1<<23 {
+			t.Fatalf("query id %d is too big", id)
+		}
+		if id&1023 == 1023 {
+			t.Fatalf("query id %d uses reserved bit number", id)
+		}
+		if id>>15 != createdAt%paymentsWalletTTL {
+			t.Fatalf("query id %d is out of time window", id)
+		}
+		shifts[id>>10] = true
+	}
+	if len(shifts) != 32 {
+		t.Fatalf("only %d of 32 random shifts are used", len(shifts))
+	}
+}
+
+func TestWithdraw(t *testing.T) {
+	to := address.MustParseAddr("EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N")
+	amount := tlb.MustFromTON("1.5")
+
+	for _, active := range []bool{false, true} {
+		t.Run(fmt.Sprint("deployed ", active), func(t *testing.T) {
+			api := &mockAPI{active: active}
+			c := &Client{api: api}
+			key := testKey(7)
+
+			hash, err := c.Withdraw(context.Background(), key, to, amount, "out")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(api.sent) != 1 {
+				t.Fatalf("%d messages are sent", len(api.sent))
+			}
+
+			ext := api.sent[0]
+			if !bytes.Equal(hash, ext.Body.Hash()) {
+				t.Fatal("returned hash is not of sent transaction")
+			}
+
+			svc, err := pWallet.InitWallet(api, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ext.DstAddr.Equals(svc.WalletAddress()) {
+				t.Fatalf("message is sent to %s, payments wallet is %s", ext.DstAddr, svc.WalletAddress())
+			}
+			if (ext.StateInit != nil) == active {
+				t.Fatal("state init should be attached only when wallet is not deployed")
+			}
+			if id := sentQueryID(t, ext); id&1023 == 1023 {
+				t.Fatalf("query id %d uses reserved bit number", id)
+			}
+		})
+	}
+
+	t.Run("send error", func(t *testing.T) {
+		c := &Client{api: &mockAPI{sendErr: fmt.Errorf("liteserver is down")}}
+		if _, err := c.Withdraw(context.Background(), testKey(7), to, amount, ""); err == nil {
+			t.Fatal("error of send is not returned")
+		}
+	})
+}
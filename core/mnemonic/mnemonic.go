@@ -0,0 +1,72 @@
+// Package mnemonic encodes key seed as BIP39 words, to back it up on paper.
+//
+// Words are the seed itself with checksum, not a source to derive key from,
+// so they restore the same key only here and not in TON wallet apps.
+package mnemonic
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var index = func() map[string]int {
+	m := make(map[string]int, len(words))
+	for i, w := range words {
+		m[w] = i
+	}
+	return m
+}()
+
+// FromSeed encodes seed, its length should be multiple of 4 bytes from 16 to 32
+func FromSeed(seed []byte) ([]string, error) {
+	if len(seed) < 16 || len(seed) > 32 || len(seed)%4 != 0 {
+		return nil, fmt.Errorf("invalid seed length %d", len(seed))
+	}
+
+	csBits := len(seed) / 4
+	hash := sha256.Sum256(seed)
+
+	v := new(big.Int).SetBytes(seed)
+	v.Lsh(v, uint(csBits))
+	v.Or(v, big.NewInt(int64(hash[0]>>(8-csBits))))
+
+	num := (len(seed)*8 + csBits) / 11
+	res := make([]string, num)
+	mask := big.NewInt(2047)
+	for i := num - 1; i >= 0; i-- {
+		res[i] = words[new(big.Int).And(v, mask).Int64()]
+		v.Rsh(v, 11)
+	}
+	return res, nil
+}
+
+// ToSeed decodes words and verifies checksum, words can be separated by any spaces
+func ToSeed(phrase string) ([]byte, error) {
+	list := strings.Fields(strings.ToLower(phrase))
+	if len(list) < 12 || len(list) > 24 || len(list)%3 != 0 {
+		return nil, fmt.Errorf("should be 12, 15, 18, 21 or 24 words, got %d", len(list))
+	}
+
+	v := new(big.Int)
+	for i, w := range list {
+		n, ok := index[w]
+		if !ok {
+			return nil, fmt.Errorf("unknown word %d '%s'", i+1, w)
+		}
+		v.Lsh(v, 11)
+		v.Or(v, big.NewInt(int64(n)))
+	}
+
+	csBits := len(list) / 3
+	cs := new(big.Int).And(v, big.NewInt(int64(1<<csBits-1))).Int64()
+	v.Rsh(v, uint(csBits))
+
+	seed := v.FillBytes(make([]byte, csBits*4))
+	hash := sha256.Sum256(seed)
+	if int64(hash[0]>>(8-csBits)) != cs {
+		return nil, fmt.Errorf("invalid checksum, some word is wrong")
+	}
+	return seed, nil
+}
@@ -0,0 +1,107 @@
+package mnemonic
+
+import (
+	"bytes"
+	"crypto/rand"
+	"strings"
+	"testing"
+)
+
+// vectors are from BIP39 reference test set
+var vectors = []struct {
+	seed   []byte
+	phrase string
+}{
+	{bytes.Repeat([]byte{0x00}, 16), strings.Repeat("abandon ", 11) + "about"},
+	{bytes.Repeat([]byte{0x7f}, 16), "legal winner thank year wave sausage worth useful legal winner thank yellow"},
+	{bytes.Repeat([]byte{0x80}, 16), "letter advice cage absurd amount doctor acoustic avoid letter advice cage above"},
+	{bytes.Repeat([]byte{0xff}, 16), strings.Repeat("zoo ", 11) + "wrong"},
+	{bytes.Repeat([]byte{0x00}, 32), strings.Repeat("abandon ", 23) + "art"},
+	{bytes.Repeat([]byte{0xff}, 32), strings.Repeat("zoo ", 23) + "vote"},
+}
+
+func TestVectors(t *testing.T) {
+	for _, v := range vectors {
+		list, err := FromSeed(v.seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(list, " "); got != v.phrase {
+			t.Fatalf("seed %x encoded as '%s', expected '%s'", v.seed, got, v.phrase)
+		}
+
+		seed, err := ToSeed(v.phrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(seed, v.seed) {
+			t.Fatalf("'%s' decoded as %x, expected %x", v.phrase, seed, v.seed)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for size := 16; size <= 32; size += 4 {
+		for i := 0; i < 100; i++ {
+			seed := make([]byte, size)
+			if _, err := rand.Read(seed); err != nil {
+				t.Fatal(err)
+			}
+
+			list, err := FromSeed(seed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != size*3/4 {
+				t.Fatalf("%d words for seed of %d bytes", len(list), size)
+			}
+
+			// user can type words with other case and spacing
+			got, err := ToSeed("  " + strings.ToUpper(strings.Join(list, " \n\t")) + " ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, seed) {
+				t.Fatalf("seed %x restored as %x", seed, got)
+			}
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	valid := strings.Repeat("abandon ", 23) + "art"
+
+	tests := []struct {
+		name   string
+		phrase string
+		errHas string
+	}{
+		{"bad checksum", strings.Repeat("abandon ", 23) + "zoo", "invalid checksum"},
+		{"swapped words", "art " + strings.Repeat("abandon ", 23), "invalid checksum"},
+		{"short phrase bad checksum", strings.Repeat("abandon ", 12), "invalid checksum"},
+		{"unknown word", strings.Replace(valid, "abandon", "abandonn", 1), "unknown word 1"},
+		{"unknown last word", strings.Repeat("abandon ", 23) + "ar", "unknown word 24"},
+		{"too few words", strings.Repeat("abandon ", 11), "got 11"},
+		{"too many words", valid + " abandon abandon abandon", "got 27"},
+		{"not multiple of 3", strings.Repeat("abandon ", 13), "got 13"},
+		{"empty", "", "got 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ToSeed(tt.phrase)
+			if err == nil {
+				t.Fatal("phrase is accepted")
+			}
+			if !strings.Contains(err.Error(), tt.errHas) {
+				t.Fatalf("error '%s', expected '%s'", err, tt.errHas)
+			}
+		})
+	}
+
+	for _, size := range []int{0, 12, 15, 17, 36} {
+		if _, err := FromSeed(make([]byte, size)); err == nil {
+			t.Fatalf("seed of %d bytes is encoded", size)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// Package nodespool fetches tunnel nodes pool published by URL.
+//
+// Publisher can sign pool with ed25519 key, detached signature of the exact file bytes is published
+// next to it, by the same URL with SignatureSuffix added to path, as base64 text or as raw 64 bytes.
+// Signed pool has Seqno, which grows with every published version, so an older signed version
+// can't be served again instead of the cached one, and it can have ExpiresAt.
+package nodespool
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/ton-blockchain/adnl-tunnel/config"
+)
+
+// SignatureSuffix is added to pool URL to get its signature
+const SignatureSuffix = ".sig"
+
+// maxPoolSize limits downloaded pool, it is a list of keys with payment info
+const maxPoolSize = 4 << 20
+
+const fetchTimeout = 30 * time.Second
+
+// Pool is tunnel library pool with version fields, library reads the same file and ignores them
+type Pool struct {
+	config.SharedConfig
+
+	// Seqno is version of pool, it is required for signed pools
+	Seqno uint64 `json:",omitempty"`
+	// ExpiresAt is unix time after which pool is not accepted, 0 means it does not expire
+	ExpiresAt int64 `json:",omitempty"`
+}
+
+// Expired reports if pool should not be used anymore
+func (p *Pool) Expired(now time.Time) bool {
+	return p.ExpiresAt != 0 && now.Unix() > p.ExpiresAt
+}
+
+// IsURL reports if pool can be fetched from s
+func IsURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// ParseKey parses publisher key in base64 or hex, empty key means pool is not verified
+func ParseKey(s string) (ed25519.PublicKey, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		if key, err = hex.DecodeString(s); err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("publisher key should be 32 bytes in base64 or hex")
+		}
+	}
+	return key, nil
+}
+
+// Fetch downloads pool, verifies its signature when publisher key is set, and validates it.
+// Signed pool with seqno less than minSeqno, which is seqno of cached pool, is rejected.
+func Fetch(ctx context.Context, link string, publisher ed25519.PublicKey, minSeqno uint64) ([]byte, error) {
+	if !IsURL(link) {
+		return nil, fmt.Errorf("only http and https urls are supported")
+	}
+	sigLink, err := signatureURL(link)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	data, err := download(ctx, link)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download pool: %w", err)
+	}
+
+	if publisher != nil {
+		sig, err := download(ctx, sigLink)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download pool signature: %w", err)
+		}
+		if err = Verify(data, sig, publisher); err != nil {
+			return nil, err
+		}
+	}
+
+	pool, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if pool.Expired(time.Now()) {
+		return nil, fmt.Errorf("pool has expired at %s", time.Unix(pool.ExpiresAt, 0).Format(time.RFC3339))
+	}
+	if publisher != nil {
+		// unsigned seqno means nothing, anyone can serve any version
+		if pool.Seqno == 0 {
+			return nil, fmt.Errorf("signed pool has no seqno, its version can't be checked")
+		}
+		if pool.Seqno < minSeqno {
+			return nil, fmt.Errorf("pool version %d is older than cached version %d", pool.Seqno, minSeqno)
+		}
+	}
+	return data, nil
+}
+
+// signatureURL adds suffix to path of pool URL, query is kept as is
+func signatureURL(link string) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", fmt.Errorf("invalid url: %w", err)
+	}
+
+	u.Path += SignatureSuffix
+	if u.RawPath != "" {
+		u.RawPath += SignatureSuffix
+	}
+	u.Fragment, u.RawFragment = "", ""
+	return u.String(), nil
+}
+
+// Verify checks signature of pool, it can be base64 text or raw bytes
+func Verify(data, sig []byte, publisher ed25519.PublicKey) error {
+	if len(sig) != ed25519.SignatureSize {
+		raw, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig)))
+		if err != nil || len(raw) != ed25519.SignatureSize {
+			return fmt.Errorf("invalid pool signature format")
+		}
+		sig = raw
+	}
+
+	if !ed25519.Verify(publisher, data, sig) {
+		return fmt.Errorf("pool signature is not valid for publisher key")
+	}
+	return nil
+}
+
+// Parse parses and validates pool
+func Parse(data []byte) (*Pool, error) {
+	var cfg Pool
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse pool: %w", err)
+	}
+
+	if len(cfg.NodesPool) == 0 {
+		return nil, fmt.Errorf("pool has no nodes")
+	}
+	for i, n := range cfg.NodesPool {
+		if len(n.Key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("node %d of pool has invalid key", i)
+		}
+	}
+	return &cfg, nil
+}
+
+// Diff counts nodes which are added to pool and removed from it, by key
+func Diff(old, new *Pool) (added, removed int) {
+	keys := func(cfg *Pool) map[string]bool {
+		res := map[string]bool{}
+		if cfg != nil {
+			for _, n := range cfg.NodesPool {
+				res[string(n.Key)] = true
+			}
+		}
+		return res
+	}
+
+	was, now := keys(old), keys(new)
+	for k := range now {
+		if !was[k] {
+			added++
+		}
+	}
+	for k := range was {
+		if !now[k] {
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// Save writes pool to file, temp file is used first to not leave broken cache on failure
+func Save(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func download(ctx context.Context, link string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPoolSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxPoolSize {
+		return nil, fmt.Errorf("file is too big")
+	}
+	return data, nil
+}
@@ -0,0 +1,136 @@
+package nodespool
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ton-blockchain/adnl-tunnel/config"
+)
+
+func testPoolData(t *testing.T, seqno uint64, expiresAt int64) []byte {
+	p := Pool{Seqno: seqno, ExpiresAt: expiresAt}
+	p.NodesPool = []config.TunnelRouteSection{{Key: bytes.Repeat([]byte{1}, 32)}, {Key: bytes.Repeat([]byte{2}, 32)}}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestSignatureURL(t *testing.T) {
+	tests := []struct {
+		link, want string
+	}{
+		{"https://example.com/pool.json", "https://example.com/pool.json.sig"},
+		{"https://example.com/pool.json?token=abc&v=2", "https://example.com/pool.json.sig?token=abc&v=2"},
+		{"https://example.com/dir/pool.json#latest", "https://example.com/dir/pool.json.sig"},
+		{"https://example.com/my%2Fpool.json?x=1", "https://example.com/my%2Fpool.json.sig?x=1"},
+		// string concatenation would change port here
+		{"http://example.com:8080", "http://example.com:8080/.sig"},
+	}
+
+	for _, tt := range tests {
+		got, err := signatureURL(tt.link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("signature of %s is fetched from %s, expected %s", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pool, sig []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/pool.json":
+			_, _ = w.Write(pool)
+		case "/pool.json.sig":
+			_, _ = w.Write(sig)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	link := srv.URL + "/pool.json?token=secret"
+
+	future := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name      string
+		pool      []byte
+		signer    ed25519.PrivateKey
+		publisher ed25519.PublicKey
+		minSeqno  uint64
+		errHas    string
+	}{
+		{"signed", testPoolData(t, 5, 0), priv, pub, 0, ""},
+		{"same version as cached", testPoolData(t, 5, 0), priv, pub, 5, ""},
+		{"newer than cached", testPoolData(t, 6, future), priv, pub, 5, ""},
+		{"older than cached", testPoolData(t, 4, 0), priv, pub, 5, "older than cached"},
+		{"signed without seqno", testPoolData(t, 0, 0), priv, pub, 0, "has no seqno"},
+		{"expired", testPoolData(t, 7, past), priv, pub, 5, "expired"},
+		{"wrong signer", testPoolData(t, 7, 0), other, pub, 0, "not valid"},
+		{"unsigned without seqno", testPoolData(t, 0, 0), nil, nil, 0, ""},
+		{"unsigned expired", testPoolData(t, 0, past), nil, nil, 0, "expired"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, sig = tt.pool, nil
+			if tt.signer != nil {
+				sig = []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(tt.signer, tt.pool)))
+			}
+
+			data, err := Fetch(context.Background(), link, tt.publisher, tt.minSeqno)
+			if tt.errHas == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(data, tt.pool) {
+					t.Fatal("fetched pool is different from published")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errHas) {
+				t.Fatalf("error %v, expected %q", err, tt.errHas)
+			}
+		})
+	}
+}
+
+func TestPoolIsReadByLibrary(t *testing.T) {
+	data := testPoolData(t, 3, time.Now().Unix())
+
+	var cfg config.SharedConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.NodesPool) != 2 {
+		t.Fatalf("library reads %d nodes of 2", len(cfg.NodesPool))
+	}
+}
@@ -0,0 +1,480 @@
+// Package qrcode encodes short texts, like bag links, to QR codes.
+// Only byte mode with medium error correction and versions up to 10 are supported,
+// it is enough for about 200 bytes.
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// Code is a square of modules, true is dark
+type Code struct {
+	Size    int
+	modules [][]bool
+	// function modules are not used for data and not masked
+	function [][]bool
+}
+
+type blocks struct {
+	ecLen int
+	// counts and data lengths of blocks, second group is one codeword longer
+	num1, len1 int
+	num2, len2 int
+}
+
+// ecM is error correction layout of versions 1-10 for level M
+var ecM = []blocks{
+	{10, 1, 16, 0, 0},
+	{16, 1, 28, 0, 0},
+	{26, 1, 44, 0, 0},
+	{18, 2, 32, 0, 0},
+	{24, 2, 43, 0, 0},
+	{16, 4, 27, 0, 0},
+	{18, 4, 31, 0, 0},
+	{22, 2, 38, 2, 39},
+	{22, 3, 36, 2, 37},
+	{26, 4, 43, 1, 44},
+}
+
+var alignment = [][]int{
+	nil,
+	{6, 18},
+	{6, 22},
+	{6, 26},
+	{6, 30},
+	{6, 34},
+	{6, 22, 38},
+	{6, 24, 42},
+	{6, 26, 46},
+	{6, 28, 50},
+}
+
+// Encode makes the smallest code which fits data
+func Encode(data []byte) (*Code, error) {
+	for v := 1; v <= len(ecM); v++ {
+		b := ecM[v-1]
+		capacity := b.num1*b.len1 + b.num2*b.len2
+		if 4+countBits(v)+len(data)*8 > capacity*8 {
+			continue
+		}
+
+		c := newCode(v)
+		c.place(interleave(b, encodeData(v, data, capacity)))
+		c.applyBestMask()
+		return c, nil
+	}
+	return nil, fmt.Errorf("too long data for qr code: %d bytes", len(data))
+}
+
+// Dark reports color of module, x is column and y is row
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// Image draws code with quiet zone, every module is scale pixels
+func (c *Code) Image(scale int) image.Image {
+	const quiet = 4
+	sz := (c.Size + 2*quiet) * scale
+	img := image.NewGray(image.Rect(0, 0, sz, sz))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.modules[y][x] {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetGray((x+quiet)*scale+dx, (y+quiet)*scale+dy, color.Gray{})
+				}
+			}
+		}
+	}
+	return img
+}
+
+// PNG encodes code image
+func (c *Code) PNG(scale int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.Image(scale)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) append(val uint, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, (val>>i)&1 == 1)
+	}
+}
+
+func encodeData(version int, data []byte, capacity int) []byte {
+	var bits bitBuffer
+	bits.append(0b0100, 4) // byte mode
+	bits.append(uint(len(data)), countBits(version))
+	for _, d := range data {
+		bits.append(uint(d), 8)
+	}
+
+	bits.append(0, min(4, capacity*8-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+
+	res := make([]byte, 0, capacity)
+	for i := 0; i < len(bits); i += 8 {
+		var v byte
+		for _, bit := range bits[i : i+8] {
+			v <<= 1
+			if bit {
+				v |= 1
+			}
+		}
+		res = append(res, v)
+	}
+	for pad := byte(0xEC); len(res) < capacity; pad ^= 0xEC ^ 0x11 {
+		res = append(res, pad)
+	}
+	return res
+}
+
+// interleave splits data to blocks, adds error correction to each and mixes them
+func interleave(b blocks, data []byte) []byte {
+	divisor := rsDivisor(b.ecLen)
+
+	var dataBlocks, ecBlocks [][]byte
+	for i := 0; i < b.num1+b.num2; i++ {
+		ln := b.len1
+		if i >= b.num1 {
+			ln = b.len2
+		}
+		dataBlocks = append(dataBlocks, data[:ln])
+		ecBlocks = append(ecBlocks, rsRemainder(data[:ln], divisor))
+		data = data[ln:]
+	}
+
+	var res []byte
+	for i := 0; i < max(b.len1, b.len2); i++ {
+		for _, blk := range dataBlocks {
+			if i < len(blk) {
+				res = append(res, blk[i])
+			}
+		}
+	}
+	for i := 0; i < b.ecLen; i++ {
+		for _, blk := range ecBlocks {
+			res = append(res, blk[i])
+		}
+	}
+	return res
+}
+
+func newCode(version int) *Code {
+	c := &Code{Size: 17 + 4*version}
+	c.modules = make([][]bool, c.Size)
+	c.function = make([][]bool, c.Size)
+	for i := range c.modules {
+		c.modules[i] = make([]bool, c.Size)
+		c.function[i] = make([]bool, c.Size)
+	}
+
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+
+	c.finder(3, 3)
+	c.finder(c.Size-4, 3)
+	c.finder(3, c.Size-4)
+
+	pos := alignment[version-1]
+	for i := range pos {
+		for j := range pos {
+			last := len(pos) - 1
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			c.alignment(pos[i], pos[j])
+		}
+	}
+
+	// reserve format area, real bits are set with mask
+	c.format(0)
+	if version >= 7 {
+		c.version(version)
+	}
+	return c
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+func (c *Code) finder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= c.Size || yy < 0 || yy >= c.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.set(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (c *Code) alignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+func (c *Code) format(mask int) {
+	// level M is 00
+	data := mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true)
+}
+
+func (c *Code) version(version int) {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		dark := (bits>>i)&1 == 1
+		a, b := c.Size-11+i%3, i/3
+		c.set(a, b, dark)
+		c.set(b, a, dark)
+	}
+}
+
+// place puts codewords in zigzag from bottom right corner, skipping function modules
+func (c *Code) place(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if c.function[y][x] || i >= len(data)*8 {
+					continue
+				}
+				c.modules[y][x] = (data[i>>3]>>(7-i&7))&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+func maskBit(mask, y, x int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+func (c *Code) mask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.function[y][x] && maskBit(mask, y, x) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+	c.format(mask)
+}
+
+func (c *Code) applyBestMask() {
+	best, bestPenalty := 0, -1
+	for m := 0; m < 8; m++ {
+		c.mask(m)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = m, p
+		}
+		// masking twice restores data
+		c.mask(m)
+	}
+	c.mask(best)
+}
+
+func (c *Code) penalty() int {
+	res := 0
+	line := make([]bool, c.Size)
+	for dir := 0; dir < 2; dir++ {
+		for i := 0; i < c.Size; i++ {
+			for j := 0; j < c.Size; j++ {
+				if dir == 0 {
+					line[j] = c.modules[i][j]
+				} else {
+					line[j] = c.modules[j][i]
+				}
+			}
+			res += linePenalty(line)
+		}
+	}
+
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 {
+				v := c.modules[y][x]
+				if c.modules[y-1][x] == v && c.modules[y][x-1] == v && c.modules[y-1][x-1] == v {
+					res += 3
+				}
+			}
+		}
+	}
+
+	total := c.Size * c.Size
+	res += abs(dark*20-total*10) / total * 10
+	return res
+}
+
+var finderLike = []bool{true, false, true, true, true, false, true}
+
+func linePenalty(line []bool) int {
+	res := 0
+	for i := 0; i < len(line); {
+		j := i
+		for j < len(line) && line[j] == line[i] {
+			j++
+		}
+		if j-i >= 5 {
+			res += 3 + j - i - 5
+		}
+		i = j
+	}
+
+	light := func(from, to int) bool {
+		for k := from; k < to; k++ {
+			if k >= 0 && k < len(line) && line[k] {
+				return false
+			}
+		}
+		return true
+	}
+	for i := 0; i+len(finderLike) <= len(line); i++ {
+		match := true
+		for k, v := range finderLike {
+			if line[i+k] != v {
+				match = false
+				break
+			}
+		}
+		if match && (light(i-4, i) || light(i+7, i+11)) {
+			res += 40
+		}
+	}
+	return res
+}
+
+func rsDivisor(degree int) []byte {
+	res := make([]byte, degree)
+	res[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range res {
+			res[j] = gfMul(res[j], root)
+			if j+1 < len(res) {
+				res[j] ^= res[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return res
+}
+
+func rsRemainder(data, divisor []byte) []byte {
+	res := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ res[0]
+		copy(res, res[1:])
+		res[len(res)-1] = 0
+		for i := range res {
+			res[i] ^= gfMul(divisor[i], factor)
+		}
+	}
+	return res
+}
+
+// gfMul multiplies in GF(2^8) with polynomial 0x11D
+func gfMul(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,433 @@
+package qrcode
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// formatM is format information of level M for masks 0-7, from the standard
+var formatM = []string{
+	"101010000010010",
+	"101000100100101",
+	"101111001111100",
+	"101101101001011",
+	"100010111111001",
+	"100000011001110",
+	"100111110010111",
+	"100101010100000",
+}
+
+// versionInfo is version information of versions 7-10, from the standard
+var versionInfo = map[int]string{
+	7:  "000111110010010100",
+	8:  "001000010110111100",
+	9:  "001001101010011001",
+	10: "001010010011010011",
+}
+
+// byteCapacity is how many bytes fit versions 1-10 at level M, from the standard
+var byteCapacity = []int{14, 26, 42, 62, 84, 106, 122, 152, 180, 213}
+
+func TestReedSolomon(t *testing.T) {
+	// "HELLO WORLD" of version 1-M from well known QR tutorial
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	if got := rsRemainder(data, rsDivisor(len(want))); !bytes.Equal(got, want) {
+		t.Fatalf("error correction %v, expected %v", got, want)
+	}
+}
+
+func TestGFMul(t *testing.T) {
+	// powers of 2 go through all 255 non zero elements
+	seen := map[byte]bool{}
+	v := byte(1)
+	for i := 0; i < 255; i++ {
+		if seen[v] {
+			t.Fatalf("2^%d = %d is repeated", i, v)
+		}
+		seen[v] = true
+		v = gfMul(v, 2)
+	}
+	if v != 1 {
+		t.Fatalf("2^255 = %d, expected 1", v)
+	}
+
+	if got := gfMul(0x80, 2); got != 0x1D {
+		t.Fatalf("0x80 * 2 = %#x, expected 0x1d", got)
+	}
+	for x := 0; x < 256; x++ {
+		if gfMul(byte(x), 1) != byte(x) || gfMul(byte(x), 0) != 0 {
+			t.Fatalf("%d is not multiplied by 1 or 0", x)
+		}
+	}
+}
+
+func TestEncodeData(t *testing.T) {
+	// byte mode, count 1, 'A', terminator, then pad bytes
+	want := []byte{0x40, 0x14, 0x10, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC}
+	if got := encodeData(1, []byte("A"), 16); !bytes.Equal(got, want) {
+		t.Fatalf("data codewords %x, expected %x", got, want)
+	}
+
+	// version 10 has 16 bits count
+	got := encodeData(10, []byte("A"), 216)
+	if !bytes.Equal(got[:4], []byte{0x40, 0x00, 0x14, 0x10}) {
+		t.Fatalf("data codewords start with %x", got[:4])
+	}
+}
+
+func TestVersions(t *testing.T) {
+	for v, capacity := range byteCapacity {
+		version := v + 1
+
+		c, err := Encode(bytes.Repeat([]byte{'a'}, capacity))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Size != 17+4*version {
+			t.Fatalf("%d bytes are encoded to size %d, expected version %d", capacity, c.Size, version)
+		}
+
+		if version == len(byteCapacity) {
+			break
+		}
+		if c, err = Encode(bytes.Repeat([]byte{'a'}, capacity+1)); err != nil {
+			t.Fatal(err)
+		}
+		if c.Size != 21+4*version {
+			t.Fatalf("%d bytes are encoded to size %d, expected version %d", capacity+1, c.Size, version+1)
+		}
+	}
+
+	if _, err := Encode(make([]byte, byteCapacity[len(byteCapacity)-1]+1)); err == nil {
+		t.Fatal("too long data is encoded")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for v, capacity := range byteCapacity {
+		for _, n := range []int{capacity, capacity / 2} {
+			data := make([]byte, n)
+			for i := range data {
+				data[i] = byte(i*31 + v)
+			}
+
+			c, err := Encode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := decode(c)
+			if err != nil {
+				t.Fatalf("version %d, %d bytes: %v", v+1, n, err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("version %d, %d bytes: decoded data is different", v+1, n)
+			}
+		}
+	}
+}
+
+func TestGolden(t *testing.T) {
+	// hashes pin module matrix, TestDecode checks such codes are valid, so change here is a change of output
+	tests := []struct {
+		text string
+		hash string
+	}{
+		{"tonstorage://x", "6d2e9a4e32bd7e2c"},
+		{"tonstorage://meta?url=https%3A%2F%2Fexample.com%2Fbag.tonbag", "d39310721c5d1e76"},
+		{"tonstorage://" + strings.Repeat("0123456789abcdef", 7), "cce41f5d73a1b00f"},
+		{"tonstorage://" + strings.Repeat("0123456789abcdef", 12), "8189a0124699facc"},
+	}
+
+	for _, tt := range tests {
+		c, err := Encode([]byte(tt.text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := matrixHash(c); got != tt.hash {
+			t.Errorf("code of %d bytes, size %d, has hash %s, expected %s", len(tt.text), c.Size, got, tt.hash)
+		}
+	}
+}
+
+func TestPNG(t *testing.T) {
+	c, err := Encode([]byte("tonstorage://x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.PNG(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sz := img.Bounds().Dx(); sz != (c.Size+8)*3 {
+		t.Fatalf("image size %d, expected %d", sz, (c.Size+8)*3)
+	}
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			r, _, _, _ := img.At((x+4)*3+1, (y+4)*3+1).RGBA()
+			if (r == 0) != c.Dark(x, y) {
+				t.Fatalf("module %d,%d has wrong color", x, y)
+			}
+		}
+	}
+}
+
+func matrixHash(c *Code) string {
+	h := sha256.New()
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Dark(x, y) {
+				h.Write([]byte{1})
+			} else {
+				h.Write([]byte{0})
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// decode reads code by the standard, independently of encoder, only byte mode at level M is supported
+func decode(c *Code) ([]byte, error) {
+	version := (c.Size - 17) / 4
+	if version < 1 || version > len(ecM) || c.Size != 17+4*version {
+		return nil, fmt.Errorf("bad size %d", c.Size)
+	}
+	bit := func(x, y int) byte {
+		if c.Dark(x, y) {
+			return '1'
+		}
+		return '0'
+	}
+
+	// format is read from both copies, row 8 goes first from the left, then column 8 upwards
+	var f1, f2 []byte
+	for x := 0; x <= 5; x++ {
+		f1 = append(f1, bit(x, 8))
+	}
+	f1 = append(f1, bit(7, 8), bit(8, 8), bit(8, 7))
+	for y := 5; y >= 0; y-- {
+		f1 = append(f1, bit(8, y))
+	}
+	for y := c.Size - 1; y >= c.Size-7; y-- {
+		f2 = append(f2, bit(8, y))
+	}
+	for x := c.Size - 8; x < c.Size; x++ {
+		f2 = append(f2, bit(x, 8))
+	}
+	if string(f1) != string(f2) {
+		return nil, fmt.Errorf("format copies are different: %s and %s", f1, f2)
+	}
+	mask := -1
+	for m, f := range formatM {
+		if f == string(f1) {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		return nil, fmt.Errorf("unknown format %s", f1)
+	}
+
+	if version >= 7 {
+		var v1, v2 []byte
+		for i := 17; i >= 0; i-- {
+			v1 = append(v1, bit(i/3, c.Size-11+i%3))
+			v2 = append(v2, bit(c.Size-11+i%3, i/3))
+		}
+		if string(v1) != versionInfo[version] || string(v2) != versionInfo[version] {
+			return nil, fmt.Errorf("version info %s and %s, expected %s", v1, v2, versionInfo[version])
+		}
+	}
+
+	if !c.Dark(8, c.Size-8) {
+		return nil, fmt.Errorf("dark module is light")
+	}
+
+	reserved := reservedModules(c.Size, version)
+	var codewords []byte
+	var cur byte
+	n := 0
+	// column pairs from the right, direction changes after each pair, timing column is skipped
+	up := true
+	for x := c.Size - 1; x > 0; x -= 2 {
+		if x == 6 {
+			x--
+		}
+		for i := 0; i < c.Size; i++ {
+			y := i
+			if up {
+				y = c.Size - 1 - i
+			}
+			for _, xx := range []int{x, x - 1} {
+				if reserved[y][xx] {
+					continue
+				}
+				v := c.Dark(xx, y)
+				if maskFormula(mask, xx, y) {
+					v = !v
+				}
+				cur <<= 1
+				if v {
+					cur |= 1
+				}
+				if n++; n%8 == 0 {
+					codewords = append(codewords, cur)
+					cur = 0
+				}
+			}
+		}
+		up = !up
+	}
+
+	b := ecM[version-1]
+	total := b.num1*(b.len1+b.ecLen) + b.num2*(b.len2+b.ecLen)
+	if len(codewords) < total {
+		return nil, fmt.Errorf("%d codewords, expected %d", len(codewords), total)
+	}
+
+	// undo interleaving
+	num := b.num1 + b.num2
+	blocks := make([][]byte, num)
+	pos := 0
+	for i := 0; i < max(b.len1, b.len2); i++ {
+		for k := 0; k < num; k++ {
+			ln := b.len1
+			if k >= b.num1 {
+				ln = b.len2
+			}
+			if i < ln {
+				blocks[k] = append(blocks[k], codewords[pos])
+				pos++
+			}
+		}
+	}
+	for i := 0; i < b.ecLen; i++ {
+		for k := 0; k < num; k++ {
+			blocks[k] = append(blocks[k], codewords[pos])
+			pos++
+		}
+	}
+
+	var data []byte
+	for k, blk := range blocks {
+		// every syndrome of valid block is zero
+		for i := 0; i < b.ecLen; i++ {
+			alpha := byte(1)
+			for j := 0; j < i; j++ {
+				alpha = gfMul(alpha, 2)
+			}
+			var s byte
+			for _, cw := range blk {
+				s = gfMul(s, alpha) ^ cw
+			}
+			if s != 0 {
+				return nil, fmt.Errorf("block %d has error at syndrome %d", k, i)
+			}
+		}
+		data = append(data, blk[:len(blk)-b.ecLen]...)
+	}
+
+	var bits []bool
+	for _, d := range data {
+		for i := 7; i >= 0; i-- {
+			bits = append(bits, (d>>i)&1 == 1)
+		}
+	}
+	read := func(n int) int {
+		v := 0
+		for i := 0; i < n; i++ {
+			v <<= 1
+			if bits[i] {
+				v |= 1
+			}
+		}
+		bits = bits[n:]
+		return v
+	}
+
+	if mode := read(4); mode != 0b0100 {
+		return nil, fmt.Errorf("mode %04b is not byte mode", mode)
+	}
+	count := 8
+	if version >= 10 {
+		count = 16
+	}
+	ln := read(count)
+	if ln*8 > len(bits) {
+		return nil, fmt.Errorf("length %d is out of data", ln)
+	}
+	res := make([]byte, ln)
+	for i := range res {
+		res[i] = byte(read(8))
+	}
+	return res, nil
+}
+
+func maskFormula(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (y+x)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (y+x)%3 == 0
+	case 4:
+		return (y/2+x/3)%2 == 0
+	case 5:
+		return (y*x)%2+(y*x)%3 == 0
+	case 6:
+		return ((y*x)%2+(y*x)%3)%2 == 0
+	default:
+		return ((y+x)%2+(y*x)%3)%2 == 0
+	}
+}
+
+// reservedModules marks finders with separators and format, timing, alignment and version modules
+func reservedModules(size, version int) [][]bool {
+	res := make([][]bool, size)
+	for i := range res {
+		res[i] = make([]bool, size)
+	}
+	area := func(x0, y0, w, h int) {
+		for y := y0; y < y0+h; y++ {
+			for x := x0; x < x0+w; x++ {
+				res[y][x] = true
+			}
+		}
+	}
+
+	area(0, 0, 9, 9)
+	area(size-8, 0, 8, 9)
+	area(0, size-8, 9, 8)
+	area(6, 0, 1, size)
+	area(0, 6, size, 1)
+
+	pos := alignment[version-1]
+	for _, y := range pos {
+		for _, x := range pos {
+			if (x < 9 || x > size-9) && y < 9 || x < 9 && y > size-9 {
+				// overlaps finder
+				continue
+			}
+			area(x-2, y-2, 5, 5)
+		}
+	}
+
+	if version >= 7 {
+		area(size-11, 0, 3, 6)
+		area(0, size-11, 6, 3)
+	}
+	return res
+}
@@ -0,0 +1,118 @@
+// Package routeprice estimates cost of 1 MB through tunnel route, in every currency of its nodes.
+//
+// Route goes to the outer node through out sections and back through in sections.
+// Node of out section is paid for every packet sent through it, node of in section for every received packet,
+// and the outer node for both, so it is counted in price in and price out.
+// Payments are made through virtual channels, every one of them costs a fee for each payment network
+// proxy on the way to node, channel is used for PacketsPerChannel packets, so part of the fee is added per MB.
+package routeprice
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// DefaultPacketSize is an average payload of tunnel packet, in bytes
+const DefaultPacketSize = 512
+
+const bytesInMB = 1 << 20
+
+// MaxPacketSize is the biggest packet size estimation accepts
+const MaxPacketSize = bytesInMB
+
+// Node is a paid section of route
+type Node struct {
+	// Currency is any key which identifies coin node is paid in, prices are summed by it
+	Currency       string
+	PricePerPacket uint64
+	// ProxyFees are min fees of payment network proxies for virtual channel to node, in smallest units
+	ProxyFees []*big.Int
+}
+
+// Model is assumptions of estimation
+type Model struct {
+	// PacketSize is an average payload of packet, in bytes
+	PacketSize uint64
+	// PacketsPerChannel is number of packets paid through one virtual channel
+	PacketsPerChannel int64
+}
+
+// Price is cost of 1 MB in one currency, in its smallest units
+type Price struct {
+	Currency string
+	In, Out  *big.Int
+}
+
+func (m Model) Validate() error {
+	if m.PacketSize == 0 || m.PacketSize > MaxPacketSize {
+		return fmt.Errorf("packet size should be from 1 to %d bytes", MaxPacketSize)
+	}
+	if m.PacketsPerChannel <= 0 {
+		return fmt.Errorf("packets per channel should be positive")
+	}
+	return nil
+}
+
+// PacketsPerMB is number of packets to send 1 MB, rounded up
+func (m Model) PacketsPerMB() int64 {
+	return int64((bytesInMB + m.PacketSize - 1) / m.PacketSize)
+}
+
+// NodePerMB is cost of 1 MB through node, including its share of virtual channel fee
+func (m Model) NodePerMB(n Node) *big.Int {
+	packets := big.NewInt(m.PacketsPerMB())
+
+	amt := new(big.Int).SetUint64(n.PricePerPacket)
+	amt.Mul(amt, packets)
+
+	fee := big.NewInt(0)
+	for _, f := range n.ProxyFees {
+		if f != nil {
+			fee.Add(fee, f)
+		}
+	}
+	if fee.Sign() > 0 {
+		// fee * packets / packets per channel, rounded up to not show part of fee as zero
+		fee.Mul(fee, packets)
+		fee.Add(fee, big.NewInt(m.PacketsPerChannel-1))
+		fee.Div(fee, big.NewInt(m.PacketsPerChannel))
+		amt.Add(amt, fee)
+	}
+	return amt
+}
+
+// Estimate returns prices of route by currency, in order of first node paid in it.
+// Out are paid nodes of the way to the outer node, which is the last one, in are paid nodes of the way back.
+func (m Model) Estimate(out []Node, outer *Node, in []Node) ([]*Price, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	var prices []*Price
+	byCurrency := map[string]*Price{}
+	get := func(currency string) *Price {
+		p := byCurrency[currency]
+		if p == nil {
+			p = &Price{Currency: currency, In: big.NewInt(0), Out: big.NewInt(0)}
+			byCurrency[currency] = p
+			prices = append(prices, p)
+		}
+		return p
+	}
+
+	for _, n := range out {
+		p := get(n.Currency)
+		p.Out.Add(p.Out, m.NodePerMB(n))
+	}
+	if outer != nil {
+		p := get(outer.Currency)
+		amt := m.NodePerMB(*outer)
+		p.Out.Add(p.Out, amt)
+		p.In.Add(p.In, amt)
+	}
+	for _, n := range in {
+		p := get(n.Currency)
+		p.In.Add(p.In, m.NodePerMB(n))
+	}
+	return prices, nil
+}
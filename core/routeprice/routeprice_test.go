@@ -0,0 +1,167 @@
+package routeprice
+
+import (
+	"math/big"
+	"testing"
+)
+
+func fees(v ...int64) []*big.Int {
+	var list []*big.Int
+	for _, f := range v {
+		list = append(list, big.NewInt(f))
+	}
+	return list
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		model Model
+		ok    bool
+	}{
+		{"default", Model{PacketSize: DefaultPacketSize, PacketsPerChannel: 1000}, true},
+		{"one byte packets", Model{PacketSize: 1, PacketsPerChannel: 1}, true},
+		{"max packet size", Model{PacketSize: MaxPacketSize, PacketsPerChannel: 1}, true},
+		{"zero packet size", Model{PacketSize: 0, PacketsPerChannel: 1000}, false},
+		{"too big packet size", Model{PacketSize: MaxPacketSize + 1, PacketsPerChannel: 1000}, false},
+		{"zero packets per channel", Model{PacketSize: 512, PacketsPerChannel: 0}, false},
+		{"negative packets per channel", Model{PacketSize: 512, PacketsPerChannel: -1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.model.Validate()
+			if tt.ok && err != nil {
+				t.Fatal("valid model is rejected:", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("invalid model is accepted")
+			}
+		})
+	}
+
+	if _, err := (Model{}).Estimate(nil, nil, nil); err == nil {
+		t.Fatal("estimate is made with invalid model")
+	}
+}
+
+func TestPacketsPerMB(t *testing.T) {
+	tests := []struct {
+		size uint64
+		want int64
+	}{
+		{512, 2048},
+		{1000, 1049},
+		{1, bytesInMB},
+		{MaxPacketSize, 1},
+		{MaxPacketSize - 1, 2},
+	}
+
+	for _, tt := range tests {
+		if got := (Model{PacketSize: tt.size, PacketsPerChannel: 1}).PacketsPerMB(); got != tt.want {
+			t.Errorf("%d packets per MB of %d bytes, expected %d", got, tt.size, tt.want)
+		}
+	}
+}
+
+func TestNodePerMB(t *testing.T) {
+	m := Model{PacketSize: 512, PacketsPerChannel: 1000}
+
+	tests := []struct {
+		name string
+		node Node
+		want int64
+	}{
+		{"no fee", Node{PricePerPacket: 10}, 20480},
+		{"free node", Node{}, 0},
+		// 7 * 2048 / 1000 = 14.336
+		{"fee is rounded up", Node{PricePerPacket: 10, ProxyFees: fees(3, 4)}, 20480 + 15},
+		// 1 * 2048 / 1000 = 2.048
+		{"small fee is not lost", Node{ProxyFees: fees(1)}, 3},
+		// 500 * 2048 / 1000 = 1024 exactly
+		{"exact fee is not rounded", Node{PricePerPacket: 1, ProxyFees: fees(500)}, 2048 + 1024},
+		{"nil fee is skipped", Node{PricePerPacket: 1, ProxyFees: []*big.Int{nil, big.NewInt(500)}}, 2048 + 1024},
+		{"zero fee", Node{PricePerPacket: 1, ProxyFees: fees(0, 0)}, 2048},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.NodePerMB(tt.node); got.Cmp(big.NewInt(tt.want)) != 0 {
+				t.Fatalf("%s per MB, expected %d", got, tt.want)
+			}
+		})
+	}
+
+	// price does not fit into uint64 after multiplication
+	huge := m.NodePerMB(Node{PricePerPacket: 1 << 63})
+	want := new(big.Int).Mul(new(big.Int).SetUint64(1<<63), big.NewInt(2048))
+	if huge.Cmp(want) != 0 {
+		t.Fatalf("%s per MB, expected %s", huge, want)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	m := Model{PacketSize: 512, PacketsPerChannel: 1000}
+	const mb = 2048
+
+	type amounts struct {
+		currency string
+		in, out  int64
+	}
+
+	tests := []struct {
+		name  string
+		out   []Node
+		outer *Node
+		in    []Node
+		want  []amounts
+	}{
+		{"empty route", nil, nil, nil, nil},
+		{"out only",
+			[]Node{{Currency: "TON", PricePerPacket: 1}, {Currency: "TON", PricePerPacket: 2}}, nil, nil,
+			[]amounts{{"TON", 0, 3 * mb}}},
+		{"in only",
+			nil, nil, []Node{{Currency: "TON", PricePerPacket: 5}},
+			[]amounts{{"TON", 5 * mb, 0}}},
+		{"outer is paid both ways",
+			nil, &Node{Currency: "TON", PricePerPacket: 4}, nil,
+			[]amounts{{"TON", 4 * mb, 4 * mb}}},
+		{"full route",
+			[]Node{{Currency: "TON", PricePerPacket: 1}},
+			&Node{Currency: "TON", PricePerPacket: 10},
+			[]Node{{Currency: "TON", PricePerPacket: 2}, {Currency: "TON", PricePerPacket: 3}},
+			[]amounts{{"TON", 15 * mb, 11 * mb}}},
+		{"currencies in order of first node",
+			[]Node{{Currency: "USDT", PricePerPacket: 1}, {Currency: "TON", PricePerPacket: 2}},
+			&Node{Currency: "USDT", PricePerPacket: 3},
+			[]Node{{Currency: "NOT", PricePerPacket: 4}, {Currency: "TON", PricePerPacket: 5}},
+			[]amounts{{"USDT", 3 * mb, 4 * mb}, {"TON", 5 * mb, 2 * mb}, {"NOT", 4 * mb, 0}}},
+		{"fees are counted per section",
+			[]Node{{Currency: "TON", PricePerPacket: 1, ProxyFees: fees(1)}},
+			&Node{Currency: "TON", ProxyFees: fees(1)},
+			[]Node{{Currency: "TON", ProxyFees: fees(1)}},
+			// every fee is 3 after rounding, outer one is in both directions
+			[]amounts{{"TON", 3 + 3, mb + 3 + 3}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prices, err := m.Estimate(tt.out, tt.outer, tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(prices) != len(tt.want) {
+				t.Fatalf("%d currencies, expected %d", len(prices), len(tt.want))
+			}
+			for i, w := range tt.want {
+				p := prices[i]
+				if p.Currency != w.currency {
+					t.Fatalf("currency %d is %s, expected %s", i, p.Currency, w.currency)
+				}
+				if p.In.Cmp(big.NewInt(w.in)) != 0 || p.Out.Cmp(big.NewInt(w.out)) != 0 {
+					t.Fatalf("%s in %s out %s, expected in %d out %d", p.Currency, p.In, p.Out, w.in, w.out)
+				}
+			}
+		})
+	}
+}
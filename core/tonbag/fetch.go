@@ -0,0 +1,58 @@
+package tonbag
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// maxMetaSize limits size of downloaded or archived meta file, header of bag with many files can be big
+const maxMetaSize = 64 << 20
+
+const fetchTimeout = 30 * time.Second
+
+// IsURL reports if meta should be downloaded by Fetch
+func IsURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Fetch downloads meta file by http or https url and validates it, text formats are accepted too
+func Fetch(ctx context.Context, link string) ([]byte, error) {
+	if !IsURL(link) {
+		return nil, fmt.Errorf("only http and https urls are supported")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download meta: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download meta: server returned %s", resp.Status)
+	}
+	if resp.ContentLength > maxMetaSize {
+		return nil, fmt.Errorf("meta file is too big: %d bytes", resp.ContentLength)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxMetaSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download meta: %w", err)
+	}
+	if len(data) > maxMetaSize {
+		return nil, fmt.Errorf("meta file is too big")
+	}
+	return Decode(data)
+}
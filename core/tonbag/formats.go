@@ -0,0 +1,210 @@
+package tonbag
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Manifest describes bag for indexing, Meta makes it possible to add bag from manifest
+type Manifest struct {
+	BagID       string
+	Description string
+	// Size is a size of files, without header
+	Size      uint64
+	PieceSize uint32
+	DirName   string
+	Files     []File
+	// Meta is base64 of .tonbag file, it is empty in list of archive
+	Meta string
+}
+
+// Link is an url which opens bag in client
+func Link(bagId []byte) string {
+	return "tonstorage://" + strings.ToUpper(hex.EncodeToString(bagId))
+}
+
+// Text encodes meta to base64, to paste it where files can't be sent
+func Text(meta []byte) string {
+	return base64.StdEncoding.EncodeToString(meta)
+}
+
+// NewManifest describes bag of meta file
+func NewManifest(meta []byte) (*Manifest, error) {
+	p, err := Inspect(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Manifest{
+		BagID:       strings.ToUpper(hex.EncodeToString(p.BagID)),
+		Description: p.Description,
+		Size:        p.Size - p.HeaderSize,
+		PieceSize:   p.PieceSize,
+		DirName:     strings.TrimSuffix(p.DirName, "/"),
+		Files:       p.Files,
+		Meta:        Text(meta),
+	}, nil
+}
+
+// Decode accepts .tonbag file, base64 text or json manifest and returns validated .tonbag file
+func Decode(data []byte) ([]byte, error) {
+	_, parseErr := Parse(data)
+	if parseErr == nil {
+		return data, nil
+	}
+
+	text := bytes.TrimSpace(data)
+	var bagId string
+	if len(text) > 0 && text[0] == '{' {
+		var m Manifest
+		if err := json.Unmarshal(text, &m); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		if m.Meta == "" {
+			return nil, fmt.Errorf("manifest has no meta, bag can be added by id %s", m.BagID)
+		}
+		text, bagId = []byte(m.Meta), m.BagID
+	}
+
+	// text can be wrapped by chats
+	meta, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(text)), ""))
+	if err != nil {
+		if bagId == "" {
+			if isText(text) {
+				return nil, fmt.Errorf("text is not a bag id, base64 of .tonbag or manifest")
+			}
+			return nil, parseErr
+		}
+		return nil, fmt.Errorf("failed to decode meta of manifest: %w", err)
+	}
+
+	mf, err := Parse(meta)
+	if err != nil {
+		return nil, err
+	}
+	if bagId != "" && !strings.EqualFold(bagId, hex.EncodeToString(mf.Hash)) {
+		return nil, fmt.Errorf("bag id of manifest does not match its meta")
+	}
+	return meta, nil
+}
+
+func isText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteArchive writes .tonbag files to zip, with manifest.json listing them
+func WriteArchive(w io.Writer, metas [][]byte) error {
+	zw := zip.NewWriter(w)
+	now := time.Now()
+	create := func(name string) (io.Writer, error) {
+		return zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+	}
+
+	list := make([]*Manifest, 0, len(metas))
+	used := map[string]bool{}
+	for _, meta := range metas {
+		m, err := NewManifest(meta)
+		if err != nil {
+			return err
+		}
+
+		name := FileName(m.Description, m.BagID)
+		if used[name] {
+			// same description, bag id makes it unique
+			name += " " + m.BagID
+		}
+		used[name] = true
+
+		f, err := create(name + ".tonbag")
+		if err != nil {
+			return err
+		}
+		if _, err = f.Write(meta); err != nil {
+			return err
+		}
+
+		m.Meta = ""
+		list = append(list, m)
+	}
+
+	f, err := create("manifest.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(list); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// ReadArchive returns .tonbag files from zip, other files are skipped
+func ReadArchive(data []byte) ([][]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	var res [][]byte
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !strings.HasSuffix(strings.ToLower(f.Name), ".tonbag") {
+			continue
+		}
+		if f.UncompressedSize64 > maxMetaSize {
+			return nil, fmt.Errorf("%s is too big for meta file", f.Name)
+		}
+
+		rd, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+		}
+		meta, err := io.ReadAll(io.LimitReader(rd, maxMetaSize))
+		_ = rd.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+		}
+		res = append(res, meta)
+	}
+
+	if len(res) == 0 {
+		return nil, fmt.Errorf("archive has no .tonbag files")
+	}
+	return res, nil
+}
+
+// FileName makes file name for bag from its description, bag id is used when there is no description
+func FileName(description, bagId string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(" .-_()[]", r) {
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(description))
+
+	if r := []rune(name); len(r) > 100 {
+		name = string(r[:100])
+	}
+	name = strings.Trim(name, " .")
+	if name == "" {
+		return bagId
+	}
+	return name
+}
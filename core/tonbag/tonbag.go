@@ -0,0 +1,171 @@
+// Package tonbag reads .tonbag meta files without adding them to storage.
+package tonbag
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/tonutils/torrent-client/core/bagfiles"
+	"github.com/tonutils/torrent-client/core/client"
+	"github.com/xssnick/tonutils-go/tl"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// metaPrefix is tl id of meta file, it is written by some tools, and skipped by others
+const metaPrefix = 0x6a7181e0
+
+type File struct {
+	Name string
+	Size uint64
+}
+
+// Preview is content of meta file, enough to decide if bag should be added
+type Preview struct {
+	BagID       []byte
+	Description string
+	// Size is a size of bag data including header
+	Size        uint64
+	HeaderSize  uint64
+	PieceSize   uint32
+	PiecesCount uint32
+
+	HasHeader    bool
+	HasRootProof bool
+
+	// DirName and Files are known only when meta has header
+	DirName string
+	Files   []File
+}
+
+// Unbox removes tl id of meta file if it is there
+func Unbox(meta []byte) []byte {
+	if len(meta) >= 4 && binary.LittleEndian.Uint32(meta) == metaPrefix {
+		return meta[4:]
+	}
+	return meta
+}
+
+// Parse reads meta file and validates it, boxed and not boxed formats are supported
+func Parse(meta []byte) (*client.MetaFile, error) {
+	meta = Unbox(meta)
+	if len(meta) < 8 {
+		return nil, fmt.Errorf("too short meta")
+	}
+
+	var mf client.MetaFile
+	if _, err := tl.Parse(&mf, meta, false); err != nil {
+		return nil, err
+	}
+	if err := Validate(&mf); err != nil {
+		return nil, err
+	}
+	return &mf, nil
+}
+
+// Validate checks that header and root proof of meta file belong to its bag info,
+// bag id covers only info, so header and proof can be replaced without changing it
+func Validate(mf *client.MetaFile) error {
+	info := &mf.Info
+	if info.PieceSize == 0 {
+		return fmt.Errorf("bag info has zero piece size")
+	}
+	if info.HeaderSize > info.FileSize {
+		return fmt.Errorf("bag info header size is bigger than bag")
+	}
+
+	if mf.RootProof != nil {
+		if _, err := cell.UnwrapProof(mf.RootProof, info.RootHash); err != nil {
+			return fmt.Errorf("root proof does not match bag: %w", err)
+		}
+	}
+
+	if mf.Header == nil {
+		return nil
+	}
+	if err := bagfiles.CheckHeader(mf.Header); err != nil {
+		return err
+	}
+
+	data, err := tl.Serialize(mf.Header, true)
+	if err != nil {
+		return fmt.Errorf("failed to serialize header: %w", err)
+	}
+	if uint64(len(data)) != info.HeaderSize {
+		return fmt.Errorf("header size %d does not match bag info size %d", len(data), info.HeaderSize)
+	}
+	if hash := sha256.Sum256(data); !bytes.Equal(hash[:], info.HeaderHash) {
+		return fmt.Errorf("header hash does not match bag info, meta file is damaged or modified")
+	}
+
+	var dataSize uint64
+	if n := len(mf.Header.DataIndex); n > 0 {
+		dataSize = mf.Header.DataIndex[n-1]
+	}
+	if info.HeaderSize+dataSize != info.FileSize {
+		return fmt.Errorf("files size %d does not match bag size %d", dataSize, info.FileSize-info.HeaderSize)
+	}
+
+	if dir := strings.TrimSuffix(string(mf.Header.DirName), "/"); dir != "" {
+		if err = checkName(dir); err != nil {
+			return fmt.Errorf("bad dir name: %w", err)
+		}
+	}
+	for i := uint32(0); i < mf.Header.FilesCount; i++ {
+		name, _ := bagfiles.HeaderFile(mf.Header, i)
+		if err = checkName(name); err != nil {
+			return fmt.Errorf("bad name of file %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// checkName rejects names which would be written outside of bag dir
+func checkName(name string) error {
+	if name == "" {
+		return fmt.Errorf("name is empty")
+	}
+	if strings.ContainsRune(name, 0) {
+		return fmt.Errorf("%q contains zero byte", name)
+	}
+	if strings.HasPrefix(name, "/") || strings.HasPrefix(name, "\\") || (len(name) > 1 && name[1] == ':') {
+		return fmt.Errorf("%q is absolute path", name)
+	}
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return fmt.Errorf("%q points outside of bag", name)
+		}
+	}
+	return nil
+}
+
+// Inspect parses meta file and lists what is inside
+func Inspect(meta []byte) (*Preview, error) {
+	mf, err := Parse(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Preview{
+		BagID:        mf.Hash,
+		Description:  mf.Info.Description.Value,
+		Size:         mf.Info.FileSize,
+		HeaderSize:   mf.Info.HeaderSize,
+		PieceSize:    mf.Info.PieceSize,
+		PiecesCount:  mf.Info.PiecesNum(),
+		HasHeader:    mf.Header != nil,
+		HasRootProof: mf.RootProof != nil,
+	}
+
+	if mf.Header != nil {
+		p.DirName = string(mf.Header.DirName)
+		p.Files = make([]File, 0, mf.Header.FilesCount)
+		for i := uint32(0); i < mf.Header.FilesCount; i++ {
+			name, size := bagfiles.HeaderFile(mf.Header, i)
+			p.Files = append(p.Files, File{Name: name, Size: size})
+		}
+	}
+	return p, nil
+}
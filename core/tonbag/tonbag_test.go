@@ -0,0 +1,211 @@
+package tonbag
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/tonutils/torrent-client/core/bagfiles"
+	"github.com/tonutils/torrent-client/core/client"
+	"github.com/xssnick/tonutils-go/tl"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"github.com/xssnick/tonutils-storage/storage"
+)
+
+type sizedFile struct {
+	name string
+	size uint64
+}
+
+func (f sizedFile) GetName() string {
+	return f.name
+}
+
+func (f sizedFile) GetSize() uint64 {
+	return f.size
+}
+
+func (f sizedFile) CreateReader() (io.ReaderAt, func() error, error) {
+	return bytes.NewReader(make([]byte, f.size)), func() error { return nil }, nil
+}
+
+// testMeta makes consistent meta file, root is a single cell instead of real pieces tree
+func testMeta(t testing.TB, dir string, names ...string) *client.MetaFile {
+	var refs []storage.FileRef
+	var size uint64
+	for i, name := range names {
+		f := sizedFile{name: name, size: uint64(100 * (i + 1))}
+		refs = append(refs, f)
+		size += f.size
+	}
+	header := bagfiles.BuildHeader(dir, refs)
+
+	headerData, err := tl.Serialize(header, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := cell.BeginCell().MustStoreUInt(0xBAC, 32).EndCell()
+	proof, err := root.CreateProof(cell.CreateProofSkeleton())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tor := storage.NewTorrent("", nil, nil)
+	tor.SetInfoStats(1024, headerData, root.Hash(), uint64(len(headerData))+size, uint64(len(headerData)), "test bag")
+	return &client.MetaFile{Info: *tor.Info, RootProof: proof, Header: header}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		meta   func(t *testing.T) *client.MetaFile
+		errHas string
+	}{
+		{"valid", func(t *testing.T) *client.MetaFile {
+			return testMeta(t, "bag", "a.txt", "sub/b.txt")
+		}, ""},
+		{"valid without dir", func(t *testing.T) *client.MetaFile {
+			return testMeta(t, "", "a.txt")
+		}, ""},
+		{"tampered file name", func(t *testing.T) *client.MetaFile {
+			mf := testMeta(t, "bag", "a.txt", "b.txt")
+			mf.Header.Names[0] = 'x'
+			return mf
+		}, "header hash does not match"},
+		{"tampered file size", func(t *testing.T) *client.MetaFile {
+			mf := testMeta(t, "bag", "a.txt", "b.txt")
+			mf.Header.DataIndex[1]++
+			return mf
+		}, "header hash does not match"},
+		{"tampered dir name", func(t *testing.T) *client.MetaFile {
+			mf := testMeta(t, "bag", "a.txt")
+			mf.Header.DirName = []byte("bad")
+			return mf
+		}, "header hash does not match"},
+		{"tampered proof", func(t *testing.T) *client.MetaFile {
+			mf := testMeta(t, "bag", "a.txt")
+			other := cell.BeginCell().MustStoreUInt(0xBAD, 32).EndCell()
+			proof, err := other.CreateProof(cell.CreateProofSkeleton())
+			if err != nil {
+				t.Fatal(err)
+			}
+			mf.RootProof = proof
+			return mf
+		}, "root proof does not match"},
+		{"proof is not a proof", func(t *testing.T) *client.MetaFile {
+			mf := testMeta(t, "bag", "a.txt")
+			mf.RootProof = cell.BeginCell().MustStoreUInt(0xBAC, 32).EndCell()
+			return mf
+		}, "root proof does not match"},
+		{"parent dir in file name", func(t *testing.T) *client.MetaFile {
+			return testMeta(t, "bag", "a.txt", "../evil.txt")
+		}, "points outside of bag"},
+		{"parent dir inside file name", func(t *testing.T) *client.MetaFile {
+			return testMeta(t, "bag", "sub/../../evil.txt")
+		}, "points outside of bag"},
+		{"parent dir with backslash", func(t *testing.T) *client.MetaFile {
+			return testMeta(t, "bag", "sub\\..\\..\\evil.txt")
+		}, "points outside of bag"},
+		{"parent dir as dir name", func(t *testing.T) *client.MetaFile {
+			return testMeta(t, "..", "a.txt")
+		}, "bad dir name"},
+		{"absolute file name", func(t *testing.T) *client.MetaFile {
+			return testMeta(t, "bag", "/etc/passwd")
+		}, "is absolute path"},
+		{"windows absolute file name", func(t *testing.T) *client.MetaFile {
+			return testMeta(t, "bag", "C:\\evil.txt")
+		}, "is absolute path"},
+		{"zero piece size", func(t *testing.T) *client.MetaFile {
+			mf := testMeta(t, "bag", "a.txt")
+			mf.Info.PieceSize = 0
+			return mf
+		}, "zero piece size"},
+		{"bag size mismatch", func(t *testing.T) *client.MetaFile {
+			mf := testMeta(t, "bag", "a.txt")
+			mf.Info.FileSize++
+			return mf
+		}, "does not match bag size"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.meta(t))
+			if tt.errHas == "" {
+				if err != nil {
+					t.Fatal("valid meta is rejected:", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("meta is accepted, expected error:", tt.errHas)
+			}
+			if !strings.Contains(err.Error(), tt.errHas) {
+				t.Fatalf("error %q, expected %q", err, tt.errHas)
+			}
+		})
+	}
+}
+
+func TestParseSerialized(t *testing.T) {
+	mf := testMeta(t, "bag", "a.txt", "sub/b.txt")
+
+	info, err := tlb.ToCell(mf.Info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	boxed, err := tl.Serialize(mf, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range [][]byte{boxed, Unbox(boxed)} {
+		got, err := Parse(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Hash, info.Hash()) {
+			t.Fatalf("bag id %x, expected %x", got.Hash, info.Hash())
+		}
+		if got.Header == nil || got.Header.FilesCount != 2 {
+			t.Fatal("header is not parsed")
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	mf := testMeta(f, "bag", "a.txt", "sub/b.txt")
+	data, err := tl.Serialize(mf, true)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(data)
+	f.Add(Unbox(data))
+	f.Add([]byte{})
+
+	mf.RootProof = nil
+	mf.Header = nil
+	if data, err = tl.Serialize(mf, false); err != nil {
+		f.Fatal(err)
+	}
+	f.Add(data)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		mf, err := Parse(data)
+		if err != nil {
+			return
+		}
+
+		// accepted meta must stay valid after it is written again
+		again, err := tl.Serialize(mf, true)
+		if err != nil {
+			t.Fatal("failed to serialize parsed meta:", err)
+		}
+		if _, err = Parse(again); err != nil {
+			t.Fatal("serialized meta is rejected:", err)
+		}
+	})
+}
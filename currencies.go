@@ -0,0 +1,370 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ton-blockchain/adnl-tunnel/tunnel"
+	"github.com/tonutils/torrent-client/core/routeprice"
+	paymentsConfig "github.com/xssnick/ton-payment-network/tonpayments/config"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+)
+
+// Currency is a coin which tunnel nodes can be paid in, it is kept in payments config of tunnel
+type Currency struct {
+	// Jetton is a master address, ExtraCurrencyID is set for extra currency, when both are empty it is TON
+	Jetton          string
+	ExtraCurrencyID uint32
+	Symbol          string
+	Decimals        uint8
+	// Enabled currencies are accepted, routes with nodes paid in others can't be built
+	Enabled bool
+
+	// DepositUpTo and DepositWhenLessThan control top up of payment channel, in units of currency
+	DepositUpTo         string
+	DepositWhenLessThan string
+
+	// Balance of payments wallet, empty when it was not fetched
+	Balance string
+}
+
+type CurrenciesResult struct {
+	Currencies []Currency
+	Err        string
+}
+
+// coinPrice is a price of 1 MB through route in one currency, in its smallest units
+type coinPrice struct {
+	symbol   string
+	decimals int
+	ton      bool
+
+	in, out *big.Int
+}
+
+func (p *coinPrice) paid() bool {
+	return p.in.Sign() > 0 || p.out.Sign() > 0
+}
+
+// resolveCoin finds config of accepted currency, like payments service does
+func resolveCoin(coins *paymentsConfig.CoinTypes, jetton *address.Address, ecID uint32) (*paymentsConfig.CoinConfig, error) {
+	if jetton != nil {
+		key := jetton.Bounce(true).String()
+		for k, cc := range coins.Jettons {
+			if a, err := address.ParseAddr(k); err == nil && a.Bounce(true).String() == key {
+				if !cc.Enabled {
+					return nil, fmt.Errorf("jetton %s is not enabled", cc.Symbol)
+				}
+				return &cc, nil
+			}
+		}
+		return nil, fmt.Errorf("jetton %s is not accepted", key)
+	}
+
+	if ecID != 0 {
+		cc, ok := coins.ExtraCurrencies[ecID]
+		if !ok {
+			return nil, fmt.Errorf("extra currency %d is not accepted", ecID)
+		}
+		if !cc.Enabled {
+			return nil, fmt.Errorf("extra currency %s is not enabled", cc.Symbol)
+		}
+		return &cc, nil
+	}
+
+	if !coins.Ton.Enabled {
+		return nil, fmt.Errorf("TON is not enabled")
+	}
+	return &coins.Ton, nil
+}
+
+// packetModel is assumptions of route price estimation, packets are paid through channel till its capacity
+func packetModel(packetSize uint64) routeprice.Model {
+	return routeprice.Model{
+		PacketSize:        packetSize,
+		PacketsPerChannel: tunnel.ChannelCapacityForNumPayments * tunnel.ChannelPacketsToPrepay,
+	}
+}
+
+// routePrice estimates price of 1 MB in and out through route, for every currency of its nodes
+func routePrice(to, from []*tunnel.SectionInfo, coins *paymentsConfig.CoinTypes, packetSize uint64) ([]*coinPrice, error) {
+	model := packetModel(packetSize)
+
+	byCoin := map[string]*coinPrice{}
+	node := func(n *tunnel.SectionInfo) (*routeprice.Node, error) {
+		cc, err := resolveCoin(coins, n.PaymentInfo.JettonMaster, n.PaymentInfo.ExtraCurrencyID)
+		if err != nil {
+			return nil, err
+		}
+
+		key := fmt.Sprint(n.PaymentInfo.ExtraCurrencyID)
+		if n.PaymentInfo.JettonMaster != nil {
+			key = n.PaymentInfo.JettonMaster.Bounce(true).String()
+		}
+		if byCoin[key] == nil {
+			byCoin[key] = &coinPrice{
+				symbol:   cc.Symbol,
+				decimals: int(cc.Decimals),
+				ton:      n.PaymentInfo.JettonMaster == nil && n.PaymentInfo.ExtraCurrencyID == 0,
+			}
+		}
+
+		res := &routeprice.Node{Currency: key, PricePerPacket: n.PaymentInfo.PricePerPacket}
+		for _, section := range n.PaymentInfo.PaymentTunnel {
+			res.ProxyFees = append(res.ProxyFees, section.MinFee)
+		}
+		return res, nil
+	}
+
+	var out, in []routeprice.Node
+	var outer *routeprice.Node
+	for i, n := range to {
+		if n.PaymentInfo == nil {
+			continue
+		}
+		nd, err := node(n)
+		if err != nil {
+			return nil, err
+		}
+		if i == len(to)-1 {
+			outer = nd
+			continue
+		}
+		out = append(out, *nd)
+	}
+	for _, n := range from {
+		if n.PaymentInfo == nil {
+			continue
+		}
+		nd, err := node(n)
+		if err != nil {
+			return nil, err
+		}
+		in = append(in, *nd)
+	}
+
+	list, err := model.Estimate(out, outer, in)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make([]*coinPrice, 0, len(list))
+	for _, p := range list {
+		cp := byCoin[p.Currency]
+		cp.in, cp.out = p.In, p.Out
+		prices = append(prices, cp)
+	}
+
+	sort.SliceStable(prices, func(i, j int) bool {
+		if prices[i].ton != prices[j].ton {
+			return prices[i].ton
+		}
+		return prices[i].symbol < prices[j].symbol
+	})
+	return prices, nil
+}
+
+// formatPrices joins prices in every currency, like "0.01 TON + 0.5 USDT"
+func formatPrices(prices []*coinPrice, in bool) string {
+	var parts []string
+	for _, p := range prices {
+		amt := p.out
+		if in {
+			amt = p.in
+		}
+		parts = append(parts, tlb.MustFromNano(amt, p.decimals).String()+" "+p.symbol)
+	}
+	if len(parts) == 0 {
+		return "0 TON"
+	}
+	return strings.Join(parts, " + ")
+}
+
+// formatPaid joins paid amounts, TON goes first
+func formatPaid(paid map[string]tlb.Coins) string {
+	amounts := make(map[string]string, len(paid))
+	for s, v := range paid {
+		amounts[s] = v.String()
+	}
+	return formatAmounts(amounts)
+}
+
+// formatAmounts joins amounts keyed by currency symbol, like "0.1 TON, 0.0015 USDT"
+func formatAmounts(amounts map[string]string) string {
+	symbols := make([]string, 0, len(amounts))
+	for s := range amounts {
+		symbols = append(symbols, s)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		if (symbols[i] == "TON") != (symbols[j] == "TON") {
+			return symbols[i] == "TON"
+		}
+		return symbols[i] < symbols[j]
+	})
+
+	var parts []string
+	for _, s := range symbols {
+		parts = append(parts, amounts[s]+" "+s)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// currencies lists accepted currencies of config, TON goes first
+func currencies(coins *paymentsConfig.CoinTypes) []Currency {
+	res := []Currency{currencyOf(coins.Ton)}
+	res[0].Symbol = "TON"
+
+	var other []Currency
+	for k, cc := range coins.Jettons {
+		c := currencyOf(cc)
+		c.Jetton = k
+		other = append(other, c)
+	}
+	for id, cc := range coins.ExtraCurrencies {
+		c := currencyOf(cc)
+		c.ExtraCurrencyID = id
+		other = append(other, c)
+	}
+	sort.Slice(other, func(i, j int) bool {
+		return other[i].Symbol < other[j].Symbol
+	})
+	return append(res, other...)
+}
+
+func currencyOf(cc paymentsConfig.CoinConfig) Currency {
+	c := Currency{
+		Symbol:   cc.Symbol,
+		Decimals: cc.Decimals,
+		Enabled:  cc.Enabled,
+	}
+	if cc.BalanceControl != nil {
+		c.DepositUpTo = cc.BalanceControl.DepositUpToAmount
+		c.DepositWhenLessThan = cc.BalanceControl.DepositWhenAmountLessThan
+	}
+	return c
+}
+
+// applyCurrencies validates list and puts it to coins, settings of existing currencies which are not edited are kept
+func applyCurrencies(coins *paymentsConfig.CoinTypes, list []Currency) error {
+	jettons := map[string]paymentsConfig.CoinConfig{}
+	ecs := map[uint32]paymentsConfig.CoinConfig{}
+	hasTon := false
+
+	for _, c := range list {
+		if c.Jetton != "" && c.ExtraCurrencyID != 0 {
+			return fmt.Errorf("%s can't be both jetton and extra currency", c.Symbol)
+		}
+
+		c.Symbol = strings.TrimSpace(c.Symbol)
+		if c.Symbol == "" {
+			return fmt.Errorf("currency symbol is not set")
+		}
+		if c.Decimals > 18 {
+			return fmt.Errorf("%s decimals should be not more than 18", c.Symbol)
+		}
+		// empty amount is zero, it disables top up, payments service can't parse empty one
+		c.DepositUpTo, c.DepositWhenLessThan = strings.TrimSpace(c.DepositUpTo), strings.TrimSpace(c.DepositWhenLessThan)
+		if c.DepositUpTo == "" {
+			c.DepositUpTo = "0"
+		}
+		if c.DepositWhenLessThan == "" {
+			c.DepositWhenLessThan = "0"
+		}
+		if _, err := tlb.FromDecimal(c.DepositUpTo, int(c.Decimals)); err != nil {
+			return fmt.Errorf("invalid %s deposit amount: %w", c.Symbol, err)
+		}
+		if _, err := tlb.FromDecimal(c.DepositWhenLessThan, int(c.Decimals)); err != nil {
+			return fmt.Errorf("invalid %s deposit threshold: %w", c.Symbol, err)
+		}
+
+		switch {
+		case c.Jetton != "":
+			addr, err := address.ParseAddr(strings.TrimSpace(c.Jetton))
+			if err != nil {
+				return fmt.Errorf("invalid %s jetton master address: %w", c.Symbol, err)
+			}
+			key := addr.Bounce(true).String()
+			if _, ok := jettons[key]; ok {
+				return fmt.Errorf("jetton %s is added twice", key)
+			}
+
+			var cc paymentsConfig.CoinConfig
+			for k, v := range coins.Jettons {
+				if a, err := address.ParseAddr(k); err == nil && a.Bounce(true).String() == key {
+					cc = v
+				}
+			}
+			if jettons[key], err = updateCoin(cc, c); err != nil {
+				return err
+			}
+		case c.ExtraCurrencyID != 0:
+			if _, ok := ecs[c.ExtraCurrencyID]; ok {
+				return fmt.Errorf("extra currency %d is added twice", c.ExtraCurrencyID)
+			}
+			cc, err := updateCoin(coins.ExtraCurrencies[c.ExtraCurrencyID], c)
+			if err != nil {
+				return err
+			}
+			ecs[c.ExtraCurrencyID] = cc
+		default:
+			if hasTon {
+				return fmt.Errorf("TON is added twice")
+			}
+			hasTon = true
+
+			// decimals of TON are fixed
+			c.Symbol, c.Decimals = "TON", 9
+			cc, err := updateCoin(coins.Ton, c)
+			if err != nil {
+				return err
+			}
+			coins.Ton = cc
+		}
+	}
+
+	if !hasTon {
+		return fmt.Errorf("TON can be disabled, but not removed")
+	}
+	coins.Jettons = jettons
+	coins.ExtraCurrencies = ecs
+	return nil
+}
+
+// updateCoin puts edited settings to coin config, amounts are checked the same way as payments service does on start
+func updateCoin(cc paymentsConfig.CoinConfig, c Currency) (paymentsConfig.CoinConfig, error) {
+	if cc.Symbol == "" {
+		// new currency, payments service parses these amounts, so they should be set
+		cc.VirtualTunnelConfig = paymentsConfig.VirtualConfig{ProxyMaxCapacity: "0", ProxyMinFee: "0"}
+		cc.MisbehaviorFine = "0"
+		cc.ExcessFeeTon = "0.35"
+	}
+
+	cc.Enabled = c.Enabled
+	cc.Symbol = c.Symbol
+	cc.Decimals = c.Decimals
+
+	bc := paymentsConfig.BalanceControlConfig{WithdrawWhenAmountReached: "0"}
+	if cc.BalanceControl != nil {
+		bc = *cc.BalanceControl
+	}
+	bc.DepositUpToAmount = c.DepositUpTo
+	bc.DepositWhenAmountLessThan = c.DepositWhenLessThan
+	cc.BalanceControl = &bc
+
+	upTo := tlb.MustFromDecimal(bc.DepositUpToAmount, int(cc.Decimals))
+	when := tlb.MustFromDecimal(bc.DepositWhenAmountLessThan, int(cc.Decimals))
+	withdraw, err := tlb.FromDecimal(bc.WithdrawWhenAmountReached, int(cc.Decimals))
+	if err != nil {
+		return cc, fmt.Errorf("invalid %s withdraw amount in config: %w", cc.Symbol, err)
+	}
+	if upTo.Nano().Sign() != 0 && when.Compare(&upTo) > 0 {
+		return cc, fmt.Errorf("%s deposit threshold should not be more than deposit amount", cc.Symbol)
+	}
+	if upTo.Nano().Sign() != 0 && withdraw.Nano().Sign() != 0 && withdraw.Compare(&upTo) < 0 {
+		return cc, fmt.Errorf("%s deposit amount should not be more than withdraw amount %s of config", cc.Symbol, bc.WithdrawWhenAmountReached)
+	}
+	return cc, nil
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ton-blockchain/adnl-tunnel/tunnel"
+	paymentsConfig "github.com/xssnick/ton-payment-network/tonpayments/config"
+	"github.com/xssnick/tonutils-go/address"
+)
+
+var testJetton = address.MustParseAddr("EQCxE6mUtQJKFnGfaROTKOt1lZbDiiX1kCixRv7Nw2Id_sDs")
+
+// section is a node of route as library passes it to acceptor, price 0 is a free node
+func section(price uint64, opts ...func(p *tunnel.Payer)) *tunnel.SectionInfo {
+	s := &tunnel.SectionInfo{Keys: &tunnel.EncryptionKeys{}}
+	if price == 0 && len(opts) == 0 {
+		return s
+	}
+	s.PaymentInfo = &tunnel.Payer{PricePerPacket: price}
+	for _, o := range opts {
+		o(s.PaymentInfo)
+	}
+	return s
+}
+
+func inJetton(p *tunnel.Payer) {
+	p.JettonMaster = testJetton
+}
+
+func inExtra(id uint32) func(p *tunnel.Payer) {
+	return func(p *tunnel.Payer) {
+		p.ExtraCurrencyID = id
+	}
+}
+
+func withFee(fees ...int64) func(p *tunnel.Payer) {
+	return func(p *tunnel.Payer) {
+		for _, f := range fees {
+			p.PaymentTunnel = append(p.PaymentTunnel, tunnel.PaymentTunnelSection{MinFee: big.NewInt(f)})
+		}
+	}
+}
+
+func testCoins() *paymentsConfig.CoinTypes {
+	return &paymentsConfig.CoinTypes{
+		Ton: paymentsConfig.CoinConfig{Enabled: true, Symbol: "TON", Decimals: 9},
+		Jettons: map[string]paymentsConfig.CoinConfig{
+			// other form of address than nodes have
+			testJetton.Bounce(false).String(): {Enabled: true, Symbol: "USDT", Decimals: 6},
+		},
+		ExtraCurrencies: map[uint32]paymentsConfig.CoinConfig{
+			7: {Enabled: true, Symbol: "ECX", Decimals: 9},
+			8: {Enabled: false, Symbol: "OFF", Decimals: 9},
+		},
+	}
+}
+
+func TestRoutePrice(t *testing.T) {
+	// 512 bytes packets, 2048 of them in MB
+	const mb = 2048
+
+	type price struct {
+		symbol  string
+		in, out int64
+	}
+
+	tests := []struct {
+		name     string
+		to, from []*tunnel.SectionInfo
+		want     []price
+	}{
+		{"free route",
+			[]*tunnel.SectionInfo{section(0), section(0)}, []*tunnel.SectionInfo{section(0)},
+			nil},
+		{"outer node is the last of outbound sections",
+			[]*tunnel.SectionInfo{section(1), section(10)}, []*tunnel.SectionInfo{section(2), section(3)},
+			[]price{{"TON", (10 + 2 + 3) * mb, (1 + 10) * mb}}},
+		{"last inbound node is not outer",
+			[]*tunnel.SectionInfo{section(0)}, []*tunnel.SectionInfo{section(4)},
+			[]price{{"TON", 4 * mb, 0}}},
+		{"free outer node",
+			[]*tunnel.SectionInfo{section(3), section(0)}, []*tunnel.SectionInfo{section(0)},
+			[]price{{"TON", 0, 3 * mb}}},
+		{"free sections are skipped",
+			[]*tunnel.SectionInfo{section(0), section(0), section(5)}, []*tunnel.SectionInfo{section(0), section(0)},
+			[]price{{"TON", 5 * mb, 5 * mb}}},
+		{"single paid outer",
+			[]*tunnel.SectionInfo{section(6)}, nil,
+			[]price{{"TON", 6 * mb, 6 * mb}}},
+		{"grouped by currency, TON goes first",
+			[]*tunnel.SectionInfo{section(1, inJetton), section(2), section(3, inExtra(7))},
+			[]*tunnel.SectionInfo{section(4, inJetton), section(5, inExtra(7))},
+			[]price{{"TON", 0, 2 * mb}, {"ECX", (3 + 5) * mb, 3 * mb}, {"USDT", 4 * mb, 1 * mb}}},
+		{"proxy fee is shared by packets of channel",
+			// 6000000 packets per channel, 2048 of them in MB, fee 3000000 gives 1024
+			[]*tunnel.SectionInfo{section(1, withFee(3000000)), section(0)}, nil,
+			[]price{{"TON", 0, mb + 1024}}},
+		{"small proxy fees are rounded up",
+			[]*tunnel.SectionInfo{section(0, withFee(1, 1))}, nil,
+			[]price{{"TON", 1, 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prices, err := routePrice(tt.to, tt.from, testCoins(), 512)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(prices) != len(tt.want) {
+				t.Fatalf("%d currencies, expected %d", len(prices), len(tt.want))
+			}
+			for i, w := range tt.want {
+				p := prices[i]
+				if p.symbol != w.symbol {
+					t.Fatalf("currency %d is %s, expected %s", i, p.symbol, w.symbol)
+				}
+				if p.in.Cmp(big.NewInt(w.in)) != 0 || p.out.Cmp(big.NewInt(w.out)) != 0 {
+					t.Fatalf("%s in %s out %s, expected in %d out %d", p.symbol, p.in, p.out, w.in, w.out)
+				}
+			}
+		})
+	}
+}
+
+func TestRoutePriceCurrencies(t *testing.T) {
+	otherJetton := func(p *tunnel.Payer) {
+		p.JettonMaster = address.MustParseAddr("EQAvlWFDxGF2lXm67y4yzC17wYKD9A0guwPkMs1gOsM__NOT")
+	}
+
+	tests := []struct {
+		name   string
+		node   *tunnel.SectionInfo
+		coins  func(c *paymentsConfig.CoinTypes)
+		errHas string
+	}{
+		{"jetton is not accepted", section(1, otherJetton), nil, "is not accepted"},
+		{"jetton is disabled", section(1, inJetton), func(c *paymentsConfig.CoinTypes) {
+			cc := c.Jettons[testJetton.Bounce(false).String()]
+			cc.Enabled = false
+			c.Jettons[testJetton.Bounce(false).String()] = cc
+		}, "USDT is not enabled"},
+		{"extra currency is not accepted", section(1, inExtra(9)), nil, "extra currency 9 is not accepted"},
+		{"extra currency is disabled", section(1, inExtra(8)), nil, "OFF is not enabled"},
+		{"TON is disabled", section(1), func(c *paymentsConfig.CoinTypes) {
+			c.Ton.Enabled = false
+		}, "TON is not enabled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coins := testCoins()
+			if tt.coins != nil {
+				tt.coins(coins)
+			}
+
+			// not accepted node anywhere in route rejects it
+			routes := [][2][]*tunnel.SectionInfo{
+				{{tt.node}, nil},
+				{{tt.node, section(0)}, nil},
+				{{section(0)}, {tt.node}},
+			}
+			for _, r := range routes {
+				_, err := routePrice(r[0], r[1], coins, 512)
+				if err == nil || !strings.Contains(err.Error(), tt.errHas) {
+					t.Fatalf("error %v, expected %q", err, tt.errHas)
+				}
+			}
+		})
+	}
+
+	// free node of any currency config is not checked
+	coins := testCoins()
+	coins.Ton.Enabled = false
+	if _, err := routePrice([]*tunnel.SectionInfo{section(0)}, nil, coins, 512); err != nil {
+		t.Fatal("free route is rejected:", err)
+	}
+}
+
+func TestRoutePricePacketSize(t *testing.T) {
+	route := []*tunnel.SectionInfo{section(0), section(1)}
+	for size, perMB := range map[uint64]int64{512: 2048, 1024: 1024, 1000: 1049} {
+		prices, err := routePrice(route, nil, testCoins(), size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prices[0].out.Int64() != perMB {
+			t.Fatalf("%s per MB with %d bytes packets, expected %d", prices[0].out, size, perMB)
+		}
+	}
+
+	if _, err := routePrice(route, nil, testCoins(), 0); err == nil {
+		t.Fatal("price is estimated with zero packet size")
+	}
+}
+
+func TestApplyCurrencies(t *testing.T) {
+	existing := func() *paymentsConfig.CoinTypes {
+		coins := testCoins()
+		coins.Ton.VirtualTunnelConfig = paymentsConfig.VirtualConfig{ProxyMaxCapacity: "5", ProxyMinFee: "0.01"}
+		coins.Ton.ExcessFeeTon = "0.2"
+		coins.Ton.BalanceControl = &paymentsConfig.BalanceControlConfig{
+			DepositUpToAmount:         "3",
+			DepositWhenAmountLessThan: "1",
+			WithdrawWhenAmountReached: "10",
+		}
+
+		cc := coins.Jettons[testJetton.Bounce(false).String()]
+		cc.MisbehaviorFine = "0.5"
+		cc.VirtualTunnelConfig = paymentsConfig.VirtualConfig{ProxyMaxCapacity: "100", ProxyMinFee: "0.1"}
+		coins.Jettons[testJetton.Bounce(false).String()] = cc
+		return coins
+	}
+
+	// the same list as UI shows, with edits
+	list := currencies(existing())
+	for i := range list {
+		list[i].Balance = "123"
+		switch list[i].Symbol {
+		case "TON":
+			list[i].DepositUpTo = "4"
+			// TON symbol and decimals can't be changed
+			list[i].Symbol, list[i].Decimals = "XTON", 6
+		case "USDT":
+			list[i].Enabled = false
+			list[i].DepositUpTo, list[i].DepositWhenLessThan = "50", "5"
+			list[i].Jetton = " " + testJetton.Bounce(true).String() + " "
+		}
+	}
+	// OFF is removed and new currency is added
+	var edited []Currency
+	for _, c := range list {
+		if c.Symbol != "OFF" {
+			edited = append(edited, c)
+		}
+	}
+	edited = append(edited, Currency{ExtraCurrencyID: 100, Symbol: " NEW ", Decimals: 3, Enabled: true})
+
+	coins := existing()
+	if err := applyCurrencies(coins, edited); err != nil {
+		t.Fatal(err)
+	}
+
+	ton := coins.Ton
+	if ton.Symbol != "TON" || ton.Decimals != 9 || !ton.Enabled {
+		t.Fatalf("TON is changed to %s with %d decimals", ton.Symbol, ton.Decimals)
+	}
+	if ton.ExcessFeeTon != "0.2" || ton.VirtualTunnelConfig.ProxyMinFee != "0.01" || ton.BalanceControl.WithdrawWhenAmountReached != "10" {
+		t.Fatal("not edited TON settings are lost")
+	}
+	if ton.BalanceControl.DepositUpToAmount != "4" || ton.BalanceControl.DepositWhenAmountLessThan != "1" {
+		t.Fatal("TON deposit is not edited")
+	}
+
+	usdt, ok := coins.Jettons[testJetton.Bounce(true).String()]
+	if !ok || len(coins.Jettons) != 1 {
+		t.Fatalf("jettons are %v", coins.Jettons)
+	}
+	if usdt.Enabled || usdt.MisbehaviorFine != "0.5" || usdt.VirtualTunnelConfig.ProxyMaxCapacity != "100" {
+		t.Fatal("jetton settings are lost or not edited")
+	}
+	if usdt.BalanceControl.DepositUpToAmount != "50" || usdt.BalanceControl.WithdrawWhenAmountReached != "0" {
+		t.Fatal("jetton deposit is not edited")
+	}
+
+	if _, ok = coins.ExtraCurrencies[8]; ok {
+		t.Fatal("removed currency is kept")
+	}
+	if coins.ExtraCurrencies[7].Symbol != "ECX" || !coins.ExtraCurrencies[7].Enabled {
+		t.Fatal("not edited currency is changed")
+	}
+	added := coins.ExtraCurrencies[100]
+	if added.Symbol != "NEW" || added.Decimals != 3 {
+		t.Fatalf("added currency is %+v", added)
+	}
+	// payments service fails to parse empty amounts
+	if added.ExcessFeeTon == "" || added.MisbehaviorFine == "" || added.VirtualTunnelConfig.ProxyMinFee == "" || added.BalanceControl.WithdrawWhenAmountReached == "" {
+		t.Fatalf("added currency has empty amounts %+v", added)
+	}
+}
+
+func TestApplyCurrenciesInvalid(t *testing.T) {
+	ton := Currency{Symbol: "TON", Decimals: 9, Enabled: true}
+	jetton := Currency{Jetton: testJetton.Bounce(true).String(), Symbol: "USDT", Decimals: 6}
+
+	tests := []struct {
+		name   string
+		list   []Currency
+		errHas string
+	}{
+		{"no TON", []Currency{jetton}, "TON can be disabled, but not removed"},
+		{"TON twice", []Currency{ton, ton}, "TON is added twice"},
+		{"jetton and extra currency", []Currency{ton, {Jetton: jetton.Jetton, ExtraCurrencyID: 1, Symbol: "X"}}, "can't be both jetton and extra currency"},
+		{"no symbol", []Currency{ton, {ExtraCurrencyID: 1, Symbol: " "}}, "currency symbol is not set"},
+		{"too many decimals", []Currency{ton, {ExtraCurrencyID: 1, Symbol: "X", Decimals: 19}}, "X decimals should be not more than 18"},
+		{"bad deposit", []Currency{ton, {ExtraCurrencyID: 1, Symbol: "X", DepositUpTo: "a lot"}}, "invalid X deposit amount"},
+		{"bad threshold", []Currency{{Symbol: "TON", DepositWhenLessThan: "1,5"}}, "invalid TON deposit threshold"},
+		{"threshold over deposit", []Currency{{Symbol: "TON", DepositUpTo: "1", DepositWhenLessThan: "2"}}, "TON deposit threshold should not be more than deposit amount"},
+		{"bad jetton address", []Currency{ton, {Jetton: "not an address", Symbol: "X"}}, "invalid X jetton master address"},
+		{"same jetton in other form", []Currency{ton, jetton, {Jetton: testJetton.Bounce(false).String(), Symbol: "USDT2"}}, "is added twice"},
+		{"extra currency twice", []Currency{ton, {ExtraCurrencyID: 5, Symbol: "A"}, {ExtraCurrencyID: 5, Symbol: "B"}}, "extra currency 5 is added twice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := applyCurrencies(testCoins(), tt.list)
+			if err == nil || !strings.Contains(err.Error(), tt.errHas) {
+				t.Fatalf("error %v, expected %q", err, tt.errHas)
+			}
+		})
+	}
+}
+
+func TestApplyCurrenciesWithdraw(t *testing.T) {
+	coins := testCoins()
+	coins.Ton.BalanceControl = &paymentsConfig.BalanceControlConfig{WithdrawWhenAmountReached: "10"}
+
+	err := applyCurrencies(coins, []Currency{{Symbol: "TON", DepositUpTo: "20"}})
+	if err == nil || !strings.Contains(err.Error(), "TON deposit amount should not be more than withdraw amount 10") {
+		t.Fatal("deposit over withdraw amount is accepted:", err)
+	}
+	if err = applyCurrencies(coins, []Currency{{Symbol: "TON", DepositUpTo: "10", DepositWhenLessThan: "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if coins.Ton.BalanceControl.WithdrawWhenAmountReached != "10" || coins.Ton.BalanceControl.DepositUpToAmount != "10" {
+		t.Fatalf("balance control is %+v", *coins.Ton.BalanceControl)
+	}
+}
@@ -20,6 +20,7 @@ import {CreateTorrentModal} from "./components/ModalCreateTorrent";
 import PeersTorrentMenu from "./components/PeersTorrentMenu";
 import {SettingsModal} from "./components/ModalSettings";
 import {RemoveConfirmModal} from "./components/ModalRemoveConfirm";
+import {CreateVersionModal} from "./components/ModalCreateVersion";
 import {ProvidersTorrentMenu} from "./components/ProvidersTorrentMenu";
 import {AddProviderModal} from "./components/ModalAddProvider";
 import {DoTxModal} from "./components/ModalDoTx";
@@ -56,6 +57,7 @@ interface State {
     tunnelSectionsToApprove: SectionInfo[]
     tunnelSectionsPriceIn: string
     tunnelSectionsPriceOut: string
+    tunnelSectionsCurrencies: string[]
 
     tunnelPaidAmount: string
 
@@ -66,11 +68,15 @@ interface State {
     ready: boolean
 
     openFileHash?: string
+    openFileMeta?: string
     addProviderTorrentHash?: string
     removeHashes?: string[]
+    createVersionHash?: string
     doProviderTxModalData?: DoProviderTxModalData
 
     tunnelAddr?: string
+    // tunnelState describes storage connection while tunnel is not built
+    tunnelState?: string
     loadingMessage: string
 }
 
@@ -103,6 +109,7 @@ export class App extends Component<{}, State> {
             tunnelSectionsToApprove: [],
             tunnelSectionsPriceIn: "",
             tunnelSectionsPriceOut: "",
+            tunnelSectionsCurrencies: [],
             loadingMessage: "Loading...",
             tunnelPaidAmount: "",
         }
@@ -130,7 +137,7 @@ export class App extends Component<{}, State> {
     }
 
     toggleAddTorrentModal = () => {
-        this.setState((current)=>({...current, showAddTorrentModal: !this.state.showAddTorrentModal, openFileHash: undefined}))
+        this.setState((current)=>({...current, showAddTorrentModal: !this.state.showAddTorrentModal, openFileHash: undefined, openFileMeta: undefined}))
     }
     toggleCreateTorrentModal = () => {
         this.setState((current)=>({...current, showCreateTorrentModal: !this.state.showCreateTorrentModal}))
@@ -138,6 +145,9 @@ export class App extends Component<{}, State> {
     toggleSettingsModal = () => {
         this.setState((current)=>({...current, showSettingsModal: !this.state.showSettingsModal}))
     }
+    closeCreateVersionModal = () => {
+        this.setState((current)=>({...current, createVersionHash: undefined}))
+    }
     toggleRemoveConfirmModal = () => {
         this.setState((current)=>({...current, showRemoveConfirmModal: !this.state.showRemoveConfirmModal, removeHashes: undefined}))
     }
@@ -173,20 +183,29 @@ export class App extends Component<{}, State> {
         EventsOn("want_remove_torrent", (hashes: string[]) => {
             this.setState((current)=>({...current, removeHashes: hashes, showRemoveConfirmModal: true}))
         })
+        EventsOn("want_create_version", (hash: string) => {
+            this.setState((current)=>({...current, createVersionHash: hash}))
+        })
         EventsOn("open_torrent", (hash: string) => {
             this.setState((current)=>({...current, showAddTorrentModal: true, openFileHash: hash}))
         })
+        EventsOn("open_torrent_meta", (meta: string) => {
+            this.setState((current)=>({...current, showAddTorrentModal: true, openFileMeta: meta}))
+        })
         EventsOn("daemon_ready", (ready: boolean)=> {
             this.setState((current)=>({...current, ready: ready}));
             if (!ready) {
-                this.setState((current)=>({...current, loadingMessage: "Reloading...", tunnelAddr: undefined, tunnelPaidAmount: "", selectedItems: []}));
+                this.setState((current)=>({...current, loadingMessage: "Reloading...", tunnelAddr: undefined, tunnelState: undefined, tunnelPaidAmount: "", selectedItems: []}));
             }
         })
         EventsOn("tunnel_assigned", (addr: string)=> {
-            this.setState((current)=>({...current, tunnelAddr: addr}));
+            this.setState((current)=>({...current, tunnelAddr: addr, tunnelState: undefined}));
         })
-        EventsOn("tunnel_check", (sections: SectionInfo[], priceIn: string, priceOut: string)=> {
-            this.setState((current)=>({...current, showTunnelRouteModal: true, tunnelSectionsToApprove: sections, tunnelSectionsPriceIn: priceIn, tunnelSectionsPriceOut: priceOut}));
+        EventsOn("tunnel_state", (msg: string)=> {
+            this.setState((current)=>({...current, tunnelAddr: undefined, tunnelState: msg}));
+        })
+        EventsOn("tunnel_check", (sections: SectionInfo[], priceIn: string, priceOut: string, currencies: string[] | null)=> {
+            this.setState((current)=>({...current, showTunnelRouteModal: true, tunnelSectionsToApprove: sections, tunnelSectionsPriceIn: priceIn, tunnelSectionsPriceOut: priceOut, tunnelSectionsCurrencies: currencies ?? []}));
         })
         EventsOn("tunnel_reinit_ask", ()=> {
             this.setState((current)=>({...current, showTunnelReinitModal: true}));
@@ -328,13 +347,14 @@ export class App extends Component<{}, State> {
                     }}
                     pricePerMBIn={this.state.tunnelSectionsPriceIn}
                     pricePerMBOut={this.state.tunnelSectionsPriceOut}
+                    currencies={this.state.tunnelSectionsCurrencies}
                     sections={this.state.tunnelSectionsToApprove}
                 /> : null}
                 {this.state.showTunnelReinitModal ? <ReinitTunnelConfirm onExit={this.toggleTunnelReinitModal}/> : null}
-                {this.state.showAddTorrentModal ? <AddTorrentModal openHash={this.state.openFileHash} onExit={this.toggleAddTorrentModal} isDark={this.state.isDark}/> : null}
-                {this.state.showAddTorrentModal ? <AddTorrentModal openHash={this.state.openFileHash} onExit={this.toggleAddTorrentModal} isDark={this.state.isDark}/> : null}
+                {this.state.showAddTorrentModal ? <AddTorrentModal openHash={this.state.openFileHash} openMeta={this.state.openFileMeta} onExit={this.toggleAddTorrentModal} isDark={this.state.isDark}/> : null}
                 {this.state.showCreateTorrentModal ? <CreateTorrentModal onExit={this.toggleCreateTorrentModal}/> : null}
                 {this.state.showSettingsModal ? <SettingsModal onExit={this.toggleSettingsModal}/> : null}
+                {this.state.createVersionHash ? <CreateVersionModal hash={this.state.createVersionHash} onExit={this.closeCreateVersionModal}/> : null}
                 {this.state.showRemoveConfirmModal ? <RemoveConfirmModal hashes={this.state.removeHashes!}  onExit={this.toggleRemoveConfirmModal} isDark={this.state.isDark}/> : null}
                 {this.state.showAddProviderModal ? <AddProviderModal hash={this.state.addProviderTorrentHash!} onExit={this.toggleAddProviderModal}/> : null}
                 {this.state.showDoTransactionModal ? <DoTxModal hash={this.state.doProviderTxModalData!.hash} owner={this.state.doProviderTxModalData!.owner} providers={this.state.doProviderTxModalData!.providers} justTopup={this.state.doProviderTxModalData!.justTopup}  onExit={this.toggleDoTransactionModal}/> : null}
@@ -430,9 +450,13 @@ export class App extends Component<{}, State> {
                             <span><img src={this.state.isDark ? TunnelDark : TunnelLight}
                                        alt=""/>{this.state.tunnelAddr}</span>
                         </div>:  ""}
+                        {this.state.tunnelState ? <div className="tunnel">
+                            <span><img src={this.state.isDark ? TunnelDark : TunnelLight}
+                                       alt=""/>{this.state.tunnelState}</span>
+                        </div>:  ""}
                         {this.state.tunnelPaidAmount != "" ? <div className="tunnel-paid">
                             <span><img src={this.state.isDark ? TunnelPaidDark : TunnelPaidLight}
-                                       alt=""/>{this.state.tunnelPaidAmount} TON</span>
+                                       alt=""/>{this.state.tunnelPaidAmount}</span>
                         </div>:  ""}
                         <div className="speed">
                             <span><img src={this.state.isDark ? DownloadDark : DownloadLight}
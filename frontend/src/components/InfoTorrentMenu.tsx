@@ -1,7 +1,7 @@
 import React, { useState, useEffect } from 'react';
 import { EventsOff, EventsOn } from "../../wailsjs/runtime";
 import { GetInfo } from "../../wailsjs/go/main/App";
-import { textState } from "./Table";
+import { isActiveState, textState } from "./Table";
 
 export interface InfoProps {
     torrent: string;
@@ -21,6 +21,10 @@ interface State {
     progress: string;
     uploaded: string;
     ratio: string;
+    error: string;
+    errorAt: string;
+    prevVersion: string;
+    nextVersion: string;
 }
 
  const InfoTorrentMenu: React.FC<InfoProps> = (props) => {
@@ -38,6 +42,10 @@ interface State {
         progress: "",
         uploaded: "",
         ratio: "",
+        error: "",
+        errorAt: "",
+        prevVersion: "",
+        nextVersion: "",
     });
 
     const short = (val: string) => {
@@ -70,6 +78,10 @@ interface State {
                 peers: tr.Peers,
                 uploaded: tr.Uploaded,
                 ratio: tr.Ratio,
+                error: tr.Error,
+                errorAt: tr.ErrorAt,
+                prevVersion: tr.PrevVersion,
+                nextVersion: tr.NextVersion,
             });
         });
     };
@@ -97,10 +109,10 @@ interface State {
                         </div>
                     </div>
                 </div>
-                {(state.status === "downloading" || state.status === "seeding") ? <div className="basic">
+                {isActiveState(state.status) ? <div className="basic">
                     <div className="item" style={{ width: "20%" }}><span className="field">Upload Speed</span></div>
                     <div className="item" style={{ width: "20%" }}><span className="value">{state.uploadSpeed}</span></div>
-                    {state.status === "downloading" ? <>
+                    {(state.status === "downloading" || state.status === "download-only") ? <>
                         <div className="item" style={{ width: "20%" }}><span className="field">Download Speed</span></div>
                         <div className="item" style={{ width: "15%" }}><span className="value">{state.downloadSpeed}</span></div>
                         <div className="item" style={{ width: "12%" }}><span className="field">Remaining</span></div>
@@ -142,6 +154,27 @@ interface State {
                     <div className="item" style={{ width: "20%" }}><span className="field">Status</span></div>
                     <div className="item" style={{ flexGrow: "1" }}><span className="value">{textState(state.status, Number(state.peers))}</span></div>
                 </div>
+                {state.error !== "" ?
+                    <div className="basic">
+                        <div className="item" style={{ width: "20%" }}><span className="field">Error</span></div>
+                        <div className="item" style={{ flexGrow: "1", maxWidth: "80%" }}><span className="value" style={{ maxWidth: "90%" }}>{state.error} ({state.errorAt})</span></div>
+                    </div> : ""}
+                {state.prevVersion !== "" ?
+                    <div className="basic">
+                        <div className="item" style={{ width: "20%" }}><span className="field">Previous version</span></div>
+                        <div className="item" style={{ flexGrow: "1", maxWidth: "75%" }}>
+                            <span className="value" style={{ maxWidth: "90%" }}>{short(state.prevVersion)}</span>
+                            <button onClick={copy(state.prevVersion)} />
+                        </div>
+                    </div> : ""}
+                {state.nextVersion !== "" ?
+                    <div className="basic">
+                        <div className="item" style={{ width: "20%" }}><span className="field">Newer version</span></div>
+                        <div className="item" style={{ flexGrow: "1", maxWidth: "75%" }}>
+                            <span className="value" style={{ maxWidth: "90%" }}>{short(state.nextVersion)}</span>
+                            <button onClick={copy(state.nextVersion)} />
+                        </div>
+                    </div> : ""}
                 {state.description !== "" ?
                     <div className="basic">
                         <div className="item" style={{ width: "20%" }}><span className="field">Name</span></div>
@@ -3,12 +3,17 @@ import {
     AddTorrentByHash,
     AddTorrentByMeta,
     CheckHeader,
+    FetchMeta,
     GetFiles,
+    InspectMeta,
+    OpenDir,
     RemoveTorrent,
     StartDownload
 } from "../../wailsjs/go/main/App";
 import {Refresh} from "./Table";
+import {EventsOn} from "../../wailsjs/runtime";
 import {Modal} from "./Modal";
+import {api} from "../../wailsjs/go/models";
 import Upload from "../assets/images/icons/upload.svg";
 import FileLight from "../../public/light/file-popup.svg";
 import FileDark from "../../public/dark/file-popup.svg";
@@ -18,6 +23,13 @@ interface State {
     fieldHash?: string
     fileName?: string
     fieldMeta?: ArrayBuffer
+    // directory with already downloaded data, it will be verified instead of download
+    existingDir?: string
+    verifyProgress?: string
+    // meta is being downloaded by pasted url
+    fetching?: boolean
+    // content of selected meta file, shown before it is added
+    preview?: api.MetaPreview
     err: string
 
     canContinue: boolean
@@ -28,6 +40,8 @@ interface State {
 interface AddTorrentModalProps {
     onExit: () => void
     openHash?: string
+    // base64 of opened .tonbag file
+    openMeta?: string
     isDark: boolean
 }
 
@@ -44,11 +58,21 @@ export class AddTorrentModal extends Component<AddTorrentModalProps, State> {
         }
     }
     inter?: number
+    fetchTimer?: number
+    // table listens the same event, so only own listener is removed
+    offProgress?: () => void
 
     componentDidMount() {
         if (this.props.openHash) {
             this.startCheckFiles(this.props.openHash);
+        } else if (this.props.openMeta) {
+            this.inspect("Opened file", Uint8Array.from(atob(this.props.openMeta), (c) => c.charCodeAt(0)).buffer);
         }
+        this.offProgress = EventsOn("update-recheck-progress", (id: string, progress: string) => {
+            if (this.state.verifyProgress !== undefined) {
+                this.setState((current) => ({...current, verifyProgress: progress}))
+            }
+        });
     }
 
     cancel = () => {
@@ -87,9 +111,18 @@ export class AddTorrentModal extends Component<AddTorrentModalProps, State> {
             }
 
             if (this.state.fieldMeta) {
-                // to base64
-                const meta = btoa(String.fromCharCode(...new Uint8Array(this.state.fieldMeta)));
-                AddTorrentByMeta(meta).then((ti: any) => {
+                const meta = this.metaBase64(this.state.fieldMeta);
+                if (this.state.existingDir) {
+                    this.setState((current) => ({...current, verifyProgress: "0.00"}))
+                }
+                AddTorrentByMeta(meta, this.state.existingDir ?? "").then((ti: any) => {
+                    if (this.state.existingDir && ti.Err == "") {
+                        // data is verified, nothing to select
+                        Refresh();
+                        this.props.onExit();
+                        return
+                    }
+                    this.setState((current) => ({...current, verifyProgress: undefined}))
                     process(ti.Hash, ti.Err);
                 })
             } else if (this.state.fieldHash) {
@@ -116,9 +149,57 @@ export class AddTorrentModal extends Component<AddTorrentModalProps, State> {
         }
     }
 
+    metaBase64 = (meta: ArrayBuffer) => {
+        return btoa(String.fromCharCode(...new Uint8Array(meta)));
+    }
+
+    inspect = (name: string, meta: ArrayBuffer) => {
+        this.setState((current) => ({...current, fileName: name, fieldHash: undefined, fieldMeta: meta, existingDir: undefined, preview: undefined, err: "", fetching: false, canContinue: false}))
+        InspectMeta(this.metaBase64(meta)).then((res) => {
+            if (res.Err) {
+                this.setState((current) => ({...current, err: "Invalid .tonbag file: " + res.Err}))
+                return
+            }
+            this.setState((current) => ({...current, preview: res.Preview, canContinue: true}))
+        })
+    }
+
+    fetch = (url: string) => {
+        this.setState((current) => ({...current, fetching: true}))
+        FetchMeta(url).then((res) => {
+            if (this.state.fieldHash?.trim() != url) {
+                // url was changed while downloading
+                return
+            }
+            this.setState((current) => ({...current, fetching: false}))
+            if (res.Err) {
+                this.setState((current) => ({...current, err: res.Err}))
+                return
+            }
+            this.inspect(url.slice(url.lastIndexOf("/") + 1) || url, Uint8Array.from(atob(res.Meta), (c) => c.charCodeAt(0)).buffer);
+            this.setState((current) => ({...current, fieldHash: url}));
+        })
+    }
+
+    renderPreview(p: api.MetaPreview) {
+        return <div className="meta-preview">
+            <span className="name">{p.Description || p.DirName || p.BagID}</span>
+            <span>{p.Size}, {p.PiecesCount} pieces of {p.PieceSize}</span>
+            {p.HasHeader ? <>
+                <span>{p.FilesCount} {p.FilesCount == 1 ? "file" : "files"}{p.DirName ? " in " + p.DirName : ""}</span>
+                {p.Files.slice(0, 5).map((f) => <span key={f.Name} className="file">{f.Name} <span className="size">[{f.Size}]</span></span>)}
+                {p.FilesCount > 5 ? <span className="file">...and {p.FilesCount - 5} more</span> : ""}
+            </> : <span>Files list is not included, it will be downloaded from peers</span>}
+            {!p.HasRootProof ? <span>No root proof</span> : ""}
+        </div>
+    }
+
     componentWillUnmount() {
         if (this.inter)
             clearInterval(this.inter)
+        clearTimeout(this.fetchTimer)
+        if (this.offProgress)
+            this.offProgress()
     }
 
     checkAndSet = (id: string) => {
@@ -208,34 +289,57 @@ export class AddTorrentModal extends Component<AddTorrentModalProps, State> {
                 </div>
                 <div style={this.state.selectFilesStage ? {display: "none"} : {width: "287px"}} className="add-torrent-block">
                     <span className="title">Add Torrent</span>
-                    <input id="torrent-hash-field" required={true} autoFocus={true} placeholder="Insert Bag ID..." onChange={(v) => {
-                        this.setState((current) => ({...current, err: this.state.err, fieldMeta: undefined, fieldHash: v.target.value,
+                    <input id="torrent-hash-field" required={true} autoFocus={true} placeholder="Insert Bag ID, .tonbag text or url..." onChange={(v) => {
+                        let text = v.target.value.trim();
+                        clearTimeout(this.fetchTimer);
+                        if (text.startsWith("http://") || text.startsWith("https://")) {
+                            this.setState((current) => ({...current, fieldHash: v.target.value, fieldMeta: undefined, preview: undefined, err: "", fetching: false, canContinue: false}));
+                            // wait until url is typed or pasted fully
+                            this.fetchTimer = window.setTimeout(() => this.fetch(text), 500);
+                            return
+                        }
+                        if (text.length > 64) {
+                            // base64 or json manifest copied from another client
+                            this.inspect("Pasted text", new TextEncoder().encode(text).buffer as ArrayBuffer);
+                            // keep text visible, meta is used when it is set
+                            this.setState((current) => ({...current, fieldHash: v.target.value}));
+                            return
+                        }
+                        this.setState((current) => ({...current, err: this.state.err, fieldMeta: undefined, preview: undefined, fetching: false, fieldHash: v.target.value,
                             canContinue: v.target.value.length == 64}));
                         (document.getElementById("file-select") as HTMLInputElement).value = "";
                     }} value={this.state.fieldHash} type="text"/>
                     <hr className="hr-text" data-content="or"/>
                     <div className={"file-selector-ui "+ (this.state.fieldMeta !== undefined ? "selected" : "" )}>
                         <img src={this.state.fieldMeta !== undefined ? (this.props.isDark ? FileDark : FileLight) : Upload}/>
-                        <label className="big">{this.state.fieldMeta !== undefined ? "File added" : "Select .tonbag file" }</label>
+                        <label className="big">{this.state.fieldMeta !== undefined ? "File added" : "Select .tonbag or manifest" }</label>
                         <label>{this.state.fieldMeta !== undefined ? this.state.fileName : "Click or drag and drop file here."}</label>
                     </div>
-                    <input id="file-select" type="file" className="file" accept=".tonbag" required={true} onInput={(e)=> {
+                    <input id="file-select" type="file" className="file" accept=".tonbag,.json,.txt" required={true} onInput={(e)=> {
                         let reader = new FileReader();
                         let fileInput = e.target as HTMLInputElement;
                         if (fileInput && fileInput.files) {
                             let name = fileInput.files[0].name;
-                            if(!name.endsWith(".tonbag")) {
+                            if(![".tonbag", ".json", ".txt"].some((ext) => name.endsWith(ext))) {
                                 return;
                             }
 
                             reader.readAsArrayBuffer(fileInput.files[0]);
                             reader.onload = (ev) => {
                                 if (ev.type === "load") {
-                                    this.setState((current) => ({...current, fileName: name, fieldHash: undefined, fieldMeta: reader.result as ArrayBuffer, canContinue: true}))
+                                    this.inspect(name, reader.result as ArrayBuffer)
                                 }
                             }
                         }
                     }}/>
+                    {this.state.preview ? this.renderPreview(this.state.preview) : ""}
+                    {this.state.fieldMeta !== undefined ? <button className="second-button" onClick={() => {
+                        OpenDir().then((dir: string) => {
+                            this.setState((current) => ({...current, existingDir: dir == "" ? undefined : dir}))
+                        })
+                    }}>{this.state.existingDir ? "Data: " + this.state.existingDir : "Already downloaded? Select data folder"}</button> : ""}
+                    {this.state.fetching ? <span>Downloading meta...</span> : ""}
+                    {this.state.verifyProgress !== undefined ? <span>Checking existing data... {this.state.verifyProgress}%</span> : ""}
                     <span className="error">{this.state.err}</span>
                 </div>
                 <div className="modal-control">
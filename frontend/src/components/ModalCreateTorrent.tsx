@@ -2,9 +2,10 @@ import React, {Component} from 'react';
 import {Modal} from "./Modal";
 import {
     CancelCreateTorrent,
-    CreateTorrent,
+    CreateTorrentWithOptions,
     OpenDir,
     OpenFile,
+    PlanTorrent,
 } from "../../wailsjs/go/main/App";
 import {EventsOff, EventsOn} from "../../wailsjs/runtime";
 
@@ -14,8 +15,16 @@ interface State {
     path: string
     name: string
     singleFile: boolean
+    exclude: string
+    includeHidden: boolean
+    deterministic: boolean
+    plan: string
+    pieceSize: number
+    copyInside: boolean
+    startSeeding: boolean
 
     creationProgress: string
+    creationSpeed: string
 
     hash?: string
     err?: string
@@ -35,13 +44,22 @@ export class CreateTorrentModal extends Component<CreateTorrentModalProps, State
             singleFile: false,
             path: "",
             name: "",
-            creationProgress: "0"
+            exclude: "",
+            includeHidden: false,
+            deterministic: false,
+            plan: "",
+            pieceSize: 0,
+            copyInside: false,
+            startSeeding: true,
+            creationProgress: "0",
+            creationSpeed: ""
         }
     }
 
     componentDidMount() {
-        EventsOn("update-create-progress", (progress: string) => {
-            this.setState((current) => ({ ...current, creationProgress: progress }))
+        EventsOn("update-create-progress", (progress: string, speed: string, eta: string) => {
+            let info = speed ? speed+", "+eta+" left" : "";
+            this.setState((current) => ({ ...current, creationProgress: progress, creationSpeed: info }))
         })
     }
     componentWillUnmount() {
@@ -49,11 +67,50 @@ export class CreateTorrentModal extends Component<CreateTorrentModalProps, State
         EventsOff("update-create-progress")
     }
 
+    planSeq = 0
+
+    pieceSizes = [16, 32, 64, 128, 256, 512, 1024, 2048, 4096, 8192]
+
+    options = () => {
+        return {
+            Paths: [this.state.path],
+            Exclude: this.state.exclude.split(",").map((p) => p.trim()).filter((p) => p.length > 0),
+            IncludeHidden: this.state.includeHidden,
+            FollowSymlinks: false,
+            Order: "",
+            DirName: "",
+            Deterministic: this.state.deterministic,
+        }
+    }
+
+    plan = () => {
+        if (this.state.path.length == 0) {
+            this.setState((current) => ({ ...current, plan: "" }))
+            return
+        }
+
+        let seq = ++this.planSeq;
+        PlanTorrent(this.options(), this.state.pieceSize).then((res) => {
+            if (seq != this.planSeq) {
+                // options were changed while scanning
+                return
+            }
+
+            let plan = res.Err ? res.Err : res.FilesCount+" files, "+res.Size+", "+res.PiecesCount+" pieces of "+res.PieceSize;
+            this.setState((current) => ({ ...current, plan: plan, canContinue: !res.Err && current.name.length > 0 }))
+        })
+    }
+
     next = () => {
         if (!this.state.createdStage) {
             this.setState((current) => ({ ...current, canContinue: false, createdStage: true }))
 
-            CreateTorrent(this.state.path, this.state.name).then((res: any) => {
+            CreateTorrentWithOptions(this.options(), {
+                PieceSize: this.state.pieceSize,
+                CopyInside: this.state.copyInside,
+                CopyDir: "",
+                StartPaused: !this.state.startSeeding,
+            }, this.state.name).then((res: any) => {
                 if (res.Hash) {
                     this.setState((current) => ({...current, canContinue: true, hash: res.Hash}))
                 } else {
@@ -80,6 +137,7 @@ export class CreateTorrentModal extends Component<CreateTorrentModalProps, State
                                     <div className="create-progress-bar-form">
                                         <div className="create-progress-bar-small" style={{width: this.state.creationProgress+"%"}}></div>
                                     </div></div>
+                                {this.state.creationSpeed ? <span className="plan">{this.state.creationSpeed}</span> : ""}
                             </div>
                         </div></>}
                 </div>
@@ -98,7 +156,7 @@ export class CreateTorrentModal extends Component<CreateTorrentModalProps, State
                             let e = (p: string) => {
                                 if (p.length > 0) {
                                     let can = p.length > 0 && this.state.name.length > 0;
-                                    this.setState((current) => ({...current, path: p, canContinue: can}))
+                                    this.setState((current) => ({...current, path: p, canContinue: can}), this.plan)
                                 }
                             }
 
@@ -113,11 +171,55 @@ export class CreateTorrentModal extends Component<CreateTorrentModalProps, State
                         <label className="checkbox-file single-file">Single File
                             <input type="checkbox" checked={this.state.singleFile}
                                    onChange={(e) => {
-                                       this.setState((current) => ({...current, path: "", canContinue: false, singleFile: !this.state.singleFile}))
+                                       this.setState((current) => ({...current, path: "", plan: "", canContinue: false, singleFile: !this.state.singleFile}))
+                                   }}/>
+                            <span className="checkmark"></span>
+                        </label>
+                        <label className="checkbox-file single-file">Hidden files
+                            <input type="checkbox" checked={this.state.includeHidden}
+                                   onChange={(e) => {
+                                       this.setState((current) => ({...current, includeHidden: !current.includeHidden}), this.plan)
+                                   }}/>
+                            <span className="checkmark"></span>
+                        </label>
+                        <label className="checkbox-file single-file">Deterministic
+                            <input type="checkbox" checked={this.state.deterministic}
+                                   onChange={(e) => {
+                                       this.setState((current) => ({...current, deterministic: !current.deterministic}), this.plan)
+                                   }}/>
+                            <span className="checkmark"></span>
+                        </label>
+                    </div>
+                    {!this.state.singleFile ? <input className="torrent-name-input" placeholder={"Exclude: .git, node_modules, *.tmp"} onChange={(e) => {
+                        let val = e.currentTarget.value;
+                        this.setState((current) => ({...current, exclude: val}), this.plan)
+                    }}/> : ""}
+                    <select className="torrent-name-input" value={this.state.pieceSize} onChange={(e) => {
+                        let val = parseInt(e.currentTarget.value);
+                        this.setState((current) => ({...current, pieceSize: val}), this.plan)
+                    }}>
+                        <option value={0}>Piece size: auto</option>
+                        {this.pieceSizes.map((kb) => <option key={kb} value={kb*1024}>
+                            Piece size: {kb >= 1024 ? (kb/1024)+" MB" : kb+" KB"}
+                        </option>)}
+                    </select>
+                    <div className="type-input">
+                        <label className="checkbox-file single-file">Copy inside
+                            <input type="checkbox" checked={this.state.copyInside}
+                                   onChange={(e) => {
+                                       this.setState((current) => ({...current, copyInside: !current.copyInside}))
+                                   }}/>
+                            <span className="checkmark"></span>
+                        </label>
+                        <label className="checkbox-file single-file">Start seeding
+                            <input type="checkbox" checked={this.state.startSeeding}
+                                   onChange={(e) => {
+                                       this.setState((current) => ({...current, startSeeding: !current.startSeeding}))
                                    }}/>
                             <span className="checkmark"></span>
                         </label>
                     </div>
+                    {this.state.plan ? <span className="plan">{this.state.plan}</span> : ""}
                     {this.state.err ? <span className="error">{this.state.err}</span> : ""}
                 </div>
                 {(this.state.createdStage && this.state.hash) ? <div className="modal-control">
@@ -0,0 +1,115 @@
+import React, {Component} from 'react';
+import {Modal} from "./Modal";
+import {CancelCreateTorrent, CreateTorrentVersion, GetInfo} from "../../wailsjs/go/main/App";
+import {EventsOff, EventsOn} from "../../wailsjs/runtime";
+import {Refresh} from "./Table";
+
+interface State {
+    name: string
+    retireAfterPeers: number
+    createdStage: boolean
+
+    creationProgress: string
+    creationSpeed: string
+
+    hash?: string
+    err?: string
+}
+
+interface CreateVersionModalProps {
+    hash: string
+    onExit: () => void
+}
+
+export class CreateVersionModal extends Component<CreateVersionModalProps, State> {
+    constructor(props: CreateVersionModalProps) {
+        super(props);
+        this.state = {
+            name: "",
+            retireAfterPeers: 0,
+            createdStage: false,
+            creationProgress: "0",
+            creationSpeed: ""
+        }
+    }
+
+    componentDidMount() {
+        GetInfo(this.props.hash).then((info) => {
+            this.setState((current) => ({...current, name: info.Description}))
+        })
+        EventsOn("update-create-progress", (progress: string, speed: string, eta: string) => {
+            let info = speed ? speed+", "+eta+" left" : "";
+            this.setState((current) => ({ ...current, creationProgress: progress, creationSpeed: info }))
+        })
+    }
+    componentWillUnmount() {
+        CancelCreateTorrent().then()
+        EventsOff("update-create-progress")
+    }
+
+    next = () => {
+        if (this.state.hash) {
+            this.props.onExit()
+            return
+        }
+
+        this.setState((current) => ({ ...current, createdStage: true, err: "" }))
+        CreateTorrentVersion(this.props.hash, this.state.retireAfterPeers).then((res) => {
+            if (res.Hash) {
+                this.setState((current) => ({...current, hash: res.Hash}))
+                Refresh()
+            } else {
+                this.setState((current) => ({...current, createdStage: false, err: res.Err}))
+            }
+        })
+    }
+
+    render() {
+        return <Modal allowClose={!this.state.createdStage} onHide={this.props.onExit} content={(
+            <>
+                <div style={{width: "287px"}} className="add-torrent-block">
+                    {this.state.hash ? <div className="torrent-created">
+                        <div className="success"/>
+                        <span className="title" style={{width: "70%"}}>New version created!</span>
+                    </div> : this.state.createdStage ? <><span className="title">Creating new version...</span>
+                        <div className="files-selector">
+                            <div className="create-torrent-loader-block ">
+                                <div className="create-progress-block">
+                                    <span style={{width: "20%", textAlign: "center"}}>{this.state.creationProgress}%</span>
+                                    <div className="create-progress-bar-form">
+                                        <div className="create-progress-bar-small" style={{width: this.state.creationProgress+"%"}}></div>
+                                    </div></div>
+                                {this.state.creationSpeed ? <span className="plan">{this.state.creationSpeed}</span> : ""}
+                            </div>
+                        </div></> : <>
+                        <span className="title">New version of {this.state.name}</span>
+                        <span className="plan">Files are read again from the same place, unchanged data is not hashed again.</span>
+                        <select className="torrent-name-input" value={this.state.retireAfterPeers} onChange={(e) => {
+                            let val = parseInt(e.currentTarget.value);
+                            this.setState((current) => ({...current, retireAfterPeers: val}))
+                        }}>
+                            <option value={0}>Keep seeding old version</option>
+                            {[1, 3, 5, 10].map((n) => <option key={n} value={n}>
+                                Stop old version after {n} {n == 1 ? "peer" : "peers"}
+                            </option>)}
+                        </select>
+                        {this.state.err ? <span className="error">{this.state.err}</span> : ""}
+                    </>}
+                </div>
+                {this.state.hash ? <div className="modal-control">
+                        <button className="main-button" style={{width: "100%"}} onClick={this.next}>
+                            Done
+                        </button>
+                    </div> :
+                    <div className="modal-control">
+                        <button className="second-button" onClick={this.props.onExit}>
+                            Cancel
+                        </button>
+                        <button className="main-button" disabled={this.state.createdStage} onClick={this.next}>
+                            Continue
+                        </button>
+                    </div>}
+            </>
+        )}/>;
+    }
+}
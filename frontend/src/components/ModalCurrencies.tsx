@@ -0,0 +1,187 @@
+import React, {Component} from 'react';
+import {Modal} from "./Modal";
+import {GetCurrencies, SaveCurrencies} from "../../wailsjs/go/main/App";
+import {main} from "../../wailsjs/go/models";
+
+interface Row {
+    currency: main.Currency
+    // added rows can have their kind and decimals edited, existing are fixed
+    added?: "jetton" | "ec"
+    ecId: string
+    decimals: string
+}
+
+interface State {
+    rows: Row[]
+    loading: boolean
+    balancesErr?: string
+
+    err?: string
+}
+
+interface CurrenciesModalProps {
+    onExit: () => void
+}
+
+export class CurrenciesModal extends Component<CurrenciesModalProps, State> {
+    constructor(props: CurrenciesModalProps) {
+        super(props);
+        this.state = {
+            rows: [],
+            loading: true,
+        }
+    }
+
+    componentDidMount() {
+        GetCurrencies().then((res) => {
+            this.setState((current) => ({...current, loading: false, balancesErr: res.Err,
+                rows: (res.Currencies ?? []).map((c) => ({currency: c, ecId: c.ExtraCurrencyID.toString(), decimals: c.Decimals.toString()})),
+            }))
+        })
+    }
+
+    update = (i: number, upd: (r: Row) => void) => {
+        this.setState((current) => {
+            let rows = [...current.rows];
+            let row = {...rows[i], currency: new main.Currency(rows[i].currency)};
+            upd(row);
+            rows[i] = row;
+            return {...current, rows};
+        })
+    }
+
+    add = (kind: "jetton" | "ec") => {
+        this.setState((current) => ({...current, rows: [...current.rows, {
+            added: kind,
+            ecId: "",
+            decimals: "9",
+            currency: new main.Currency({
+                Jetton: "",
+                ExtraCurrencyID: 0,
+                Symbol: "",
+                Decimals: 9,
+                Enabled: true,
+                DepositUpTo: "",
+                DepositWhenLessThan: "",
+                Balance: "",
+            }),
+        }]}))
+    }
+
+    save = () => {
+        SaveCurrencies(this.state.rows.map((r) => {
+            let c = new main.Currency(r.currency);
+            if (r.added) {
+                c.Decimals = r.decimals != "" ? Number(r.decimals) : 0;
+                if (r.added == "ec") {
+                    c.ExtraCurrencyID = r.ecId != "" ? Number(r.ecId) : 0;
+                }
+            }
+            return c;
+        })).then((err) => {
+            if (err) {
+                this.setState((current) => ({...current, err}))
+                return
+            }
+            this.props.onExit()
+        })
+    }
+
+    renderRow(r: Row, i: number) {
+        let c = r.currency;
+        let isTon = !r.added && c.Jetton == "" && c.ExtraCurrencyID == 0;
+
+        return <div key={i} style={{marginTop: "7px"}}>
+            <div className="set-speed">
+                <label className="checkbox-file daemon">{(c.Symbol || "New currency") + (c.Balance ? ", balance " + c.Balance : "")}
+                    <input type="checkbox" className="file-to-download" checked={c.Enabled}
+                           onChange={() => {
+                               this.update(i, (row) => row.currency.Enabled = !c.Enabled)
+                           }}/>
+                    <span className="checkmark"></span>
+                </label>
+            </div>
+            {r.added ? <>
+                <div className="set-speed">
+                    <div className="info">
+                        <span className="field-name">Symbol</span>
+                        <input type="text" value={c.Symbol}
+                               onChange={(e) => {
+                                   this.update(i, (row) => row.currency.Symbol = e.target.value)
+                               }}/>
+                    </div>
+                    <div className="info">
+                        <span className="field-name">Decimals</span>
+                        <input type="text" pattern="[0-9]*" value={r.decimals}
+                               onChange={(e) => {
+                                   if (e.target.validity.valid)
+                                       this.update(i, (row) => row.decimals = e.target.value)
+                               }}/>
+                    </div>
+                </div>
+                {r.added == "jetton" ?
+                    <input className="torrent-name-input" placeholder="Jetton master address" value={c.Jetton}
+                           onChange={(e) => {
+                               this.update(i, (row) => row.currency.Jetton = e.target.value)
+                           }}/> :
+                    <input className="torrent-name-input" pattern="[0-9]*" placeholder="Extra currency id" value={r.ecId}
+                           onChange={(e) => {
+                               if (e.target.validity.valid)
+                                   this.update(i, (row) => row.ecId = e.target.value)
+                           }}/>}
+            </> : (!isTon ? <span className="field-name" title={c.Jetton}>{c.Jetton != "" ?
+                "Jetton " + c.Jetton.slice(0, 8) + "..." + c.Jetton.slice(c.Jetton.length - 8) :
+                "Extra currency " + c.ExtraCurrencyID}</span> : null)}
+            <div className="set-speed">
+                <div className="info">
+                    <span className="field-name">Deposit up to</span>
+                    <input type="text" value={c.DepositUpTo}
+                           onChange={(e) => {
+                               this.update(i, (row) => row.currency.DepositUpTo = e.target.value)
+                           }}/>
+                </div>
+                <div className="info">
+                    <span className="field-name">When less than</span>
+                    <input type="text" value={c.DepositWhenLessThan}
+                           onChange={(e) => {
+                               this.update(i, (row) => row.currency.DepositWhenLessThan = e.target.value)
+                           }}/>
+                </div>
+            </div>
+            {!isTon ? <button className="second-button" onClick={() => {
+                this.setState((current) => ({...current, rows: current.rows.filter((_, idx) => idx != i)}))
+            }}>Remove</button> : null}
+        </div>
+    }
+
+    render() {
+        return <Modal allowClose={true} onHide={this.props.onExit} content={(
+            <>
+                <div style={{width: "287px"}} className="add-torrent-block">
+                    <span className="title">Payment currencies</span>
+                    <span className="field-name">Tunnel nodes can be paid only in enabled currencies,
+                        changes are applied when tunnel is started again</span>
+                    {this.state.loading ? <span className="loader" style={{height: "12px", width: "12px"}}/> :
+                        <div style={{maxHeight: "360px", overflowY: "auto"}}>
+                            {this.state.rows.map((r, i) => this.renderRow(r, i))}
+                        </div>}
+                    {this.state.balancesErr ? <span className="field-name">{this.state.balancesErr}</span> : ""}
+                    <div className="create-input" style={{marginTop: "7px"}}>
+                        <span>Add currency</span>
+                        <button onClick={() => this.add("jetton")}>Jetton</button>
+                        <button style={{marginLeft: "4px"}} onClick={() => this.add("ec")}>Extra</button>
+                    </div>
+                    {this.state.err ? <span className="error">{this.state.err}</span> : ""}
+                </div>
+                <div className="modal-control">
+                    <button className="second-button" onClick={this.props.onExit}>
+                        Cancel
+                    </button>
+                    <button className="main-button" onClick={this.save} disabled={this.state.loading}>
+                        Save
+                    </button>
+                </div>
+            </>
+        )}/>;
+    }
+}
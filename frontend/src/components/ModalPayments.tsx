@@ -0,0 +1,138 @@
+import React, {Component} from 'react';
+import {Modal} from "./Modal";
+import {GetPaymentsDashboard, SaveSpendingCap} from "../../wailsjs/go/main/App";
+import {main} from "../../wailsjs/go/models";
+
+interface State {
+    dashboard?: main.PaymentsDashboard
+    perSession: {[key: string]: string}
+    perDay: {[key: string]: string}
+
+    err?: string
+}
+
+interface PaymentsModalProps {
+    onExit: () => void
+}
+
+function shortAddr(addr: string) {
+    return addr.length > 16 ? addr.slice(0, 8) + "..." + addr.slice(addr.length - 8) : addr
+}
+
+export class PaymentsModal extends Component<PaymentsModalProps, State> {
+    constructor(props: PaymentsModalProps) {
+        super(props);
+        this.state = {
+            perSession: {},
+            perDay: {},
+        }
+    }
+
+    componentDidMount() {
+        GetPaymentsDashboard().then((dashboard) => {
+            this.setState((current) => ({...current, dashboard,
+                perSession: {...(dashboard.Cap.PerSession ?? {})},
+                perDay: {...(dashboard.Cap.PerDay ?? {})},
+            }))
+        })
+    }
+
+    save = () => {
+        // empty limit is not set
+        let clean = (limits: {[key: string]: string}) => {
+            let res: {[key: string]: string} = {};
+            for (const sym of Object.keys(limits)) {
+                if (limits[sym].trim() != "") {
+                    res[sym] = limits[sym].trim();
+                }
+            }
+            return res;
+        }
+
+        SaveSpendingCap(new main.SpendingCap({
+            PerSession: clean(this.state.perSession),
+            PerDay: clean(this.state.perDay),
+        })).then((err) => {
+            if (err) {
+                this.setState((current) => ({...current, err}))
+                return
+            }
+            this.props.onExit()
+        })
+    }
+
+    renderChannel(ch: main.PaymentChannel) {
+        return <div key={ch.Address} style={{marginTop: "7px"}}>
+            <span className="field-name" title={ch.Address}>{shortAddr(ch.Address) + ", " + ch.Status}</span>
+            <span className="field-name">{"Balance " + ch.Balance + (ch.Locked ? ", locked " + ch.Locked : "")}</span>
+            <span className="field-name">{"Deposited " + ch.Deposited + ", spent " + ch.Spent}</span>
+        </div>
+    }
+
+    renderLimits(sym: string) {
+        return <div key={sym} className="set-speed">
+            <div className="info">
+                <span className="field-name">{sym + " per session"}</span>
+                <input type="text" placeholder="No limit" value={this.state.perSession[sym] ?? ""}
+                       onChange={(e) => {
+                           let v = e.target.value;
+                           this.setState((current) => ({...current, perSession: {...current.perSession, [sym]: v}}))
+                       }}/>
+            </div>
+            <div className="info">
+                <span className="field-name">{sym + " per day"}</span>
+                <input type="text" placeholder="No limit" value={this.state.perDay[sym] ?? ""}
+                       onChange={(e) => {
+                           let v = e.target.value;
+                           this.setState((current) => ({...current, perDay: {...current.perDay, [sym]: v}}))
+                       }}/>
+            </div>
+        </div>
+    }
+
+    render() {
+        let d = this.state.dashboard;
+
+        return <Modal allowClose={true} onHide={this.props.onExit} content={(
+            <>
+                <div style={{width: "287px"}} className="add-torrent-block">
+                    <span className="title">Tunnel payments</span>
+                    {!d ? <span className="loader" style={{height: "12px", width: "12px"}}/> : <>
+                        {d.CapHit ? <span className="error">{"Paid tunnel is stopped: " + d.CapHit +
+                            ". Raise the cap to start it again"}</span> : ""}
+                        <span className="field-name">{"Paid this session: " + (d.Session || "nothing")}</span>
+                        {(d.Days ?? []).slice(0, 3).map((day) =>
+                            <span key={day.Day} className="field-name">{day.Day + ": " + day.Paid}</span>)}
+
+                        <span style={{marginTop: "7px"}} className="field-name">Spending caps</span>
+                        {(d.Symbols ?? []).map((sym) => this.renderLimits(sym))}
+
+                        <span style={{marginTop: "7px"}} className="field-name">Channels</span>
+                        <div style={{maxHeight: "150px", overflowY: "auto"}}>
+                            {d.ChannelsErr ? <span className="field-name">{d.ChannelsErr}</span> :
+                                (d.Channels.length == 0 ? <span className="field-name">No channels yet</span> :
+                                    d.Channels.map((ch) => this.renderChannel(ch)))}
+                        </div>
+
+                        {d.History.length > 0 ? <>
+                            <span style={{marginTop: "7px"}} className="field-name">History</span>
+                            <div style={{maxHeight: "120px", overflowY: "auto"}}>
+                                {d.History.map((e, i) => <span key={i} className="field-name" title={e.Channel}>
+                                    {e.At + " " + e.Action + (e.Amount ? " " + e.Amount : "")}</span>)}
+                            </div>
+                        </> : ""}
+                    </>}
+                    {this.state.err ? <span className="error">{this.state.err}</span> : ""}
+                </div>
+                <div className="modal-control">
+                    <button className="second-button" onClick={this.props.onExit}>
+                        Close
+                    </button>
+                    <button className="main-button" onClick={this.save} disabled={!d}>
+                        Save caps
+                    </button>
+                </div>
+            </>
+        )}/>;
+    }
+}
@@ -0,0 +1,116 @@
+import React, {Component} from 'react';
+import {Modal} from "./Modal";
+import {GetPoolSubscription, SavePoolSubscription} from "../../wailsjs/go/main/App";
+import {main} from "../../wailsjs/go/models";
+
+interface State {
+    status?: main.PoolSubscriptionResult
+
+    url: string
+    publisherKey: string
+    refresh: string
+    saving: boolean
+
+    err?: string
+}
+
+interface PoolSubscriptionModalProps {
+    onExit: () => void
+}
+
+export class PoolSubscriptionModal extends Component<PoolSubscriptionModalProps, State> {
+    constructor(props: PoolSubscriptionModalProps) {
+        super(props);
+        this.state = {
+            url: "",
+            publisherKey: "",
+            refresh: "",
+            saving: false,
+        }
+    }
+
+    componentDidMount() {
+        GetPoolSubscription().then((status) => {
+            let s = status.Subscription;
+            this.setState((current) => ({...current, status,
+                url: s.URL,
+                publisherKey: s.PublisherKey,
+                refresh: s.RefreshMinutes > 0 ? s.RefreshMinutes.toString() : "",
+            }))
+        })
+    }
+
+    save = (apply: boolean) => {
+        this.setState((current) => ({...current, saving: true, err: undefined}))
+        SavePoolSubscription(new main.TunnelPoolSubscription({
+            URL: this.state.url.trim(),
+            PublisherKey: this.state.publisherKey.trim(),
+            RefreshMinutes: this.state.refresh.trim() != "" ? Number(this.state.refresh) : 0,
+        }), apply).then((err) => {
+            if (err) {
+                this.setState((current) => ({...current, err, saving: false}))
+                return
+            }
+            this.props.onExit()
+        })
+    }
+
+    render() {
+        let d = this.state.status;
+
+        return <Modal allowClose={true} onHide={this.props.onExit} content={(
+            <>
+                <div style={{width: "287px"}} className="add-torrent-block">
+                    <span className="title">Nodes pool subscription</span>
+                    {!d ? <span className="loader" style={{height: "12px", width: "12px"}}/> : <>
+                        <span className="field-name">Pool is fetched by URL instead of local file, leave empty
+                            to use local file</span>
+                        <div className="info">
+                            <span className="field-name">Pool URL</span>
+                            <input type="text" placeholder="https://.../nodes-pool.json" value={this.state.url}
+                                   onChange={(e) => {
+                                       let v = e.target.value;
+                                       this.setState((current) => ({...current, url: v}))
+                                   }}/>
+                        </div>
+                        <div className="info">
+                            <span className="field-name">Publisher ed25519 key, base64 or hex</span>
+                            <input type="text" placeholder="Not verified" value={this.state.publisherKey}
+                                   onChange={(e) => {
+                                       let v = e.target.value;
+                                       this.setState((current) => ({...current, publisherKey: v}))
+                                   }}/>
+                        </div>
+                        <div className="info">
+                            <span className="field-name">Refresh every, minutes</span>
+                            <input type="text" pattern="[0-9]*" placeholder="60" value={this.state.refresh}
+                                   onChange={(e) => {
+                                       if (!e.target.validity.valid) return;
+                                       let v = e.target.value;
+                                       this.setState((current) => ({...current, refresh: v}))
+                                   }}/>
+                        </div>
+
+                        {d.FetchedAt ? <span className="field-name">
+                            {"Fetched " + d.FetchedAt + ", " + d.Nodes + " nodes"}</span> : ""}
+                        {d.CheckedAt ? <span className="field-name">{"Last checked " + d.CheckedAt}</span> : ""}
+                        {d.Pending ? <span className="field-name">
+                            {"Applied on the next reroute: " + d.Pending}</span> : ""}
+                        {d.Err ? <span className="error">{"Last fetch failed: " + d.Err}</span> : ""}
+                    </>}
+                    {this.state.err ? <span className="error">{this.state.err}</span> : ""}
+                </div>
+                <div className="modal-control">
+                    <button className="second-button" onClick={() => this.save(true)}
+                            disabled={!d || this.state.saving}>
+                        Save and apply
+                    </button>
+                    <button className="main-button" onClick={() => this.save(false)}
+                            disabled={!d || this.state.saving}>
+                        Save
+                    </button>
+                </div>
+            </>
+        )}/>;
+    }
+}
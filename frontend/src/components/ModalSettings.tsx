@@ -7,17 +7,38 @@ import {
     SaveConfig,
     SetSpeedLimit,
     OpenTunnelConfig,
+    ExportAllMeta,
+    ImportMetaArchive,
+    GetTunnelPolicy,
 } from "../../wailsjs/go/main/App";
 import {BrowserOpenURL} from "../../wailsjs/runtime";
+import {Refresh} from "./Table";
+import {TunnelPolicyModal} from "./ModalTunnelPolicy";
+import {CurrenciesModal} from "./ModalCurrencies";
+import {PaymentsModal} from "./ModalPayments";
+import {WalletModal} from "./ModalWallet";
+import {TunnelInspectorModal} from "./ModalTunnelInspector";
+import {TunnelHealthModal} from "./ModalTunnelHealth";
+import {PoolSubscriptionModal} from "./ModalPoolSubscription";
 
 interface State {
     downloads: string
     tunnelConfig: string
+    tunnelRouting: string
+    tunnelRequired: boolean
     addr: string
     addrValid: boolean
     uploadSpeed: string
     downloadSpeed: string
     selectedTunnelConfig: boolean
+    tunnelPolicy: boolean
+    showPolicy: boolean
+    showCurrencies: boolean
+    showPayments: boolean
+    showWallet: boolean
+    showInspector: boolean
+    showHealth: boolean
+    showSubscription: boolean
 
     seedFiles: boolean
 
@@ -40,7 +61,17 @@ export class SettingsModal extends Component<SettingsModalProps, State> {
             downloadSpeed: "",
             seedFiles: false,
             tunnelConfig: "",
+            tunnelRouting: "",
+            tunnelRequired: false,
             selectedTunnelConfig: false,
+            tunnelPolicy: false,
+            showPolicy: false,
+            showCurrencies: false,
+            showPayments: false,
+            showWallet: false,
+            showInspector: false,
+            showHealth: false,
+            showSubscription: false,
         };
     }
 
@@ -54,6 +85,8 @@ export class SettingsModal extends Component<SettingsModalProps, State> {
                 daemonMasterAddr: cfg.DaemonControlAddr,
                 seedFiles: cfg.SeedMode,
                 tunnelConfig: cfg.TunnelConfig.NodesPoolConfigPath,
+                tunnelRouting: cfg.TunnelRouting ?? "",
+                tunnelRequired: cfg.TunnelRequired ?? false,
             }))
         })
         GetSpeedLimit().then((lim: any) => {
@@ -62,6 +95,13 @@ export class SettingsModal extends Component<SettingsModalProps, State> {
 
             this.setState((current)=>({...current, uploadSpeed: u, downloadSpeed: d}))
         })
+        this.loadPolicy()
+    }
+
+    loadPolicy = () => {
+        GetTunnelPolicy().then((p) => {
+            this.setState((current)=>({...current, tunnelPolicy: p.Enabled}))
+        })
     }
 
     next = () => {
@@ -74,13 +114,50 @@ export class SettingsModal extends Component<SettingsModalProps, State> {
             u = Number(this.state.uploadSpeed);
         }
 
-        SaveConfig(this.state.downloads, this.state.seedFiles, this.state.addr, this.state.tunnelConfig, this.state.selectedTunnelConfig).then(()=>{
+        SaveConfig(this.state.downloads, this.state.seedFiles, this.state.addr, this.state.tunnelConfig, this.state.selectedTunnelConfig, this.state.tunnelRouting, this.state.tunnelRequired).then(()=>{
             SetSpeedLimit(d, u).then();
         });
         this.props.onExit();
     }
 
     render() {
+        if (this.state.showPolicy) {
+            return <TunnelPolicyModal onExit={() => {
+                this.setState((current) => ({...current, showPolicy: false}))
+                this.loadPolicy()
+            }}/>
+        }
+        if (this.state.showCurrencies) {
+            return <CurrenciesModal onExit={() => {
+                this.setState((current) => ({...current, showCurrencies: false}))
+            }}/>
+        }
+        if (this.state.showPayments) {
+            return <PaymentsModal onExit={() => {
+                this.setState((current) => ({...current, showPayments: false}))
+            }}/>
+        }
+        if (this.state.showInspector) {
+            return <TunnelInspectorModal onExit={() => {
+                this.setState((current) => ({...current, showInspector: false}))
+            }}/>
+        }
+        if (this.state.showHealth) {
+            return <TunnelHealthModal onExit={() => {
+                this.setState((current) => ({...current, showHealth: false}))
+            }}/>
+        }
+        if (this.state.showSubscription) {
+            return <PoolSubscriptionModal onExit={() => {
+                this.setState((current) => ({...current, showSubscription: false}))
+            }}/>
+        }
+        if (this.state.showWallet) {
+            return <WalletModal onExit={() => {
+                this.setState((current) => ({...current, showWallet: false}))
+            }}/>
+        }
+
         return <Modal allowClose={true} onHide={this.props.onExit} content={(
             <>
                 <div style={{width: "287px"}} className="add-torrent-block">
@@ -113,6 +190,92 @@ export class SettingsModal extends Component<SettingsModalProps, State> {
                         }}>Select
                         </button>
                     </div>
+                    <span style={{ marginTop: "7px" }} className="field-name">Through tunnel</span>
+                    <select className="torrent-name-input" value={this.state.tunnelRouting} onChange={(e) => {
+                        let val = e.currentTarget.value;
+                        this.setState((current) => ({...current, tunnelRouting: val}))
+                    }}>
+                        <option value="">All traffic</option>
+                        <option value="bags">Bags data, DHT direct</option>
+                        <option value="private">Only private bags</option>
+                    </select>
+                    <div className="set-speed">
+                        <label className="checkbox-file daemon" title="Without it, bags go directly until tunnel is ready, with a one-time key not linked to the tunnel one">Tunnel required (kill switch)
+                            <input type="checkbox" className="file-to-download" checked={this.state.tunnelRequired}
+                                   onChange={(e) => {
+                                       this.setState((current) => ({...current, tunnelRequired: !this.state.tunnelRequired}))
+                                   }}/>
+                            <span className="checkmark"></span>
+                        </label>
+                    </div>
+                    <span style={{ marginTop: "7px" }} className="field-name">Nodes pool subscription</span>
+                    <div className="create-input">
+                        <span>Fetch pool by URL</span>
+                        <button onClick={() => {
+                            this.setState((current) => ({...current, showSubscription: true}))
+                        }}>Edit
+                        </button>
+                    </div>
+                    <span style={{ marginTop: "7px" }} className="field-name">Tunnel routes</span>
+                    <div className="create-input">
+                        <span>{this.state.tunnelPolicy ? "Decided by policy" : "Ask for every route"}</span>
+                        <button onClick={() => {
+                            this.setState((current) => ({...current, showPolicy: true}))
+                        }}>Edit
+                        </button>
+                    </div>
+                    <span style={{ marginTop: "7px" }} className="field-name">Tunnel inspector</span>
+                    <div className="create-input">
+                        <span>Route nodes, pin and exclude</span>
+                        <button onClick={() => {
+                            this.setState((current) => ({...current, showInspector: true}))
+                        }}>Open
+                        </button>
+                    </div>
+                    <span style={{ marginTop: "7px" }} className="field-name">Tunnel health</span>
+                    <div className="create-input">
+                        <span>Reroute and event log</span>
+                        <button onClick={() => {
+                            this.setState((current) => ({...current, showHealth: true}))
+                        }}>Open
+                        </button>
+                    </div>
+                    <span style={{ marginTop: "7px" }} className="field-name">Payment currencies</span>
+                    <div className="create-input">
+                        <span>Currencies and balances</span>
+                        <button onClick={() => {
+                            this.setState((current) => ({...current, showCurrencies: true}))
+                        }}>Edit
+                        </button>
+                    </div>
+                    <span style={{ marginTop: "7px" }} className="field-name">Tunnel payments</span>
+                    <div className="create-input">
+                        <span>Channels and spending caps</span>
+                        <button onClick={() => {
+                            this.setState((current) => ({...current, showPayments: true}))
+                        }}>Open
+                        </button>
+                    </div>
+                    <span style={{ marginTop: "7px" }} className="field-name">Payments wallet</span>
+                    <div className="create-input">
+                        <span>Balance, top up and backup</span>
+                        <button onClick={() => {
+                            this.setState((current) => ({...current, showWallet: true}))
+                        }}>Open
+                        </button>
+                    </div>
+                    <span style={{ marginTop: "7px" }} className="field-name">All bags</span>
+                    <div className="create-input">
+                        <span>Archive of .tonbag files</span>
+                        <button onClick={() => {
+                            ExportAllMeta().then()
+                        }}>Export
+                        </button>
+                        <button style={{marginLeft: "4px"}} onClick={() => {
+                            ImportMetaArchive().then(Refresh)
+                        }}>Import
+                        </button>
+                    </div>
                     <div className="set-speed">
                         <div className="info">
                             <span className="field-name">Max upload KB/s</span>
@@ -0,0 +1,153 @@
+import React, {Component} from 'react';
+import {Modal} from "./Modal";
+import {GetTunnelHealth, SaveTunnelHealthPolicy} from "../../wailsjs/go/main/App";
+import {main} from "../../wailsjs/go/models";
+
+type Field = "maxLoss" | "maxRTT" | "expected" | "minThroughput" | "outage" | "breach" | "cooldown"
+
+interface State {
+    health?: main.TunnelHealthResult
+
+    autoReroute: boolean
+    maxLoss: string
+    maxRTT: string
+    expected: string
+    minThroughput: string
+    outage: string
+    breach: string
+    cooldown: string
+
+    err?: string
+}
+
+interface TunnelHealthModalProps {
+    onExit: () => void
+}
+
+const num = (v: string) => v.trim() != "" ? Number(v) : 0;
+const str = (v: number) => v > 0 ? v.toString() : "";
+
+export class TunnelHealthModal extends Component<TunnelHealthModalProps, State> {
+    constructor(props: TunnelHealthModalProps) {
+        super(props);
+        this.state = {
+            autoReroute: false,
+            maxLoss: "",
+            maxRTT: "",
+            expected: "",
+            minThroughput: "",
+            outage: "",
+            breach: "",
+            cooldown: "",
+        }
+    }
+
+    componentDidMount() {
+        GetTunnelHealth().then((health) => {
+            let p = health.Policy;
+            this.setState((current) => ({...current, health,
+                autoReroute: p.AutoReroute,
+                maxLoss: str(p.MaxLossPercent),
+                maxRTT: str(p.MaxRTTMs),
+                expected: str(p.ExpectedKBps),
+                minThroughput: str(p.MinThroughputPercent),
+                outage: str(p.OutageSeconds),
+                breach: str(p.BreachSeconds),
+                cooldown: str(p.CooldownSeconds),
+            }))
+        })
+    }
+
+    save = () => {
+        SaveTunnelHealthPolicy(new main.TunnelHealthPolicy({
+            AutoReroute: this.state.autoReroute,
+            MaxLossPercent: num(this.state.maxLoss),
+            MaxRTTMs: num(this.state.maxRTT),
+            ExpectedKBps: num(this.state.expected),
+            MinThroughputPercent: num(this.state.minThroughput),
+            OutageSeconds: num(this.state.outage),
+            BreachSeconds: num(this.state.breach),
+            CooldownSeconds: num(this.state.cooldown),
+        })).then((err) => {
+            if (err) {
+                this.setState((current) => ({...current, err}))
+                return
+            }
+            this.props.onExit()
+        })
+    }
+
+    renderInput(name: string, field: Field, placeholder: string, decimal?: boolean) {
+        return <div className="info">
+            <span className="field-name">{name}</span>
+            <input type="text" pattern={decimal ? "[0-9]*[.]?[0-9]*" : "[0-9]*"} placeholder={placeholder}
+                   value={this.state[field]}
+                   onChange={(e) => {
+                       if (!e.target.validity.valid) return;
+                       let v = e.target.value;
+                       this.setState((current) => ({...current, [field]: v}))
+                   }}/>
+        </div>
+    }
+
+    render() {
+        let d = this.state.health;
+
+        return <Modal allowClose={true} onHide={this.props.onExit} content={(
+            <>
+                <div style={{width: "287px"}} className="add-torrent-block">
+                    <span className="title">Tunnel health</span>
+                    {!d ? <span className="loader" style={{height: "12px", width: "12px"}}/> : <>
+                        {d.Health.RTT ? <>
+                            <span className="field-name">{"Loss " + d.Health.Loss + ", round trip " + d.Health.RTT}</span>
+                            <span className="field-name">{"In " + d.Health.In + ", out " + d.Health.Out}</span>
+                        </> : <span className="field-name">Health is not measured yet</span>}
+                        {d.Breach ? <span className="error">{"Route is unhealthy: " + d.Breach}</span> : ""}
+
+                        <div className="set-speed">
+                            <label className="checkbox-file daemon">Reroute automatically
+                                <input type="checkbox" className="file-to-download" checked={this.state.autoReroute}
+                                       onChange={() => {
+                                           this.setState((current) => ({...current, autoReroute: !current.autoReroute}))
+                                       }}/>
+                                <span className="checkmark"></span>
+                            </label>
+                        </div>
+                        <div className="set-speed">
+                            {this.renderInput("Max loss, %", "maxLoss", "No limit", true)}
+                            {this.renderInput("Max round trip, ms", "maxRTT", "No limit")}
+                        </div>
+                        <div className="set-speed">
+                            {this.renderInput("Expected KB/s in", "expected", "Not set")}
+                            {this.renderInput("Min % of expected", "minThroughput", "No limit")}
+                        </div>
+                        <div className="set-speed">
+                            {this.renderInput("Outage after, s", "outage", "Library default")}
+                            {this.renderInput("Breached for, s", "breach", "0")}
+                        </div>
+                        <div className="set-speed">
+                            {this.renderInput("New route grace, s", "cooldown", "0")}
+                        </div>
+
+                        <span style={{marginTop: "7px"}} className="field-name">Events</span>
+                        <div style={{maxHeight: "150px", overflowY: "auto"}}>
+                            {d.Events.length == 0 ? <span className="field-name">No events yet</span> :
+                                d.Events.map((e, i) => <span key={i} className="field-name"
+                                                             title={e.Nodes + (e.ExtAddr ? ", " + e.ExtAddr : "")}>
+                                    {e.At + " " + e.Kind + (e.Reason ? ": " + e.Reason : "")}</span>)}
+                        </div>
+                    </>}
+                    {this.state.err ? <span className="error">{this.state.err}</span> : ""}
+                </div>
+                <div className="modal-control">
+                    <button className="second-button" onClick={this.props.onExit}>
+                        Cancel
+                    </button>
+                    <button className="main-button" onClick={this.save} disabled={!d}>
+                        Save
+                    </button>
+                </div>
+            </>
+        )}/>;
+    }
+}
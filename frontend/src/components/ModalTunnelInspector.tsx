@@ -0,0 +1,130 @@
+import React, {Component} from 'react';
+import {Modal} from "./Modal";
+import {GetTunnelInspector, SaveTunnelNodes} from "../../wailsjs/go/main/App";
+import {main} from "../../wailsjs/go/models";
+
+interface State {
+    inspector?: main.TunnelInspector
+    pinned: string[]
+    excluded: string[]
+    showPool: boolean
+
+    err?: string
+}
+
+interface TunnelInspectorModalProps {
+    onExit: () => void
+}
+
+function shortKey(key: string) {
+    return key.slice(0, 8) + "..." + key.slice(key.length - 6)
+}
+
+export class TunnelInspectorModal extends Component<TunnelInspectorModalProps, State> {
+    constructor(props: TunnelInspectorModalProps) {
+        super(props);
+        this.state = {
+            pinned: [],
+            excluded: [],
+            showPool: false,
+        }
+    }
+
+    componentDidMount() {
+        GetTunnelInspector().then((inspector) => {
+            this.setState((current) => ({...current, inspector,
+                pinned: inspector.Nodes.Pinned ?? [], excluded: inspector.Nodes.Excluded ?? [],
+                err: inspector.Err || undefined}))
+        })
+    }
+
+    toggle = (key: string, pin: boolean) => {
+        this.setState((current) => {
+            let pinned = current.pinned.filter((k) => k != key)
+            let excluded = current.excluded.filter((k) => k != key)
+            if (pin && !current.pinned.includes(key)) {
+                pinned.push(key)
+            } else if (!pin && !current.excluded.includes(key)) {
+                excluded.push(key)
+            }
+            return {...current, pinned, excluded}
+        })
+    }
+
+    save = (reroute: boolean) => {
+        SaveTunnelNodes(new main.TunnelNodes({Pinned: this.state.pinned, Excluded: this.state.excluded}), reroute).then((err) => {
+            if (err) {
+                this.setState((current) => ({...current, err}))
+                return
+            }
+            this.props.onExit()
+        })
+    }
+
+    renderNode(key: string, info: string) {
+        let pinned = this.state.pinned.includes(key)
+        let excluded = this.state.excluded.includes(key)
+
+        return <div key={key} className="create-input" title={key}>
+            <span>{shortKey(key) + (info ? ", " + info : "")}</span>
+            <button style={{opacity: pinned ? 1 : 0.6}} onClick={() => this.toggle(key, true)}>
+                {pinned ? "Pinned" : "Pin"}
+            </button>
+            <button style={{marginLeft: "4px", opacity: excluded ? 1 : 0.6}} onClick={() => this.toggle(key, false)}>
+                {excluded ? "Excluded" : "Exclude"}
+            </button>
+        </div>
+    }
+
+    renderRoute(r: main.InspectorRoute, i: number) {
+        return <div key={i} style={{marginTop: "7px"}}>
+            <span className="field-name">{(r.Current ? "Current route" : "Route") + ", external " + r.ExtAddr}</span>
+            <span className="field-name">{r.Since + (r.Until ? " - " + r.Until : "") + ", uptime " + r.Uptime}</span>
+            <span className="field-name">{"Relayed in " + r.BytesIn + ", out " + r.BytesOut}</span>
+            {r.LastChecked ? <span className="field-name">{"Last answered " + r.LastChecked}</span> : ""}
+            {r.Current && r.Health.RTT ? <span className="field-name">
+                {"Round trip " + r.Health.RTT + ", loss " + r.Health.Loss}</span> : ""}
+            {(r.Sections ?? []).map((s) => this.renderNode(s.Key, s.Role + ", " + s.Price + " per MB"))}
+        </div>
+    }
+
+    render() {
+        let d = this.state.inspector;
+
+        return <Modal allowClose={true} onHide={this.props.onExit} content={(
+            <>
+                <div style={{width: "287px"}} className="add-torrent-block">
+                    <span className="title">Tunnel routes</span>
+                    {!d ? <span className="loader" style={{height: "12px", width: "12px"}}/> : <>
+                        <span className="field-name">Pinned nodes must be in the next route only, up to one
+                            per section, excluded nodes are removed from pool</span>
+                        <div style={{maxHeight: "250px", overflowY: "auto"}}>
+                            {d.Routes.length == 0 ? <span className="field-name">Tunnel is not used</span> :
+                                d.Routes.map((r, i) => this.renderRoute(r, i))}
+                        </div>
+
+                        <div style={{marginTop: "7px"}} className="create-input">
+                            <span>{"Nodes pool, " + d.Pool.length + " nodes"}</span>
+                            <button onClick={() => {
+                                this.setState((current) => ({...current, showPool: !current.showPool}))
+                            }}>{this.state.showPool ? "Hide" : "Show"}
+                            </button>
+                        </div>
+                        {this.state.showPool ? <div style={{maxHeight: "150px", overflowY: "auto"}}>
+                            {d.Pool.map((n) => this.renderNode(n.Key, n.Price ? n.Price + " per MB" : "free"))}
+                        </div> : ""}
+                    </>}
+                    {this.state.err ? <span className="error">{this.state.err}</span> : ""}
+                </div>
+                <div className="modal-control">
+                    <button className="second-button" onClick={() => this.save(true)} disabled={!d}>
+                        Save and reroute
+                    </button>
+                    <button className="main-button" onClick={() => this.save(false)} disabled={!d}>
+                        Save
+                    </button>
+                </div>
+            </>
+        )}/>;
+    }
+}
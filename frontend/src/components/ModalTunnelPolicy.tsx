@@ -0,0 +1,157 @@
+import React, {Component} from 'react';
+import {Modal} from "./Modal";
+import {GetTunnelPolicy, SaveTunnelPolicy} from "../../wailsjs/go/main/App";
+import {main} from "../../wailsjs/go/models";
+
+interface State {
+    enabled: boolean
+    maxPriceIn: string
+    maxPriceOut: string
+    freeOnly: boolean
+    sectionsNum: string
+    allowed: string
+    denied: string
+    askOnMismatch: boolean
+
+    err?: string
+}
+
+interface TunnelPolicyModalProps {
+    onExit: () => void
+}
+
+const keys = (list: string) => list.split(",").map((k) => k.trim()).filter((k) => k != "");
+
+export class TunnelPolicyModal extends Component<TunnelPolicyModalProps, State> {
+    constructor(props: TunnelPolicyModalProps) {
+        super(props);
+        this.state = {
+            enabled: false,
+            maxPriceIn: "",
+            maxPriceOut: "",
+            freeOnly: false,
+            sectionsNum: "",
+            allowed: "",
+            denied: "",
+            askOnMismatch: false,
+        }
+    }
+
+    componentDidMount() {
+        GetTunnelPolicy().then((p) => {
+            this.setState((current) => ({...current,
+                enabled: p.Enabled,
+                maxPriceIn: p.MaxPriceInPerMB,
+                maxPriceOut: p.MaxPriceOutPerMB,
+                freeOnly: p.FreeOnly,
+                sectionsNum: p.SectionsNum > 0 ? p.SectionsNum.toString() : "",
+                allowed: (p.AllowedNodes ?? []).join(", "),
+                denied: (p.DeniedNodes ?? []).join(", "),
+                askOnMismatch: p.AskOnMismatch,
+            }))
+        })
+    }
+
+    save = () => {
+        SaveTunnelPolicy(new main.TunnelPolicy({
+            Enabled: this.state.enabled,
+            MaxPriceInPerMB: this.state.maxPriceIn.trim(),
+            MaxPriceOutPerMB: this.state.maxPriceOut.trim(),
+            FreeOnly: this.state.freeOnly,
+            SectionsNum: this.state.sectionsNum != "" ? Number(this.state.sectionsNum) : 0,
+            AllowedNodes: keys(this.state.allowed),
+            DeniedNodes: keys(this.state.denied),
+            AskOnMismatch: this.state.askOnMismatch,
+        })).then((err) => {
+            if (err) {
+                this.setState((current) => ({...current, err}))
+                return
+            }
+            this.props.onExit()
+        })
+    }
+
+    render() {
+        return <Modal allowClose={true} onHide={this.props.onExit} content={(
+            <>
+                <div style={{width: "287px"}} className="add-torrent-block">
+                    <span className="title">Tunnel policy</span>
+                    <div className="set-speed">
+                        <label className="checkbox-file daemon">Decide on routes without asking
+                            <input type="checkbox" className="file-to-download" checked={this.state.enabled}
+                                   onChange={() => {
+                                       this.setState((current) => ({...current, enabled: !this.state.enabled}))
+                                   }}/>
+                            <span className="checkmark"></span>
+                        </label>
+                    </div>
+                    <div style={this.state.enabled ? {} : {opacity: 0.5, pointerEvents: "none"}}>
+                        <div className="set-speed">
+                            <div className="info">
+                                <span className="field-name">Max TON per MB in</span>
+                                <input type="text" placeholder="No limit" value={this.state.maxPriceIn}
+                                       onChange={(e) => {
+                                           this.setState((current) => ({...current, maxPriceIn: e.target.value}))
+                                       }}/>
+                            </div>
+                            <div className="info">
+                                <span className="field-name">Max TON per MB out</span>
+                                <input type="text" placeholder="No limit" value={this.state.maxPriceOut}
+                                       onChange={(e) => {
+                                           this.setState((current) => ({...current, maxPriceOut: e.target.value}))
+                                       }}/>
+                            </div>
+                        </div>
+                        <div className="set-speed">
+                            <label className="checkbox-file daemon">Free routes only
+                                <input type="checkbox" className="file-to-download" checked={this.state.freeOnly}
+                                       onChange={() => {
+                                           this.setState((current) => ({...current, freeOnly: !this.state.freeOnly}))
+                                       }}/>
+                                <span className="checkmark"></span>
+                            </label>
+                        </div>
+                        <div className="set-speed">
+                            <div className="info">
+                                <span className="field-name">Min sections in route</span>
+                                <input type="text" pattern="[0-9]*" placeholder="Any" value={this.state.sectionsNum}
+                                       onChange={(e) => {
+                                           if (e.target.validity.valid)
+                                               this.setState((current) => ({...current, sectionsNum: e.target.value}))
+                                       }}/>
+                            </div>
+                        </div>
+                        <span style={{marginTop: "7px"}} className="field-name">Allowed nodes</span>
+                        <input className="torrent-name-input" placeholder="Any, or base64 keys separated by comma" value={this.state.allowed}
+                               onChange={(e) => {
+                                   this.setState((current) => ({...current, allowed: e.target.value}))
+                               }}/>
+                        <span style={{marginTop: "7px"}} className="field-name">Denied nodes</span>
+                        <input className="torrent-name-input" placeholder="Base64 keys separated by comma" value={this.state.denied}
+                               onChange={(e) => {
+                                   this.setState((current) => ({...current, denied: e.target.value}))
+                               }}/>
+                        <div className="set-speed">
+                            <label className="checkbox-file daemon">Ask when route does not match
+                                <input type="checkbox" className="file-to-download" checked={this.state.askOnMismatch}
+                                       onChange={() => {
+                                           this.setState((current) => ({...current, askOnMismatch: !this.state.askOnMismatch}))
+                                       }}/>
+                                <span className="checkmark"></span>
+                            </label>
+                        </div>
+                    </div>
+                    {this.state.err ? <span className="error">{this.state.err}</span> : ""}
+                </div>
+                <div className="modal-control">
+                    <button className="second-button" onClick={this.props.onExit}>
+                        Cancel
+                    </button>
+                    <button className="main-button" onClick={this.save}>
+                        Save
+                    </button>
+                </div>
+            </>
+        )}/>;
+    }
+}
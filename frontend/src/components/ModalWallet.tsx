@@ -0,0 +1,184 @@
+import React, {Component} from 'react';
+import {Modal} from "./Modal";
+import {
+    GetPaymentWallet,
+    GetPaymentWalletMnemonic,
+    GetTopUpLink,
+    RestorePaymentWallet,
+    WithdrawFromPaymentWallet
+} from "../../wailsjs/go/main/App";
+import {main} from "../../wailsjs/go/models";
+
+interface State {
+    wallet?: main.PaymentWalletResult
+
+    topUpAmount: string
+    topUp?: main.TopUpResult
+
+    withdrawTo: string
+    withdrawAmount: string
+    withdrawComment: string
+    withdrawing: boolean
+    withdrawHash?: string
+
+    words?: string
+    restoreWords: string
+    showRestore: boolean
+
+    err?: string
+}
+
+interface WalletModalProps {
+    onExit: () => void
+}
+
+export class WalletModal extends Component<WalletModalProps, State> {
+    constructor(props: WalletModalProps) {
+        super(props);
+        this.state = {
+            topUpAmount: "",
+            withdrawTo: "",
+            withdrawAmount: "",
+            withdrawComment: "",
+            withdrawing: false,
+            restoreWords: "",
+            showRestore: false,
+        }
+    }
+
+    componentDidMount() {
+        this.refresh()
+    }
+
+    refresh = () => {
+        GetPaymentWallet().then((wallet) => {
+            this.setState((current) => ({...current, wallet}))
+        })
+    }
+
+    topUp = () => {
+        GetTopUpLink(this.state.topUpAmount).then((topUp) => {
+            this.setState((current) => ({...current, topUp, err: topUp.Err || undefined}))
+        })
+    }
+
+    withdraw = () => {
+        this.setState((current) => ({...current, withdrawing: true, withdrawHash: undefined, err: undefined}))
+        WithdrawFromPaymentWallet(this.state.withdrawTo, this.state.withdrawAmount, this.state.withdrawComment).then((res) => {
+            this.setState((current) => ({...current, withdrawing: false,
+                err: res.Err || undefined, withdrawHash: res.Hash || undefined}))
+            if (!res.Err) {
+                this.refresh()
+            }
+        })
+    }
+
+    restore = () => {
+        RestorePaymentWallet(this.state.restoreWords).then((err) => {
+            if (err) {
+                this.setState((current) => ({...current, err}))
+                return
+            }
+            this.props.onExit()
+        })
+    }
+
+    render() {
+        let w = this.state.wallet;
+
+        return <Modal allowClose={true} onHide={this.props.onExit} content={(
+            <>
+                <div style={{width: "287px"}} className="add-torrent-block">
+                    <span className="title">Payments wallet</span>
+                    {!w ? <span className="loader" style={{height: "12px", width: "12px"}}/> : <>
+                        <input className="torrent-name-input" readOnly value={w.Address}
+                               onClick={(e) => e.currentTarget.select()}/>
+                        <span className="field-name">{w.Balance ? "Balance " + w.Balance + " TON" : w.Err}</span>
+
+                        <span style={{marginTop: "7px"}} className="field-name">Top up</span>
+                        <div className="create-input">
+                            <input type="text" placeholder="Amount in TON, optional" value={this.state.topUpAmount}
+                                   onChange={(e) => {
+                                       let v = e.target.value;
+                                       this.setState((current) => ({...current, topUpAmount: v}))
+                                   }}/>
+                            <button onClick={this.topUp}>Link</button>
+                        </div>
+                        {this.state.topUp?.Link ? <>
+                            <input className="torrent-name-input" readOnly value={this.state.topUp.Link}
+                                   onClick={(e) => e.currentTarget.select()}/>
+                            {this.state.topUp.QR ? <img style={{width: "150px", alignSelf: "center"}}
+                                                        src={this.state.topUp.QR} alt=""/> : ""}
+                        </> : ""}
+
+                        <span style={{marginTop: "7px"}} className="field-name">Withdraw TON</span>
+                        <input className="torrent-name-input" placeholder="To address" value={this.state.withdrawTo}
+                               onChange={(e) => {
+                                   let v = e.target.value;
+                                   this.setState((current) => ({...current, withdrawTo: v}))
+                               }}/>
+                        <div className="set-speed">
+                            <div className="info">
+                                <span className="field-name">Amount</span>
+                                <input type="text" value={this.state.withdrawAmount}
+                                       onChange={(e) => {
+                                           let v = e.target.value;
+                                           this.setState((current) => ({...current, withdrawAmount: v}))
+                                       }}/>
+                            </div>
+                            <div className="info">
+                                <span className="field-name">Comment</span>
+                                <input type="text" value={this.state.withdrawComment}
+                                       onChange={(e) => {
+                                           let v = e.target.value;
+                                           this.setState((current) => ({...current, withdrawComment: v}))
+                                       }}/>
+                            </div>
+                        </div>
+                        <button className="second-button" disabled={this.state.withdrawing} onClick={this.withdraw}>
+                            {this.state.withdrawing ? "Withdrawing..." : "Withdraw"}
+                        </button>
+                        {this.state.withdrawHash ? <span className="field-name" title={this.state.withdrawHash}>
+                            {"Done, transaction " + this.state.withdrawHash.slice(0, 16) + "..."}</span> : ""}
+
+                        <span style={{marginTop: "7px"}} className="field-name">Backup</span>
+                        {this.state.words ? <>
+                            <span className="field-name">Keep these words in a safe place, they restore the wallet
+                                only in TON Torrent</span>
+                            <textarea readOnly rows={4} value={this.state.words}
+                                      onClick={(e) => e.currentTarget.select()}/>
+                        </> : <div className="create-input">
+                            <span>Recovery words</span>
+                            <button onClick={() => {
+                                GetPaymentWalletMnemonic().then((words) => {
+                                    this.setState((current) => ({...current, words}))
+                                })
+                            }}>Show
+                            </button>
+                            <button style={{marginLeft: "4px"}} onClick={() => {
+                                this.setState((current) => ({...current, showRestore: !current.showRestore}))
+                            }}>Restore
+                            </button>
+                        </div>}
+                        {this.state.showRestore ? <>
+                            <span className="field-name">Current wallet is replaced, back it up first.
+                                Storage is restarted after restore</span>
+                            <textarea rows={4} placeholder="24 recovery words" value={this.state.restoreWords}
+                                      onChange={(e) => {
+                                          let v = e.target.value;
+                                          this.setState((current) => ({...current, restoreWords: v}))
+                                      }}/>
+                            <button className="second-button" onClick={this.restore}>Restore wallet</button>
+                        </> : ""}
+                    </>}
+                    {this.state.err ? <span className="error">{this.state.err}</span> : ""}
+                </div>
+                <div className="modal-control">
+                    <button className="main-button" onClick={this.props.onExit}>
+                        Close
+                    </button>
+                </div>
+            </>
+        )}/>;
+    }
+}
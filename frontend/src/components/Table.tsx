@@ -1,9 +1,21 @@
 import React, {Component} from 'react';
 import {
     ExportMeta,
+    ExportMetaManifest,
+    ExportMetaQR,
+    ExportMetaText,
     GetTorrents,
+    GetTunnelRouting,
+    OpenDir as SelectDir,
     OpenFolder,
+    RecheckTorrent,
+    RelocateTorrent,
+    RetryTorrent,
     SetActive,
+    SetActiveDownload,
+    SetActiveUpload,
+    SetPrivate,
+    VerifyDirMatchesBag,
     WantRemoveTorrent
 } from "../../wailsjs/go/main/App";
 import {EventsEmit, EventsOff, EventsOn} from "../../wailsjs/runtime";
@@ -30,12 +42,20 @@ export interface TorrentItem {
     progress: number
     peersNum: number
     selected: boolean
+    activeDownload: boolean
+    activeUpload: boolean
+    error: string
+    private: boolean
 }
 
 interface State {
     contextShow: boolean
     contextItems: JSX.Element[]
     torrents: TorrentItem[]
+    // progress of running force rechecks by bag id
+    rechecks: {[id: string]: string}
+    // bags are routed by private flag, only private ones go through tunnel
+    privateRouting: boolean
 }
 
 export interface Filter {
@@ -60,6 +80,12 @@ export function textState(state: string, peers: number) {
             return "Verifying";
         case "downloading":
             return peers > 0 ? "Downloading" : "Searching for peers";
+        case "download-only":
+            return peers > 0 ? "Downloading, not seeding" : "Searching for peers";
+        case "seed-only":
+            return "Seeding, download paused";
+        case "completed":
+            return "Completed, not seeding";
         case "fail":
             return "Failed";
         case "inactive":
@@ -68,6 +94,10 @@ export function textState(state: string, peers: number) {
     return "";
 }
 
+export function isActiveState(state: string) {
+    return state == "downloading" || state == "download-only" || state == "seeding" || state == "seed-only";
+}
+
 export class Table extends Component<TableProps,State> {
     constructor(props: TableProps, state:State) {
         super(props, state);
@@ -76,6 +106,8 @@ export class Table extends Component<TableProps,State> {
             contextShow: false,
             contextItems: [],
             torrents: [],
+            rechecks: {},
+            privateRouting: false,
         }
     }
 
@@ -100,6 +132,10 @@ export class Table extends Component<TableProps,State> {
                     progress: t.Progress,
                     peersNum: t.PeersNum,
                     selected:  selected,
+                    activeDownload: t.ActiveDownload,
+                    activeUpload: t.ActiveUpload,
+                    error: t.Error,
+                    private: t.Private,
                 })
             })
 
@@ -111,7 +147,7 @@ export class Table extends Component<TableProps,State> {
             this.props.onSelect(selected.map<SelectedTorrent>((ti) => {
                 return {
                     hash: ti.id,
-                    active: ti.state == "downloading" || ti.state == "seeding",
+                    active: isActiveState(ti.state),
                 }
             }));
         });
@@ -121,19 +157,83 @@ export class Table extends Component<TableProps,State> {
         EventsOn("update", () => {
             this.update();
         });
+        EventsOn("update-recheck-progress", (id: string, progress: string)=> {
+            this.setRecheck(id, progress);
+        });
         EventsOn("daemon_ready", (ready: boolean)=> {
             if (!ready) {
                 this.setState({
                     torrents: []
                 });
+                return
             }
+            GetTunnelRouting().then((routing) => {
+                this.setState({privateRouting: routing == "private"})
+            })
         });
     }
     componentWillUnmount() {
         EventsOff("update");
+        EventsOff("update-recheck-progress");
         EventsOff("daemon_ready");
     }
 
+    setRecheck(id: string, progress: string | null) {
+        this.setState((current) => {
+            let rechecks = {...current.rechecks};
+            if (progress === null) {
+                delete rechecks[id];
+            } else {
+                rechecks[id] = progress;
+            }
+            return {...current, rechecks: rechecks};
+        });
+    }
+
+    relocate(id: string) {
+        SelectDir().then((dir: string) => {
+            if (dir == "") {
+                return
+            }
+
+            this.setRecheck(id, "0.00");
+            RelocateTorrent(id, dir).then((err) => {
+                if (err != "") {
+                    console.log("relocation of " + id + " failed: " + err);
+                }
+                this.setRecheck(id, null);
+                Refresh();
+            });
+        })
+    }
+
+    compareDir(id: string) {
+        SelectDir().then((dir: string) => {
+            if (dir == "") {
+                return
+            }
+
+            this.setRecheck(id, "0.00");
+            VerifyDirMatchesBag(id, dir).then((err) => {
+                if (err != "") {
+                    console.log("comparing " + id + " failed: " + err);
+                }
+                this.setRecheck(id, null);
+            });
+        })
+    }
+
+    recheck(id: string) {
+        this.setRecheck(id, "0.00");
+        RecheckTorrent(id).then((res) => {
+            if (res.Err != "") {
+                console.log("recheck of " + id + " failed: " + res.Err);
+            }
+            this.setRecheck(id, null);
+            Refresh();
+        });
+    }
+
     clickRow(t: TorrentItem) {
         return (e: React.MouseEvent) => {
             // unselect old when no ctrl or command pressed
@@ -159,7 +259,7 @@ export class Table extends Component<TableProps,State> {
                 // EventsEmit("select-torrent", ti.id);
                 return {
                     hash: ti.id,
-                    active: ti.state == "downloading" || ti.state == "seeding",
+                    active: isActiveState(ti.state),
                 }
             }));
         }
@@ -174,11 +274,11 @@ export class Table extends Component<TableProps,State> {
 
         switch (this.props.filter.type) {
             case "Downloading":
-                if(state != "downloading")
+                if(state != "downloading" && state != "download-only")
                     return false;
                 break
             case "Seeding":
-                if(state != "seeding")
+                if(state != "seeding" && state != "seed-only")
                     return false;
                 break
             case "Failed":
@@ -186,11 +286,11 @@ export class Table extends Component<TableProps,State> {
                     return false;
                 break
             case "Active":
-                if(state != "downloading" && state != "seeding")
+                if(!isActiveState(state))
                     return false;
                 break
             case "Inactive":
-                if(state != "fail" && state != "inactive")
+                if(state != "fail" && state != "inactive" && state != "completed")
                     return false;
                 break
         }
@@ -221,24 +321,75 @@ export class Table extends Component<TableProps,State> {
                                    OpenFolder(t.path).then()}}>
                                    <img src={OpenDir} alt=""/><span>Open directory</span></div>)
 
-                               if (t.state != "downloading" && t.state != "seeding" && t.state != "fail") {
+                               if (t.state == "fail") {
+                                   elems.push(<div onClick={() => {
+                                       RetryTorrent(t.id).then(Refresh)
+                                   }}><img src={Play} alt=""/><span>Retry / recheck</span></div>)
+                               }
+                               if (!isActiveState(t.state) && t.state != "fail") {
                                    elems.push(<div onClick={() => {
                                        SetActive(t.id, true).then(Refresh)
                                    }}>
                                        <img src={Play} alt=""/><span>Start</span></div>)
                                }
-                               if (t.state != "inactive" && t.state != "fail") {
+                               if (t.state != "inactive" && (t.state != "fail" || t.activeDownload || t.activeUpload)) {
                                    elems.push(<div onClick={() => {
                                        SetActive(t.id, false).then(Refresh)
                                    }}><img src={Pause} alt=""/><span>Pause</span></div>)
                                }
+                               if (t.activeDownload && t.activeUpload && t.progress < 100) {
+                                   elems.push(<div onClick={() => {
+                                       SetActiveUpload(t.id, false).then(Refresh)
+                                   }}><img src={Pause} alt=""/><span>Download only</span></div>)
+                                   elems.push(<div onClick={() => {
+                                       SetActiveDownload(t.id, false).then(Refresh)
+                                   }}><img src={Pause} alt=""/><span>Seed only</span></div>)
+                               } else if (t.activeDownload != t.activeUpload || t.state == "completed") {
+                                   elems.push(<div onClick={() => {
+                                       SetActive(t.id, true).then(Refresh)
+                                   }}><img src={Play} alt=""/><span>Download and seed</span></div>)
+                               }
+                               if (t.state != "verifying" && this.state.rechecks[t.id] === undefined) {
+                                   elems.push(<div onClick={() => {
+                                       this.recheck(t.id)
+                                   }}><img src={Play} alt=""/><span>Force recheck</span></div>)
+                                   elems.push(<div onClick={() => {
+                                       this.relocate(t.id)
+                                   }}><img src={OpenDir} alt=""/><span>Set data location...</span></div>)
+                                   elems.push(<div onClick={() => {
+                                       this.compareDir(t.id)
+                                   }}><img src={OpenDir} alt=""/><span>Compare folder with bag...</span></div>)
+                               }
                                elems.push(<div onClick={() => {
                                    WantRemoveTorrent([t.id]).then(Refresh)
                                }}><img src={Close} alt=""/><span>Remove</span></div>)
 
+                               if (this.state.privateRouting) {
+                                   elems.push(<div onClick={() => {
+                                       SetPrivate(t.id, !t.private).then(Refresh)
+                                   }}><img src={Play} alt=""/><span>{t.private ? "Send directly" : "Send through tunnel"}</span></div>)
+                               }
+
+                               if (t.state == "seeding" || t.state == "completed") {
+                                   elems.push(<div onClick={() => {
+                                       EventsEmit("want_create_version", t.id)
+                                   }}><img src={Copy} alt=""/><span>Create new version...</span></div>)
+                               }
+
                                elems.push(<div onClick={() => {
                                    ExportMeta(t.id).then()
                                }}><img src={Export} alt=""/><span>Export .tonbag</span></div>)
+                               elems.push(<div onClick={() => {
+                                   ExportMetaManifest(t.id).then()
+                               }}><img src={Export} alt=""/><span>Export JSON manifest</span></div>)
+                               elems.push(<div onClick={() => {
+                                   ExportMetaQR(t.id).then()
+                               }}><img src={Export} alt=""/><span>Export QR code</span></div>)
+                               elems.push(<div onClick={() => {
+                                   ExportMetaText(t.id).then((text) => {
+                                       if (text) navigator.clipboard.writeText(text).then();
+                                   })
+                               }}><img src={Copy} alt=""/><span>Copy .tonbag as text</span></div>)
 
                                elems.push(<div onClick={() => {
                                    navigator.clipboard.writeText(t.id).then();
@@ -252,7 +403,7 @@ export class Table extends Component<TableProps,State> {
                            }}>
                 <td style={{flexGrow:"1", width: "70px"}}><div className={"item-name"}><div className={"item-state-container"} onMouseEnter={(e) =>{
                     let tip = document.getElementById("tip");
-                    tip!.textContent = textState(t.state, t.peersNum);
+                    tip!.textContent = t.error != "" ? t.error : textState(t.state, t.peersNum);
                     let rectItem = document.getElementById("state-"+t.id)!.getBoundingClientRect()
                     let rectTip = tip!.getBoundingClientRect();
 
@@ -269,9 +420,9 @@ export class Table extends Component<TableProps,State> {
                     }
                 }><div id={"state-"+t.id} className={"item-state "+(t.state == 'verifying' || (t.state == 'downloading' && t.peersNum == 0) ? 'searching' : t.state)}></div></div><span>{t.name}</span></div></td>
                 <td style={{width:"130px"}}><div className="progress-block-small">
-                    <span style={{textAlign:"left", width:"38px"}}>{t.progress}%</span>
+                    <span style={{textAlign:"left", width:"38px"}}>{this.state.rechecks[t.id] ?? t.progress}%</span>
                     <div className="progress-bar-small-form">
-                        <div className="progress-bar-small" style={{width: t.progress+"%"}}></div>
+                        <div className="progress-bar-small" style={{width: (this.state.rechecks[t.id] ?? t.progress)+"%"}}></div>
                     </div>
                 </div>
                 </td>
@@ -9,6 +9,7 @@ interface TunnelNodesModalProps {
     sections: SectionInfo[];
     pricePerMBIn: string;
     pricePerMBOut: string;
+    currencies: string[];
     onCancel: () => void;
     onReroute: (num: number) => void;
     onAccept: () => void;
@@ -18,6 +19,7 @@ export const TunnelNodesModal: React.FC<TunnelNodesModalProps> = ({
                                                                       sections,
                                                                       pricePerMBIn,
                                                                       pricePerMBOut,
+                                                                      currencies,
                                                                       onCancel,
                                                                       onReroute,
                                                                       onAccept,
@@ -97,15 +99,15 @@ export const TunnelNodesModal: React.FC<TunnelNodesModalProps> = ({
             <div className="prices">
                 <div className="price">
                     <span className="label">Price per MB (In):</span>
-                    <span className="value">{pricePerMBIn} TON</span>
+                    <span className="value">{pricePerMBIn}</span>
                 </div>
                 <div className="price">
                     <span className="label">Price per MB (Out):</span>
-                    <span className="value">{pricePerMBOut} TON</span>
+                    <span className="value">{pricePerMBOut}</span>
                 </div>
             </div>
 
-            {pricePerMBIn !== "0" || pricePerMBOut !== "0" ? <div className="config-block">
+            {currencies.length > 0 ? <div className="config-block">
                 <div className="field-group checkbox-group">
                     <div className="ton-address">
                         <label htmlFor="tonAddress">TON Address:</label>
@@ -120,6 +122,8 @@ export const TunnelNodesModal: React.FC<TunnelNodesModalProps> = ({
                     </div>
                     <div className="important-text">
                         Please make sure there is enough TON for tunnel payments and payment-network contract deployment, deposit at least 5.5 TON.
+                        {currencies.filter((c) => c != "TON").length > 0 ? " Route is also paid in " +
+                            currencies.filter((c) => c != "TON").join(", ") + ", deposit them to the same address." : ""}
                     </div>
                 </div>
             </div>: null}
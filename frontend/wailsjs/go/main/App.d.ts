@@ -2,10 +2,12 @@
 // This file is automatically generated. DO NOT EDIT
 import {main} from '../models';
 import {api} from '../models';
+import {bagfiles} from '../models';
+import {client} from '../models';
 
 export function AddTorrentByHash(arg1:string):Promise<string>;
 
-export function AddTorrentByMeta(arg1:string):Promise<main.TorrentAddResult>;
+export function AddTorrentByMeta(arg1:string,arg2:string):Promise<main.TorrentAddResult>;
 
 export function BuildProviderContractData(arg1:string,arg2:string,arg3:string,arg4:Array<api.NewProviderData>):Promise<api.Transaction>;
 
@@ -17,14 +19,30 @@ export function CheckHeader(arg1:string):Promise<boolean>;
 
 export function CreateTorrent(arg1:string,arg2:string):Promise<main.TorrentCreateResult>;
 
+export function CreateTorrentVersion(arg1:string,arg2:number):Promise<main.TorrentCreateResult>;
+
+export function CreateTorrentWithOptions(arg1:bagfiles.Options,arg2:client.CreateOptions,arg3:string):Promise<main.TorrentCreateResult>;
+
 export function DummySec():Promise<Array<main.SectionInfo>>;
 
+export function ExportAllMeta():Promise<string>;
+
 export function ExportMeta(arg1:string):Promise<string>;
 
+export function ExportMetaManifest(arg1:string):Promise<string>;
+
+export function ExportMetaQR(arg1:string):Promise<string>;
+
+export function ExportMetaText(arg1:string):Promise<string>;
+
+export function FetchMeta(arg1:string):Promise<main.MetaFetchResult>;
+
 export function FetchProviderRates(arg1:string,arg2:string):Promise<api.ProviderRates>;
 
 export function GetConfig():Promise<main.Config>;
 
+export function GetCurrencies():Promise<main.CurrenciesResult>;
+
 export function GetFiles(arg1:string):Promise<Array<api.File>>;
 
 export function GetInfo(arg1:string):Promise<api.TorrentInfo>;
@@ -33,16 +51,38 @@ export function GetMaxTunnelNodes():Promise<number>;
 
 export function GetPaymentNetworkWalletAddr():Promise<string>;
 
+export function GetPaymentWallet():Promise<main.PaymentWalletResult>;
+
+export function GetPaymentWalletMnemonic():Promise<string>;
+
+export function GetPaymentsDashboard():Promise<main.PaymentsDashboard>;
+
 export function GetPeers(arg1:string):Promise<Array<api.Peer>>;
 
 export function GetPlainFiles(arg1:string):Promise<Array<api.PlainFile>>;
 
+export function GetPoolSubscription():Promise<main.PoolSubscriptionResult>;
+
 export function GetProviderContract(arg1:string,arg2:string):Promise<api.ProviderContract>;
 
 export function GetSpeedLimit():Promise<api.SpeedLimits>;
 
+export function GetTopUpLink(arg1:string):Promise<main.TopUpResult>;
+
 export function GetTorrents():Promise<Array<api.Torrent>>;
 
+export function GetTunnelHealth():Promise<main.TunnelHealthResult>;
+
+export function GetTunnelInspector():Promise<main.TunnelInspector>;
+
+export function GetTunnelPolicy():Promise<main.TunnelPolicy>;
+
+export function GetTunnelRouting():Promise<string>;
+
+export function ImportMetaArchive():Promise<void>;
+
+export function InspectMeta(arg1:string):Promise<main.MetaPreviewResult>;
+
 export function IsDarkTheme():Promise<boolean>;
 
 export function OpenDir():Promise<string>;
@@ -55,18 +95,46 @@ export function OpenFolderSelectFile(arg1:string):Promise<void>;
 
 export function OpenTunnelConfig():Promise<main.TunnelConfigInfo>;
 
+export function PlanTorrent(arg1:bagfiles.Options,arg2:number):Promise<main.TorrentPlanResult>;
+
+export function RecheckTorrent(arg1:string):Promise<main.TorrentRecheckResult>;
+
 export function ReinitApp():Promise<void>;
 
+export function RelocateTorrent(arg1:string,arg2:string):Promise<string>;
+
 export function RemoveTorrent(arg1:string,arg2:boolean,arg3:boolean):Promise<string>;
 
 export function RequestProviderStorageInfo(arg1:string,arg2:string,arg3:string):Promise<api.ProviderStorageInfo>;
 
-export function SaveConfig(arg1:string,arg2:boolean,arg3:string,arg4:string,arg5:boolean):Promise<string>;
+export function RestorePaymentWallet(arg1:string):Promise<string>;
+
+export function RetryTorrent(arg1:string):Promise<string>;
+
+export function SaveConfig(arg1:string,arg2:boolean,arg3:string,arg4:string,arg5:boolean,arg6:string,arg7:boolean):Promise<string>;
+
+export function SaveCurrencies(arg1:Array<main.Currency>):Promise<string>;
+
+export function SavePoolSubscription(arg1:main.TunnelPoolSubscription,arg2:boolean):Promise<string>;
+
+export function SaveSpendingCap(arg1:main.SpendingCap):Promise<string>;
 
 export function SaveTunnelConfig(arg1:number,arg2:boolean):Promise<string>;
 
+export function SaveTunnelHealthPolicy(arg1:main.TunnelHealthPolicy):Promise<string>;
+
+export function SaveTunnelNodes(arg1:main.TunnelNodes,arg2:boolean):Promise<string>;
+
+export function SaveTunnelPolicy(arg1:main.TunnelPolicy):Promise<string>;
+
 export function SetActive(arg1:string,arg2:boolean):Promise<string>;
 
+export function SetActiveDownload(arg1:string,arg2:boolean):Promise<string>;
+
+export function SetActiveUpload(arg1:string,arg2:boolean):Promise<string>;
+
+export function SetPrivate(arg1:string,arg2:boolean):Promise<string>;
+
 export function SetSpeedLimit(arg1:number,arg2:number):Promise<string>;
 
 export function ShowMsg(arg1:string):Promise<void>;
@@ -79,6 +147,10 @@ export function SwitchTheme():Promise<void>;
 
 export function Throw(arg1:Error):Promise<void>;
 
+export function VerifyDirMatchesBag(arg1:string,arg2:string):Promise<string>;
+
 export function WaitReady():Promise<void>;
 
 export function WantRemoveTorrent(arg1:Array<string>):Promise<void>;
+
+export function WithdrawFromPaymentWallet(arg1:string,arg2:string,arg3:string):Promise<main.WithdrawResult>;
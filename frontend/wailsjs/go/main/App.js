@@ -6,8 +6,8 @@ export function AddTorrentByHash(arg1) {
   return window['go']['main']['App']['AddTorrentByHash'](arg1);
 }
 
-export function AddTorrentByMeta(arg1) {
-  return window['go']['main']['App']['AddTorrentByMeta'](arg1);
+export function AddTorrentByMeta(arg1, arg2) {
+  return window['go']['main']['App']['AddTorrentByMeta'](arg1, arg2);
 }
 
 export function BuildProviderContractData(arg1, arg2, arg3, arg4) {
@@ -30,14 +30,42 @@ export function CreateTorrent(arg1, arg2) {
   return window['go']['main']['App']['CreateTorrent'](arg1, arg2);
 }
 
+export function CreateTorrentVersion(arg1, arg2) {
+  return window['go']['main']['App']['CreateTorrentVersion'](arg1, arg2);
+}
+
+export function CreateTorrentWithOptions(arg1, arg2, arg3) {
+  return window['go']['main']['App']['CreateTorrentWithOptions'](arg1, arg2, arg3);
+}
+
 export function DummySec() {
   return window['go']['main']['App']['DummySec']();
 }
 
+export function ExportAllMeta() {
+  return window['go']['main']['App']['ExportAllMeta']();
+}
+
 export function ExportMeta(arg1) {
   return window['go']['main']['App']['ExportMeta'](arg1);
 }
 
+export function ExportMetaManifest(arg1) {
+  return window['go']['main']['App']['ExportMetaManifest'](arg1);
+}
+
+export function ExportMetaQR(arg1) {
+  return window['go']['main']['App']['ExportMetaQR'](arg1);
+}
+
+export function ExportMetaText(arg1) {
+  return window['go']['main']['App']['ExportMetaText'](arg1);
+}
+
+export function FetchMeta(arg1) {
+  return window['go']['main']['App']['FetchMeta'](arg1);
+}
+
 export function FetchProviderRates(arg1, arg2) {
   return window['go']['main']['App']['FetchProviderRates'](arg1, arg2);
 }
@@ -46,6 +74,10 @@ export function GetConfig() {
   return window['go']['main']['App']['GetConfig']();
 }
 
+export function GetCurrencies() {
+  return window['go']['main']['App']['GetCurrencies']();
+}
+
 export function GetFiles(arg1) {
   return window['go']['main']['App']['GetFiles'](arg1);
 }
@@ -62,6 +94,18 @@ export function GetPaymentNetworkWalletAddr() {
   return window['go']['main']['App']['GetPaymentNetworkWalletAddr']();
 }
 
+export function GetPaymentWallet() {
+  return window['go']['main']['App']['GetPaymentWallet']();
+}
+
+export function GetPaymentWalletMnemonic() {
+  return window['go']['main']['App']['GetPaymentWalletMnemonic']();
+}
+
+export function GetPaymentsDashboard() {
+  return window['go']['main']['App']['GetPaymentsDashboard']();
+}
+
 export function GetPeers(arg1) {
   return window['go']['main']['App']['GetPeers'](arg1);
 }
@@ -70,6 +114,10 @@ export function GetPlainFiles(arg1) {
   return window['go']['main']['App']['GetPlainFiles'](arg1);
 }
 
+export function GetPoolSubscription() {
+  return window['go']['main']['App']['GetPoolSubscription']();
+}
+
 export function GetProviderContract(arg1, arg2) {
   return window['go']['main']['App']['GetProviderContract'](arg1, arg2);
 }
@@ -78,10 +126,38 @@ export function GetSpeedLimit() {
   return window['go']['main']['App']['GetSpeedLimit']();
 }
 
+export function GetTopUpLink(arg1) {
+  return window['go']['main']['App']['GetTopUpLink'](arg1);
+}
+
 export function GetTorrents() {
   return window['go']['main']['App']['GetTorrents']();
 }
 
+export function GetTunnelHealth() {
+  return window['go']['main']['App']['GetTunnelHealth']();
+}
+
+export function GetTunnelInspector() {
+  return window['go']['main']['App']['GetTunnelInspector']();
+}
+
+export function GetTunnelPolicy() {
+  return window['go']['main']['App']['GetTunnelPolicy']();
+}
+
+export function GetTunnelRouting() {
+  return window['go']['main']['App']['GetTunnelRouting']();
+}
+
+export function ImportMetaArchive() {
+  return window['go']['main']['App']['ImportMetaArchive']();
+}
+
+export function InspectMeta(arg1) {
+  return window['go']['main']['App']['InspectMeta'](arg1);
+}
+
 export function IsDarkTheme() {
   return window['go']['main']['App']['IsDarkTheme']();
 }
@@ -106,10 +182,22 @@ export function OpenTunnelConfig() {
   return window['go']['main']['App']['OpenTunnelConfig']();
 }
 
+export function PlanTorrent(arg1, arg2) {
+  return window['go']['main']['App']['PlanTorrent'](arg1, arg2);
+}
+
+export function RecheckTorrent(arg1) {
+  return window['go']['main']['App']['RecheckTorrent'](arg1);
+}
+
 export function ReinitApp() {
   return window['go']['main']['App']['ReinitApp']();
 }
 
+export function RelocateTorrent(arg1, arg2) {
+  return window['go']['main']['App']['RelocateTorrent'](arg1, arg2);
+}
+
 export function RemoveTorrent(arg1, arg2, arg3) {
   return window['go']['main']['App']['RemoveTorrent'](arg1, arg2, arg3);
 }
@@ -118,18 +206,62 @@ export function RequestProviderStorageInfo(arg1, arg2, arg3) {
   return window['go']['main']['App']['RequestProviderStorageInfo'](arg1, arg2, arg3);
 }
 
-export function SaveConfig(arg1, arg2, arg3, arg4, arg5) {
-  return window['go']['main']['App']['SaveConfig'](arg1, arg2, arg3, arg4, arg5);
+export function RestorePaymentWallet(arg1) {
+  return window['go']['main']['App']['RestorePaymentWallet'](arg1);
+}
+
+export function RetryTorrent(arg1) {
+  return window['go']['main']['App']['RetryTorrent'](arg1);
+}
+
+export function SaveConfig(arg1, arg2, arg3, arg4, arg5, arg6, arg7) {
+  return window['go']['main']['App']['SaveConfig'](arg1, arg2, arg3, arg4, arg5, arg6, arg7);
+}
+
+export function SaveCurrencies(arg1) {
+  return window['go']['main']['App']['SaveCurrencies'](arg1);
+}
+
+export function SavePoolSubscription(arg1, arg2) {
+  return window['go']['main']['App']['SavePoolSubscription'](arg1, arg2);
+}
+
+export function SaveSpendingCap(arg1) {
+  return window['go']['main']['App']['SaveSpendingCap'](arg1);
 }
 
 export function SaveTunnelConfig(arg1, arg2) {
   return window['go']['main']['App']['SaveTunnelConfig'](arg1, arg2);
 }
 
+export function SaveTunnelHealthPolicy(arg1) {
+  return window['go']['main']['App']['SaveTunnelHealthPolicy'](arg1);
+}
+
+export function SaveTunnelNodes(arg1, arg2) {
+  return window['go']['main']['App']['SaveTunnelNodes'](arg1, arg2);
+}
+
+export function SaveTunnelPolicy(arg1) {
+  return window['go']['main']['App']['SaveTunnelPolicy'](arg1);
+}
+
 export function SetActive(arg1, arg2) {
   return window['go']['main']['App']['SetActive'](arg1, arg2);
 }
 
+export function SetActiveDownload(arg1, arg2) {
+  return window['go']['main']['App']['SetActiveDownload'](arg1, arg2);
+}
+
+export function SetActiveUpload(arg1, arg2) {
+  return window['go']['main']['App']['SetActiveUpload'](arg1, arg2);
+}
+
+export function SetPrivate(arg1, arg2) {
+  return window['go']['main']['App']['SetPrivate'](arg1, arg2);
+}
+
 export function SetSpeedLimit(arg1, arg2) {
   return window['go']['main']['App']['SetSpeedLimit'](arg1, arg2);
 }
@@ -154,6 +286,10 @@ export function Throw(arg1) {
   return window['go']['main']['App']['Throw'](arg1);
 }
 
+export function VerifyDirMatchesBag(arg1, arg2) {
+  return window['go']['main']['App']['VerifyDirMatchesBag'](arg1, arg2);
+}
+
 export function WaitReady() {
   return window['go']['main']['App']['WaitReady']();
 }
@@ -161,3 +297,7 @@ export function WaitReady() {
 export function WantRemoveTorrent(arg1) {
   return window['go']['main']['App']['WantRemoveTorrent'](arg1);
 }
+
+export function WithdrawFromPaymentWallet(arg1, arg2, arg3) {
+  return window['go']['main']['App']['WithdrawFromPaymentWallet'](arg1, arg2, arg3);
+}
@@ -36,6 +36,68 @@ export namespace api {
 		    return a;
 		}
 	}
+	export class MetaPreview {
+	    BagID: string;
+	    Description: string;
+	    Size: string;
+	    PieceSize: string;
+	    PiecesCount: number;
+	    DirName: string;
+	    HasHeader: boolean;
+	    HasRootProof: boolean;
+	    FilesCount: number;
+	    Files: MetaPreviewFile[];
+	
+	    static createFrom(source: any = {}) {
+	        return new MetaPreview(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.BagID = source["BagID"];
+	        this.Description = source["Description"];
+	        this.Size = source["Size"];
+	        this.PieceSize = source["PieceSize"];
+	        this.PiecesCount = source["PiecesCount"];
+	        this.DirName = source["DirName"];
+	        this.HasHeader = source["HasHeader"];
+	        this.HasRootProof = source["HasRootProof"];
+	        this.FilesCount = source["FilesCount"];
+	        this.Files = this.convertValues(source["Files"], MetaPreviewFile);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class MetaPreviewFile {
+	    Name: string;
+	    Size: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new MetaPreviewFile(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Name = source["Name"];
+	        this.Size = source["Size"];
+	    }
+	}
 	export class NewProviderData {
 	    Key: string;
 	    MaxSpan: number;
@@ -255,6 +317,10 @@ export namespace api {
 	    PeersNum: number;
 	    Uploaded: string;
 	    Ratio: string;
+	    ActiveDownload: boolean;
+	    ActiveUpload: boolean;
+	    Error: string;
+	    Private: boolean;
 	
 	    static createFrom(source: any = {}) {
 	        return new Torrent(source);
@@ -274,6 +340,10 @@ export namespace api {
 	        this.PeersNum = source["PeersNum"];
 	        this.Uploaded = source["Uploaded"];
 	        this.Ratio = source["Ratio"];
+	        this.ActiveDownload = source["ActiveDownload"];
+	        this.ActiveUpload = source["ActiveUpload"];
+	        this.Error = source["Error"];
+	        this.Private = source["Private"];
 	    }
 	}
 	export class TorrentInfo {
@@ -290,6 +360,12 @@ export namespace api {
 	    AddedAt: string;
 	    Uploaded: string;
 	    Ratio: string;
+	    ActiveDownload: boolean;
+	    ActiveUpload: boolean;
+	    Error: string;
+	    ErrorAt: string;
+	    PrevVersion: string;
+	    NextVersion: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new TorrentInfo(source);
@@ -310,6 +386,12 @@ export namespace api {
 	        this.AddedAt = source["AddedAt"];
 	        this.Uploaded = source["Uploaded"];
 	        this.Ratio = source["Ratio"];
+	        this.ActiveDownload = source["ActiveDownload"];
+	        this.ActiveUpload = source["ActiveUpload"];
+	        this.Error = source["Error"];
+	        this.ErrorAt = source["ErrorAt"];
+	        this.PrevVersion = source["PrevVersion"];
+	        this.NextVersion = source["NextVersion"];
 	    }
 	}
 	export class Transaction {
@@ -333,6 +415,58 @@ export namespace api {
 
 }
 
+export namespace bagfiles {
+	
+	export class Options {
+	    Paths: string[];
+	    Exclude: string[];
+	    IncludeHidden: boolean;
+	    FollowSymlinks: boolean;
+	    Order: string;
+	    DirName: string;
+	    Deterministic: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Options(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Paths = source["Paths"];
+	        this.Exclude = source["Exclude"];
+	        this.IncludeHidden = source["IncludeHidden"];
+	        this.FollowSymlinks = source["FollowSymlinks"];
+	        this.Order = source["Order"];
+	        this.DirName = source["DirName"];
+	        this.Deterministic = source["Deterministic"];
+	    }
+	}
+
+}
+
+export namespace client {
+	
+	export class CreateOptions {
+	    PieceSize: number;
+	    CopyInside: boolean;
+	    CopyDir: string;
+	    StartPaused: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new CreateOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.PieceSize = source["PieceSize"];
+	        this.CopyInside = source["CopyInside"];
+	        this.CopyDir = source["CopyDir"];
+	        this.StartPaused = source["StartPaused"];
+	    }
+	}
+
+}
+
 export namespace config {
 	
 	export class BalanceControlConfig {
@@ -582,6 +716,7 @@ export namespace main {
 	    NetworkConfigPath: string;
 	    FetchIPOnStartup: boolean;
 	    TunnelConfig?: config.ClientConfig;
+	    TunnelPolicy: TunnelPolicy;
 	
 	    static createFrom(source: any = {}) {
 	        return new Config(source);
@@ -599,6 +734,339 @@ export namespace main {
 	        this.NetworkConfigPath = source["NetworkConfigPath"];
 	        this.FetchIPOnStartup = source["FetchIPOnStartup"];
 	        this.TunnelConfig = this.convertValues(source["TunnelConfig"], config.ClientConfig);
+	        this.TunnelPolicy = this.convertValues(source["TunnelPolicy"], TunnelPolicy);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class CurrenciesResult {
+	    Currencies: Currency[];
+	    Err: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CurrenciesResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Currencies = this.convertValues(source["Currencies"], Currency);
+	        this.Err = source["Err"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Currency {
+	    Jetton: string;
+	    ExtraCurrencyID: number;
+	    Symbol: string;
+	    Decimals: number;
+	    Enabled: boolean;
+	    DepositUpTo: string;
+	    DepositWhenLessThan: string;
+	    Balance: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Currency(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Jetton = source["Jetton"];
+	        this.ExtraCurrencyID = source["ExtraCurrencyID"];
+	        this.Symbol = source["Symbol"];
+	        this.Decimals = source["Decimals"];
+	        this.Enabled = source["Enabled"];
+	        this.DepositUpTo = source["DepositUpTo"];
+	        this.DepositWhenLessThan = source["DepositWhenLessThan"];
+	        this.Balance = source["Balance"];
+	    }
+	}
+	export class InspectorRoute {
+	    Sections: InspectorSection[];
+	    ExtAddr: string;
+	    Current: boolean;
+	    Since: string;
+	    Until: string;
+	    Uptime: string;
+	    BytesIn: string;
+	    BytesOut: string;
+	    LastChecked: string;
+	    Health: TunnelHealthInfo;
+	
+	    static createFrom(source: any = {}) {
+	        return new InspectorRoute(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Sections = this.convertValues(source["Sections"], InspectorSection);
+	        this.ExtAddr = source["ExtAddr"];
+	        this.Current = source["Current"];
+	        this.Since = source["Since"];
+	        this.Until = source["Until"];
+	        this.Uptime = source["Uptime"];
+	        this.BytesIn = source["BytesIn"];
+	        this.BytesOut = source["BytesOut"];
+	        this.LastChecked = source["LastChecked"];
+	        this.Health = this.convertValues(source["Health"], TunnelHealthInfo);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class InspectorSection {
+	    Key: string;
+	    Role: string;
+	    Price: string;
+	    Pinned: boolean;
+	    Excluded: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new InspectorSection(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Key = source["Key"];
+	        this.Role = source["Role"];
+	        this.Price = source["Price"];
+	        this.Pinned = source["Pinned"];
+	        this.Excluded = source["Excluded"];
+	    }
+	}
+	export class MetaFetchResult {
+	    Meta: string;
+	    Err: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new MetaFetchResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Meta = source["Meta"];
+	        this.Err = source["Err"];
+	    }
+	}
+	export class MetaPreviewResult {
+	    Preview: api.MetaPreview;
+	    Err: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new MetaPreviewResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Preview = this.convertValues(source["Preview"], api.MetaPreview);
+	        this.Err = source["Err"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class PaymentChannel {
+	    Address: string;
+	    Currency: string;
+	    Status: string;
+	    Balance: string;
+	    Locked: string;
+	    Deposited: string;
+	    Spent: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new PaymentChannel(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Address = source["Address"];
+	        this.Currency = source["Currency"];
+	        this.Status = source["Status"];
+	        this.Balance = source["Balance"];
+	        this.Locked = source["Locked"];
+	        this.Deposited = source["Deposited"];
+	        this.Spent = source["Spent"];
+	    }
+	}
+	export class PaymentEvent {
+	    At: string;
+	    Channel: string;
+	    Action: string;
+	    Amount: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new PaymentEvent(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.At = source["At"];
+	        this.Channel = source["Channel"];
+	        this.Action = source["Action"];
+	        this.Amount = source["Amount"];
+	    }
+	}
+	export class PaymentWalletResult {
+	    Address: string;
+	    Balance: string;
+	    Err: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new PaymentWalletResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Address = source["Address"];
+	        this.Balance = source["Balance"];
+	        this.Err = source["Err"];
+	    }
+	}
+	export class PaymentsDashboard {
+	    Channels: PaymentChannel[];
+	    ChannelsErr: string;
+	    History: PaymentEvent[];
+	    Session: string;
+	    Days: SpendingDay[];
+	    Cap: SpendingCap;
+	    CapHit: string;
+	    Symbols: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new PaymentsDashboard(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Channels = this.convertValues(source["Channels"], PaymentChannel);
+	        this.ChannelsErr = source["ChannelsErr"];
+	        this.History = this.convertValues(source["History"], PaymentEvent);
+	        this.Session = source["Session"];
+	        this.Days = this.convertValues(source["Days"], SpendingDay);
+	        this.Cap = this.convertValues(source["Cap"], SpendingCap);
+	        this.CapHit = source["CapHit"];
+	        this.Symbols = source["Symbols"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class PoolNode {
+	    Key: string;
+	    Price: string;
+	    Pinned: boolean;
+	    Excluded: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new PoolNode(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Key = source["Key"];
+	        this.Price = source["Price"];
+	        this.Pinned = source["Pinned"];
+	        this.Excluded = source["Excluded"];
+	    }
+	}
+	export class PoolSubscriptionResult {
+	    Subscription: TunnelPoolSubscription;
+	    FetchedAt: string;
+	    CheckedAt: string;
+	    Nodes: number;
+	    Pending: string;
+	    Err: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new PoolSubscriptionResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Subscription = this.convertValues(source["Subscription"], TunnelPoolSubscription);
+	        this.FetchedAt = source["FetchedAt"];
+	        this.CheckedAt = source["CheckedAt"];
+	        this.Nodes = source["Nodes"];
+	        this.Pending = source["Pending"];
+	        this.Err = source["Err"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -633,6 +1101,50 @@ export namespace main {
 	        this.Outer = source["Outer"];
 	    }
 	}
+	export class SpendingCap {
+	    PerSession: {[key: string]: string};
+	    PerDay: {[key: string]: string};
+	
+	    static createFrom(source: any = {}) {
+	        return new SpendingCap(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.PerSession = source["PerSession"];
+	        this.PerDay = source["PerDay"];
+	    }
+	}
+	export class SpendingDay {
+	    Day: string;
+	    Paid: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SpendingDay(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Day = source["Day"];
+	        this.Paid = source["Paid"];
+	    }
+	}
+	export class TopUpResult {
+	    Link: string;
+	    QR: string;
+	    Err: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new TopUpResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Link = source["Link"];
+	        this.QR = source["QR"];
+	        this.Err = source["Err"];
+	    }
+	}
 	export class TorrentAddResult {
 	    Hash: string;
 	    Err: string;
@@ -661,6 +1173,40 @@ export namespace main {
 	        this.Err = source["Err"];
 	    }
 	}
+	export class TorrentPlanResult {
+	    FilesCount: number;
+	    Size: string;
+	    PieceSize: string;
+	    PiecesCount: number;
+	    Err: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new TorrentPlanResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.FilesCount = source["FilesCount"];
+	        this.Size = source["Size"];
+	        this.PieceSize = source["PieceSize"];
+	        this.PiecesCount = source["PiecesCount"];
+	        this.Err = source["Err"];
+	    }
+	}
+	export class TorrentRecheckResult {
+	    BadPieces: number;
+	    Err: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new TorrentRecheckResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.BadPieces = source["BadPieces"];
+	        this.Err = source["Err"];
+	    }
+	}
 	export class TunnelConfigInfo {
 	    Max: number;
 	    MaxFree: number;
@@ -677,6 +1223,234 @@ export namespace main {
 	        this.Path = source["Path"];
 	    }
 	}
+	export class TunnelEventInfo {
+	    At: string;
+	    Kind: string;
+	    Reason: string;
+	    Nodes: string;
+	    ExtAddr: string;
+	    Health: TunnelHealthInfo;
+	
+	    static createFrom(source: any = {}) {
+	        return new TunnelEventInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.At = source["At"];
+	        this.Kind = source["Kind"];
+	        this.Reason = source["Reason"];
+	        this.Nodes = source["Nodes"];
+	        this.ExtAddr = source["ExtAddr"];
+	        this.Health = this.convertValues(source["Health"], TunnelHealthInfo);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class TunnelHealthInfo {
+	    Loss: string;
+	    RTT: string;
+	    In: string;
+	    Out: string;
+	    NoAnswerFor: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new TunnelHealthInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Loss = source["Loss"];
+	        this.RTT = source["RTT"];
+	        this.In = source["In"];
+	        this.Out = source["Out"];
+	        this.NoAnswerFor = source["NoAnswerFor"];
+	    }
+	}
+	export class TunnelHealthPolicy {
+	    AutoReroute: boolean;
+	    MaxLossPercent: number;
+	    MaxRTTMs: number;
+	    ExpectedKBps: number;
+	    MinThroughputPercent: number;
+	    OutageSeconds: number;
+	    BreachSeconds: number;
+	    CooldownSeconds: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new TunnelHealthPolicy(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.AutoReroute = source["AutoReroute"];
+	        this.MaxLossPercent = source["MaxLossPercent"];
+	        this.MaxRTTMs = source["MaxRTTMs"];
+	        this.ExpectedKBps = source["ExpectedKBps"];
+	        this.MinThroughputPercent = source["MinThroughputPercent"];
+	        this.OutageSeconds = source["OutageSeconds"];
+	        this.BreachSeconds = source["BreachSeconds"];
+	        this.CooldownSeconds = source["CooldownSeconds"];
+	    }
+	}
+	export class TunnelHealthResult {
+	    Policy: TunnelHealthPolicy;
+	    Health: TunnelHealthInfo;
+	    Breach: string;
+	    Events: TunnelEventInfo[];
+	
+	    static createFrom(source: any = {}) {
+	        return new TunnelHealthResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Policy = this.convertValues(source["Policy"], TunnelHealthPolicy);
+	        this.Health = this.convertValues(source["Health"], TunnelHealthInfo);
+	        this.Breach = source["Breach"];
+	        this.Events = this.convertValues(source["Events"], TunnelEventInfo);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class TunnelInspector {
+	    Routes: InspectorRoute[];
+	    Pool: PoolNode[];
+	    Nodes: TunnelNodes;
+	    Err: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new TunnelInspector(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Routes = this.convertValues(source["Routes"], InspectorRoute);
+	        this.Pool = this.convertValues(source["Pool"], PoolNode);
+	        this.Nodes = this.convertValues(source["Nodes"], TunnelNodes);
+	        this.Err = source["Err"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class TunnelNodes {
+	    Pinned: string[];
+	    Excluded: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new TunnelNodes(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Pinned = source["Pinned"];
+	        this.Excluded = source["Excluded"];
+	    }
+	}
+	export class TunnelPolicy {
+	    Enabled: boolean;
+	    MaxPriceInPerMB: string;
+	    MaxPriceOutPerMB: string;
+	    FreeOnly: boolean;
+	    AllowedNodes: string[];
+	    DeniedNodes: string[];
+	    SectionsNum: number;
+	    AskOnMismatch: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new TunnelPolicy(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Enabled = source["Enabled"];
+	        this.MaxPriceInPerMB = source["MaxPriceInPerMB"];
+	        this.MaxPriceOutPerMB = source["MaxPriceOutPerMB"];
+	        this.FreeOnly = source["FreeOnly"];
+	        this.AllowedNodes = source["AllowedNodes"];
+	        this.DeniedNodes = source["DeniedNodes"];
+	        this.SectionsNum = source["SectionsNum"];
+	        this.AskOnMismatch = source["AskOnMismatch"];
+	    }
+	}
+	export class TunnelPoolSubscription {
+	    URL: string;
+	    PublisherKey: string;
+	    RefreshMinutes: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new TunnelPoolSubscription(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.URL = source["URL"];
+	        this.PublisherKey = source["PublisherKey"];
+	        this.RefreshMinutes = source["RefreshMinutes"];
+	    }
+	}
+	export class WithdrawResult {
+	    Hash: string;
+	    Err: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new WithdrawResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Hash = source["Hash"];
+	        this.Err = source["Err"];
+	    }
+	}
 
 }
 
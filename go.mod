@@ -14,6 +14,7 @@ require (
 	github.com/xssnick/tonutils-go v1.14.0
 	github.com/xssnick/tonutils-storage v1.1.4-0.20250715114132-9ba7bd152f66
 	github.com/xssnick/tonutils-storage-provider v0.3.9
+	golang.org/x/text v0.27.0
 )
 
 require (
@@ -65,6 +66,5 @@ require (
 	golang.org/x/sync v0.16.0 // indirect
 	golang.org/x/sys v0.34.0 // indirect
 	golang.org/x/term v0.33.0 // indirect
-	golang.org/x/text v0.27.0 // indirect
 	google.golang.org/protobuf v1.36.5 // indirect
 )
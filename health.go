@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tonutils/torrent-client/core/api"
+	"github.com/tonutils/torrent-client/core/client"
+)
+
+// tunnel events kept in memory to show, file keeps all of them
+const tunnelEventsShown = 100
+
+// event log is moved to .old file when it is bigger, to not grow forever
+const tunnelEventsMaxSize = 1 << 20
+
+const (
+	// tunnelEventRoute is logged when new route is used
+	tunnelEventRoute = "route"
+	// tunnelEventBreach is logged when route becomes unhealthy, tunnelEventRecovered when it is healthy again
+	tunnelEventBreach    = "breach"
+	tunnelEventRecovered = "recovered"
+	// tunnelEventReroute is logged when route is switched, tunnelEventDeclined when user declined it
+	tunnelEventReroute  = "reroute"
+	tunnelEventDeclined = "declined"
+)
+
+// TunnelHealthPolicy reroutes tunnel when its route is unhealthy, thresholds with zero value are not checked
+type TunnelHealthPolicy struct {
+	// AutoReroute reroutes without asking user, otherwise breaches are only logged
+	AutoReroute bool
+
+	MaxLossPercent float64
+	MaxRTTMs       uint
+	// ExpectedKBps is incoming throughput expected while bags are downloaded from peers,
+	// route is unhealthy when it is less than MinThroughputPercent of it
+	ExpectedKBps         uint
+	MinThroughputPercent uint
+
+	// OutageSeconds is how long route may not answer, shorter outages are ignored
+	OutageSeconds uint
+	// BreachSeconds is how long thresholds should be breached to reroute, to not reroute on spikes
+	BreachSeconds uint
+	// CooldownSeconds is time after route start when it is not rerouted, except outage
+	CooldownSeconds uint
+}
+
+func defaultTunnelHealth() TunnelHealthPolicy {
+	return TunnelHealthPolicy{
+		MaxLossPercent:  20,
+		MaxRTTMs:        3000,
+		OutageSeconds:   20,
+		BreachSeconds:   30,
+		CooldownSeconds: 120,
+	}
+}
+
+func (p *TunnelHealthPolicy) Validate() error {
+	if p.MaxLossPercent < 0 || p.MaxLossPercent > 100 {
+		return fmt.Errorf("max loss should be from 0 to 100 percent")
+	}
+	if p.MinThroughputPercent > 100 {
+		return fmt.Errorf("min throughput should be from 0 to 100 percent of expected")
+	}
+	if p.OutageSeconds != 0 && p.OutageSeconds < 10 {
+		// route is probed once a second, a few probes can be lost
+		return fmt.Errorf("outage should be at least 10 seconds")
+	}
+	return nil
+}
+
+// mismatch returns reason when health breaches thresholds, outage is checked separately
+func (p *TunnelHealthPolicy) mismatch(h client.TunnelHealth, downloading bool) string {
+	if p.MaxLossPercent > 0 && h.Packets > 0 && h.Loss*100 > p.MaxLossPercent {
+		return fmt.Sprintf("loss %.1f%% is more than %g%%", h.Loss*100, p.MaxLossPercent)
+	}
+	if p.MaxRTTMs > 0 && h.RTT > time.Duration(p.MaxRTTMs)*time.Millisecond {
+		return fmt.Sprintf("round trip %s is more than %d ms", h.RTT.Round(time.Millisecond), p.MaxRTTMs)
+	}
+	if p.ExpectedKBps > 0 && p.MinThroughputPercent > 0 && downloading {
+		if min := uint64(p.ExpectedKBps) * 1024 * uint64(p.MinThroughputPercent) / 100; h.In < min {
+			return fmt.Sprintf("throughput %s/s is less than %d%% of expected %d KB/s",
+				api.FormatSize(int64(h.In)), p.MinThroughputPercent, p.ExpectedKBps)
+		}
+	}
+	return ""
+}
+
+// TunnelEvent is a record of tunnel event log, it is written as JSON line to tunnel-events.jsonl
+type TunnelEvent struct {
+	At     time.Time
+	Kind   string
+	Reason string
+	// Nodes are base64 keys of route nodes
+	Nodes   []string
+	ExtAddr string
+	// Health is last measured health of route when event happened
+	Health client.TunnelHealth
+}
+
+type TunnelHealthInfo struct {
+	// fields are empty when health is not measured yet
+	Loss        string
+	RTT         string
+	In          string
+	Out         string
+	NoAnswerFor string
+}
+
+type TunnelEventInfo struct {
+	At     string
+	Kind   string
+	Reason string
+	// Nodes are short keys of route nodes
+	Nodes   string
+	ExtAddr string
+	Health  TunnelHealthInfo
+}
+
+type TunnelHealthResult struct {
+	Policy TunnelHealthPolicy
+	Health TunnelHealthInfo
+	// Breach is a reason why current route is unhealthy, empty when it is healthy
+	Breach string
+	// Events are newest first
+	Events []TunnelEventInfo
+}
+
+func healthInfo(h client.TunnelHealth) TunnelHealthInfo {
+	if h.MeasuredAt.IsZero() {
+		return TunnelHealthInfo{}
+	}
+
+	res := TunnelHealthInfo{
+		Loss:        "not measured",
+		RTT:         "no answer",
+		In:          api.FormatSize(int64(h.In)) + "/s",
+		Out:         api.FormatSize(int64(h.Out)) + "/s",
+		NoAnswerFor: h.NoAnswerFor.String(),
+	}
+	if h.Packets > 0 {
+		res.Loss = fmt.Sprintf("%.1f%%", h.Loss*100)
+	}
+	if h.RTT > 0 {
+		res.RTT = h.RTT.Round(time.Millisecond).String()
+	}
+	return res
+}
+
+func tunnelEventInfo(e TunnelEvent) TunnelEventInfo {
+	var nodes []string
+	for _, n := range e.Nodes {
+		if len(n) > 8 {
+			n = n[:8]
+		}
+		nodes = append(nodes, n)
+	}
+
+	return TunnelEventInfo{
+		At:      e.At.Format("02 Jan 2006 15:04:05"),
+		Kind:    e.Kind,
+		Reason:  e.Reason,
+		Nodes:   strings.Join(nodes, ", "),
+		ExtAddr: e.ExtAddr,
+		Health:  healthInfo(e.Health),
+	}
+}
+
+// tunnelHealth watches health of current route and decides on reroute
+type tunnelHealth struct {
+	path   string
+	events []TunnelEvent
+
+	// route is start time of current route, it identifies it
+	route   time.Time
+	nodes   []string
+	extAddr string
+	last    client.TunnelHealth
+
+	breach      string
+	breachSince time.Time
+	// pending is reason of reroute requested for current route
+	pending string
+
+	mx sync.Mutex
+}
+
+func loadTunnelHealth(path string) *tunnelHealth {
+	h := &tunnelHealth{path: path}
+
+	if fi, err := os.Stat(path); err == nil && fi.Size() > tunnelEventsMaxSize {
+		if err = os.Rename(path, path+".old"); err != nil {
+			log.Println("failed to rotate tunnel event log:", err.Error())
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println("failed to load tunnel event log:", err.Error())
+		}
+		return h
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e TunnelEvent
+		if err = json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue
+		}
+		h.events = append(h.events, e)
+		if len(h.events) > tunnelEventsShown {
+			h.events = h.events[1:]
+		}
+	}
+	return h
+}
+
+// observe takes measured health of current route, reason is returned when it should be rerouted now.
+// Outage is set when route does not answer, tunnel library asks to reroute such route itself.
+func (h *tunnelHealth) observe(r client.TunnelRoute, p TunnelHealthPolicy, downloading bool) (reason string, outage bool) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	h.extAddr = r.ExtAddr
+	if !r.Since.Equal(h.route) {
+		h.route = r.Since
+		h.nodes = nil
+		for _, s := range r.Sections {
+			h.nodes = append(h.nodes, base64.StdEncoding.EncodeToString(s.Key))
+		}
+		h.breach, h.pending = "", ""
+		h.last = client.TunnelHealth{}
+		h.log(tunnelEventRoute, "")
+	}
+	h.last = r.Health
+
+	now := r.Health.MeasuredAt
+	reason = p.mismatch(r.Health, downloading)
+	outage = p.OutageSeconds > 0 && r.Health.NoAnswerFor >= time.Duration(p.OutageSeconds)*time.Second
+	if outage {
+		reason = "route does not answer for " + r.Health.NoAnswerFor.String()
+	}
+
+	if reason == "" {
+		if h.breach != "" {
+			h.log(tunnelEventRecovered, h.breach)
+			h.breach = ""
+		}
+		// route is not rerouted when it recovers before library asks about it
+		h.pending = ""
+		return "", false
+	}
+
+	if h.breach == "" {
+		h.breachSince = now
+		h.log(tunnelEventBreach, reason)
+	}
+	h.breach = reason
+
+	if !p.AutoReroute || h.pending != "" {
+		return "", false
+	}
+	if !outage {
+		if now.Sub(h.breachSince) < time.Duration(p.BreachSeconds)*time.Second ||
+			now.Sub(r.Since) < time.Duration(p.CooldownSeconds)*time.Second {
+			return "", false
+		}
+	}
+
+	h.pending = reason
+	h.log(tunnelEventReroute, reason)
+	return reason, outage
+}
+
+// requested returns reason when reroute of current route was requested by monitor
+func (h *tunnelHealth) requested() (string, bool) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	return h.pending, h.pending != ""
+}
+
+// stalled is called when tunnel library found that route does not answer, and reroute is decided
+func (h *tunnelHealth) stalled(reroute bool, reason string) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	if reroute {
+		h.pending = reason
+		h.log(tunnelEventReroute, reason)
+		return
+	}
+	h.log(tunnelEventDeclined, reason)
+}
+
+// current returns last health of route and reason why it is unhealthy
+func (h *tunnelHealth) current() (client.TunnelHealth, string) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	return h.last, h.breach
+}
+
+// getEvents returns events newest first
+func (h *tunnelHealth) getEvents() []TunnelEvent {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	res := make([]TunnelEvent, 0, len(h.events))
+	for i := len(h.events) - 1; i >= 0; i-- {
+		res = append(res, h.events[i])
+	}
+	return res
+}
+
+// log must be called under lock
+func (h *tunnelHealth) log(kind, reason string) {
+	e := TunnelEvent{
+		At:      time.Now(),
+		Kind:    kind,
+		Reason:  reason,
+		Nodes:   h.nodes,
+		ExtAddr: h.extAddr,
+		Health:  h.last,
+	}
+	log.Println("tunnel event:", kind, reason)
+
+	h.events = append(h.events, e)
+	if len(h.events) > tunnelEventsShown {
+		h.events = h.events[1:]
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Println("failed to serialize tunnel event:", err.Error())
+		return
+	}
+
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0766)
+	if err == nil {
+		_, err = f.Write(append(data, '\n'))
+		_ = f.Close()
+	}
+	if err != nil {
+		log.Println("failed to write tunnel event log:", err.Error())
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tonutils/torrent-client/core/client"
+)
+
+func TestHealthObserve(t *testing.T) {
+	policy := TunnelHealthPolicy{
+		AutoReroute:     true,
+		MaxRTTMs:        1000,
+		OutageSeconds:   20,
+		BreachSeconds:   30,
+		CooldownSeconds: 120,
+	}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	slow := client.TunnelHealth{RTT: 2 * time.Second}
+	fine := client.TunnelHealth{RTT: 100 * time.Millisecond}
+	silent := client.TunnelHealth{NoAnswerFor: 25 * time.Second}
+
+	type step struct {
+		// at is seconds since route start
+		at     int
+		health client.TunnelHealth
+		reason string
+		outage bool
+	}
+
+	tests := []struct {
+		name   string
+		policy func(p *TunnelHealthPolicy)
+		steps  []step
+		kinds  string
+	}{
+		{"healthy route", nil, []step{
+			{5, fine, "", false},
+			{200, fine, "", false},
+		}, "route"},
+		{"breach in cooldown is not rerouted", nil, []step{
+			{5, slow, "", false},
+			{60, slow, "", false},
+			{115, slow, "", false},
+			{120, slow, "round trip 2s is more than 1000 ms", false},
+		}, "route breach reroute"},
+		{"breach is rerouted after breach time", nil, []step{
+			{200, slow, "", false},
+			{225, slow, "", false},
+			{230, slow, "round trip 2s is more than 1000 ms", false},
+			// reroute is requested once per route
+			{235, slow, "", false},
+		}, "route breach reroute"},
+		{"recovery restarts breach time", nil, []step{
+			{200, slow, "", false},
+			{220, fine, "", false},
+			{225, slow, "", false},
+			{250, slow, "", false},
+			{255, slow, "round trip 2s is more than 1000 ms", false},
+		}, "route breach recovered breach reroute"},
+		{"outage ignores cooldown", nil, []step{
+			{5, fine, "", false},
+			{30, silent, "route does not answer for 25s", true},
+		}, "route breach reroute"},
+		{"short silence is not outage", nil, []step{
+			{10, fine, "", false},
+			{15, client.TunnelHealth{NoAnswerFor: 10 * time.Second}, "", false},
+			{25, silent, "route does not answer for 25s", true},
+		}, "route breach reroute"},
+		{"pending reroute is dropped on recovery", nil, []step{
+			{30, silent, "route does not answer for 25s", true},
+			{35, silent, "", false},
+			{40, fine, "", false},
+			{50, silent, "route does not answer for 25s", true},
+		}, "route breach reroute recovered breach reroute"},
+		{"without auto reroute breaches are logged", func(p *TunnelHealthPolicy) {
+			p.AutoReroute = false
+		}, []step{
+			{200, slow, "", false},
+			{300, silent, "", false},
+			{305, fine, "", false},
+		}, "route breach recovered"},
+		{"zero outage is not checked", func(p *TunnelHealthPolicy) {
+			p.OutageSeconds = 0
+		}, []step{
+			{30, silent, "", false},
+		}, "route"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := policy
+			if tt.policy != nil {
+				tt.policy(&p)
+			}
+
+			h := loadTunnelHealth(filepath.Join(t.TempDir(), "tunnel-events.jsonl"))
+			route := client.TunnelRoute{Since: start, Sections: []client.TunnelSection{{Key: make([]byte, 32), Outer: true}}}
+			for _, st := range tt.steps {
+				route.Health = st.health
+				route.Health.MeasuredAt = start.Add(time.Duration(st.at) * time.Second)
+
+				reason, outage := h.observe(route, p, false)
+				if reason != st.reason || outage != st.outage {
+					t.Fatalf("at %ds: reason %q outage %v, expected %q %v", st.at, reason, outage, st.reason, st.outage)
+				}
+			}
+
+			var kinds []string
+			for _, e := range h.getEvents() {
+				kinds = append([]string{e.Kind}, kinds...)
+			}
+			if got := strings.Join(kinds, " "); got != tt.kinds {
+				t.Fatalf("events %q, expected %q", got, tt.kinds)
+			}
+		})
+	}
+}
+
+func TestHealthNewRoute(t *testing.T) {
+	p := TunnelHealthPolicy{AutoReroute: true, OutageSeconds: 20}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := loadTunnelHealth(filepath.Join(t.TempDir(), "tunnel-events.jsonl"))
+
+	silent := client.TunnelHealth{NoAnswerFor: 30 * time.Second, MeasuredAt: start.Add(30 * time.Second)}
+	if reason, _ := h.observe(client.TunnelRoute{Since: start, Health: silent}, p, false); reason == "" {
+		t.Fatal("outage is not rerouted")
+	}
+	if _, ok := h.requested(); !ok {
+		t.Fatal("reroute is not requested for library")
+	}
+
+	// new route starts clean, without pending reroute and breach
+	next := start.Add(40 * time.Second)
+	h.observe(client.TunnelRoute{Since: next, Health: client.TunnelHealth{MeasuredAt: next.Add(5 * time.Second)}}, p, false)
+	if _, ok := h.requested(); ok {
+		t.Fatal("reroute is still requested for new route")
+	}
+	if _, breach := h.current(); breach != "" {
+		t.Fatal("breach of old route is kept:", breach)
+	}
+
+	// events are kept in file
+	if n := len(loadTunnelHealth(h.path).getEvents()); n != 4 {
+		t.Fatalf("%d events are loaded, expected 4", n)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/base64"
+	"time"
+
+	"github.com/ton-blockchain/adnl-tunnel/config"
+	"github.com/tonutils/torrent-client/core/api"
+	"github.com/tonutils/torrent-client/core/client"
+	"github.com/tonutils/torrent-client/core/routeprice"
+	paymentsConfig "github.com/xssnick/ton-payment-network/tonpayments/config"
+	"github.com/xssnick/tonutils-go/tlb"
+)
+
+type InspectorSection struct {
+	// Key is full base64 key of node
+	Key string
+	// Role is "out", "outer" or "in", outer node is paid for both directions
+	Role string
+	// Price is per MB through node, including its share of virtual channel fees
+	Price    string
+	Pinned   bool
+	Excluded bool
+}
+
+type InspectorRoute struct {
+	Sections []InspectorSection
+	ExtAddr  string
+	// Current is a route which is used now, others are replaced by reroute
+	Current bool
+	Since   string
+	Until   string
+	Uptime  string
+	// BytesIn and BytesOut are relayed through route
+	BytesIn  string
+	BytesOut string
+	// LastChecked is when the whole route answered to ping last time
+	LastChecked string
+	// Health is last measured, round trip is of the whole route
+	Health TunnelHealthInfo
+}
+
+type PoolNode struct {
+	Key string
+	// Price is per MB when node is in the middle of route and when it is outer, empty for free node
+	Price    string
+	Pinned   bool
+	Excluded bool
+}
+
+type TunnelInspector struct {
+	// Routes are newest first
+	Routes []InspectorRoute
+	Pool   []PoolNode
+	Nodes  TunnelNodes
+	Err    string
+}
+
+func inspectorRoute(r client.TunnelRoute, current bool, coins *paymentsConfig.CoinTypes, model routeprice.Model, nodes TunnelNodes) InspectorRoute {
+	const layout = "02 Jan 2006 15:04:05"
+
+	res := InspectorRoute{
+		Sections: []InspectorSection{},
+		ExtAddr:  r.ExtAddr,
+		Current:  current,
+		Since:    r.Since.Format(layout),
+		BytesIn:  api.FormatSize(int64(r.BytesIn)),
+		BytesOut: api.FormatSize(int64(r.BytesOut)),
+		Health:   healthInfo(r.Health),
+	}
+
+	until := time.Now()
+	if !r.Until.IsZero() {
+		until = r.Until
+		res.Until = r.Until.Format(layout)
+	}
+	res.Uptime = until.Sub(r.Since).Round(time.Second).String()
+	if !r.LastCheckedAt.IsZero() && r.LastCheckedAt.Unix() > 0 {
+		res.LastChecked = r.LastCheckedAt.Format(layout)
+	}
+
+	for _, s := range r.Sections {
+		sec := InspectorSection{
+			Key:   base64.StdEncoding.EncodeToString(s.Key),
+			Role:  "out",
+			Price: "free",
+		}
+		switch {
+		case s.Outer:
+			sec.Role = "outer"
+		case s.Inbound:
+			sec.Role = "in"
+		}
+		sec.Pinned, sec.Excluded = nodes.state(sec.Key)
+
+		if p := s.Payment; p != nil {
+			symbol, decimals := coinOf(coins, p.JettonMaster, p.ExtraCurrencyID)
+			amt := model.NodePerMB(routeprice.Node{PricePerPacket: p.PricePerPacket, ProxyFees: p.ProxyFees})
+			sec.Price = tlb.MustFromNano(amt, decimals).String() + " " + symbol
+		}
+		res.Sections = append(res.Sections, sec)
+	}
+	return res
+}
+
+func poolNode(n config.TunnelRouteSection, coins *paymentsConfig.CoinTypes, model routeprice.Model, nodes TunnelNodes) PoolNode {
+	res := PoolNode{Key: base64.StdEncoding.EncodeToString(n.Key)}
+	res.Pinned, res.Excluded = nodes.state(res.Key)
+
+	if p := n.Payment; p != nil {
+		jetton := ""
+		if p.JettonMaster != nil {
+			jetton = *p.JettonMaster
+		}
+		symbol, decimals := coinOf(coins, jetton, p.ExtraCurrencyID)
+		perMB := func(price uint64) string {
+			return tlb.MustFromNano(model.NodePerMB(routeprice.Node{PricePerPacket: price}), decimals).String()
+		}
+		res.Price = perMB(p.PricePerPacketRouteNano) + " / " + perMB(p.PricePerPacketOutNano) + " " + symbol
+	}
+	return res
+}
@@ -7,13 +7,13 @@ package oshook
 //#include "app_darwin.h"
 import "C"
 import (
-	"net/url"
 	"os"
 	"unsafe"
 )
 
 var cbFile func([]byte)
 var cbHash func(string)
+var cbMetaURL func(string)
 
 //export OnLoadFile
 func OnLoadFile(data *C.char, length C.uint) {
@@ -28,10 +28,16 @@ func OnLoadFile(data *C.char, length C.uint) {
 //export OnLoadURL
 func OnLoadURL(u *C.char) {
 	if cbHash != nil {
-		ul, err := url.Parse(C.GoString(u))
-		if err == nil {
-			// to not block main thread
-			go cbHash(ul.Host)
+		hash, metaURL, err := parseLink(C.GoString(u))
+		if err != nil {
+			return
+		}
+
+		// to not block main thread
+		if metaURL != "" {
+			go cbMetaURL(metaURL)
+		} else {
+			go cbHash(hash)
 		}
 	}
 }
@@ -49,8 +55,9 @@ func OnLoadFileFromPath(path *C.char) {
 	}
 }
 
-func HookStartup(callbackFile func([]byte), callbackHash func(string)) {
+func HookStartup(callbackFile func([]byte), callbackHash func(string), callbackMetaURL func(string)) {
 	cbFile = callbackFile
 	cbHash = callbackHash
+	cbMetaURL = callbackMetaURL
 	C.HookDelegate()
 }
@@ -6,7 +6,6 @@ import (
 	"bytes"
 	"io"
 	"net/http"
-	"net/url"
 	"os"
 	"strings"
 	"sync"
@@ -17,7 +16,7 @@ import (
 
 var once sync.Once
 
-func initCrossApp(cbFile func([]byte), cbHash func(string)) {
+func initCrossApp(cbFile func([]byte), cbHash func(string), cbMetaURL func(string)) {
 	mx := http.NewServeMux()
 	mx.HandleFunc("/open", func(w http.ResponseWriter, r *http.Request) {
 		_ = goforeground.Activate(os.Getpid()) // bring to front
@@ -36,26 +35,43 @@ func initCrossApp(cbFile func([]byte), cbHash func(string)) {
 			cbHash(string(hash))
 		}
 	})
+	mx.HandleFunc("/open/meta-url", func(w http.ResponseWriter, r *http.Request) {
+		_ = goforeground.Activate(os.Getpid()) // bring to front
+		link, err := io.ReadAll(r.Body)
+		if err == nil {
+			// meta is downloaded in background, to not keep caller waiting
+			go cbMetaURL(string(link))
+		}
+	})
 	_ = http.ListenAndServe("127.0.0.1:33038", mx)
 }
 
-func HookStartup(cbFile func([]byte), cbHash func(string)) {
+func HookStartup(cbFile func([]byte), cbHash func(string), cbMetaURL func(string)) {
 	once.Do(func() {
 		client := http.Client{
 			Timeout: 500 * time.Millisecond,
 		}
 		if len(os.Args) > 1 {
 			if strings.HasPrefix(os.Args[1], "tonbag://") || strings.HasPrefix(os.Args[1], "tonstorage://") {
-				u, err := url.Parse(os.Args[1])
-				if err == nil {
+				hash, metaURL, err := parseLink(os.Args[1])
+				if err == nil && metaURL != "" {
+					_, err = client.Post("http://127.0.0.1:33038/open/meta-url",
+						"application/octet-stream", bytes.NewBuffer([]byte(metaURL)))
+					if err == nil {
+						os.Exit(0)
+						return
+					}
+
+					cbMetaURL(metaURL)
+				} else if err == nil {
 					_, err = client.Post("http://127.0.0.1:33038/open/hash",
-						"application/octet-stream", bytes.NewBuffer([]byte(u.Host)))
+						"application/octet-stream", bytes.NewBuffer([]byte(hash)))
 					if err == nil {
 						os.Exit(0)
 						return
 					}
 
-					cbHash(u.Host)
+					cbHash(hash)
 				}
 			} else {
 				data, err := os.ReadFile(os.Args[1])
@@ -79,6 +95,6 @@ func HookStartup(cbFile func([]byte), cbHash func(string)) {
 		}
 
 		// when no running instances, run cross server
-		go initCrossApp(cbFile, cbHash)
+		go initCrossApp(cbFile, cbHash, cbMetaURL)
 	})
 }
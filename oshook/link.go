@@ -0,0 +1,24 @@
+package oshook
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// parseLink reads tonstorage:// and tonbag:// links, they have bag id as host,
+// or meta url as parameter: tonstorage://meta?url=https://...
+func parseLink(link string) (hash, metaURL string, err error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", "", err
+	}
+
+	if u.Host == "meta" {
+		metaURL = u.Query().Get("url")
+		if metaURL == "" {
+			return "", "", fmt.Errorf("meta link has no url")
+		}
+		return "", metaURL, nil
+	}
+	return u.Host, "", nil
+}
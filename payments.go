@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	paymentsConfig "github.com/xssnick/ton-payment-network/tonpayments/config"
+	"github.com/xssnick/ton-payment-network/tonpayments/db"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+)
+
+// history of channels is shown for this period, newest events first
+const (
+	paymentHistoryPeriod = 7 * 24 * time.Hour
+	paymentHistoryMax    = 100
+)
+
+// PaymentChannel is onchain channel of payments node with a tunnel node, amounts are in units of its currency
+type PaymentChannel struct {
+	Address  string
+	Currency string
+	Status   string
+	// Balance is available to pay, Locked is reserved for virtual channels of running tunnel
+	Balance   string
+	Locked    string
+	Deposited string
+	Spent     string
+}
+
+type PaymentEvent struct {
+	At      string
+	Channel string
+	Action  string
+	Amount  string
+}
+
+type SpendingDay struct {
+	Day  string
+	Paid string
+}
+
+type PaymentsDashboard struct {
+	Channels []PaymentChannel
+	// ChannelsErr is set when channels can't be listed, they are available only when tunnel payments are enabled
+	ChannelsErr string
+	History     []PaymentEvent
+
+	// Session is paid since app start, Days are paid by day, newest first
+	Session string
+	Days    []SpendingDay
+
+	Cap SpendingCap
+	// CapHit is a reason why paid tunnel is stopped, empty when it is not
+	CapHit string
+	// Symbols of accepted currencies, to set limits for
+	Symbols []string
+}
+
+// coinOf resolves symbol and decimals of channel currency, not accepted anymore currency is shown in smallest units
+func coinOf(coins *paymentsConfig.CoinTypes, jetton string, ecID uint32) (string, int) {
+	if jetton != "" {
+		if addr, err := address.ParseAddr(jetton); err == nil {
+			jetton = addr.Bounce(true).String()
+		}
+	}
+
+	for _, c := range currencies(coins) {
+		if c.ExtraCurrencyID != ecID {
+			continue
+		}
+		if c.Jetton != "" {
+			if addr, err := address.ParseAddr(c.Jetton); err != nil || addr.Bounce(true).String() != jetton {
+				continue
+			}
+		} else if jetton != "" {
+			continue
+		}
+		return c.Symbol, int(c.Decimals)
+	}
+
+	if jetton != "" {
+		return "units of jetton " + jetton, 0
+	}
+	return fmt.Sprintf("units of extra currency %d", ecID), 0
+}
+
+func channelStatus(s db.ChannelStatus) string {
+	switch s {
+	case db.ChannelStateInactive:
+		return "Inactive"
+	case db.ChannelStateActive:
+		return "Active"
+	case db.ChannelStateClosing:
+		return "Closing"
+	}
+	return fmt.Sprintf("Unknown (%d)", s)
+}
+
+func paymentChannel(ch *db.Channel, coins *paymentsConfig.CoinTypes) PaymentChannel {
+	symbol, decimals := coinOf(coins, ch.JettonAddress, ch.ExtraCurrencyID)
+	format := func(amt *big.Int) string {
+		if amt == nil {
+			amt = big.NewInt(0)
+		}
+		return tlb.MustFromNano(amt, decimals).String() + " " + symbol
+	}
+
+	res := PaymentChannel{
+		Address:   ch.Address,
+		Currency:  symbol,
+		Status:    channelStatus(ch.Status),
+		Deposited: format(ch.OurOnchain.Deposited),
+		Spent:     format(ch.Our.State.Data.Sent.Nano()),
+	}
+
+	balance, locked, err := ch.CalcBalance(false)
+	if err != nil {
+		res.Balance = "unknown: " + err.Error()
+		return res
+	}
+	res.Balance, res.Locked = format(balance), format(locked)
+	return res
+}
+
+func historyAction(a db.ChannelHistoryEventType) string {
+	switch a {
+	case db.ChannelHistoryActionTopup:
+		return "Deposit"
+	case db.ChannelHistoryActionTopupCapacity:
+		return "Capacity deposit"
+	case db.ChannelHistoryActionWithdraw:
+		return "Withdraw"
+	case db.ChannelHistoryActionWithdrawCapacity:
+		return "Capacity withdraw"
+	case db.ChannelHistoryActionTransferIn:
+		return "Received"
+	case db.ChannelHistoryActionTransferOut:
+		return "Paid"
+	case db.ChannelHistoryActionUncooperativeCloseStarted:
+		return "Force close started"
+	case db.ChannelHistoryActionClosed:
+		return "Closed"
+	}
+	return fmt.Sprintf("Unknown (%d)", a)
+}
+
+// paymentEvents converts history of channel, amounts in history are in smallest units
+func paymentEvents(ch PaymentChannel, decimals int, items []db.ChannelHistoryItem) []paymentEvent {
+	var res []paymentEvent
+	for _, item := range items {
+		var amt string
+		switch d := item.ParseData().(type) {
+		case *db.ChannelHistoryActionAmountData:
+			amt = d.Amount
+		case *db.ChannelHistoryActionTransferInData:
+			amt = d.Amount
+		case *db.ChannelHistoryActionTransferOutData:
+			amt = d.Amount
+		}
+
+		if v, ok := new(big.Int).SetString(amt, 10); ok {
+			amt = tlb.MustFromNano(v, decimals).String() + " " + ch.Currency
+		}
+
+		res = append(res, paymentEvent{
+			at: item.At,
+			PaymentEvent: PaymentEvent{
+				At:      item.At.Format("02 Jan 2006 15:04:05"),
+				Channel: ch.Address,
+				Action:  historyAction(item.Action),
+				Amount:  amt,
+			},
+		})
+	}
+	return res
+}
+
+// paymentEvent keeps time to sort events of all channels
+type paymentEvent struct {
+	PaymentEvent
+	at time.Time
+}
+
+func sortPaymentEvents(events []paymentEvent) []PaymentEvent {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].at.After(events[j].at)
+	})
+	if len(events) > paymentHistoryMax {
+		events = events[:paymentHistoryMax]
+	}
+
+	res := make([]PaymentEvent, 0, len(events))
+	for _, e := range events {
+		res = append(res, e.PaymentEvent)
+	}
+	return res
+}
+
+// openPaymentChannels counts channels which are not closed yet, they can be listed only when tunnel payments are enabled
+func (a *App) openPaymentChannels() (int, error) {
+	if !a.loaded {
+		return 0, fmt.Errorf("storage is not loaded")
+	}
+
+	list, err := a.api.GetPaymentChannels()
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, ch := range list {
+		if ch.Status != db.ChannelStateInactive {
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+
+	"github.com/ton-blockchain/adnl-tunnel/tunnel"
+	"github.com/xssnick/tonutils-go/tlb"
+)
+
+// TunnelPolicy accepts or rejects tunnel routes without asking user, machines without user can't answer dialogs
+type TunnelPolicy struct {
+	// Enabled makes routes to be decided by policy, otherwise every route is shown to user
+	Enabled bool
+
+	// MaxPriceInPerMB and MaxPriceOutPerMB are in TON, empty means no limit
+	MaxPriceInPerMB  string
+	MaxPriceOutPerMB string
+	FreeOnly         bool
+
+	// AllowedNodes and DeniedNodes are base64 keys of nodes, when allowed are set, route can have only them
+	AllowedNodes []string
+	DeniedNodes  []string
+
+	// SectionsNum is minimal number of sections in route, 0 means any
+	SectionsNum uint
+
+	// AskOnMismatch shows route to user when it does not match policy, instead of rejecting it
+	AskOnMismatch bool
+}
+
+// TunnelNodes are nodes of pool chosen by user, excluded are removed from pool,
+// so they are not used even when policy is disabled. Pinned are put into the next route only,
+// they are cleared when it is built or when it can't be built with them.
+type TunnelNodes struct {
+	// Pinned and Excluded are base64 keys of nodes
+	Pinned   []string
+	Excluded []string
+}
+
+const (
+	policyAccept = iota
+	policyReject
+	policyAsk
+)
+
+type tunnelRoute struct {
+	nodes    []string
+	sections int
+	prices   []*coinPrice
+}
+
+func newTunnelRoute(to, from []*tunnel.SectionInfo, prices []*coinPrice) *tunnelRoute {
+	r := &tunnelRoute{sections: len(to), prices: prices}
+	for _, n := range append(to, from...) {
+		r.nodes = append(r.nodes, base64.StdEncoding.EncodeToString(n.Keys.ReceiverPubKey))
+	}
+	return r
+}
+
+// Validate checks prices, they are kept as text to be edited by user
+func (p *TunnelPolicy) Validate() error {
+	if _, err := parsePolicyPrice(p.MaxPriceInPerMB); err != nil {
+		return fmt.Errorf("invalid max price in: %w", err)
+	}
+	if _, err := parsePolicyPrice(p.MaxPriceOutPerMB); err != nil {
+		return fmt.Errorf("invalid max price out: %w", err)
+	}
+	for _, k := range append(append([]string{}, p.AllowedNodes...), p.DeniedNodes...) {
+		if key, err := base64.StdEncoding.DecodeString(k); err != nil || len(key) != 32 {
+			return fmt.Errorf("invalid node key %q, it should be base64 of 32 bytes", k)
+		}
+	}
+	return nil
+}
+
+// decide returns decision on route and reason when it does not match
+func (p *TunnelPolicy) decide(r *tunnelRoute) (int, string) {
+	if !p.Enabled {
+		return policyAsk, ""
+	}
+
+	reason := p.mismatch(r)
+	if reason == "" {
+		return policyAccept, ""
+	}
+	if p.AskOnMismatch {
+		return policyAsk, reason
+	}
+	return policyReject, reason
+}
+
+func (p *TunnelPolicy) mismatch(r *tunnelRoute) string {
+	allowed := map[string]bool{}
+	for _, k := range p.AllowedNodes {
+		allowed[k] = true
+	}
+	denied := map[string]bool{}
+	for _, k := range p.DeniedNodes {
+		denied[k] = true
+	}
+
+	for _, n := range r.nodes {
+		if denied[n] {
+			return "node " + n + " is denied"
+		}
+		if len(allowed) > 0 && !allowed[n] {
+			return "node " + n + " is not in allowed list"
+		}
+	}
+
+	if uint(r.sections) < p.SectionsNum {
+		return fmt.Sprintf("route has %d sections, %d required", r.sections, p.SectionsNum)
+	}
+
+	maxIn, _ := parsePolicyPrice(p.MaxPriceInPerMB)
+	maxOut, _ := parsePolicyPrice(p.MaxPriceOutPerMB)
+	for _, c := range r.prices {
+		if !c.paid() {
+			continue
+		}
+		if p.FreeOnly {
+			return "route is not free"
+		}
+
+		if !c.ton {
+			if maxIn != nil || maxOut != nil {
+				// limits can't be compared with other currencies
+				return "route is paid in " + c.symbol + ", but price limits are set in TON"
+			}
+			continue
+		}
+
+		if maxIn != nil && c.in.Cmp(maxIn) > 0 {
+			return "price in " + tlb.FromNanoTON(c.in).String() + " TON is more than " + p.MaxPriceInPerMB
+		}
+		if maxOut != nil && c.out.Cmp(maxOut) > 0 {
+			return "price out " + tlb.FromNanoTON(c.out).String() + " TON is more than " + p.MaxPriceOutPerMB
+		}
+	}
+	return ""
+}
+
+// Validate checks keys, route has sections number of nodes on the way out, more can't be pinned
+func (n *TunnelNodes) Validate(sections uint) error {
+	if sections == 0 {
+		sections = 1
+	}
+	if uint(len(n.Pinned)) > sections {
+		return fmt.Errorf("route has %d sections, so at most %d nodes can be pinned", sections, sections)
+	}
+
+	excluded := map[string]bool{}
+	for _, k := range n.Excluded {
+		if key, err := base64.StdEncoding.DecodeString(k); err != nil || len(key) != 32 {
+			return fmt.Errorf("invalid node key %q, it should be base64 of 32 bytes", k)
+		}
+		excluded[k] = true
+	}
+	pinned := map[string]bool{}
+	for _, k := range n.Pinned {
+		if key, err := base64.StdEncoding.DecodeString(k); err != nil || len(key) != 32 {
+			return fmt.Errorf("invalid node key %q, it should be base64 of 32 bytes", k)
+		}
+		if excluded[k] {
+			return fmt.Errorf("node %s can't be pinned and excluded at the same time", k)
+		}
+		if pinned[k] {
+			return fmt.Errorf("node %s is pinned twice", k)
+		}
+		pinned[k] = true
+	}
+	return nil
+}
+
+// pinnedKeys returns keys which must be in the next route
+func (n *TunnelNodes) pinnedKeys() [][]byte {
+	var res [][]byte
+	for _, k := range n.Pinned {
+		if key, err := base64.StdEncoding.DecodeString(k); err == nil {
+			res = append(res, key)
+		}
+	}
+	return res
+}
+
+// excludedKeys returns keys to remove from pool
+func (n *TunnelNodes) excludedKeys() [][]byte {
+	var res [][]byte
+	for _, k := range n.Excluded {
+		if key, err := base64.StdEncoding.DecodeString(k); err == nil {
+			res = append(res, key)
+		}
+	}
+	return res
+}
+
+// state returns whether node is pinned and excluded
+func (n *TunnelNodes) state(key string) (pinned, excluded bool) {
+	for _, k := range n.Pinned {
+		pinned = pinned || k == key
+	}
+	for _, k := range n.Excluded {
+		excluded = excluded || k == key
+	}
+	return
+}
+
+// missingPinned returns reason when route has no some of pinned nodes
+func (n *TunnelNodes) missingPinned(r *tunnelRoute) string {
+	has := map[string]bool{}
+	for _, k := range r.nodes {
+		has[k] = true
+	}
+	for _, k := range n.Pinned {
+		if !has[k] {
+			return "pinned node " + k + " is not in route"
+		}
+	}
+	return ""
+}
+
+// parsePolicyPrice returns price in nano TON, nil when there is no limit
+func parsePolicyPrice(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	c, err := tlb.FromTON(s)
+	if err != nil {
+		return nil, err
+	}
+	return c.Nano(), nil
+}
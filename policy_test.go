@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func testNodeKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+// tonPrice is a price per MB in nano TON
+func tonPrice(in, out int64) *coinPrice {
+	return &coinPrice{symbol: "TON", decimals: 9, ton: true, in: big.NewInt(in), out: big.NewInt(out)}
+}
+
+func TestPolicyDecide(t *testing.T) {
+	a, b, c := testNodeKey(1), testNodeKey(2), testNodeKey(3)
+	route := func(prices ...*coinPrice) *tunnelRoute {
+		return &tunnelRoute{nodes: []string{a, b}, sections: 2, prices: prices}
+	}
+	usdt := &coinPrice{symbol: "USDT", decimals: 6, in: big.NewInt(1), out: big.NewInt(1)}
+
+	tests := []struct {
+		name     string
+		policy   TunnelPolicy
+		route    *tunnelRoute
+		decision int
+		reason   string
+	}{
+		{"disabled asks user", TunnelPolicy{FreeOnly: true}, route(tonPrice(1, 1)), policyAsk, ""},
+		{"empty policy accepts", TunnelPolicy{Enabled: true}, route(tonPrice(1, 1)), policyAccept, ""},
+		{"allowed nodes", TunnelPolicy{Enabled: true, AllowedNodes: []string{a, b, c}}, route(), policyAccept, ""},
+		{"node is not allowed", TunnelPolicy{Enabled: true, AllowedNodes: []string{a}}, route(), policyReject, "node " + b + " is not in allowed list"},
+		{"node is denied", TunnelPolicy{Enabled: true, DeniedNodes: []string{c, b}}, route(), policyReject, "node " + b + " is denied"},
+		{"denied wins over allowed", TunnelPolicy{Enabled: true, AllowedNodes: []string{a, b}, DeniedNodes: []string{a}}, route(), policyReject, "is denied"},
+		{"enough sections", TunnelPolicy{Enabled: true, SectionsNum: 2}, route(), policyAccept, ""},
+		{"too few sections", TunnelPolicy{Enabled: true, SectionsNum: 3}, route(), policyReject, "route has 2 sections, 3 required"},
+		{"free only accepts free route", TunnelPolicy{Enabled: true, FreeOnly: true}, route(tonPrice(0, 0)), policyAccept, ""},
+		{"free only rejects paid route", TunnelPolicy{Enabled: true, FreeOnly: true}, route(tonPrice(0, 1)), policyReject, "route is not free"},
+		{"free only rejects paid in jetton", TunnelPolicy{Enabled: true, FreeOnly: true}, route(usdt), policyReject, "route is not free"},
+		{"price at limit", TunnelPolicy{Enabled: true, MaxPriceInPerMB: "0.001", MaxPriceOutPerMB: "0.002"}, route(tonPrice(1000000, 2000000)), policyAccept, ""},
+		{"price in over limit", TunnelPolicy{Enabled: true, MaxPriceInPerMB: "0.001"}, route(tonPrice(1000001, 0)), policyReject, "price in 0.001000001 TON is more than 0.001"},
+		{"price out over limit", TunnelPolicy{Enabled: true, MaxPriceOutPerMB: "0.002"}, route(tonPrice(0, 2000001)), policyReject, "price out 0.002000001 TON is more than 0.002"},
+		{"limits are in TON only", TunnelPolicy{Enabled: true, MaxPriceOutPerMB: "100"}, route(tonPrice(1, 1), usdt), policyReject, "paid in USDT, but price limits are set in TON"},
+		{"jetton without limits", TunnelPolicy{Enabled: true}, route(usdt), policyAccept, ""},
+		{"mismatch is asked", TunnelPolicy{Enabled: true, FreeOnly: true, AskOnMismatch: true}, route(tonPrice(1, 0)), policyAsk, "route is not free"},
+		{"match is not asked", TunnelPolicy{Enabled: true, FreeOnly: true, AskOnMismatch: true}, route(), policyAccept, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, reason := tt.policy.decide(tt.route)
+			if decision != tt.decision {
+				t.Fatalf("decision %d, expected %d, reason: %s", decision, tt.decision, reason)
+			}
+			if tt.reason == "" && reason != "" || !strings.Contains(reason, tt.reason) {
+				t.Fatalf("reason %q, expected %q", reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy TunnelPolicy
+		errHas string
+	}{
+		{"empty", TunnelPolicy{}, ""},
+		{"limits and nodes", TunnelPolicy{MaxPriceInPerMB: "0.5", MaxPriceOutPerMB: "1", AllowedNodes: []string{testNodeKey(1)}, DeniedNodes: []string{testNodeKey(2)}}, ""},
+		{"bad price in", TunnelPolicy{MaxPriceInPerMB: "abc"}, "invalid max price in"},
+		{"bad price out", TunnelPolicy{MaxPriceOutPerMB: "1.5.0"}, "invalid max price out"},
+		{"bad allowed key", TunnelPolicy{AllowedNodes: []string{"not a key"}}, "invalid node key"},
+		{"short denied key", TunnelPolicy{DeniedNodes: []string{base64.StdEncoding.EncodeToString([]byte{1, 2, 3})}}, "invalid node key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if tt.errHas == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errHas) {
+				t.Fatalf("error %v, expected %q", err, tt.errHas)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tonutils/torrent-client/core/nodespool"
+)
+
+// defaultPoolRefreshMinutes is used when subscription has no refresh interval
+const defaultPoolRefreshMinutes = 60
+
+// minPoolRefreshMinutes is to not load publisher of pool too often
+const minPoolRefreshMinutes = 5
+
+// TunnelPoolSubscription fetches nodes pool by URL instead of local file, empty URL disables it
+type TunnelPoolSubscription struct {
+	URL string
+	// PublisherKey is ed25519 key of pool publisher in base64 or hex, pool is not verified when empty
+	PublisherKey   string
+	RefreshMinutes uint
+}
+
+func (s *TunnelPoolSubscription) Validate() error {
+	if s.URL == "" {
+		return nil
+	}
+	if !nodespool.IsURL(s.URL) {
+		return fmt.Errorf("pool url should start with http:// or https://")
+	}
+	if _, err := nodespool.ParseKey(s.PublisherKey); err != nil {
+		return err
+	}
+	if s.RefreshMinutes != 0 && s.RefreshMinutes < minPoolRefreshMinutes {
+		return fmt.Errorf("pool should be refreshed not more often than every %d minutes", minPoolRefreshMinutes)
+	}
+	return nil
+}
+
+func (s *TunnelPoolSubscription) refreshEvery() time.Duration {
+	if s.RefreshMinutes == 0 {
+		return defaultPoolRefreshMinutes * time.Minute
+	}
+	return time.Duration(s.RefreshMinutes) * time.Minute
+}
+
+// poolCache is the last known good pool of subscription, tunnel starts with it when publisher is offline
+type poolCache struct {
+	URL          string
+	PublisherKey string
+	FetchedAt    time.Time
+	// Data is pool exactly as published, signature was checked over it
+	Data []byte
+}
+
+func (c *poolCache) matches(s TunnelPoolSubscription) bool {
+	return c != nil && c.URL == s.URL && c.PublisherKey == s.PublisherKey
+}
+
+type PoolSubscriptionResult struct {
+	Subscription TunnelPoolSubscription
+	FetchedAt    string
+	CheckedAt    string
+	Nodes        int
+	// Pending describes fetched pool which is applied on the next reroute, empty when there is none
+	Pending string
+	Err     string
+}
+
+// tunnelPool keeps pool of subscription, tunnel library reads pool only at start,
+// so fetched changes are pending until the next reroute restarts tunnel with them
+type tunnelPool struct {
+	cachePath string
+	// poolPath is file given to tunnel library
+	poolPath string
+
+	cache *poolCache
+	// applied is pool used by running tunnel, nil when it was started without subscription
+	applied []byte
+	// using is set when running tunnel uses subscription
+	using bool
+
+	checkedAt time.Time
+	err       string
+	refresh   chan struct{}
+
+	mx sync.Mutex
+}
+
+func loadTunnelPool(cachePath, poolPath string) *tunnelPool {
+	p := &tunnelPool{cachePath: cachePath, poolPath: poolPath, refresh: make(chan struct{}, 1)}
+
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println("failed to load tunnel pool cache:", err.Error())
+		}
+		return p
+	}
+
+	var c poolCache
+	if err = json.Unmarshal(data, &c); err != nil {
+		log.Println("failed to parse tunnel pool cache:", err.Error())
+		return p
+	}
+	p.cache = &c
+	return p
+}
+
+// fetch downloads pool of subscription and caches it when it is valid
+func (p *tunnelPool) fetch(ctx context.Context, s TunnelPoolSubscription) error {
+	key, err := nodespool.ParseKey(s.PublisherKey)
+	if err == nil {
+		var data []byte
+		if data, err = nodespool.Fetch(ctx, s.URL, key, p.cachedSeqno(s)); err == nil {
+			err = p.store(&poolCache{URL: s.URL, PublisherKey: s.PublisherKey, FetchedAt: time.Now(), Data: data})
+		}
+	}
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.checkedAt = time.Now()
+	p.err = ""
+	if err != nil {
+		p.err = err.Error()
+	}
+	return err
+}
+
+func (p *tunnelPool) store(c *poolCache) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err = nodespool.Save(p.cachePath, data); err != nil {
+		return fmt.Errorf("failed to save pool cache: %w", err)
+	}
+
+	p.mx.Lock()
+	p.cache = c
+	p.mx.Unlock()
+	return nil
+}
+
+// cachedSeqno is version of cached pool of subscription, fetched one can't be older
+func (p *tunnelPool) cachedSeqno(s TunnelPoolSubscription) uint64 {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if !p.cache.matches(s) {
+		return 0
+	}
+	cfg, err := nodespool.Parse(p.cache.Data)
+	if err != nil {
+		return 0
+	}
+	return cfg.Seqno
+}
+
+// cached reports if there is pool of subscription to start with
+func (p *tunnelPool) cached(s TunnelPoolSubscription) bool {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	return p.cache.matches(s)
+}
+
+// apply writes cached pool of subscription for tunnel library and returns its path
+func (p *tunnelPool) apply(s TunnelPoolSubscription) (string, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if !p.cache.matches(s) {
+		return "", fmt.Errorf("pool was never fetched from %s", s.URL)
+	}
+	if cfg, err := nodespool.Parse(p.cache.Data); err == nil && cfg.Expired(time.Now()) {
+		return "", fmt.Errorf("cached pool of %s has expired", s.URL)
+	}
+	if err := nodespool.Save(p.poolPath, p.cache.Data); err != nil {
+		return "", fmt.Errorf("failed to write pool: %w", err)
+	}
+	p.applied, p.using = p.cache.Data, true
+	return p.poolPath, nil
+}
+
+// unused is called when tunnel starts with local pool or without tunnel
+func (p *tunnelPool) unused() {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.applied, p.using = nil, false
+}
+
+// path returns pool file of subscription when running tunnel uses it
+func (p *tunnelPool) path() string {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if !p.using {
+		return ""
+	}
+	return p.poolPath
+}
+
+// pending describes pool which differs from the one used by tunnel, empty when there is no change
+func (p *tunnelPool) pending(s TunnelPoolSubscription) string {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if s.URL == "" {
+		if p.using {
+			return "subscription is disabled, local pool is used"
+		}
+		return ""
+	}
+	if !p.cache.matches(s) || bytes.Equal(p.cache.Data, p.applied) {
+		return ""
+	}
+	if !p.using {
+		return "pool of subscription is not used yet"
+	}
+
+	var old *nodespool.Pool
+	if p.applied != nil {
+		old, _ = nodespool.Parse(p.applied)
+	}
+	cfg, err := nodespool.Parse(p.cache.Data)
+	if err != nil {
+		return ""
+	}
+	added, removed := nodespool.Diff(old, cfg)
+	return fmt.Sprintf("%d nodes added, %d removed", added, removed)
+}
+
+// refreshNow wakes refresher, when subscription is changed
+func (p *tunnelPool) refreshNow() {
+	select {
+	case p.refresh <- struct{}{}:
+	default:
+	}
+}
+
+func (p *tunnelPool) status(s TunnelPoolSubscription) PoolSubscriptionResult {
+	res := PoolSubscriptionResult{Subscription: s, Pending: p.pending(s)}
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if !p.checkedAt.IsZero() {
+		res.CheckedAt = p.checkedAt.Format("02 Jan 2006 15:04:05")
+	}
+	res.Err = p.err
+	if p.cache.matches(s) {
+		res.FetchedAt = p.cache.FetchedAt.Format("02 Jan 2006 15:04:05")
+		if cfg, err := nodespool.Parse(p.cache.Data); err == nil {
+			res.Nodes = len(cfg.NodesPool)
+		}
+	}
+	return res
+}
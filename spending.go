@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	paymentsConfig "github.com/xssnick/ton-payment-network/tonpayments/config"
+	"github.com/xssnick/tonutils-go/tlb"
+)
+
+// days of spending to keep in log
+const spendingDaysKept = 31
+
+// SpendingCap limits amounts paid for tunnel, keyed by currency symbol, in units of currency.
+// When one of limits is reached, paid tunnel is stopped and only free nodes are used.
+type SpendingCap struct {
+	PerSession map[string]string
+	PerDay     map[string]string
+}
+
+// Validate checks that limits are set for accepted currencies and are valid amounts
+func (c SpendingCap) Validate(coins *paymentsConfig.CoinTypes) error {
+	decimals := map[string]int{}
+	for _, cur := range currencies(coins) {
+		decimals[cur.Symbol] = int(cur.Decimals)
+	}
+
+	for _, limits := range []map[string]string{c.PerSession, c.PerDay} {
+		for sym, v := range limits {
+			if strings.TrimSpace(v) == "" {
+				continue
+			}
+
+			dec, ok := decimals[sym]
+			if !ok {
+				return fmt.Errorf("currency %s is not accepted", sym)
+			}
+			if _, err := tlb.FromDecimal(strings.TrimSpace(v), dec); err != nil {
+				return fmt.Errorf("invalid %s limit: %w", sym, err)
+			}
+		}
+	}
+	return nil
+}
+
+// spendingLog is persisted amounts paid for tunnels by day, to apply daily cap across restarts
+type spendingLog struct {
+	Days map[string]map[string]string
+}
+
+// spending counts amounts paid for tunnels since app start and by day
+type spending struct {
+	path string
+	log  spendingLog
+
+	// last is amount reported by current storage client, it counts from zero after every restart
+	last    map[string]tlb.Coins
+	session map[string]tlb.Coins
+
+	// capHit is a reason why paid tunnel was stopped
+	capHit string
+
+	mx sync.Mutex
+}
+
+func loadSpending(path string) *spending {
+	s := &spending{
+		path:    path,
+		session: map[string]tlb.Coins{},
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &s.log)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		log.Println("failed to load tunnel spending log:", err.Error())
+	}
+	if s.log.Days == nil {
+		s.log.Days = map[string]map[string]string{}
+	}
+	return s
+}
+
+func spendingDay(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// restarted should be called when new storage client is started
+func (s *spending) restarted() {
+	s.mx.Lock()
+	s.last = nil
+	s.mx.Unlock()
+}
+
+// add takes total paid amounts of storage client and counts what was paid since last call,
+// amounts paid since app start are returned
+func (s *spending) add(paid map[string]tlb.Coins) map[string]tlb.Coins {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	today := spendingDay(time.Now())
+	changed := false
+	for sym, amt := range paid {
+		delta := amt.Nano()
+		if last, ok := s.last[sym]; ok {
+			delta = new(big.Int).Sub(delta, last.Nano())
+		}
+		if delta.Sign() <= 0 {
+			continue
+		}
+		changed = true
+
+		s.session[sym] = addCoins(s.session[sym], delta, amt.Decimals())
+
+		day := s.log.Days[today]
+		if day == nil {
+			day = map[string]string{}
+			s.log.Days[today] = day
+		}
+		// not parsed value is counted as zero
+		cur, _ := tlb.FromDecimal(day[sym], amt.Decimals())
+		day[sym] = addCoins(cur, delta, amt.Decimals()).String()
+	}
+
+	s.last = map[string]tlb.Coins{}
+	for sym, amt := range paid {
+		s.last[sym] = amt
+	}
+
+	if changed {
+		s.save()
+	}
+
+	session := map[string]tlb.Coins{}
+	for sym, amt := range s.session {
+		session[sym] = amt
+	}
+	return session
+}
+
+func addCoins(to tlb.Coins, amt *big.Int, decimals int) tlb.Coins {
+	return tlb.MustFromNano(new(big.Int).Add(to.Nano(), amt), decimals)
+}
+
+// save must be called under lock
+func (s *spending) save() {
+	if len(s.log.Days) > spendingDaysKept {
+		days := make([]string, 0, len(s.log.Days))
+		for d := range s.log.Days {
+			days = append(days, d)
+		}
+		sort.Strings(days)
+		for _, d := range days[:len(days)-spendingDaysKept] {
+			delete(s.log.Days, d)
+		}
+	}
+
+	data, err := json.MarshalIndent(s.log, "", "\t")
+	if err == nil {
+		err = os.WriteFile(s.path, data, 0766)
+	}
+	if err != nil {
+		log.Println("failed to save tunnel spending log:", err.Error())
+	}
+}
+
+// totals returns amounts paid since app start and today
+func (s *spending) totals() (session, today map[string]string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	session = map[string]string{}
+	for sym, amt := range s.session {
+		session[sym] = amt.String()
+	}
+	today = map[string]string{}
+	for sym, amt := range s.log.Days[spendingDay(time.Now())] {
+		today[sym] = amt
+	}
+	return session, today
+}
+
+// days returns amounts paid by day, newest first
+func (s *spending) days() []SpendingDay {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	res := make([]SpendingDay, 0, len(s.log.Days))
+	for d, amounts := range s.log.Days {
+		res = append(res, SpendingDay{Day: d, Paid: formatAmounts(amounts)})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Day > res[j].Day
+	})
+	return res
+}
+
+// exceeded returns a reason when one of limits is reached, or empty string
+func (s *spending) exceeded(c SpendingCap) string {
+	session, today := s.totals()
+	if r := capReached(c.PerSession, session, "session"); r != "" {
+		return r
+	}
+	return capReached(c.PerDay, today, "daily")
+}
+
+func capReached(limits, spent map[string]string, kind string) string {
+	var reasons []string
+	for sym, v := range limits {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+
+		// decimals are the same for limit and amount, so compare as numbers
+		limit, ok := new(big.Float).SetString(strings.TrimSpace(v))
+		if !ok {
+			continue
+		}
+		amt, ok := new(big.Float).SetString(spent[sym])
+		if !ok {
+			// nothing paid yet
+			amt = new(big.Float)
+		}
+		if amt.Cmp(limit) >= 0 {
+			reasons = append(reasons, fmt.Sprintf("%s spending cap of %s %s is reached", kind, v, sym))
+		}
+	}
+	sort.Strings(reasons)
+	return strings.Join(reasons, ", ")
+}
+
+func (s *spending) setCapHit(reason string) (changed bool) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	changed = s.capHit != reason
+	s.capHit = reason
+	return changed
+}
+
+func (s *spending) getCapHit() string {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.capHit
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xssnick/tonutils-go/tlb"
+)
+
+func paidTON(v string) map[string]tlb.Coins {
+	return map[string]tlb.Coins{"TON": tlb.MustFromTON(v)}
+}
+
+func TestSpendingAdd(t *testing.T) {
+	s := loadSpending(filepath.Join(t.TempDir(), "spending.json"))
+
+	steps := []struct {
+		name    string
+		restart bool
+		paid    map[string]tlb.Coins
+		session string
+	}{
+		{"first payment", false, paidTON("0.1"), "0.1"},
+		{"client total grows", false, paidTON("0.25"), "0.25"},
+		{"same total is not counted twice", false, paidTON("0.25"), "0.25"},
+		// new storage client counts from zero
+		{"after client restart", true, paidTON("0.05"), "0.3"},
+		{"lower total is not negative", false, paidTON("0.01"), "0.3"},
+		{"grows after lower total", false, paidTON("0.02"), "0.31"},
+		{"other currency", false, map[string]tlb.Coins{"TON": tlb.MustFromTON("0.02"), "USDT": tlb.MustFromDecimal("1.5", 6)}, "0.31"},
+	}
+
+	for _, st := range steps {
+		if st.restart {
+			s.restarted()
+		}
+		session := s.add(st.paid)
+		if got := session["TON"].String(); got != st.session {
+			t.Fatalf("%s: session is %s TON, expected %s", st.name, got, st.session)
+		}
+	}
+
+	session, today := s.totals()
+	if session["USDT"] != "1.5" || today["USDT"] != "1.5" || today["TON"] != "0.31" {
+		t.Fatalf("session %v, today %v", session, today)
+	}
+}
+
+func TestSpendingCapAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spending.json")
+	c := SpendingCap{
+		PerSession: map[string]string{"TON": "0.5"},
+		PerDay:     map[string]string{"TON": "1"},
+	}
+
+	s := loadSpending(path)
+	s.add(paidTON("0.4"))
+	if r := s.exceeded(c); r != "" {
+		t.Fatal("cap is reached below limits:", r)
+	}
+
+	s.add(paidTON("0.6"))
+	if r := s.exceeded(c); r != "session spending cap of 0.5 TON is reached" {
+		t.Fatal("session cap is not reached:", r)
+	}
+
+	// app restart keeps paid today, but starts new session
+	s = loadSpending(path)
+	if r := s.exceeded(c); r != "" {
+		t.Fatal("cap is reached after restart:", r)
+	}
+	s.add(paidTON("0.3"))
+	if r := s.exceeded(c); r != "" {
+		t.Fatal("cap is reached at 0.9 TON of day:", r)
+	}
+	s.add(paidTON("0.4"))
+	if r := s.exceeded(c); r != "daily spending cap of 1 TON is reached" {
+		t.Fatal("daily cap is not reached at 1 TON of day:", r)
+	}
+
+	if _, today := loadSpending(path).totals(); today["TON"] != "1" {
+		t.Fatalf("%s TON is saved for today, expected 1", today["TON"])
+	}
+}
+
+func TestCapReached(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits map[string]string
+		spent  map[string]string
+		reason string
+	}{
+		{"no limits", nil, map[string]string{"TON": "100"}, ""},
+		{"below limit", map[string]string{"TON": "1"}, map[string]string{"TON": "0.999999999"}, ""},
+		{"at limit", map[string]string{"TON": "1"}, map[string]string{"TON": "1"}, "daily spending cap of 1 TON is reached"},
+		{"over limit", map[string]string{"TON": " 1 "}, map[string]string{"TON": "1.5"}, "daily spending cap of  1  TON is reached"},
+		{"nothing paid", map[string]string{"TON": "1"}, nil, ""},
+		{"zero limit", map[string]string{"TON": "0"}, nil, "daily spending cap of 0 TON is reached"},
+		{"empty limit", map[string]string{"TON": " "}, map[string]string{"TON": "5"}, ""},
+		{"invalid limit", map[string]string{"TON": "abc"}, map[string]string{"TON": "5"}, ""},
+		{"limit of other currency", map[string]string{"USDT": "10"}, map[string]string{"TON": "50"}, ""},
+		{"several currencies", map[string]string{"TON": "1", "USDT": "10", "NOT": "5"}, map[string]string{"TON": "2", "USDT": "10", "NOT": "1"},
+			"daily spending cap of 1 TON is reached, daily spending cap of 10 USDT is reached"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := capReached(tt.limits, tt.spent, "daily"); got != tt.reason {
+				t.Fatalf("reason %q, expected %q", got, tt.reason)
+			}
+		})
+	}
+}
+
+func TestSpendingDaysKept(t *testing.T) {
+	s := loadSpending(filepath.Join(t.TempDir(), "spending.json"))
+	start := time.Now().AddDate(0, 0, -40)
+	for i := 0; i < 40; i++ {
+		s.log.Days[spendingDay(start.AddDate(0, 0, i))] = map[string]string{"TON": fmt.Sprint(i)}
+	}
+	s.add(paidTON("1"))
+
+	days := s.days()
+	if len(days) != spendingDaysKept {
+		t.Fatalf("%d days are kept, expected %d", len(days), spendingDaysKept)
+	}
+	if days[0].Day != spendingDay(time.Now()) || days[len(days)-1].Day != spendingDay(start.AddDate(0, 0, 40-spendingDaysKept+1)) {
+		t.Fatalf("days from %s to %s are kept", days[len(days)-1].Day, days[0].Day)
+	}
+}
+
+func TestSpendingCapValidate(t *testing.T) {
+	coins := testCoins()
+
+	tests := []struct {
+		name   string
+		cap    SpendingCap
+		errHas string
+	}{
+		{"empty", SpendingCap{}, ""},
+		{"accepted currencies", SpendingCap{PerSession: map[string]string{"TON": "0.5", "USDT": "1.25"}, PerDay: map[string]string{"ECX": "3"}}, ""},
+		{"empty limit of unknown currency", SpendingCap{PerDay: map[string]string{"XXX": ""}}, ""},
+		{"unknown currency", SpendingCap{PerDay: map[string]string{"XXX": "1"}}, "currency XXX is not accepted"},
+		{"comma separator", SpendingCap{PerSession: map[string]string{"USDT": "1,5"}}, "invalid USDT limit"},
+		{"not a number", SpendingCap{PerDay: map[string]string{"TON": "one"}}, "invalid TON limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cap.Validate(coins)
+			if tt.errHas == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errHas) {
+				t.Fatalf("error %v, expected %q", err, tt.errHas)
+			}
+		})
+	}
+}